@@ -23,9 +23,11 @@ import (
 	tmcmn "github.com/tendermint/tendermint/libs/common"
 	dbm "github.com/tendermint/tendermint/libs/db"
 	tmLog "github.com/tendermint/tendermint/libs/log"
+	mempl "github.com/tendermint/tendermint/mempool"
 	"github.com/tendermint/tendermint/node"
 	"github.com/tendermint/tendermint/p2p"
 	"github.com/tendermint/tendermint/proxy"
+	sm "github.com/tendermint/tendermint/state"
 	"github.com/tendermint/tendermint/types"
 )
 
@@ -108,7 +110,12 @@ type Backend interface {
 	NodeSigner() (auth.Signer, error)
 	// Returns the TCP or UNIX socket address the backend RPC server listens on
 	RPCAddress() (string, error)
+	// Returns the height of the last block committed to the Tendermint chain data, must be called
+	// before the backend is started.
+	LastBlockHeight() (int64, error)
 	EventBus() *types.EventBus // TODO: doesn't seem to be used, remove it
+	// Returns the mempool of the node, must be called after the backend is started.
+	Mempool() *mempl.Mempool
 }
 
 type TendermintBackend struct {
@@ -460,10 +467,27 @@ func (b *TendermintBackend) Start(app abci.Application) error {
 	return nil
 }
 
+func (b *TendermintBackend) LastBlockHeight() (int64, error) {
+	cfg, err := b.parseConfig()
+	if err != nil {
+		return 0, err
+	}
+	stateDB, err := node.DefaultDBProvider(&node.DBContext{ID: "state", Config: cfg})
+	if err != nil {
+		return 0, err
+	}
+	defer stateDB.Close()
+	return sm.LoadState(stateDB).LastBlockHeight, nil
+}
+
 func (b *TendermintBackend) EventBus() *types.EventBus {
 	return b.node.EventBus()
 }
 
+func (b *TendermintBackend) Mempool() *mempl.Mempool {
+	return b.node.MempoolReactor().Mempool
+}
+
 func (b *TendermintBackend) RunForever() {
 	cmn.TrapSignal(func() {
 		if (b.node != nil) && b.node.IsRunning() {
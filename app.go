@@ -38,6 +38,8 @@ type ReadOnlyState interface {
 	Release()
 	FeatureEnabled(string, bool) bool
 	Config() *cctypes.Config
+	// OnChainSettings returns the on-chain config settings that aren't part of Config().
+	OnChainSettings() *store.OnChainSettings
 	EnabledFeatures() []string
 	GetMinBuildNumber() uint64
 }
@@ -60,6 +62,7 @@ type StoreState struct {
 	validators      loom.ValidatorSet
 	getValidatorSet GetValidatorSet
 	config          *cctypes.Config
+	settings        *store.OnChainSettings
 }
 
 var _ = State(&StoreState{})
@@ -101,6 +104,11 @@ func (s *StoreState) WithOnChainConfig(config *cctypes.Config) *StoreState {
 	return s
 }
 
+func (s *StoreState) WithOnChainSettings(settings *store.OnChainSettings) *StoreState {
+	s.settings = settings
+	return s
+}
+
 func (s *StoreState) Range(prefix []byte) plugin.RangeData {
 	return s.store.Range(prefix)
 }
@@ -200,6 +208,9 @@ func (s *StoreState) GetMinBuildNumber() uint64 {
 // ChangeConfigSetting updates the value of the given on-chain config setting.
 // If an error occurs while trying to update the config the change is discarded.
 func (s *StoreState) ChangeConfigSetting(name, value string) error {
+	if store.IsOnChainSetting(name) {
+		return s.changeOnChainSetting(name, value)
+	}
 	cfg, err := store.LoadOnChainConfig(s.store)
 	if err != nil {
 		panic(err)
@@ -227,6 +238,34 @@ func (s *StoreState) Config() *cctypes.Config {
 	return s.config
 }
 
+func (s *StoreState) changeOnChainSetting(name, value string) error {
+	settings, err := store.LoadOnChainSettings(s.store)
+	if err != nil {
+		panic(err)
+	}
+	if err := store.SetOnChainSetting(settings, name, value); err != nil {
+		return err
+	}
+	if err := store.SaveOnChainSettings(s.store, settings); err != nil {
+		return err
+	}
+	// invalidate cached settings so they're reloaded next time they're accessed
+	s.settings = nil
+	return nil
+}
+
+// OnChainSettings returns the current on-chain settings.
+func (s *StoreState) OnChainSettings() *store.OnChainSettings {
+	if s.settings == nil {
+		var err error
+		s.settings, err = store.LoadOnChainSettings(s.store)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return s.settings
+}
+
 func (s *StoreState) WithContext(ctx context.Context) State {
 	return &StoreState{
 		store:           s.store,
@@ -251,6 +290,42 @@ func (s *StoreState) Release() {
 	// noop
 }
 
+// NewCachedState returns a state that reads through to the given state, but buffers all writes in
+// memory, so the given state is never modified. This makes it possible to execute txs against a
+// read-only snapshot of the app state and then throw away the results.
+func NewCachedState(state State) State {
+	return NewCachedStateAt(state, state.Block())
+}
+
+// NewCachedStateAt is like NewCachedState, but the returned state reports the given block header
+// instead of the header of the given state. This makes it possible to replay the txs in a block on
+// top of the state the previous block left behind.
+func NewCachedStateAt(state State, block types.BlockHeader) State {
+	return &StoreState{
+		ctx:        state.Context(),
+		store:      store.WrapAtomic(state).BeginTx(),
+		block:      block,
+		validators: loom.NewValidatorSet(),
+		config:     state.Config(),
+		settings:   state.OnChainSettings(),
+	}
+}
+
+// NewStoreStateWithStore returns a state that reports the same block, context & on-chain config as
+// the given state, but reads from & writes to the given store instead of the store of the given
+// state. This makes it possible to persist changes that shouldn't be discarded along with the
+// changes made by a failed tx.
+func NewStoreStateWithStore(state State, kvStore store.KVStore) State {
+	return &StoreState{
+		ctx:        state.Context(),
+		store:      kvStore,
+		block:      state.Block(),
+		validators: loom.NewValidatorSet(),
+		config:     state.Config(),
+		settings:   state.OnChainSettings(),
+	}
+}
+
 // StoreStateSnapshot is a read-only snapshot of the app state at particular point in time,
 // it's unaffected by any changes to the app state. Multiple snapshots can exist at any one
 // time, but each snapshot should only be accessed from one thread at a time. After a snapshot
@@ -277,6 +352,16 @@ func NewStoreStateSnapshot(
 	}
 }
 
+// StoreVersion returns the version of the app store the snapshot was taken from, returns false if
+// the version is unknown. The version may not match the height of the block header of the
+// snapshot, since the header isn't obtained atomically with the store snapshot.
+func (s *StoreStateSnapshot) StoreVersion() (int64, bool) {
+	if snap, ok := s.storeSnapshot.(store.VersionedSnapshot); ok {
+		return snap.Version(), true
+	}
+	return 0, false
+}
+
 // Release releases the underlying store snapshot, safe to call multiple times.
 func (s *StoreStateSnapshot) Release() {
 	if s.storeSnapshot != nil {
@@ -335,6 +420,12 @@ type ChainConfigManager interface {
 	UpdateConfig() (int, error)
 }
 
+// StateSnapshotter is notified after each block is committed so it can export snapshots of the
+// app state.
+type StateSnapshotter interface {
+	OnCommit(height int64, appHash []byte)
+}
+
 type GetValidatorSet func(state State) (loom.ValidatorSet, error)
 
 type ValidatorsManagerFactoryFunc func(state State) (ValidatorsManager, error)
@@ -369,6 +460,15 @@ type Application struct {
 	childTxRefs                 []evmaux.ChildTxRef // links Tendermint txs to EVM txs
 	ReceiptsVersion             int32
 	committedTxs                []CommittedTx
+	blockGasUsed                uint64 // gas used by the txs in the current block
+	// Optional, exports periodic snapshots of the app state after blocks are committed.
+	StateSnapshotter StateSnapshotter
+	// Optional, tracks the progress of each block commit so an interrupted commit can be detected
+	// & rolled back when the node restarts.
+	CommitJournal *store.CommitJournal
+	// Optional, maps the validator that proposed each block to the address returned by the EVM
+	// COINBASE opcode.
+	ResolveBlockProposer BlockProposerResolver
 }
 
 var _ abci.Application = &Application{}
@@ -503,6 +603,7 @@ func (a *Application) BeginBlock(req abci.RequestBeginBlock) abci.ResponseBeginB
 
 	a.curBlockHeader = block
 	a.curBlockHash = req.Hash
+	a.blockGasUsed = 0
 
 	if a.CreateContractUpkeepHandler != nil {
 		upkeepStoreTx := store.WrapAtomic(a.Store).BeginTx()
@@ -567,6 +668,8 @@ func (a *Application) BeginBlock(req abci.RequestBeginBlock) abci.ResponseBeginB
 		}
 	}
 
+	RecordBlockContext(state, block, a.ResolveBlockProposer)
+
 	storeTx.Commit()
 
 	return abci.ResponseBeginBlock{}
@@ -582,6 +685,18 @@ func (a *Application) EndBlock(req abci.RequestEndBlock) abci.ResponseEndBlock {
 		panic(fmt.Sprintf("app height %d doesn't match EndBlock height %d", a.height(), req.Height))
 	}
 
+	// The receipts are the first thing that's committed for each block, so the commit of the
+	// block starts here.
+	if a.CommitJournal != nil {
+		txRefs := make([][]byte, 0, len(a.childTxRefs))
+		for _, ref := range a.childTxRefs {
+			txRefs = append(txRefs, ref.ParentTxHash)
+		}
+		if err := a.CommitJournal.Begin(a.height(), a.curBlockHash, txRefs); err != nil {
+			panic(err)
+		}
+	}
+
 	// TODO: receiptHandler.CommitBlock() should be moved to Application.Commit()
 	storeTx := store.WrapAtomic(a.Store).BeginTx()
 	receiptHandler := a.ReceiptHandlerProvider.Store()
@@ -696,11 +811,17 @@ func (a *Application) DeliverTx(txBytes []byte) abci.ResponseDeliverTx {
 
 	var r abci.ResponseDeliverTx
 
+	gasMeter := a.newTxGasMeter(state)
+	ctx := WithGasMeter(context.Background(), gasMeter)
+
 	if state.FeatureEnabled(features.EvmTxReceiptsVersion3_1, false) {
-		r = a.deliverTx2(storeTx, txBytes)
+		r = a.deliverTx2(ctx, storeTx, txBytes)
 	} else {
-		r = a.deliverTx(storeTx, txBytes)
+		r = a.deliverTx(ctx, storeTx, txBytes)
 	}
+	// The gas used by failed txs counts towards the block gas limit too, otherwise a tx that runs
+	// out of gas wouldn't cost anything.
+	a.blockGasUsed = gasMeter.CumulativeGasUsed()
 
 	txFailed = r.Code != abci.CodeTypeOK
 	// TODO: this isn't 100% reliable when txFailed == true
@@ -708,9 +829,30 @@ func (a *Application) DeliverTx(txBytes []byte) abci.ResponseDeliverTx {
 	return r
 }
 
+// newTxGasMeter creates a gas meter for the next tx in the current block, once gas metering is
+// enabled the tx can't use more gas than is left in the block.
+func (a *Application) newTxGasMeter(state State) *GasMeter {
+	gasMeter := NewInfiniteGasMeter(a.blockGasUsed)
+	if !state.FeatureEnabled(features.EvmGasMeteringFeature, false) {
+		return gasMeter
+	}
+	blockGasLimit := state.OnChainSettings().EvmBlockGasLimit
+	if blockGasLimit == 0 {
+		return gasMeter
+	}
+	if a.blockGasUsed >= blockGasLimit {
+		gasMeter.SetLimit(0)
+	} else {
+		gasMeter.SetLimit(blockGasLimit - a.blockGasUsed)
+	}
+	return gasMeter
+}
+
 // This version of DeliverTx doesn't store the receipts for failed EVM txs.
-func (a *Application) deliverTx(storeTx store.KVStoreTx, txBytes []byte) abci.ResponseDeliverTx {
-	r, err := a.processTx(storeTx, txBytes, false)
+func (a *Application) deliverTx(
+	ctx context.Context, storeTx store.KVStoreTx, txBytes []byte,
+) abci.ResponseDeliverTx {
+	r, err := a.processTx(ctx, storeTx, txBytes, false)
 	if err != nil {
 		log.Error("DeliverTx", "tx", hex.EncodeToString(ttypes.Tx(txBytes).Hash()), "err", err)
 		return abci.ResponseDeliverTx{Code: 1, Log: err.Error()}
@@ -718,9 +860,11 @@ func (a *Application) deliverTx(storeTx store.KVStoreTx, txBytes []byte) abci.Re
 	return abci.ResponseDeliverTx{Code: abci.CodeTypeOK, Data: r.Data, Tags: r.Tags, Info: r.Info}
 }
 
-func (a *Application) processTx(storeTx store.KVStoreTx, txBytes []byte, isCheckTx bool) (TxHandlerResult, error) {
+func (a *Application) processTx(
+	ctx context.Context, storeTx store.KVStoreTx, txBytes []byte, isCheckTx bool,
+) (TxHandlerResult, error) {
 	state := NewStoreState(
-		context.Background(),
+		ctx,
 		storeTx,
 		a.curBlockHeader,
 		a.curBlockHash,
@@ -771,9 +915,11 @@ func (a *Application) processTx(storeTx store.KVStoreTx, txBytes []byte, isCheck
 }
 
 // This version of DeliverTx stores the receipts for failed EVM txs.
-func (a *Application) deliverTx2(storeTx store.KVStoreTx, txBytes []byte) abci.ResponseDeliverTx {
+func (a *Application) deliverTx2(
+	ctx context.Context, storeTx store.KVStoreTx, txBytes []byte,
+) abci.ResponseDeliverTx {
 	state := NewStoreState(
-		context.Background(),
+		ctx,
 		storeTx,
 		a.curBlockHeader,
 		a.curBlockHash,
@@ -830,25 +976,45 @@ func (a *Application) Commit() abci.ResponseCommit {
 		commitBlockLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	appHash, _, err := a.Store.SaveVersion()
-	if err != nil {
-		panic(err)
-	}
-
 	height := a.curBlockHeader.GetHeight()
 
+	if a.CommitJournal != nil {
+		if err := a.CommitJournal.SetPhase(height, store.CommitPhaseAppStore); err != nil {
+			panic(err)
+		}
+	}
+
+	// The tx hash refs are saved before app.db so that they're not lost if the node crashes before
+	// the commit completes, if app.db isn't committed they're rolled back when the node restarts.
 	if err := a.EvmAuxStore.SaveChildTxRefs(a.childTxRefs); err != nil {
 		// TODO: consider panic instead
 		log.Error("Failed to save Tendermint -> EVM tx hash refs", "height", height, "err", err)
 	}
 	a.childTxRefs = nil
 
+	appHash, _, err := a.Store.SaveVersion()
+	if err != nil {
+		panic(err)
+	}
+
+	if a.CommitJournal != nil {
+		if err := a.CommitJournal.SetPhase(height, store.CommitPhaseBlockIndex); err != nil {
+			panic(err)
+		}
+	}
+
 	// Update the index before emitting events in case the subscribers attempt to lookup the
 	// block by number as soon as they receive an event.
 	if a.BlockIndexStore != nil {
 		a.BlockIndexStore.SetBlockHashAtHeight(uint64(height), a.curBlockHash)
 	}
 
+	if a.CommitJournal != nil {
+		if err := a.CommitJournal.Commit(height); err != nil {
+			panic(err)
+		}
+	}
+
 	// Update the last block header before emitting events in case the subscribers attempt to access
 	// the latest committed state as soon as they receive an event.
 	a.lastBlockHeader = a.curBlockHeader
@@ -881,6 +1047,10 @@ func (a *Application) Commit() abci.ResponseCommit {
 		log.Error("failed to prune app.db", "err", err)
 	}
 
+	if a.StateSnapshotter != nil {
+		a.StateSnapshotter.OnCommit(height, appHash)
+	}
+
 	return abci.ResponseCommit{
 		Data: appHash,
 	}
@@ -914,3 +1084,14 @@ func (a *Application) ReadOnlyState() State {
 		a.GetValidatorSet,
 	)
 }
+
+// ReadOnlyStateAt returns a read-only snapshot of the app state as it was right after the block
+// with the given header was committed. An error is returned if the state at that height has
+// already been pruned from the store.
+func (a *Application) ReadOnlyStateAt(blockHeader abci.Header) (State, error) {
+	snapshot, err := a.Store.GetSnapshotAt(blockHeader.Height)
+	if err != nil {
+		return nil, err
+	}
+	return NewStoreStateSnapshot(nil, snapshot, blockHeader, nil, a.GetValidatorSet), nil
+}
@@ -53,6 +53,27 @@ func TestOnChainConfig(t *testing.T) {
 	require.Equal(t, uint64(5000), state.WithOnChainConfig(curCfg).Config().Evm.GasLimit)
 }
 
+func TestOnChainSettings(t *testing.T) {
+	kvStore, err := mockMultiWriterStore(10)
+	require.NoError(t, err)
+	header := abci.Header{
+		Height: blockHeight,
+		Time:   blockTime,
+	}
+	state := NewStoreState(context.Background(), kvStore, header, nil, nil)
+	require.Equal(t, uint64(0), state.OnChainSettings().EvmBlockGasLimit)
+
+	require.NoError(t, state.ChangeConfigSetting(store.EvmBlockGasLimitSetting, "8000000"))
+	require.Equal(t, uint64(8000000), state.OnChainSettings().EvmBlockGasLimit)
+	require.Error(t, state.ChangeConfigSetting(store.EvmBlockGasLimitSetting, "-1"))
+	// settings that aren't part of the chainconfig.Config shouldn't end up in it
+	require.Equal(t, uint64(0), state.Config().GetEvm().GasLimit)
+
+	settings, err := store.LoadOnChainSettings(kvStore)
+	require.NoError(t, err)
+	require.Equal(t, uint64(8000000), settings.EvmBlockGasLimit)
+}
+
 func mockMultiWriterStore(flushInterval int64) (*store.MultiWriterAppStore, error) {
 	memDb, _ := db.LoadMemDB()
 	iavlStore, err := store.NewIAVLStore(memDb, 0, 0, flushInterval)
@@ -0,0 +1,111 @@
+package loomchain
+
+import (
+	"encoding/binary"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/util"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+
+	"github.com/loomnetwork/loomchain/features"
+	"github.com/loomnetwork/loomchain/log"
+)
+
+// NumRecentBlockHashes is the number of recent block hashes kept in the app state, the EVM
+// BLOCKHASH opcode can't look up the hashes of older blocks.
+const NumRecentBlockHashes = 256
+
+var (
+	blockHashPrefix       = []byte("blockhash")
+	blockProposerKey      = []byte("blockproposer")
+	validatorPubKeyPrefix = []byte("blockvalidator")
+)
+
+func blockHashKey(height uint64) []byte {
+	heightBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(heightBytes, height)
+	return util.PrefixKey(blockHashPrefix, heightBytes)
+}
+
+func validatorPubKeyKey(tmAddr []byte) []byte {
+	return util.PrefixKey(validatorPubKeyPrefix, tmAddr)
+}
+
+// BlockProposerResolver maps the address of the validator that proposed a block to the address
+// that should be returned by the EVM COINBASE opcode.
+type BlockProposerResolver func(state State, proposer loom.Address) (loom.LocalAddress, error)
+
+// RecordBlockContext stores the hash of the previous block, and the address of the validator that
+// proposed the current block, in the app state so they're available to the EVM. The values are
+// taken from the block header so every node records exactly the same values, regardless of which
+// blocks are available in its local block store.
+//
+// Tendermint only applies validator set changes made by the app two blocks after they're made, so
+// the validator that proposed the block may no longer be in the app's validator set. To match the
+// proposer against the validator set that actually signed the block the public key of every
+// validator in the app's set is recorded by its Tendermint address, which is derived from the key.
+// A validator always shows up in the app's set before Tendermint lets it propose blocks.
+//
+// The proposer is mapped to the COINBASE address by resolveProposer, if it's nil, or it fails,
+// the local address of the proposer is used.
+func RecordBlockContext(state State, header abci.Header, resolveProposer BlockProposerResolver) {
+	if !state.FeatureEnabled(features.EvmBlockContextFeature, false) {
+		return
+	}
+
+	if header.Height > 1 {
+		prevHeight := uint64(header.Height - 1)
+		state.Set(blockHashKey(prevHeight), header.LastBlockId.Hash)
+		if prevHeight > NumRecentBlockHashes {
+			state.Delete(blockHashKey(prevHeight - NumRecentBlockHashes))
+		}
+	}
+
+	for _, v := range state.Validators() {
+		var pubKey ed25519.PubKeyEd25519
+		if len(v.PubKey) != len(pubKey) {
+			continue
+		}
+		copy(pubKey[:], v.PubKey)
+		if key := validatorPubKeyKey(pubKey.Address()); !state.Has(key) {
+			state.Set(key, v.PubKey)
+		}
+	}
+
+	state.Delete(blockProposerKey)
+	proposerPubKey := state.Get(validatorPubKeyKey(header.ProposerAddress))
+	if proposerPubKey == nil {
+		return
+	}
+	proposer := loom.Address{
+		ChainID: state.Block().ChainID,
+		Local:   loom.LocalAddressFromPublicKey(proposerPubKey),
+	}
+	coinbase := proposer.Local
+	if resolveProposer != nil {
+		resolved, err := resolveProposer(state, proposer)
+		if err != nil {
+			log.Error("Failed to resolve block proposer", "proposer", proposer.String(), "err", err)
+		} else {
+			coinbase = resolved
+		}
+	}
+	state.Set(blockProposerKey, coinbase)
+}
+
+// GetRecentBlockHash returns the hash of the block at the given height, or nil if the block is not
+// one of the NumRecentBlockHashes blocks preceding the current block.
+func GetRecentBlockHash(state ReadOnlyState, height uint64) []byte {
+	curHeight := uint64(state.Block().Height)
+	if height >= curHeight || height+NumRecentBlockHashes < curHeight {
+		return nil
+	}
+	return state.Get(blockHashKey(height))
+}
+
+// GetBlockProposer returns the address recorded for the validator that proposed the current block,
+// or nil if the proposer isn't known.
+func GetBlockProposer(state ReadOnlyState) loom.LocalAddress {
+	return state.Get(blockProposerKey)
+}
@@ -0,0 +1,72 @@
+package loomchain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain/features"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
+)
+
+func TestRecordBlockContext(t *testing.T) {
+	proposerKey := ed25519.GenPrivKey().PubKey().(ed25519.PubKeyEd25519)
+	otherKey := ed25519.GenPrivKey().PubKey().(ed25519.PubKeyEd25519)
+	validators := []*loom.Validator{
+		{PubKey: otherKey[:], Power: 10},
+		{PubKey: proposerKey[:], Power: 10},
+	}
+	getValidatorSet := func(state State) (loom.ValidatorSet, error) {
+		return loom.NewValidatorSet(validators...), nil
+	}
+	var resolveProposer BlockProposerResolver
+	kvStore := store.NewMemStore()
+	beginBlock := func(height int64) State {
+		header := abci.Header{
+			Height:          height,
+			Time:            blockTime,
+			ProposerAddress: proposerKey.Address(),
+		}
+		header.LastBlockId.Hash = []byte{byte(height - 1)}
+		state := NewStoreState(context.Background(), kvStore, header, nil, getValidatorSet)
+		RecordBlockContext(state, header, resolveProposer)
+		return state
+	}
+
+	// nothing should be recorded until the feature is enabled
+	state := beginBlock(2)
+	require.Nil(t, GetRecentBlockHash(state, 1))
+	require.Nil(t, GetBlockProposer(state))
+
+	state.SetFeature(features.EvmBlockContextFeature, true)
+	for h := int64(2); h <= NumRecentBlockHashes+3; h++ {
+		state = beginBlock(h)
+	}
+
+	curHeight := uint64(NumRecentBlockHashes + 3)
+	require.Equal(t, []byte{byte(curHeight - 1)}, GetRecentBlockHash(state, curHeight-1))
+	require.Equal(t, []byte{3}, GetRecentBlockHash(state, curHeight-NumRecentBlockHashes))
+	require.Nil(t, GetRecentBlockHash(state, curHeight))
+	require.Nil(t, GetRecentBlockHash(state, curHeight-NumRecentBlockHashes-1))
+	// hashes that fall out of the window should be pruned from the store
+	require.False(t, state.Has(blockHashKey(curHeight-NumRecentBlockHashes-1)))
+	require.Equal(t, loom.LocalAddressFromPublicKey(proposerKey[:]), GetBlockProposer(state))
+
+	// Tendermint may let a validator propose blocks for a couple of blocks after the app removes it
+	// from the validator set.
+	validators = validators[:1]
+	state = beginBlock(int64(curHeight) + 1)
+	require.Equal(t, loom.LocalAddressFromPublicKey(proposerKey[:]), GetBlockProposer(state))
+
+	// the proposer should be mapped to the address returned by the resolver
+	mappedAddr := loom.MustParseAddress("eth:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	resolveProposer = func(_ State, proposer loom.Address) (loom.LocalAddress, error) {
+		require.Equal(t, loom.LocalAddressFromPublicKey(proposerKey[:]), proposer.Local)
+		return mappedAddr.Local, nil
+	}
+	state = beginBlock(int64(curHeight) + 2)
+	require.Equal(t, mappedAddr.Local, GetBlockProposer(state))
+}
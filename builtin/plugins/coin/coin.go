@@ -268,20 +268,36 @@ func (c *Coin) Transfer(ctx contract.Context, req *TransferRequest) error {
 }
 
 func (c *Coin) transfer(ctx contract.Context, req *TransferRequest) error {
-	from := ctx.Message().Sender
+	amount := req.Amount.Value
+	return transfer(ctx, ctx.Message().Sender, loom.UnmarshalAddressPB(req.To), &amount)
+}
+
+// BalanceOf is used by the CoinBalanceManager to look up LOOM balances outside the contract.
+func BalanceOf(ctx contract.StaticContext, owner loom.Address) (*loom.BigUInt, error) {
+	acct, err := loadAccount(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return &acct.Balance.Value, nil
+}
 
+// Transfer is used by the CoinBalanceManager to charge tx fees in LOOM.
+func Transfer(ctx contract.Context, from, to loom.Address, amount *loom.BigUInt) error {
+	return transfer(ctx, from, to, amount)
+}
+
+func transfer(ctx contract.Context, from, to loom.Address, amount *loom.BigUInt) error {
 	fromAccount, err := loadAccount(ctx, from)
 	if err != nil {
 		return err
 	}
-	amount := req.Amount.Value
 	fromBalance := fromAccount.Balance.Value
 
-	if fromBalance.Cmp(&amount) < 0 {
+	if fromBalance.Cmp(amount) < 0 {
 		return ErrSenderBalanceTooLow
 	}
 
-	fromBalance.Sub(&fromBalance, &amount)
+	fromBalance.Sub(&fromBalance, amount)
 	fromAccount.Balance.Value = fromBalance
 
 	err = saveAccount(ctx, fromAccount)
@@ -289,14 +305,13 @@ func (c *Coin) transfer(ctx contract.Context, req *TransferRequest) error {
 		return err
 	}
 
-	to := loom.UnmarshalAddressPB(req.To)
 	toAccount, err := loadAccount(ctx, to)
 	if err != nil {
 		return err
 	}
 
 	toBalance := toAccount.Balance.Value
-	toBalance.Add(&toBalance, &amount)
+	toBalance.Add(&toBalance, amount)
 	toAccount.Balance.Value = toBalance
 
 	err = saveAccount(ctx, toAccount)
@@ -304,7 +319,7 @@ func (c *Coin) transfer(ctx contract.Context, req *TransferRequest) error {
 		return err
 	}
 
-	return emitTransferEvent(ctx, from, to, &amount)
+	return emitTransferEvent(ctx, from, to, amount)
 }
 
 func (c *Coin) Approve(ctx contract.Context, req *ApproveRequest) error {
@@ -1704,6 +1704,14 @@ func rewardAndSlash(ctx contract.Context, cachedDelegations *CachedDposStorage,
 		return nil, err
 	}
 
+	var txFees *txFeeDistribution
+	if ctx.FeatureEnabled(features.TxFeeFeature, false) {
+		txFees, err = loadTxFeeDistribution(ctx, state)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	for _, validator := range state.Validators {
 		candidate := GetCandidateByPubKey(ctx, validator.PubKey)
 
@@ -1732,6 +1740,10 @@ func rewardAndSlash(ctx contract.Context, cachedDelegations *CachedDposStorage,
 			// rewarded for avoiding faults during the last slashing period
 			if common.IsZero(statistic.SlashPercentage.Value) {
 				distributionTotal := calculateRewards(statistic.DelegationTotal.Value, state.Params, state.TotalValidatorDelegations.Value)
+				if txFees != nil {
+					feeShare := txFees.addValidator(candidateAddress, statistic.DelegationTotal.Value)
+					distributionTotal.Add(&distributionTotal, &feeShare)
+				}
 
 				// The validator share, equal to validator_fee * total_validotor_reward
 				// is to be split between the referrers and the validator
@@ -1821,6 +1833,12 @@ func rewardAndSlash(ctx contract.Context, cachedDelegations *CachedDposStorage,
 		return nil, err
 	}
 
+	if txFees != nil {
+		if err := txFees.distribute(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	if ctx.FeatureEnabled(features.DPOSVersion3_1, false) {
 		state.TotalRewardDistribution.Value.Add(&state.TotalRewardDistribution.Value, distributedRewards)
 	}
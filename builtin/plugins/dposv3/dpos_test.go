@@ -15,6 +15,7 @@ import (
 	"github.com/loomnetwork/go-loom/plugin/contractpb"
 	types "github.com/loomnetwork/go-loom/types"
 	"github.com/loomnetwork/loomchain/builtin/plugins/coin"
+	"github.com/loomnetwork/loomchain/builtin/plugins/ethcoin"
 	"github.com/loomnetwork/loomchain/features"
 )
 
@@ -3306,6 +3307,84 @@ func TestEnableValidatorJailing(t *testing.T) {
 
 // UTILITIES
 
+func TestTxFeeRewards(t *testing.T) {
+	pctx := createCtx()
+	coinAddr := pctx.CreateContract(coin.Contract)
+	coinContract := &coin.Coin{}
+	coinCtx := pctx.WithAddress(coinAddr)
+	coinContract.Init(contractpb.WrapPluginContext(coinCtx), &coin.InitRequest{
+		Accounts: []*coin.InitialAccount{
+			makeAccount(delegatorAddress1, 100000000),
+			makeAccount(addr1, 100000000),
+			makeAccount(addr2, 100000000),
+		},
+	})
+	ethCoinAddr := pctx.CreateContract(ethcoin.Contract)
+	pctx.RegisterContract(EthFeeToken, ethCoinAddr, ethCoinAddr)
+	ethCoinCtx := contractpb.WrapPluginContext(pctx.WithAddress(ethCoinAddr))
+
+	// disable block rewards so the only rewards validators receive come from tx fees
+	dpos, err := deployDPOSContract(pctx, &Params{
+		ValidatorCount:      10,
+		CoinContractAddress: coinAddr.MarshalPB(),
+		MaxYearlyReward:     &types.BigUInt{Value: *loom.NewBigUIntFromInt(0)},
+	})
+	require.Nil(t, err)
+	dposCtx := contractpb.WrapPluginContext(pctx.WithAddress(dpos.Address))
+
+	registrationFee := &types.BigUInt{Value: *scientificNotation(defaultRegistrationRequirement, tokenDecimals)}
+	for i, validator := range []loom.Address{addr1, addr2} {
+		err = coinContract.Approve(contractpb.WrapPluginContext(coinCtx.WithSender(validator)), &coin.ApproveRequest{
+			Spender: dpos.Address.MarshalPB(),
+			Amount:  registrationFee,
+		})
+		require.Nil(t, err)
+		pubKey := [][]byte{pubKey1, pubKey2}[i]
+		err = dpos.RegisterCandidate(pctx.WithSender(validator), pubKey, nil, nil, nil, nil, nil, nil)
+		require.Nil(t, err)
+	}
+	require.NoError(t, elect(pctx, dpos.Address))
+
+	// pay some fees into the pools
+	loomFees := scientificNotation(10, tokenDecimals)
+	require.NoError(t, coin.Transfer(contractpb.WrapPluginContext(coinCtx), delegatorAddress1, dpos.Address, loomFees))
+	require.NoError(t, AddTxFees(dposCtx, LoomFeeToken, loomFees))
+	ethFees := loom.NewBigUIntFromInt(1001)
+	require.NoError(t, ethcoin.AddBalance(ethCoinCtx, dpos.Address, ethFees))
+	require.NoError(t, AddTxFees(dposCtx, EthFeeToken, ethFees))
+	require.Equal(t, ErrInvalidFeeToken, AddTxFees(dposCtx, "karma", ethFees))
+
+	// fees should be left in the pools until the feature is enabled
+	require.NoError(t, elect(pctx, dpos.Address))
+	pool, err := TxFeePool(dposCtx, LoomFeeToken)
+	require.NoError(t, err)
+	require.Equal(t, loomFees.String(), pool.String())
+
+	pctx.SetFeature(features.TxFeeFeature, true)
+	rewardsBefore, err := dpos.CheckRewards(pctx)
+	require.NoError(t, err)
+	require.NoError(t, elect(pctx, dpos.Address))
+	rewardsAfter, err := dpos.CheckRewards(pctx)
+	require.NoError(t, err)
+
+	// both validators have the same delegation total so each should've received half the fees
+	rewardsAfter.Sub(rewardsAfter, rewardsBefore)
+	require.Equal(t, loomFees.String(), rewardsAfter.String())
+	pool, err = TxFeePool(dposCtx, LoomFeeToken)
+	require.NoError(t, err)
+	require.True(t, common.IsZero(*pool))
+
+	for _, validator := range []loom.Address{addr1, addr2} {
+		balance, err := ethcoin.BalanceOf(ethCoinCtx, validator)
+		require.NoError(t, err)
+		require.Equal(t, int64(500), balance.Int64())
+	}
+	// the remainder should be carried over to the next election
+	pool, err = TxFeePool(dposCtx, EthFeeToken)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), pool.Int64())
+}
+
 func makeAccount(owner loom.Address, bal uint64) *coin.InitialAccount {
 	return &coin.InitialAccount{
 		Owner:   owner.MarshalPB(),
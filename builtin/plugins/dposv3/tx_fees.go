@@ -0,0 +1,138 @@
+package dposv3
+
+import (
+	loom "github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/common"
+	contract "github.com/loomnetwork/go-loom/plugin/contractpb"
+	types "github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/pkg/errors"
+)
+
+// Names of the contracts that hold the balances of the tokens tx fees can be paid in.
+const (
+	LoomFeeToken = "coin"
+	EthFeeToken  = "ethcoin"
+)
+
+var (
+	txFeePoolPrefix = []byte("txfee")
+
+	// ErrInvalidFeeToken indicates that tx fees can't be paid in the given token.
+	ErrInvalidFeeToken = errors.New("[DPOSv3] invalid fee token")
+)
+
+func txFeePoolKey(token string) []byte {
+	return util.PrefixKey(txFeePoolPrefix, []byte(token))
+}
+
+// TxFeePool returns the amount of tx fees paid in the given token that are waiting to be
+// distributed to validators at the next election.
+func TxFeePool(ctx contract.StaticContext, token string) (*loom.BigUInt, error) {
+	if token != LoomFeeToken && token != EthFeeToken {
+		return nil, ErrInvalidFeeToken
+	}
+	var pool types.BigUInt
+	if err := ctx.Get(txFeePoolKey(token), &pool); err != nil {
+		if err == contract.ErrNotFound {
+			return common.BigZero(), nil
+		}
+		return nil, err
+	}
+	return &pool.Value, nil
+}
+
+func setTxFeePool(ctx contract.Context, token string, amount *loom.BigUInt) error {
+	if common.IsZero(*amount) {
+		ctx.Delete(txFeePoolKey(token))
+		return nil
+	}
+	return ctx.Set(txFeePoolKey(token), &types.BigUInt{Value: *amount})
+}
+
+// AddTxFees adds the given amount to the pool of tx fees paid in the given token, the fees must
+// have already been transferred to the DPOS contract.
+func AddTxFees(ctx contract.Context, token string, amount *loom.BigUInt) error {
+	pool, err := TxFeePool(ctx, token)
+	if err != nil {
+		return err
+	}
+	pool.Add(pool, amount)
+	return setTxFeePool(ctx, token, pool)
+}
+
+type txFeeShare struct {
+	validator loom.Address
+	amount    loom.BigUInt
+}
+
+// txFeeDistribution splits the tx fees collected during the last election cycle between the
+// validators rewarded at the end of it, in proportion to the amount delegated to each validator.
+// LOOM fees are added to the validator rewards, so they're split between the validators and their
+// delegators, while ETH fees are transferred to the validators directly.
+type txFeeDistribution struct {
+	totalDelegations loom.BigUInt
+	loomFees         loom.BigUInt
+	loomDistributed  loom.BigUInt
+	ethFees          loom.BigUInt
+	ethShares        []txFeeShare
+}
+
+func loadTxFeeDistribution(ctx contract.StaticContext, state *State) (*txFeeDistribution, error) {
+	loomFees, err := TxFeePool(ctx, LoomFeeToken)
+	if err != nil {
+		return nil, err
+	}
+	ethFees, err := TxFeePool(ctx, EthFeeToken)
+	if err != nil {
+		return nil, err
+	}
+	return &txFeeDistribution{
+		totalDelegations: state.TotalValidatorDelegations.Value,
+		loomFees:         *loomFees,
+		loomDistributed:  *common.BigZero(),
+		ethFees:          *ethFees,
+	}, nil
+}
+
+// addValidator records the ETH fees owed to the given validator, and returns the amount of LOOM
+// fees that should be added to the validator's rewards.
+func (d *txFeeDistribution) addValidator(validator loom.Address, delegationTotal loom.BigUInt) loom.BigUInt {
+	ethShare := calculateShare(delegationTotal, d.totalDelegations, d.ethFees)
+	if !common.IsZero(ethShare) {
+		d.ethShares = append(d.ethShares, txFeeShare{validator: validator, amount: ethShare})
+	}
+	loomShare := calculateShare(delegationTotal, d.totalDelegations, d.loomFees)
+	d.loomDistributed.Add(&d.loomDistributed, &loomShare)
+	return loomShare
+}
+
+// distribute transfers the ETH fees to the validators, and updates the fee pools so that any fees
+// that weren't distributed (due to rounding, or validators missing out on rewards) carry over to
+// the next election.
+func (d *txFeeDistribution) distribute(ctx contract.Context) error {
+	ethDistributed := common.BigZero()
+	if len(d.ethShares) > 0 {
+		ethCoinAddr, err := ctx.Resolve(EthFeeToken)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve ethcoin contract address")
+		}
+		ethCoin := &ERC20{Context: ctx, ContractAddress: ethCoinAddr}
+		for i := range d.ethShares {
+			share := &d.ethShares[i]
+			if err := ethCoin.Transfer(share.validator, &share.amount); err != nil {
+				return errors.Wrapf(err, "failed to transfer tx fees to %s", share.validator.String())
+			}
+			ethDistributed.Add(ethDistributed, &share.amount)
+		}
+	}
+
+	loomRemaining := common.BigZero()
+	loomRemaining.Sub(&d.loomFees, &d.loomDistributed)
+	if err := setTxFeePool(ctx, LoomFeeToken, loomRemaining); err != nil {
+		return err
+	}
+	ethRemaining := common.BigZero()
+	ethRemaining.Sub(&d.ethFees, ethDistributed)
+	return setTxFeePool(ctx, EthFeeToken, ethRemaining)
+}
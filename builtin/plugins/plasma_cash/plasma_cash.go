@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"strconv"
 
-	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/gogo/protobuf/proto"
 	loom "github.com/loomnetwork/go-loom"
@@ -22,6 +21,7 @@ import (
 	"github.com/loomnetwork/go-loom/util"
 	"github.com/loomnetwork/mamamerkle"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
 
 	"github.com/loomnetwork/go-loom/client/plasma_cash"
 
@@ -877,7 +877,7 @@ func rlpEncodeWithSha3(pb *PlasmaTx) ([]byte, error) {
 	if err != nil {
 		return []byte{}, err
 	}
-	d := sha3.NewKeccak256()
+	d := sha3.NewLegacyKeccak256()
 	d.Write(hash)
 	return d.Sum(nil), nil
 }
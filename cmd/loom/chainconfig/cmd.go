@@ -17,6 +17,7 @@ import (
 	"github.com/loomnetwork/go-loom/config"
 	plugintypes "github.com/loomnetwork/go-loom/plugin/types"
 	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
+	"github.com/loomnetwork/loomchain/store"
 	"github.com/spf13/cobra"
 	"github.com/tendermint/go-amino"
 	"github.com/tendermint/tendermint/crypto/ed25519"
@@ -485,9 +486,15 @@ func SetSettingCmd() *cobra.Command {
 			}
 
 			// validate config setting
-			defaultConfig := config.DefaultConfig()
-			if err := config.SetConfigSetting(defaultConfig, args[0], value); err != nil {
-				return err
+			if store.IsOnChainSetting(args[0]) {
+				if err := store.SetOnChainSetting(&store.OnChainSettings{}, args[0], value); err != nil {
+					return err
+				}
+			} else {
+				defaultConfig := config.DefaultConfig()
+				if err := config.SetConfigSetting(defaultConfig, args[0], value); err != nil {
+					return err
+				}
 			}
 
 			req := &cctype.SetSettingRequest{
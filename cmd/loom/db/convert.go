@@ -0,0 +1,336 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/cmd/loom/common"
+	"github.com/loomnetwork/loomchain/config"
+	cdb "github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/store"
+	evmaux "github.com/loomnetwork/loomchain/store/evm_aux"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+const (
+	convertAppDB      = "app"
+	convertEvmDB      = "evm"
+	convertReceiptsDB = "receipts"
+	convertEventsDB   = "events"
+)
+
+// convertibleDB describes one of the node DBs that can be converted to another backend.
+type convertibleDB struct {
+	name string
+	// Backend the DB is currently stored in
+	backend string
+	// Directory the DB is currently stored in
+	dir string
+	// Config setting that must be updated after the DB is converted
+	configSetting string
+	load          func(backend, dir string) (cdb.DBWrapper, error)
+	path          func(backend, dir string) string
+}
+
+func getConvertibleDBs(cfg *config.Config) map[string]*convertibleDB {
+	nodeDBPath := func(dbName string) func(backend, dir string) string {
+		return func(backend, dir string) string {
+			return filepath.Join(dir, dbName+".db")
+		}
+	}
+	return map[string]*convertibleDB{
+		convertAppDB: {
+			name:          cfg.DBName,
+			backend:       cfg.DBBackend,
+			dir:           cfg.RootPath(),
+			configSetting: "DBBackend",
+			load: func(backend, dir string) (cdb.DBWrapper, error) {
+				return cdb.LoadDB(
+					backend, cfg.DBName, dir,
+					cfg.DBBackendConfig.CacheSizeMegs, cfg.DBBackendConfig.WriteBufferMegs, false,
+				)
+			},
+			path: nodeDBPath(cfg.DBName),
+		},
+		convertEvmDB: {
+			name:          cfg.EvmStore.DBName,
+			backend:       cfg.EvmStore.DBBackend,
+			dir:           cfg.RootPath(),
+			configSetting: "EvmStore.DBBackend",
+			load: func(backend, dir string) (cdb.DBWrapper, error) {
+				return cdb.LoadDB(
+					backend, cfg.EvmStore.DBName, dir,
+					cfg.EvmStore.CacheSizeMegs, cfg.EvmStore.WriteBufferMegs, false,
+				)
+			},
+			path: nodeDBPath(cfg.EvmStore.DBName),
+		},
+		convertReceiptsDB: {
+			name:    evmaux.EvmAuxDBName,
+			backend: cfg.DBBackendConfig.EvmAuxDBBackend,
+			// the receipts DB is always loaded from the working dir
+			dir:           ".",
+			configSetting: "DBBackendConfig.EvmAuxDBBackend",
+			load:          evmaux.LoadDBFromDir,
+			path:          evmaux.DBPath,
+		},
+		convertEventsDB: {
+			name:          cfg.EventStore.DBName,
+			backend:       cfg.EventStore.DBBackend,
+			dir:           cfg.RootPath(),
+			configSetting: "EventStore.DBBackend",
+			load: func(backend, dir string) (cdb.DBWrapper, error) {
+				return cdb.LoadDB(backend, cfg.EventStore.DBName, dir, 20, 4, false)
+			},
+			path: nodeDBPath(cfg.EventStore.DBName),
+		},
+	}
+}
+
+func newConvertDBCommand() *cobra.Command {
+	var toBackend, outDir string
+	var dbNames []string
+	var batchSize int
+	var inPlace bool
+	cmd := &cobra.Command{
+		Use:   "convert",
+		Short: "Converts the node DBs to a different DB backend",
+		Long: "Copies every key from app.db, evm.db, receipts_db, and the events store into new DBs " +
+			"of the target backend, and then checks the app hash of the converted DBs matches the " +
+			"original. The node must be stopped while the DBs are being converted.",
+		Example: "loom db convert --to cleveldb --in-place",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+			if outDir == "" {
+				outDir = filepath.Join(cfg.RootPath(), "converted")
+			}
+			return convertDBs(cfg, dbNames, toBackend, outDir, batchSize, inPlace)
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&toBackend, "to", "", "DB backend to convert to (goleveldb, cleveldb, badgerdb)")
+	flags.StringSliceVar(
+		&dbNames, "db",
+		[]string{convertAppDB, convertEvmDB, convertReceiptsDB, convertEventsDB},
+		"DBs to convert (app, evm, receipts, events)",
+	)
+	flags.StringVar(&outDir, "out", "", "Directory to write the converted DBs to (defaults to <root>/converted)")
+	flags.IntVar(&batchSize, "batch-size", cdb.DefaultCopyBatchSize, "Number of keys to write per batch")
+	flags.BoolVar(
+		&inPlace, "in-place", false,
+		"Replace the original DBs with the converted ones, the original DBs are kept as backups",
+	)
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+func convertDBs(
+	cfg *config.Config, dbNames []string, toBackend, outDir string, batchSize int, inPlace bool,
+) error {
+	switch toBackend {
+	case cdb.GoLevelDBBackend, cdb.CLevelDBBackend, cdb.BadgerDBBackend:
+	default:
+		return fmt.Errorf("can't convert to db backend: %s", toBackend)
+	}
+
+	allDBs := getConvertibleDBs(cfg)
+	// DBs are converted in the order they were specified
+	converted := map[string]*convertibleDB{}
+	toConvert := []*convertibleDB{}
+	for _, dbName := range dbNames {
+		db, ok := allDBs[dbName]
+		if !ok {
+			return fmt.Errorf("unknown db: %s", dbName)
+		}
+		if db.backend == toBackend {
+			fmt.Printf("Skipping %s, it's already stored in %s\n", db.name, toBackend)
+			continue
+		}
+		converted[dbName] = db
+		toConvert = append(toConvert, db)
+	}
+	if len(toConvert) == 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return errors.Wrapf(err, "failed to create dir %s", outDir)
+	}
+	for _, db := range toConvert {
+		numKeys, err := convertDB(db, toBackend, outDir, batchSize)
+		if err != nil {
+			return errors.Wrapf(err, "failed to convert %s", db.name)
+		}
+		fmt.Printf("Copied %d keys from %s to %s\n", numKeys, db.name, db.path(toBackend, outDir))
+	}
+
+	if err := verifyConvertedState(allDBs, converted, toBackend, outDir, cfg.AppStore.Version); err != nil {
+		return errors.Wrap(err, "converted DBs don't match the original DBs")
+	}
+
+	if !inPlace {
+		fmt.Printf("Converted DBs saved to %s\n", outDir)
+		return nil
+	}
+	for _, db := range toConvert {
+		srcPath := db.path(db.backend, db.dir)
+		backupPath := srcPath + "." + db.backend + ".bak"
+		if util.FileExists(backupPath) {
+			return fmt.Errorf("%s already exists", backupPath)
+		}
+		if err := os.Rename(srcPath, backupPath); err != nil {
+			return errors.Wrapf(err, "failed to back up %s", srcPath)
+		}
+		if err := os.Rename(db.path(toBackend, outDir), db.path(toBackend, db.dir)); err != nil {
+			return errors.Wrapf(err, "failed to replace %s", srcPath)
+		}
+		fmt.Printf("Replaced %s, the original DB was moved to %s\n", srcPath, backupPath)
+	}
+	settings := []string{}
+	for _, db := range toConvert {
+		settings = append(settings, db.configSetting)
+	}
+	fmt.Printf("Set %s to %s in loom.yml before restarting the node\n", strings.Join(settings, ", "), toBackend)
+	return nil
+}
+
+func convertDB(db *convertibleDB, toBackend, outDir string, batchSize int) (int64, error) {
+	dstPath := db.path(toBackend, outDir)
+	if util.FileExists(dstPath) {
+		return 0, fmt.Errorf("%s already exists", dstPath)
+	}
+	srcDB, err := db.load(db.backend, db.dir)
+	if err != nil {
+		return 0, err
+	}
+	defer srcDB.Close()
+	dstDB, err := db.load(toBackend, outDir)
+	if err != nil {
+		return 0, err
+	}
+	defer dstDB.Close()
+	return cdb.CopyDB(srcDB, dstDB, batchSize)
+}
+
+// verifyConvertedState checks that app.db & evm.db still produce the same app hash & EVM roots
+// after conversion. The receipts & events DBs are not covered by the app hash so they're not
+// verified.
+func verifyConvertedState(
+	allDBs, converted map[string]*convertibleDB, toBackend, outDir string, appStoreVersion int64,
+) error {
+	_, appConverted := converted[convertAppDB]
+	_, evmConverted := converted[convertEvmDB]
+	if !appConverted && !evmConverted {
+		return nil
+	}
+
+	// load the original DB, and the DB that will be used after conversion
+	loadDBs := func(name string) (cdb.DBWrapper, cdb.DBWrapper, error) {
+		db := allDBs[name]
+		srcDB, err := db.load(db.backend, db.dir)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := converted[name]; !ok {
+			return srcDB, srcDB, nil
+		}
+		dstDB, err := db.load(toBackend, outDir)
+		if err != nil {
+			srcDB.Close()
+			return nil, nil, err
+		}
+		return srcDB, dstDB, nil
+	}
+	closeDBs := func(srcDB, dstDB cdb.DBWrapper) {
+		srcDB.Close()
+		if dstDB != srcDB {
+			dstDB.Close()
+		}
+	}
+
+	srcAppDB, dstAppDB, err := loadDBs(convertAppDB)
+	if err != nil {
+		return err
+	}
+	defer closeDBs(srcAppDB, dstAppDB)
+	srcAppStore, err := store.NewIAVLStore(srcAppDB, 0, 0, -1)
+	if err != nil {
+		return err
+	}
+	dstAppStore, err := store.NewIAVLStore(dstAppDB, 0, 0, -1)
+	if err != nil {
+		return err
+	}
+	if srcAppStore.Version() != dstAppStore.Version() {
+		return fmt.Errorf(
+			"app.db height mismatch, expected %d, got %d", srcAppStore.Version(), dstAppStore.Version(),
+		)
+	}
+	if !bytes.Equal(srcAppStore.Hash(), dstAppStore.Hash()) {
+		return fmt.Errorf(
+			"app hash mismatch at height %d, expected %X, got %X",
+			srcAppStore.Version(), srcAppStore.Hash(), dstAppStore.Hash(),
+		)
+	}
+	fmt.Printf("Verified app hash %X at height %d\n", dstAppStore.Hash(), dstAppStore.Version())
+
+	if !evmConverted {
+		return nil
+	}
+	srcEvmDB, dstEvmDB, err := loadDBs(convertEvmDB)
+	if err != nil {
+		return err
+	}
+	defer closeDBs(srcEvmDB, dstEvmDB)
+	if err := compareEvmRoots(srcEvmDB, dstEvmDB); err != nil {
+		return err
+	}
+	// evm.db is only tied to app.db by the Multi-Writer App Store
+	if appStoreVersion == 3 {
+		evmStore := store.NewEvmStore(dstEvmDB, 1)
+		if err := evmStore.LoadVersion(dstAppStore.Version()); err != nil {
+			return err
+		}
+		if _, err := store.NewMultiWriterAppStore(dstAppStore, evmStore, false); err != nil {
+			return err
+		}
+	}
+	fmt.Println("Verified EVM roots")
+	return nil
+}
+
+// compareEvmRoots checks that both evm.db instances contain the same versioned Patricia roots.
+func compareEvmRoots(srcDB, dstDB cdb.DBWrapper) error {
+	start, end := store.EvmRootKeyRange()
+	srcIter := srcDB.Iterator(start, end)
+	defer srcIter.Close()
+	dstIter := dstDB.Iterator(start, end)
+	defer dstIter.Close()
+
+	for ; srcIter.Valid(); srcIter.Next() {
+		_, version := store.IsEvmRootKey(srcIter.Key())
+		if !dstIter.Valid() || !bytes.Equal(srcIter.Key(), dstIter.Key()) {
+			return fmt.Errorf("EVM root at height %d is missing", version)
+		}
+		if !bytes.Equal(srcIter.Value(), dstIter.Value()) {
+			return fmt.Errorf(
+				"EVM root mismatch at height %d, expected %X, got %X",
+				version, srcIter.Value(), dstIter.Value(),
+			)
+		}
+		dstIter.Next()
+	}
+	if dstIter.Valid() {
+		_, version := store.IsEvmRootKey(dstIter.Key())
+		return fmt.Errorf("unexpected EVM root at height %d", version)
+	}
+	return nil
+}
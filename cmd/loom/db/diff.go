@@ -0,0 +1,352 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"path/filepath"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/cmd/loom/common"
+	"github.com/loomnetwork/loomchain/config"
+	cdb "github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/evm"
+	registry "github.com/loomnetwork/loomchain/registry/factory"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+const (
+	// Maximum number of bytes of each value to print
+	maxDiffValueLen = 64
+	// Length of the local part of contract addresses
+	contractAddrLen = 20
+)
+
+// Prefix of the keys of Go contract state, must match loom.DataPrefix
+var contractDataPrefix = []byte("contract")
+
+func newDiffDBCommand() *cobra.Command {
+	var leftHeight, rightHeight int64
+	var chainID string
+	var skipEVM bool
+	cmd := &cobra.Command{
+		Use:   "diff <path/to/left/node> [path/to/right/node]",
+		Short: "Shows the differences between the app state of two nodes, or two heights of one node",
+		Long: "Compares app.db & evm.db of two nodes at the given heights and prints the keys that differ. " +
+			"If only one node dir is specified two heights of the same node are compared. " +
+			"The nodes must be stopped while their DBs are being compared.",
+		Example: "loom db diff nodes/0 nodes/1 --left-height 100 --right-height 100\n" +
+			"loom db diff nodes/0 --left-height 99 --right-height 100",
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+			leftDir, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			rightDir := leftDir
+			if len(args) > 1 {
+				if rightDir, err = filepath.Abs(args[1]); err != nil {
+					return err
+				}
+			}
+			if leftDir == rightDir && leftHeight == rightHeight {
+				return errors.New("specify two different node dirs, or two different heights")
+			}
+			// each node may use different DB settings, so load the config of each node if it has one
+			leftCfg, err := loadNodeConfig(leftDir, cfg)
+			if err != nil {
+				return errors.Wrapf(err, "failed to load config from %s", leftDir)
+			}
+			rightCfg := leftCfg
+			if rightDir != leftDir {
+				if rightCfg, err = loadNodeConfig(rightDir, cfg); err != nil {
+					return errors.Wrapf(err, "failed to load config from %s", rightDir)
+				}
+			}
+			d := &stateDiffer{
+				leftCfg:  leftCfg,
+				rightCfg: rightCfg,
+				chainID:  chainID,
+				diffEVM: !skipEVM && leftCfg.AppStore.Version == 3 &&
+					rightCfg.AppStore.Version == 3,
+				leftDir:     leftDir,
+				rightDir:    rightDir,
+				leftHeight:  leftHeight,
+				rightHeight: rightHeight,
+				contracts:   map[string]string{},
+			}
+			return d.run()
+		},
+	}
+	flags := cmd.Flags()
+	flags.Int64Var(&leftHeight, "left-height", 0, "Height of the left state (defaults to the latest height)")
+	flags.Int64Var(&rightHeight, "right-height", 0, "Height of the right state (defaults to the latest height)")
+	flags.StringVar(&chainID, "chain-id", "default", "Chain ID, used to look up contract names")
+	flags.BoolVar(&skipEVM, "skip-evm", false, "Don't compare the EVM state in evm.db")
+	return cmd
+}
+
+type stateDiffer struct {
+	leftCfg     *config.Config
+	rightCfg    *config.Config
+	chainID     string
+	diffEVM     bool
+	leftDir     string
+	rightDir    string
+	leftHeight  int64
+	rightHeight int64
+	// contract address -> contract name
+	contracts map[string]string
+	// registry state used to look up contract names
+	registryState loomchain.State
+}
+
+func (d *stateDiffer) run() error {
+	leftAppDB, rightAppDB, err := d.loadDBs(func(cfg *config.Config) (string, string, int) {
+		return cfg.DBBackend, cfg.DBName, cfg.DBBackendConfig.CacheSizeMegs
+	})
+	if err != nil {
+		return err
+	}
+	defer closeDiffDBs(leftAppDB, rightAppDB)
+
+	leftAppStore, err := store.NewIAVLStore(leftAppDB, 0, 0, -1)
+	if err != nil {
+		return err
+	}
+	rightAppStore := leftAppStore
+	if rightAppDB != leftAppDB {
+		if rightAppStore, err = store.NewIAVLStore(rightAppDB, 0, 0, -1); err != nil {
+			return err
+		}
+	}
+	if d.leftHeight == 0 {
+		d.leftHeight = leftAppStore.Version()
+	}
+	if d.rightHeight == 0 {
+		d.rightHeight = rightAppStore.Version()
+	}
+
+	snap, err := leftAppStore.GetSnapshotAt(d.leftHeight)
+	if err != nil {
+		return err
+	}
+	defer snap.Release()
+	d.registryState = loomchain.NewStoreStateSnapshot(
+		context.Background(), snap, abci.Header{Height: d.leftHeight}, nil, nil,
+	)
+
+	fmt.Printf(
+		"Comparing %s at height %d with %s at height %d\n",
+		d.leftDir, d.leftHeight, d.rightDir, d.rightHeight,
+	)
+	numKeys := 0
+	onDiff := func(diff *store.KeyDiff) {
+		numKeys++
+		fmt.Printf(
+			"%s\n  left:  %s\n  right: %s\n",
+			d.describeAppKey(diff.Key), formatDiffValue(diff.Left), formatDiffValue(diff.Right),
+		)
+	}
+	err = store.DiffIAVLStores(leftAppStore, d.leftHeight, rightAppStore, d.rightHeight, onDiff)
+	if err != nil {
+		return errors.Wrap(err, "failed to compare app.db")
+	}
+	fmt.Printf("Found %d differing keys in app.db\n", numKeys)
+
+	if !d.diffEVM {
+		return nil
+	}
+	return d.diffEVMState()
+}
+
+func (d *stateDiffer) diffEVMState() error {
+	leftEvmDB, rightEvmDB, err := d.loadDBs(func(cfg *config.Config) (string, string, int) {
+		return cfg.EvmStore.DBBackend, cfg.EvmStore.DBName, cfg.EvmStore.CacheSizeMegs
+	})
+	if err != nil {
+		return err
+	}
+	defer closeDiffDBs(leftEvmDB, rightEvmDB)
+
+	leftRoot, err := store.NewEvmStore(leftEvmDB, 1).GetRootAt(d.leftHeight)
+	if err != nil {
+		return err
+	}
+	rightRoot, err := store.NewEvmStore(rightEvmDB, 1).GetRootAt(d.rightHeight)
+	if err != nil {
+		return err
+	}
+	leftSnap := leftEvmDB.GetSnapshot()
+	defer leftSnap.Release()
+	rightSnap := rightEvmDB.GetSnapshot()
+	defer rightSnap.Release()
+
+	numAccounts := 0
+	err = evm.DiffEvmState(leftSnap, leftRoot, rightSnap, rightRoot, func(diff *evm.AccountDiff) {
+		numAccounts++
+		printAccountDiff(diff)
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to compare evm.db")
+	}
+	fmt.Printf("Found %d differing EVM accounts (EVM root %X vs %X)\n", numAccounts, leftRoot, rightRoot)
+	return nil
+}
+
+// loadDBs loads the left & right instances of a DB, if both nodes are the same the DB is only
+// loaded once. The backend, name & cache size of the DB are taken from each node's config.
+func (d *stateDiffer) loadDBs(
+	dbConfig func(cfg *config.Config) (backend, name string, cacheSizeMegs int),
+) (cdb.DBWrapper, cdb.DBWrapper, error) {
+	backend, name, cacheSizeMegs := dbConfig(d.leftCfg)
+	leftDB, err := cdb.LoadDB(backend, name, d.leftDir, cacheSizeMegs, 0, false)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to load %s from %s", name, d.leftDir)
+	}
+	if d.rightDir == d.leftDir {
+		return leftDB, leftDB, nil
+	}
+	backend, name, cacheSizeMegs = dbConfig(d.rightCfg)
+	rightDB, err := cdb.LoadDB(backend, name, d.rightDir, cacheSizeMegs, 0, false)
+	if err != nil {
+		leftDB.Close()
+		return nil, nil, errors.Wrapf(err, "failed to load %s from %s", name, d.rightDir)
+	}
+	return leftDB, rightDB, nil
+}
+
+// loadNodeConfig loads the loom.yml in the given node dir, or returns the default config if the
+// node dir doesn't contain one.
+func loadNodeConfig(dir string, defaultCfg *config.Config) (*config.Config, error) {
+	v := viper.New()
+	v.SetConfigName("loom")
+	v.AddConfigPath(dir)
+	v.AddConfigPath(filepath.Join(dir, "config"))
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
+			return defaultCfg, nil
+		}
+		return nil, err
+	}
+	cfg := config.DefaultConfig()
+	if err := v.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func closeDiffDBs(leftDB, rightDB cdb.DBWrapper) {
+	leftDB.Close()
+	if rightDB != leftDB {
+		rightDB.Close()
+	}
+}
+
+// describeAppKey returns a human readable description of an app.db key, keys that belong to
+// Go contracts are attributed to the contract that owns them.
+func (d *stateDiffer) describeAppKey(key []byte) string {
+	prefixLen := len(contractDataPrefix) + 1
+	if util.HasPrefix(key, contractDataPrefix) && len(key) >= prefixLen+contractAddrLen {
+		addr := loom.Address{
+			ChainID: d.chainID,
+			Local:   key[prefixLen : prefixLen+contractAddrLen],
+		}
+		if stateKey, err := util.UnprefixKey(key, loom.DataPrefix(addr)); err == nil {
+			return fmt.Sprintf(
+				"contract %s (%s) key %s", d.contractName(addr), addr.Local.String(), formatDiffKey(stateKey),
+			)
+		}
+	}
+	return "key " + formatDiffKey(key)
+}
+
+// contractName looks up the name of the contract with the given address in the registry.
+func (d *stateDiffer) contractName(addr loom.Address) string {
+	if name, ok := d.contracts[addr.String()]; ok {
+		return name
+	}
+	name := "<unknown>"
+	regVer, err := registry.RegistryVersionFromInt(d.leftCfg.RegistryVersion)
+	if err == nil {
+		createRegistry, err := registry.NewRegistryFactory(regVer)
+		if err == nil {
+			if record, err := createRegistry(d.registryState).GetRecord(addr); err == nil && record.Name != "" {
+				name = record.Name
+			}
+		}
+	}
+	d.contracts[addr.String()] = name
+	return name
+}
+
+func printAccountDiff(diff *evm.AccountDiff) {
+	addr := "<unknown>"
+	if diff.Address != nil {
+		addr = "0x" + hex.EncodeToString(diff.Address)
+	}
+	fmt.Printf("EVM account %s (hash 0x%x)\n", addr, diff.AddressHash)
+	switch {
+	case diff.Left == nil:
+		fmt.Println("  only exists in right state")
+	case diff.Right == nil:
+		fmt.Println("  only exists in left state")
+	}
+	if diff.Left != nil && diff.Right != nil {
+		if diff.Left.Nonce != diff.Right.Nonce {
+			fmt.Printf("  nonce: %d vs %d\n", diff.Left.Nonce, diff.Right.Nonce)
+		}
+		if diff.Left.Balance.Cmp(diff.Right.Balance) != 0 {
+			fmt.Printf("  balance: %s vs %s\n", diff.Left.Balance, diff.Right.Balance)
+		}
+		if !bytes.Equal(diff.Left.CodeHash, diff.Right.CodeHash) {
+			fmt.Printf("  code hash: 0x%x vs 0x%x\n", diff.Left.CodeHash, diff.Right.CodeHash)
+		}
+		if !bytes.Equal(diff.Left.StorageRoot, diff.Right.StorageRoot) {
+			fmt.Printf("  storage root: 0x%x vs 0x%x\n", diff.Left.StorageRoot, diff.Right.StorageRoot)
+		}
+	}
+	for _, slot := range diff.Storage {
+		key := fmt.Sprintf("<unknown> (hash 0x%x)", slot.KeyHash)
+		if slot.Key != nil {
+			key = "0x" + hex.EncodeToString(slot.Key)
+		}
+		fmt.Printf("  storage slot %s: %s vs %s\n", key, formatDiffValue(slot.Left), formatDiffValue(slot.Right))
+	}
+}
+
+// formatDiffKey prints the leading printable part of a key (which is usually a prefix) as text,
+// and the rest of the key as hex.
+func formatDiffKey(key []byte) string {
+	i := 0
+	for ; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			break
+		}
+	}
+	if i == len(key) {
+		return fmt.Sprintf("%q", key)
+	}
+	return fmt.Sprintf("%q + 0x%x", key[:i], key[i:])
+}
+
+func formatDiffValue(value []byte) string {
+	if value == nil {
+		return "<missing>"
+	}
+	if len(value) > maxDiffValueLen {
+		return fmt.Sprintf("0x%x... (%d bytes)", value[:maxDiffValueLen], len(value))
+	}
+	return fmt.Sprintf("0x%x", value)
+}
@@ -11,6 +11,7 @@ import (
 	"strings"
 
 	gcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	gstate "github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/rlp"
 	"github.com/ethereum/go-ethereum/trie"
@@ -285,7 +286,7 @@ func newDumpEVMStateFromEvmDB() *cobra.Command {
 				nil,
 			)
 
-			srcStateDB := gstate.NewDatabase(evm.NewLoomEthdb(state, nil))
+			srcStateDB := gstate.NewDatabase(rawdb.NewDatabase(evm.NewLoomEthdb(state, nil)))
 			srcStateDBTrie, err := srcStateDB.OpenTrie(evmRoot)
 			if err != nil {
 				fmt.Printf("cannot open trie, %s\n", evmRoot.Hex())
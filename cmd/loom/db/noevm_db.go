@@ -14,6 +14,9 @@ func NewDBCommand() *cobra.Command {
 	cmd.AddCommand(
 		newPruneDBCommand(),
 		newCompactDBCommand(),
+		newSnapshotCommand(),
+		newConvertDBCommand(),
+		newDiffDBCommand(),
 	)
 	return cmd
 }
@@ -0,0 +1,169 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/cmd/loom/common"
+	"github.com/loomnetwork/loomchain/config"
+	cdb "github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/loomnetwork/loomchain/store/snapshot"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+func newSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "snapshot",
+		Short: "Export & import snapshots of app.db & evm.db",
+	}
+	cmd.AddCommand(
+		newExportSnapshotCommand(),
+		newImportSnapshotCommand(),
+		newListSnapshotsCommand(),
+	)
+	return cmd
+}
+
+func newExportSnapshotCommand() *cobra.Command {
+	var outDir string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Exports a snapshot of app.db & evm.db at the last committed height",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+			if outDir == "" {
+				outDir = filepath.Join(cfg.RootPath(), cfg.StateSnapshot.Dir)
+			}
+
+			appDB, evmDB, err := loadSnapshotDBs(cfg)
+			if err != nil {
+				return err
+			}
+			defer appDB.Close()
+			defer evmDB.Close()
+
+			iavlStore, err := store.NewIAVLStore(appDB, 0, 0, -1)
+			if err != nil {
+				return err
+			}
+			appSnap := appDB.GetSnapshot()
+			defer appSnap.Release()
+			evmSnap := evmDB.GetSnapshot()
+			defer evmSnap.Release()
+
+			m, err := snapshot.Export(
+				outDir, iavlStore.Version(), iavlStore.Hash(), appSnap, evmSnap,
+				cfg.StateSnapshot.ChunkSizeMegs*1024*1024,
+			)
+			if err != nil {
+				return err
+			}
+			fmt.Printf(
+				"Exported snapshot at height %d (app hash %s) in %d chunks to %s\n",
+				m.Height, m.AppHash, len(m.Chunks), outDir,
+			)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&outDir, "out", "", "Directory to write the snapshot to (defaults to StateSnapshot.Dir)")
+	return cmd
+}
+
+func newImportSnapshotCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "import <path/to/snapshot>",
+		Short:   "Restores app.db & evm.db from a snapshot",
+		Example: "loom db snapshot import snapshots/000000100000",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+			m, err := ImportSnapshot(cfg, args[0])
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Restored app.db & evm.db at height %d (app hash %s)\n", m.Height, m.AppHash)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func newListSnapshotsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Lists the snapshots in StateSnapshot.Dir",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := common.ParseConfig()
+			if err != nil {
+				return err
+			}
+			rootDir := filepath.Join(cfg.RootPath(), cfg.StateSnapshot.Dir)
+			heights, err := snapshot.ListSnapshots(rootDir)
+			if err != nil {
+				return err
+			}
+			for _, height := range heights {
+				fmt.Println(height)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
+
+// ImportSnapshot restores app.db & evm.db from the snapshot stored in the given directory.
+// Neither of the DBs should exist prior to calling this function. If the snapshot is found to be
+// invalid the partially restored DBs are deleted.
+func ImportSnapshot(cfg *config.Config, snapshotDir string) (*snapshot.Manifest, error) {
+	dbNames := []string{cfg.DBName, cfg.EvmStore.DBName}
+	for _, dbName := range dbNames {
+		dbPath := filepath.Join(cfg.RootPath(), dbName+".db")
+		if util.FileExists(dbPath) {
+			return nil, fmt.Errorf("%s already exists", dbPath)
+		}
+	}
+
+	appDB, evmDB, err := loadSnapshotDBs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	m, err := snapshot.Import(snapshotDir, appDB, evmDB)
+	appDB.Close()
+	evmDB.Close()
+	if err != nil {
+		for _, dbName := range dbNames {
+			os.RemoveAll(filepath.Join(cfg.RootPath(), dbName+".db"))
+		}
+		return nil, errors.Wrapf(err, "failed to import snapshot from %s", snapshotDir)
+	}
+	return m, nil
+}
+
+func loadSnapshotDBs(cfg *config.Config) (cdb.DBWrapper, cdb.DBWrapper, error) {
+	appDB, err := cdb.LoadDB(
+		cfg.DBBackend, cfg.DBName, cfg.RootPath(),
+		cfg.DBBackendConfig.CacheSizeMegs, cfg.DBBackendConfig.WriteBufferMegs, false,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	evmDB, err := cdb.LoadDB(
+		cfg.EvmStore.DBBackend, cfg.EvmStore.DBName, cfg.RootPath(),
+		cfg.EvmStore.CacheSizeMegs, cfg.EvmStore.WriteBufferMegs, false,
+	)
+	if err != nil {
+		appDB.Close()
+		return nil, nil, err
+	}
+	return appDB, evmDB, nil
+}
@@ -32,6 +32,7 @@ import (
 	"github.com/loomnetwork/loomchain"
 	"github.com/loomnetwork/loomchain/abci/backend"
 	"github.com/loomnetwork/loomchain/auth"
+	"github.com/loomnetwork/loomchain/builtin/plugins/address_mapper"
 	"github.com/loomnetwork/loomchain/builtin/plugins/dposv2"
 	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
 	plasmaConfig "github.com/loomnetwork/loomchain/builtin/plugins/plasma_cash/config"
@@ -54,6 +55,7 @@ import (
 	"github.com/loomnetwork/loomchain/eth/polls"
 	"github.com/loomnetwork/loomchain/events"
 	"github.com/loomnetwork/loomchain/evm"
+	"github.com/loomnetwork/loomchain/fees"
 	"github.com/loomnetwork/loomchain/fnConsensus"
 	karma_handler "github.com/loomnetwork/loomchain/karma"
 	"github.com/loomnetwork/loomchain/log"
@@ -67,6 +69,7 @@ import (
 	"github.com/loomnetwork/loomchain/store"
 	blockindex "github.com/loomnetwork/loomchain/store/block_index"
 	evmaux "github.com/loomnetwork/loomchain/store/evm_aux"
+	"github.com/loomnetwork/loomchain/store/snapshot"
 	"github.com/loomnetwork/loomchain/throttle"
 	"github.com/loomnetwork/loomchain/tx_handler"
 	"github.com/loomnetwork/loomchain/vm"
@@ -76,6 +79,11 @@ import (
 	"golang.org/x/crypto/ed25519"
 )
 
+const (
+	// Name of the file the commit journal is stored in, relative to the root dir of the node.
+	commitJournalFilename = "commit.journal"
+)
+
 var (
 	appHeightKey = []byte("appheight")
 	configKey    = []byte("config")
@@ -332,6 +340,7 @@ func newNodeKeyCommand() *cobra.Command {
 func newRunCommand() *cobra.Command {
 	var abciServerAddr string
 	var appHeight int64
+	var restoreSnapshotDir string
 
 	cfg, err := common.ParseConfig()
 	cmd := &cobra.Command{
@@ -387,6 +396,32 @@ func newRunCommand() *cobra.Command {
 				return err
 			}
 
+			// Restore app.db & evm.db from a snapshot before loading the app, the node will then
+			// resume from the snapshot height. Snapshots don't contain any Tendermint chain data,
+			// so the blocks (and Tendermint state) up to at least the snapshot height must be copied
+			// into chaindata/data from another node beforehand. Tendermint will then only replay the
+			// blocks above the snapshot height through the app.
+			if restoreSnapshotDir != "" {
+				m, err := snapshot.LoadManifest(restoreSnapshotDir)
+				if err != nil {
+					return err
+				}
+				tmHeight, err := backend.LastBlockHeight()
+				if err != nil {
+					return errors.Wrap(err, "failed to load Tendermint state")
+				}
+				if tmHeight < m.Height {
+					return fmt.Errorf(
+						"Tendermint chain data is at height %d, but must be at or above the snapshot height %d",
+						tmHeight, m.Height,
+					)
+				}
+				if _, err := dbcmd.ImportSnapshot(cfg, restoreSnapshotDir); err != nil {
+					return err
+				}
+				log.Info("Restored state snapshot", "height", m.Height, "appHash", m.AppHash)
+			}
+
 			// Load app height from app.db
 			appDB, err := cdb.LoadDB(
 				cfg.DBBackend, cfg.DBName, cfg.RootPath(), cfg.DBBackendConfig.CacheSizeMegs,
@@ -415,7 +450,8 @@ func newRunCommand() *cobra.Command {
 				return err
 			}
 
-			if err := initQueryService(app, chainID, cfg, loader, app.ReceiptHandlerProvider); err != nil {
+			mempool := store.NewTendermintMempool(backend.Mempool())
+			if err := initQueryService(app, chainID, cfg, loader, app.ReceiptHandlerProvider, mempool); err != nil {
 				return err
 			}
 
@@ -443,6 +479,11 @@ func newRunCommand() *cobra.Command {
 	cmd.Flags().StringVar(&cfg.PersistentPeers, "persistent-peers", "", "persistent peers")
 	cmd.Flags().StringVar(&abciServerAddr, "abci-server", "", "Serve ABCI app at specified address")
 	cmd.Flags().Int64Var(&appHeight, "app-height", 0, "Start at the given block instead of the last block saved")
+	cmd.Flags().StringVar(
+		&restoreSnapshotDir, "restore-snapshot", "",
+		"Restore app.db & evm.db from the snapshot in the given directory before starting the node, "+
+			"chaindata/data must contain the Tendermint chain data up to at least the snapshot height",
+	)
 	return cmd
 }
 
@@ -607,12 +648,16 @@ func destroyBlockIndexDB(cfg *config.Config) error {
 	return nil
 }
 
-func loadAppStore(cfg *config.Config, logger *loom.Logger, targetVersion int64) (store.VersionedKVStore, error) {
+// loadAppStore loads the app store, and if state snapshots are enabled creates a snapshot manager
+// for the underlying DBs.
+func loadAppStore(
+	cfg *config.Config, logger *loom.Logger, targetVersion int64, lastCommit store.CommitJournalEntry,
+) (store.VersionedKVStore, *snapshot.Manager, error) {
 	db, err := cdb.LoadDB(
 		cfg.DBBackend, cfg.DBName, cfg.RootPath(), cfg.DBBackendConfig.CacheSizeMegs, cfg.DBBackendConfig.WriteBufferMegs, cfg.Metrics.Database,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	if cfg.AppStore.CompactOnLoad {
@@ -625,61 +670,117 @@ func loadAppStore(cfg *config.Config, logger *loom.Logger, targetVersion int64)
 		logger.Info("Finished compacting app store")
 	}
 
+	maxVersions := cfg.AppStore.MaxVersions
+	flushInterval := cfg.AppStore.IAVLFlushInterval
+	if cfg.AppStore.ArchiveMode {
+		// every version must be persisted & retained in order to serve historical state queries
+		maxVersions = 0
+		flushInterval = -1
+		logger.Info("App store archive mode enabled")
+	}
+
 	var appStore store.VersionedKVStore
+	var snapshotManager *snapshot.Manager
 	if cfg.AppStore.Version == 1 { // TODO: cleanup these hardcoded numbers
-		if cfg.AppStore.PruneInterval > int64(0) {
+		if cfg.StateSnapshot.Enabled {
+			return nil, nil, errors.New("StateSnapshot requires AppStore.Version 3")
+		}
+		if cfg.AppStore.PruneInterval > int64(0) && !cfg.AppStore.ArchiveMode {
 			logger.Info("Loading Pruning IAVL Store")
 			appStore, err = store.NewPruningIAVLStore(db, store.PruningIAVLStoreConfig{
-				MaxVersions:   cfg.AppStore.MaxVersions,
+				MaxVersions:   maxVersions,
 				BatchSize:     cfg.AppStore.PruneBatchSize,
 				Interval:      time.Duration(cfg.AppStore.PruneInterval) * time.Second,
 				Logger:        logger,
-				FlushInterval: cfg.AppStore.IAVLFlushInterval,
+				FlushInterval: flushInterval,
 			})
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		} else {
 			logger.Info("Loading IAVL Store")
-			appStore, err = store.NewIAVLStore(db, cfg.AppStore.MaxVersions, targetVersion, cfg.AppStore.IAVLFlushInterval)
+			appStore, err = store.NewIAVLStore(db, maxVersions, targetVersion, flushInterval)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 		}
 	} else if cfg.AppStore.Version == 3 {
 		logger.Info("Loading Multi-Writer App Store")
-		iavlStore, err := store.NewIAVLStore(db, cfg.AppStore.MaxVersions, targetVersion, cfg.AppStore.IAVLFlushInterval)
+		iavlStore, err := store.NewIAVLStore(db, maxVersions, targetVersion, flushInterval)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		evmStore, err := loadEvmStore(cfg, iavlStore.Version())
+		evmStore, evmDB, err := loadEvmStore(cfg, iavlStore.Version(), lastCommit)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if cfg.EvmStore.MaxVersions > 0 && !cfg.AppStore.ArchiveMode && evm.EVMEnabled {
+			evmFlushInterval := flushInterval
+			if evmFlushInterval == 0 {
+				// the flush interval is set by the on-chain config
+				onChainCfg, err := store.LoadOnChainConfig(iavlStore)
+				if err != nil {
+					return nil, nil, err
+				}
+				evmFlushInterval = int64(onChainCfg.GetAppStore().GetIAVLFlushInterval())
+			}
+			pruner, err := store.NewEvmStorePruner(evmStore, evm.WalkEvmState, store.EvmStorePrunerConfig{
+				MaxVersions:   cfg.EvmStore.MaxVersions,
+				BatchSize:     cfg.EvmStore.PruneBatchSize,
+				MaxMarkedKeys: cfg.EvmStore.PruneMaxMarkedKeys,
+				FlushInterval: evmFlushInterval,
+				Interval:      time.Duration(cfg.EvmStore.PruneInterval) * time.Second,
+				Logger:        logger,
+			})
+			if err != nil {
+				return nil, nil, err
+			}
+			pruner.Start()
+			logger.Info("EVM store pruning enabled", "maxVersions", cfg.EvmStore.MaxVersions)
 		}
 		appStore, err = store.NewMultiWriterAppStore(iavlStore, evmStore, cfg.AppStore.SaveEVMStateToIAVL)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if cfg.StateSnapshot.Enabled {
+			snapshotManager, err = snapshot.NewManager(
+				cfg.StateSnapshot, flushInterval, iavlStore.LastFlushedVersion,
+				filepath.Join(cfg.RootPath(), cfg.StateSnapshot.Dir), db, evmDB, logger,
+			)
+			if err != nil {
+				return nil, nil, err
+			}
+			logger.Info("State snapshots enabled", "interval", cfg.StateSnapshot.Interval)
 		}
 	} else {
-		return nil, errors.New("Invalid AppStore.Version config setting")
+		return nil, nil, errors.New("Invalid AppStore.Version config setting")
 	}
 
 	if cfg.LogStateDB {
 		appStore, err = store.NewLogStore(appStore)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 	}
 
 	if cfg.CachingStoreConfig.CachingEnabled {
 		appStore, err = store.NewVersionedCachingStore(appStore, cfg.CachingStoreConfig, appStore.Version())
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		logger.Info("VersionedCachingStore enabled")
 	}
 
-	return appStore, nil
+	if cfg.StateCache.Enabled {
+		stateCache, err := store.NewStateCache(cfg.StateCache, appStore.Version())
+		if err != nil {
+			return nil, nil, err
+		}
+		appStore = store.NewStateCacheInvalidator(appStore, stateCache)
+		logger.Info("StateCache enabled", "maxKeys", cfg.StateCache.MaxKeys)
+	}
+
+	return appStore, snapshotManager, nil
 }
 
 func loadEventStore(cfg *config.Config, logger *loom.Logger) (store.EventStore, error) {
@@ -697,7 +798,9 @@ func loadEventStore(cfg *config.Config, logger *loom.Logger) (store.EventStore,
 	return eventStore, nil
 }
 
-func loadEvmStore(cfg *config.Config, targetVersion int64) (*store.EvmStore, error) {
+func loadEvmStore(
+	cfg *config.Config, targetVersion int64, lastCommit store.CommitJournalEntry,
+) (*store.EvmStore, cdb.DBWrapper, error) {
 	evmStoreCfg := cfg.EvmStore
 	db, err := cdb.LoadDB(
 		evmStoreCfg.DBBackend,
@@ -708,13 +811,87 @@ func loadEvmStore(cfg *config.Config, targetVersion int64) (*store.EvmStore, err
 		cfg.Metrics.Database,
 	)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	evmStore := store.NewEvmStore(db, evmStoreCfg.NumCachedRoots)
 	if err := evmStore.LoadVersion(targetVersion); err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	// If the node crashed after evm.db was committed, but before app.db was, evm.db will contain
+	// roots that app.db doesn't know about.
+	if lastCommit.ReachedAbove(targetVersion, store.CommitPhaseAppStore) {
+		numRoots, err := evmStore.Rollback(targetVersion)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to roll back evm.db")
+		}
+		if numRoots > 0 {
+			log.Error("evm.db was ahead of app.db, rolled back EVM roots", "height", targetVersion, "count", numRoots)
+		}
+	}
+	return evmStore, db, nil
+}
+
+// repairStores uses the commit journal to bring the DBs that are written to separately from app.db
+// back in line with app.db if the node stopped while committing a block. Only the DBs the
+// interrupted commit may have reached are rolled back.
+func repairStores(
+	journal *store.CommitJournal,
+	appHeight int64,
+	receiptStore loomchain.ReceiptHandlerStore,
+	evmAuxStore *evmaux.EvmAuxStore,
+	eventStore store.EventStore,
+	blockIndexStore blockindex.BlockIndexStore,
+	logger *loom.Logger,
+) error {
+	last := journal.Last()
+	if journal.Interrupted() {
+		logger.Error(
+			"Node stopped while committing a block",
+			"height", last.Height, "phase", last.Phase, "appHeight", appHeight,
+		)
+		// The block index is updated after app.db, so if app.db contains the block the index may not.
+		if appHeight >= last.Height && blockIndexStore != nil && len(last.BlockHash) > 0 {
+			blockIndexStore.SetBlockHashAtHeight(uint64(last.Height), last.BlockHash)
+		}
 	}
-	return evmStore, nil
+
+	// The receipts are committed before app.db, so if app.db doesn't contain a block the receipts
+	// of that block have to be rolled back, otherwise they'll be committed twice when the block is
+	// replayed.
+	if last.ReachedAbove(appHeight, store.CommitPhaseReceipts) {
+		receiptsHeight := receiptStore.LastCommittedHeight()
+		if receiptsHeight > appHeight {
+			logger.Error("Receipts DB is ahead of app.db", "receiptsHeight", receiptsHeight, "appHeight", appHeight)
+			if err := receiptStore.Rollback(appHeight); err != nil {
+				// Not fatal, the receipts of the replayed blocks may just end up being duplicated.
+				logger.Error("Failed to roll back receipts", "height", appHeight, "err", err)
+			} else {
+				logger.Info("Rolled back receipts", "height", appHeight)
+			}
+		}
+	}
+
+	// The tx hash refs are saved just before app.db, only the refs of the last block are journaled,
+	// the refs of any earlier blocks will be overwritten with the same values when they're replayed.
+	if last.Height > appHeight && last.Phase >= store.CommitPhaseAppStore && evmAuxStore != nil {
+		evmAuxStore.DeleteChildTxRefs(last.TxRefs)
+		logger.Info("Rolled back tx hash refs", "height", last.Height, "count", len(last.TxRefs))
+	}
+
+	// Events are written to the event store once a block is fully committed.
+	if last.ReachedAbove(appHeight, store.CommitPhaseDone) && eventStore != nil {
+		if err := eventStore.RollbackEvents(uint64(appHeight)); err != nil {
+			return errors.Wrap(err, "failed to roll back event store")
+		}
+		logger.Info("Rolled back events", "height", appHeight)
+	}
+
+	if journal.Interrupted() || last.Height != appHeight {
+		if err := journal.Reset(appHeight); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func loadApp(
@@ -726,7 +903,12 @@ func loadApp(
 ) (*loomchain.Application, error) {
 	logger := log.Root
 
-	appStore, err := loadAppStore(cfg, log.Default, appHeight)
+	commitJournal, err := store.OpenCommitJournal(filepath.Join(cfg.RootPath(), commitJournalFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	appStore, snapshotManager, err := loadAppStore(cfg, log.Default, appHeight, commitJournal.Last())
 
 	if err != nil {
 		return nil, err
@@ -788,7 +970,7 @@ func loadApp(
 	}
 
 	// load EVM Auxiliary Store
-	evmAuxStore, err := evmaux.LoadStore()
+	evmAuxStore, err := evmaux.LoadStore(cfg.DBBackendConfig.EvmAuxDBBackend)
 	if err != nil {
 		return nil, err
 	}
@@ -815,6 +997,9 @@ func loadApp(
 	})
 
 	if evm.EVMEnabled {
+		evm.AddLoomPrecompiles(func(state loomchain.State, contractName string) (contractpb.StaticContext, error) {
+			return getContractStaticCtx(contractName, vmManager)(state)
+		})
 		vmManager.Register(vm.VMType_EVM, func(state loomchain.State) (vm.VM, error) {
 			var createABM evm.AccountBalanceManagerFactoryFunc
 			var err error
@@ -879,7 +1064,6 @@ func loadApp(
 		state.Set(configKey, configBytes)
 
 		registry := createRegistry(state)
-		evm.AddLoomPrecompiles()
 		for i, contractCfg := range gen.Contracts {
 			err := deployContract(
 				state,
@@ -1047,6 +1231,11 @@ func loadApp(
 
 	nonceTxHandler := auth.NewNonceHandler()
 	txMiddleWare = append(txMiddleWare, nonceTxHandler.TxMiddleware(appStore))
+	txMiddleWare = append(txMiddleWare, fees.NewTxFeeMiddleware(
+		appStore,
+		getFeeBalanceManagerFactory(vmManager),
+		getContractCtx("dposV3", vmManager),
+	))
 
 	if cfg.GoContractDeployerWhitelist.Enabled {
 		goDeployers, err := cfg.GoContractDeployerWhitelist.DeployerAddresses(chainID)
@@ -1073,6 +1262,30 @@ func loadApp(
 		return plugin.NewNoopValidatorsManager(), nil
 	}
 
+	// COINBASE returns the Ethereum account the block proposer mapped to its validator account via
+	// the Address Mapper, or the local address of the validator if it hasn't mapped an account.
+	resolveBlockProposer := func(state loomchain.State, proposer loom.Address) (loom.LocalAddress, error) {
+		ctx, err := getContractStaticCtx("addressmapper", vmManager)(state)
+		if err == regcommon.ErrNotFound {
+			return proposer.Local, nil
+		} else if err != nil {
+			return nil, err
+		}
+		am := &address_mapper.AddressMapper{}
+		hasResp, err := am.HasMapping(ctx, &address_mapper.HasMappingRequest{From: proposer.MarshalPB()})
+		if err != nil {
+			return nil, err
+		}
+		if !hasResp.HasMapping {
+			return proposer.Local, nil
+		}
+		resp, err := am.GetMapping(ctx, &address_mapper.GetMappingRequest{From: proposer.MarshalPB()})
+		if err != nil {
+			return nil, err
+		}
+		return loom.UnmarshalAddressPB(resp.To).Local, nil
+	}
+
 	createChainConfigManager := func(state loomchain.State) (loomchain.ChainConfigManager, error) {
 		if !cfg.ChainConfig.ContractEnabled {
 			return nil, nil
@@ -1112,11 +1325,19 @@ func loadApp(
 		}
 	}
 
+	err = repairStores(
+		commitJournal, appStore.Version(), receiptHandlerProvider.Store(), evmAuxStore, eventStore,
+		blockIndexStore, log.Default,
+	)
+	if err != nil {
+		return nil, err
+	}
+
 	// We need to make sure nonce post commit middleware is last
 	// as it doesn't pass control to other middlewares after it.
 	postCommitMiddlewares = append(postCommitMiddlewares, nonceTxHandler.PostCommitMiddleware())
 
-	return &loomchain.Application{
+	app := &loomchain.Application{
 		Store: appStore,
 		Init:  init,
 		TxHandler: loomchain.MiddlewareTxHandler(
@@ -1134,7 +1355,13 @@ func loadApp(
 		GetValidatorSet:             getValidatorSet,
 		EvmAuxStore:                 evmAuxStore,
 		ReceiptsVersion:             cfg.ReceiptsVersion,
-	}, nil
+		CommitJournal:               commitJournal,
+		ResolveBlockProposer:        resolveBlockProposer,
+	}
+	if snapshotManager != nil {
+		app.StateSnapshotter = snapshotManager
+	}
+	return app, nil
 }
 
 func deployContract(
@@ -1205,6 +1432,26 @@ func getContractStaticCtx(pluginName string, vmManager *vm.Manager) staticContex
 	}
 }
 
+func getFeeBalanceManagerFactory(vmManager *vm.Manager) fees.BalanceManagerFactory {
+	return func(state loomchain.State, token string) (fees.BalanceManager, error) {
+		pvm, err := vmManager.InitVM(vm.VMType_PLUGIN, state)
+		if err != nil {
+			return nil, err
+		}
+		pluginVM := pvm.(*plugin.PluginVM)
+		tokenAddr, err := pluginVM.Registry.Resolve(token)
+		if err != nil {
+			return nil, err
+		}
+		caller := loom.RootAddress(state.Block().ChainID)
+		ctx := pluginVM.CreateContractContext(caller, tokenAddr, false)
+		if token == dposv3.EthFeeToken {
+			return plugin.NewAccountBalanceManager(ctx), nil
+		}
+		return plugin.NewCoinBalanceManager(ctx), nil
+	}
+}
+
 func initBackend(cfg *config.Config, abciServerAddr string, fnRegistry fnConsensus.FnRegistry) backend.Backend {
 	ovCfg := &backend.OverrideConfig{
 		LogLevel:                 cfg.BlockchainLogLevel,
@@ -1228,7 +1475,7 @@ func initBackend(cfg *config.Config, abciServerAddr string, fnRegistry fnConsens
 
 func initQueryService(
 	app *loomchain.Application, chainID string, cfg *config.Config, loader plugin.Loader,
-	receiptHandlerProvider loomchain.ReceiptHandlerProvider,
+	receiptHandlerProvider loomchain.ReceiptHandlerProvider, mempool store.Mempool,
 ) error {
 	// metrics
 	fieldKeys := []string{"method", "error"}
@@ -1284,6 +1531,17 @@ func initQueryService(
 		EvmAuxStore:            app.EvmAuxStore,
 		Web3Cfg:                cfg.Web3,
 		DPOSCfg:                cfg.DPOS,
+		GasPriceOracle:         rpc.NewGasPriceOracle(cfg.Web3, blockstore),
+		Mempool:                mempool,
+	}
+	if provableStore, ok := app.Store.(store.ProvableStore); ok {
+		qs.ProvableStore = provableStore
+	}
+	if evmRootReader, ok := app.Store.(store.EvmRootReader); ok {
+		qs.EvmRootReader = evmRootReader
+	}
+	if stateCacheProvider, ok := app.Store.(store.StateCacheProvider); ok {
+		qs.StateCache = stateCacheProvider.StateCache()
 	}
 	bus := &rpc.QueryEventBus{
 		Subs:    *app.EventHandler.SubscriptionSet(),
@@ -1291,7 +1549,10 @@ func initQueryService(
 	}
 	var qsvc rpc.QueryService = rpc.NewInstrumentingMiddleWare(requestCount, requestLatency, qs)
 	logger := log.Root.With("module", "query-server")
-	err = rpc.RPCServer(qsvc, chainID, logger, bus, cfg.RPCBindAddress, cfg.UnsafeRPCEnabled, cfg.UnsafeRPCBindAddress)
+	err = rpc.RPCServer(
+		qsvc, chainID, logger, bus, cfg.RPCBindAddress, cfg.UnsafeRPCEnabled, cfg.UnsafeRPCBindAddress,
+		cfg.RPCRateLimiter, cfg.Web3,
+	)
 	if err != nil {
 		return err
 	}
@@ -13,6 +13,7 @@ import (
 	"github.com/gogo/protobuf/jsonpb"
 	"github.com/gogo/protobuf/proto"
 	"github.com/loomnetwork/loomchain/config"
+	"github.com/loomnetwork/loomchain/evm"
 	"github.com/loomnetwork/loomchain/registry"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -351,6 +352,13 @@ func newCallEvmCommand() *cobra.Command {
 				flags.PublicFile, cli.TxFlags.Algo, callerChainID, flags.Value,
 			)
 			if err != nil {
+				if data, ok := evm.ParseRevertError(err.Error()); ok {
+					if reason, errReason := evm.UnpackRevertReason(data); errReason == nil {
+						fmt.Printf("Transaction reverted: %s\n", reason)
+					} else {
+						fmt.Println("Transaction reverted, revert data: ", hex.EncodeToString(data))
+					}
+				}
 				return err
 			}
 			fmt.Println("Call response: ", resp)
@@ -22,6 +22,7 @@ import (
 	"github.com/loomnetwork/loomchain/rpc/eth"
 	"github.com/loomnetwork/loomchain/store"
 	blockindex "github.com/loomnetwork/loomchain/store/block_index"
+	"github.com/loomnetwork/loomchain/store/snapshot"
 	"github.com/loomnetwork/loomchain/throttle"
 	"github.com/pkg/errors"
 	"github.com/spf13/viper"
@@ -107,6 +108,8 @@ type Config struct {
 	BlockIndexStore *blockindex.BlockIndexStoreConfig
 	// Cashing store
 	CachingStoreConfig *store.CachingStoreConfig
+	// Cache of decoded contract state used by the query server
+	StateCache *store.StateCacheConfig
 
 	//Prometheus
 	PrometheusPushGateway *PrometheusPushGatewayConfig
@@ -122,6 +125,8 @@ type Config struct {
 
 	// AppStore
 	AppStore *store.AppStoreConfig
+	// Periodic app.db & evm.db snapshots
+	StateSnapshot *snapshot.Config
 
 	// Should pretty much never be changed
 	RootDir     string
@@ -149,9 +154,10 @@ type Config struct {
 	// Set to true to disable minimum required build number check on node startup
 	SkipMinBuildCheck bool
 
-	Web3 *eth.Web3Config
-	Geth *GethConfig
-	DPOS *DPOSConfig
+	Web3           *eth.Web3Config
+	RPCRateLimiter *eth.RateLimiterConfig
+	Geth           *GethConfig
+	DPOS           *DPOSConfig
 }
 
 type GethConfig struct {
@@ -214,6 +220,8 @@ func (dposCfg *DPOSConfig) BootstrapNodesList() map[string]bool {
 type DBBackendConfig struct {
 	CacheSizeMegs   int
 	WriteBufferMegs int
+	// DB backend used by the EVM auxiliary store (receipts_db), one of goleveldb, cleveldb, badgerdb
+	EvmAuxDBBackend string
 }
 
 type KarmaConfig struct {
@@ -262,6 +270,7 @@ func DefaultDBBackendConfig() *DBBackendConfig {
 	return &DBBackendConfig{
 		CacheSizeMegs:   1042, //1 Gigabyte
 		WriteBufferMegs: 500,  //500 megabyte
+		EvmAuxDBBackend: db.GoLevelDBBackend,
 	}
 }
 
@@ -447,12 +456,14 @@ func DefaultConfig() *Config {
 	cfg.BinanceTransferGateway = DefaultBinanceTGConfig()
 	cfg.PlasmaCash = plasmacfg.DefaultConfig()
 	cfg.AppStore = store.DefaultConfig()
+	cfg.StateSnapshot = snapshot.DefaultConfig()
 	cfg.HsmConfig = hsmpv.DefaultConfig()
 	cfg.TxLimiter = throttle.DefaultTxLimiterConfig()
 	cfg.ContractTxLimiter = throttle.DefaultContractTxLimiterConfig()
 	cfg.GoContractDeployerWhitelist = throttle.DefaultGoContractDeployerWhitelistConfig()
 	cfg.DPOSv2OracleConfig = DefaultDPOS2OracleConfig()
 	cfg.CachingStoreConfig = store.DefaultCachingStoreConfig()
+	cfg.StateCache = store.DefaultStateCacheConfig()
 	cfg.BlockStore = store.DefaultBlockStoreConfig()
 	cfg.BlockIndexStore = blockindex.DefaultBlockIndexStoreConfig()
 	cfg.Metrics = DefaultMetrics()
@@ -466,6 +477,7 @@ func DefaultConfig() *Config {
 	cfg.EventStore = events.DefaultEventStoreConfig()
 	cfg.EvmStore = evm.DefaultEvmStoreConfig()
 	cfg.Web3 = eth.DefaultWeb3Config()
+	cfg.RPCRateLimiter = eth.DefaultRateLimiterConfig()
 	cfg.Geth = DefaultGethConfig()
 	cfg.DPOS = DefaultDPOSConfig()
 
@@ -494,12 +506,15 @@ func (c *Config) Clone() *Config {
 	clone.TronTransferGateway = c.TronTransferGateway.Clone()
 	clone.PlasmaCash = c.PlasmaCash.Clone()
 	clone.AppStore = c.AppStore.Clone()
+	clone.StateSnapshot = c.StateSnapshot.Clone()
+	clone.StateCache = c.StateCache.Clone()
 	clone.HsmConfig = c.HsmConfig.Clone()
 	clone.TxLimiter = c.TxLimiter.Clone()
 	clone.ContractTxLimiter = c.ContractTxLimiter.Clone()
 	clone.EventStore = c.EventStore.Clone()
 	clone.EventDispatcher = c.EventDispatcher.Clone()
 	clone.Auth = c.Auth.Clone()
+	clone.RPCRateLimiter = c.RPCRateLimiter.Clone()
 	return &clone
 }
 
@@ -696,7 +711,16 @@ CachingStoreConfig:
   Verbose: {{ .CachingStoreConfig.Verbose }} 
   LogLevel: "{{ .CachingStoreConfig.LogLevel }}" 
   LogDestination: "{{ .CachingStoreConfig.LogDestination }}" 
+{{if .StateCache -}}
 #
+# Cache of decoded contract state (balances, address mappings, etc.) used by the query server,
+# cached values are evicted when a block modifies them.
+#
+StateCache:
+  Enabled: {{ .StateCache.Enabled }}
+  # Maximum number of app store keys to cache decoded values for
+  MaxKeys: {{ .StateCache.MaxKeys }}
+{{end}}#
 # Prometheus Push Gateway
 #
 PrometheusPushGateway:
@@ -750,6 +774,25 @@ AppStore:
   # If true the app store will write EVM state to both IAVLStore and EvmStore
   # This config works with AppStore Version 3 (MultiWriterAppStore) only
   SaveEVMStateToIAVL: {{ .AppStore.SaveEVMStateToIAVL }}
+  # If true every app store version will be written to disk and retained, which makes it possible
+  # to query the state at any block height. Overrides MaxVersions, PruneInterval & IAVLFlushInterval.
+  ArchiveMode: {{ .AppStore.ArchiveMode }}
+{{if .StateSnapshot -}}
+#
+# StateSnapshot
+#
+StateSnapshot:
+  # Periodically export snapshots of app.db & evm.db (requires AppStore.Version 3)
+  Enabled: {{ .StateSnapshot.Enabled }}
+  # Number of blocks between snapshots, must be a multiple of AppStore.IAVLFlushInterval
+  Interval: {{ .StateSnapshot.Interval }}
+  # Directory (relative to the node root dir) snapshots should be written to
+  Dir: "{{ .StateSnapshot.Dir }}"
+  # Maximum size of each snapshot chunk (in megabytes)
+  ChunkSizeMegs: {{ .StateSnapshot.ChunkSizeMegs }}
+  # Maximum number of snapshots to keep, if zero old snapshots will never be deleted
+  MaxSnapshots: {{ .StateSnapshot.MaxSnapshots }}
+{{end}}
 {{if .EventStore -}}
 #
 # EventStore
@@ -767,12 +810,24 @@ EvmStore:
   # DBName defines evm database file name
   DBName: {{.EvmStore.DBName}}
   # DBBackend defines backend EVM store type
-  # available backend types are 'goleveldb', or 'cleveldb'
+  # available backend types are 'goleveldb', 'cleveldb', or 'badgerdb'
   DBBackend: {{.EvmStore.DBBackend}}
   # CacheSizeMegs defines cache size (in megabytes) of EVM store
   CacheSizeMegs: {{.EvmStore.CacheSizeMegs}}
   # NumCachedRoots defines a number of in-memory cached EVM roots
   NumCachedRoots: {{.EvmStore.NumCachedRoots}}
+  # Number of the latest EVM state versions to keep, if zero old versions will never be deleted.
+  # If non-zero unreachable trie nodes will be periodically deleted from the EVM store, in which
+  # case it must not be lower than AppStore.IAVLFlushInterval.
+  # Ignored if AppStore.ArchiveMode is enabled.
+  MaxVersions: {{.EvmStore.MaxVersions}}
+  # Number of seconds to wait between each run of the EVM store pruner.
+  PruneInterval: {{.EvmStore.PruneInterval}}
+  # Maximum number of keys the EVM store pruner should delete at a time.
+  PruneBatchSize: {{.EvmStore.PruneBatchSize}}
+  # Maximum number of reachable trie nodes the EVM store pruner should keep track of in memory,
+  # if the EVM state contains more nodes than this the pruner will give up.
+  PruneMaxMarkedKeys: {{.EvmStore.PruneMaxMarkedKeys}}
 {{end}}
 
 {{if .Web3 -}}
@@ -782,6 +837,73 @@ EvmStore:
 Web3:
   # Specifies the maximum number of blocks eth_getLogs will query per request
   GetLogsMaxBlockRange: {{.Web3.GetLogsMaxBlockRange}}
+  # Specifies the maximum number of logs eth_getLogs will return per request, zero means no limit.
+  GetLogsMaxResults: {{.Web3.GetLogsMaxResults}}
+  # Specifies the maximum amount of gas eth_estimateGas will execute a call with,
+  # zero means the call can use up to the EVM gas limit.
+  EstimateGasCap: {{.Web3.EstimateGasCap}}
+  # Gas price (in wei) returned by eth_gasPrice, if gas price sampling is enabled this is the
+  # lowest price eth_gasPrice will return.
+  GasPrice: {{.Web3.GasPrice}}
+  # Number of recent blocks eth_gasPrice will sample the gas prices of Ethereum txs from,
+  # zero disables sampling.
+  GasPriceBlocks: {{.Web3.GasPriceBlocks}}
+  # Percentile of the sampled gas prices eth_gasPrice will return.
+  GasPricePercentile: {{.Web3.GasPricePercentile}}
+  # Exposes the debug_trace* methods, these replay EVM txs so they're expensive to serve and
+  # shouldn't be enabled on public nodes.
+  DebugAPIEnabled: {{.Web3.DebugAPIEnabled}}
+  # Exposes the EIP-1767 GraphQL endpoint at /graphql, queries are subject to the same limits as
+  # the equivalent JSON-RPC methods.
+  GraphQLEnabled: {{.Web3.GraphQLEnabled}}
+  # Max number of events that can be waiting to be sent to an eth_subscribe subscriber.
+  SubscriptionQueueSize: {{.Web3.SubscriptionQueueSize}}
+  # What happens when the queue of a subscriber is full, "drop" discards new events, "close"
+  # disconnects the client so it can resume the subscription from the last event it received.
+  SubscriptionOverflowPolicy: "{{.Web3.SubscriptionOverflowPolicy}}"
+  # Max number of blocks that can be replayed when a client resumes a subscription.
+  SubscriptionMaxReplayBlocks: {{.Web3.SubscriptionMaxReplayBlocks}}
+{{end}}
+
+{{if .RPCRateLimiter -}}
+#
+# Limits on the rate at which clients can call the JSON-RPC methods served on the /eth, /query &
+# /queryws endpoints.
+#
+RPCRateLimiter:
+  Enabled: {{.RPCRateLimiter.Enabled}}
+  # HTTP header clients should send their API key in.
+  APIKeyHeader: "{{.RPCRateLimiter.APIKeyHeader}}"
+  # HTTP header the client IP should be read from, only set this if the node is behind a proxy
+  # that sets the header.
+  ClientIPHeader: "{{.RPCRateLimiter.ClientIPHeader}}"
+  {{- if .RPCRateLimiter.DefaultLimit}}
+  # Limit applied per client IP to each method that doesn't have its own limit below, Rate is the
+  # sustained number of requests per second, Burst is the max number of requests in quick succession.
+  DefaultLimit:
+    Rate: {{.RPCRateLimiter.DefaultLimit.Rate}}
+    Burst: {{.RPCRateLimiter.DefaultLimit.Burst}}
+  {{- end}}
+  # Limits applied per client IP to specific methods.
+  MethodLimits:
+  {{- range .RPCRateLimiter.MethodLimits}}
+    - Method: "{{.Method}}"
+      Rate: {{.Rate}}
+      Burst: {{.Burst}}
+  {{- end}}
+  # Limits applied to each method called by clients that send one of these API keys, these clients
+  # are limited per API key instead of per IP.
+  APIKeys:
+  {{- range .RPCRateLimiter.APIKeys}}
+    - Key: "{{.Key}}"
+      Rate: {{.Rate}}
+      Burst: {{.Burst}}
+  {{- end}}
+  # Methods no client is allowed to call.
+  DeniedMethods:
+  {{- range .RPCRateLimiter.DeniedMethods}}
+    - "{{.}}"
+  {{- end}}
 {{end}}
 
 # 
@@ -826,9 +948,25 @@ Auth:
       TxType: "{{.TxType -}}"
       AccountType: {{.AccountType -}}
     {{- end}}
+{{if .DBBackendConfig -}}
+#
+# DB backend settings
+#
+DBBackendConfig:
+  # Cache & write buffer sizes (in megabytes) of goleveldb DBs
+  CacheSizeMegs: {{ .DBBackendConfig.CacheSizeMegs }}
+  WriteBufferMegs: {{ .DBBackendConfig.WriteBufferMegs }}
+  # DB backend used by the EVM auxiliary store (receipts_db)
+  # available backend types are 'goleveldb', 'cleveldb', or 'badgerdb'
+  EvmAuxDBBackend: {{ .DBBackendConfig.EvmAuxDBBackend }}
+{{end}}
 # These should pretty much never be changed
 RootDir: "{{ .RootDir }}"
 DBName: "{{ .DBName }}"
+# available backend types are 'goleveldb', 'cleveldb', or 'badgerdb'
+# NOTE: badgerdb limits the size of each atomic write, if it's used for app.db then
+#       AppStore.IAVLFlushInterval should be kept low so the changes flushed at once stay small.
+DBBackend: "{{ .DBBackend }}"
 GenesisFile: "{{ .GenesisFile }}"
 PluginsDir: "{{ .PluginsDir }}"
 
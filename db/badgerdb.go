@@ -0,0 +1,365 @@
+package db
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/dgraph-io/badger"
+	"github.com/loomnetwork/loomchain/log"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+const (
+	// Number of goroutines used to flatten the LSM tree during compaction.
+	badgerCompactionWorkers = 2
+	// A value log file is rewritten during compaction if at least this fraction of it can be discarded.
+	badgerValueLogGCDiscardRatio = 0.5
+)
+
+// BadgerDB is a DBWrapper backed by Badger, a pure-Go LSM tree key/value store that keeps values
+// in a separate value log in order to reduce write amplification.
+type BadgerDB struct {
+	db *badger.DB
+}
+
+var _ DBWrapper = &BadgerDB{}
+
+// LoadBadgerDB opens (or creates) a Badger DB in <dir>/<name>.db
+// Badger doesn't have an equivalent of the LevelDB block cache & write buffer settings, so the
+// Badger defaults are used regardless of DBBackendConfig.
+func LoadBadgerDB(name, dir string) (*BadgerDB, error) {
+	dbPath := filepath.Join(dir, name+".db")
+	if err := os.MkdirAll(dbPath, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create dir %s", dbPath)
+	}
+	opts := badger.DefaultOptions(dbPath).
+		// match the goleveldb backend, only SetSync, DeleteSync & WriteSync should fsync
+		WithSyncWrites(false).
+		WithLogger(&badgerLogger{})
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open Badger DB in %s", dbPath)
+	}
+	return &BadgerDB{db: db}, nil
+}
+
+func (b *BadgerDB) Get(key []byte) []byte {
+	txn := b.db.NewTransaction(false)
+	defer txn.Discard()
+	return badgerGet(txn, key)
+}
+
+func (b *BadgerDB) Has(key []byte) bool {
+	return b.Get(key) != nil
+}
+
+func (b *BadgerDB) Set(key, value []byte) {
+	err := b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, value)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (b *BadgerDB) SetSync(key, value []byte) {
+	b.Set(key, value)
+	b.sync()
+}
+
+func (b *BadgerDB) Delete(key []byte) {
+	err := b.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (b *BadgerDB) DeleteSync(key []byte) {
+	b.Delete(key)
+	b.sync()
+}
+
+func (b *BadgerDB) Iterator(start, end []byte) dbm.Iterator {
+	return newBadgerIterator(b.db.NewTransaction(false), true, start, end, false)
+}
+
+func (b *BadgerDB) ReverseIterator(start, end []byte) dbm.Iterator {
+	return newBadgerIterator(b.db.NewTransaction(false), true, start, end, true)
+}
+
+func (b *BadgerDB) Close() {
+	if err := b.db.Close(); err != nil {
+		log.Error("Failed to close Badger DB", "err", err)
+	}
+}
+
+// NewBatch returns a new write batch. Each batch is written atomically in a single Badger
+// transaction, so the size of a batch is limited by the maximum Badger transaction size (which is
+// derived from the max table size), adding more changes to a batch than will fit into a single
+// transaction will cause a panic.
+func (b *BadgerDB) NewBatch() dbm.Batch {
+	return &badgerBatch{
+		db:  b,
+		txn: b.db.NewTransaction(true),
+	}
+}
+
+func (b *BadgerDB) Print() {
+	itr := b.Iterator(nil, nil)
+	defer itr.Close()
+	for ; itr.Valid(); itr.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", itr.Key(), itr.Value())
+	}
+}
+
+func (b *BadgerDB) Stats() map[string]string {
+	lsmSize, vlogSize := b.db.Size()
+	return map[string]string{
+		"database.type": "badgerDB",
+		"lsm.size":      fmt.Sprintf("%d", lsmSize),
+		"vlog.size":     fmt.Sprintf("%d", vlogSize),
+	}
+}
+
+// Compact flattens the LSM tree and then garbage collects the value log until there's nothing
+// left to reclaim.
+func (b *BadgerDB) Compact() error {
+	if err := b.db.Flatten(badgerCompactionWorkers); err != nil {
+		return errors.Wrap(err, "failed to flatten Badger LSM tree")
+	}
+	for {
+		if err := b.db.RunValueLogGC(badgerValueLogGCDiscardRatio); err != nil {
+			if err == badger.ErrNoRewrite {
+				return nil
+			}
+			return errors.Wrap(err, "failed to GC Badger value log")
+		}
+	}
+}
+
+func (b *BadgerDB) GetSnapshot() Snapshot {
+	return &BadgerDBSnapshot{
+		txn: b.db.NewTransaction(false),
+	}
+}
+
+func (b *BadgerDB) sync() {
+	if err := b.db.Sync(); err != nil {
+		panic(err)
+	}
+}
+
+func badgerGet(txn *badger.Txn, key []byte) []byte {
+	// Badger doesn't allow empty keys, so there can't be anything stored under one
+	if len(key) == 0 {
+		return nil
+	}
+	item, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil {
+		panic(err)
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		panic(err)
+	}
+	// match the goleveldb backend, which returns an empty slice for keys with empty values
+	if val == nil {
+		val = []byte{}
+	}
+	return val
+}
+
+// BadgerDBSnapshot is a read-only view of a BadgerDB, backed by a read-only Badger transaction.
+type BadgerDBSnapshot struct {
+	txn *badger.Txn
+}
+
+var _ Snapshot = &BadgerDBSnapshot{}
+
+func (s *BadgerDBSnapshot) Get(key []byte) []byte {
+	return badgerGet(s.txn, key)
+}
+
+func (s *BadgerDBSnapshot) Has(key []byte) bool {
+	return s.Get(key) != nil
+}
+
+func (s *BadgerDBSnapshot) NewIterator(start, end []byte) dbm.Iterator {
+	return newBadgerIterator(s.txn, false, start, end, false)
+}
+
+func (s *BadgerDBSnapshot) Release() {
+	s.txn.Discard()
+}
+
+type badgerBatch struct {
+	db   *BadgerDB
+	txn  *badger.Txn
+	done bool
+}
+
+func (b *badgerBatch) Set(key, value []byte) {
+	// the transaction holds on to the given slices until it's committed, but callers are free to
+	// reuse them as soon as this function returns
+	key = append([]byte{}, key...)
+	value = append([]byte{}, value...)
+	b.checkTxnErr(b.txn.Set(key, value))
+}
+
+func (b *badgerBatch) Delete(key []byte) {
+	b.checkTxnErr(b.txn.Delete(append([]byte{}, key...)))
+}
+
+func (b *badgerBatch) checkTxnErr(err error) {
+	if err == badger.ErrTxnTooBig {
+		panic(errors.Wrap(err, "batch exceeds the max Badger transaction size"))
+	} else if err != nil {
+		panic(err)
+	}
+}
+
+func (b *badgerBatch) Write() {
+	b.done = true
+	if err := b.txn.Commit(); err != nil {
+		panic(err)
+	}
+}
+
+func (b *badgerBatch) WriteSync() {
+	b.Write()
+	b.db.sync()
+}
+
+// Close discards the batch if it hasn't been written yet.
+func (b *badgerBatch) Close() {
+	if !b.done {
+		b.done = true
+		b.txn.Discard()
+	}
+}
+
+// badgerIterator implements dbm.Iterator on top of a Badger iterator, the start key is inclusive,
+// the end key is exclusive.
+type badgerIterator struct {
+	txn       *badger.Txn
+	ownsTxn   bool
+	source    *badger.Iterator
+	start     []byte
+	end       []byte
+	isReverse bool
+}
+
+var _ dbm.Iterator = &badgerIterator{}
+
+func newBadgerIterator(txn *badger.Txn, ownsTxn bool, start, end []byte, isReverse bool) *badgerIterator {
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = isReverse
+	source := txn.NewIterator(opts)
+	if isReverse {
+		if end == nil {
+			source.Rewind()
+		} else {
+			source.Seek(end)
+			if source.Valid() && bytes.Equal(source.Item().Key(), end) {
+				source.Next()
+			}
+		}
+	} else {
+		if start == nil {
+			source.Rewind()
+		} else {
+			source.Seek(start)
+		}
+	}
+	return &badgerIterator{
+		txn:       txn,
+		ownsTxn:   ownsTxn,
+		source:    source,
+		start:     start,
+		end:       end,
+		isReverse: isReverse,
+	}
+}
+
+func (it *badgerIterator) Domain() ([]byte, []byte) {
+	return it.start, it.end
+}
+
+func (it *badgerIterator) Valid() bool {
+	if !it.source.Valid() {
+		return false
+	}
+	key := it.source.Item().Key()
+	if it.isReverse {
+		if it.start != nil && bytes.Compare(key, it.start) < 0 {
+			return false
+		}
+	} else {
+		if it.end != nil && bytes.Compare(key, it.end) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *badgerIterator) Next() {
+	it.assertIsValid()
+	it.source.Next()
+}
+
+func (it *badgerIterator) Key() []byte {
+	it.assertIsValid()
+	return it.source.Item().KeyCopy(nil)
+}
+
+func (it *badgerIterator) Value() []byte {
+	it.assertIsValid()
+	val, err := it.source.Item().ValueCopy(nil)
+	if err != nil {
+		panic(err)
+	}
+	if val == nil {
+		val = []byte{}
+	}
+	return val
+}
+
+func (it *badgerIterator) Close() {
+	it.source.Close()
+	if it.ownsTxn {
+		it.txn.Discard()
+	}
+}
+
+func (it *badgerIterator) assertIsValid() {
+	if !it.Valid() {
+		panic("badgerIterator is invalid")
+	}
+}
+
+// badgerLogger routes Badger log messages to the Loom logger.
+type badgerLogger struct{}
+
+func (l *badgerLogger) Errorf(format string, args ...interface{}) {
+	log.Error(fmt.Sprintf("[badger] "+format, args...))
+}
+
+func (l *badgerLogger) Warningf(format string, args ...interface{}) {
+	log.Warn(fmt.Sprintf("[badger] "+format, args...))
+}
+
+func (l *badgerLogger) Infof(format string, args ...interface{}) {
+	log.Debug(fmt.Sprintf("[badger] "+format, args...))
+}
+
+func (l *badgerLogger) Debugf(format string, args ...interface{}) {
+	log.Debug(fmt.Sprintf("[badger] "+format, args...))
+}
@@ -0,0 +1,116 @@
+package db
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func collectKeys(itr dbm.Iterator) []string {
+	defer itr.Close()
+	keys := []string{}
+	for ; itr.Valid(); itr.Next() {
+		keys = append(keys, string(itr.Key()))
+	}
+	return keys
+}
+
+func TestBadgerDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badgerdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := LoadDB(BadgerDBBackend, "test", dir, 0, 0, false)
+	require.NoError(t, err)
+	defer db.Close()
+
+	for _, key := range []string{"a", "b", "c", "d"} {
+		db.Set([]byte(key), []byte("val_"+key))
+	}
+	db.Set([]byte("e"), []byte{})
+
+	require.Equal(t, []byte("val_a"), db.Get([]byte("a")))
+	require.Equal(t, []byte{}, db.Get([]byte("e")))
+	require.True(t, db.Has([]byte("e")))
+	require.Nil(t, db.Get([]byte("f")))
+	require.False(t, db.Has([]byte("f")))
+	require.Nil(t, db.Get(nil))
+
+	require.Equal(t, []string{"a", "b", "c", "d", "e"}, collectKeys(db.Iterator(nil, nil)))
+	require.Equal(t, []string{"b", "c"}, collectKeys(db.Iterator([]byte("b"), []byte("d"))))
+	require.Equal(t, []string{"e", "d", "c", "b", "a"}, collectKeys(db.ReverseIterator(nil, nil)))
+	require.Equal(t, []string{"c", "b"}, collectKeys(db.ReverseIterator([]byte("b"), []byte("d"))))
+	require.Equal(t, []string{"c", "b"}, collectKeys(db.ReverseIterator([]byte("b"), []byte("cc"))))
+
+	// snapshots shouldn't see any changes made after they were created
+	snap := db.GetSnapshot()
+	batch := db.NewBatch()
+	batch.Set([]byte("a"), []byte("new_a"))
+	batch.Delete([]byte("b"))
+	batch.Write()
+
+	require.Equal(t, []byte("new_a"), db.Get([]byte("a")))
+	require.False(t, db.Has([]byte("b")))
+	require.Equal(t, []byte("val_a"), snap.Get([]byte("a")))
+	require.True(t, snap.Has([]byte("b")))
+	require.Equal(t, []string{"b", "c", "d"}, collectKeys(snap.NewIterator([]byte("b"), []byte("e"))))
+	snap.Release()
+
+	db.Delete([]byte("c"))
+	require.Equal(t, []string{"a", "d", "e"}, collectKeys(db.Iterator(nil, nil)))
+	require.NoError(t, db.Compact())
+}
+
+func TestReadWriteBatch(t *testing.T) {
+	db, err := LoadMemDB()
+	require.NoError(t, err)
+	db.Set([]byte("a"), []byte("val_a"))
+	db.Set([]byte("b"), []byte("val_b"))
+
+	batch := NewReadWriteBatch(db)
+	batch.Set([]byte("a"), []byte("new_a"))
+	batch.Delete([]byte("b"))
+	batch.Set([]byte("c"), []byte("val_c"))
+
+	// pending writes should be visible via the batch, but not via the DB
+	require.Equal(t, []byte("new_a"), batch.Get([]byte("a")))
+	require.False(t, batch.Has([]byte("b")))
+	require.True(t, batch.Has([]byte("c")))
+	require.Equal(t, []byte("val_a"), db.Get([]byte("a")))
+	require.True(t, db.Has([]byte("b")))
+
+	batch.Write()
+	require.Equal(t, []byte("new_a"), db.Get([]byte("a")))
+	require.False(t, db.Has([]byte("b")))
+	require.Equal(t, []byte("val_c"), db.Get([]byte("c")))
+}
+
+func TestBadgerBatchDiscard(t *testing.T) {
+	dir, err := ioutil.TempDir("", "badgerdb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := LoadDB(BadgerDBBackend, "test", dir, 0, 0, false)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// changes in a batch shouldn't be visible until the whole batch is written
+	batch := db.NewBatch()
+	batch.Set([]byte("a"), []byte("val_a"))
+	batch.Set([]byte("c"), []byte("val_c"))
+	require.False(t, db.Has([]byte("a")))
+	batch.Close()
+	require.False(t, db.Has([]byte("a")))
+	require.False(t, db.Has([]byte("c")))
+
+	batch = db.NewBatch()
+	batch.Set([]byte("a"), []byte("val_a"))
+	batch.Delete([]byte("a"))
+	batch.Set([]byte("c"), []byte("val_c"))
+	batch.Write()
+	require.False(t, db.Has([]byte("a")))
+	require.Equal(t, []byte("val_c"), db.Get([]byte("c")))
+}
@@ -0,0 +1,79 @@
+package db
+
+import (
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// ReadWriteBatch is a batch of writes that can be read back before the batch is written to the
+// underlying DB, it's a backend agnostic substitute for a goleveldb transaction.
+// ReadWriteBatch is not thread-safe.
+type ReadWriteBatch struct {
+	db      dbm.DB
+	batch   dbm.Batch
+	pending map[string][]byte // nil values mark deleted keys
+}
+
+var _ dbm.Batch = &ReadWriteBatch{}
+
+// NewReadWriteBatch creates a new batch for the given DB.
+func NewReadWriteBatch(db dbm.DB) *ReadWriteBatch {
+	return &ReadWriteBatch{
+		db:      db,
+		batch:   db.NewBatch(),
+		pending: map[string][]byte{},
+	}
+}
+
+// Get returns the value of the given key, taking into account any writes that were made via the
+// batch.
+func (b *ReadWriteBatch) Get(key []byte) []byte {
+	if val, ok := b.pending[string(key)]; ok {
+		return val
+	}
+	return b.db.Get(key)
+}
+
+// Has checks if the given key exists, taking into account any writes that were made via the batch.
+func (b *ReadWriteBatch) Has(key []byte) bool {
+	return b.Get(key) != nil
+}
+
+// PendingKeys returns all the keys that were set or deleted via the batch, in no particular order.
+func (b *ReadWriteBatch) PendingKeys() [][]byte {
+	keys := make([][]byte, 0, len(b.pending))
+	for key := range b.pending {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
+
+func (b *ReadWriteBatch) Set(key, value []byte) {
+	if value == nil {
+		value = []byte{}
+	}
+	b.pending[string(key)] = value
+	b.batch.Set(key, value)
+}
+
+func (b *ReadWriteBatch) Delete(key []byte) {
+	b.pending[string(key)] = nil
+	b.batch.Delete(key)
+}
+
+func (b *ReadWriteBatch) Write() {
+	b.batch.Write()
+	b.pending = map[string][]byte{}
+}
+
+func (b *ReadWriteBatch) WriteSync() {
+	b.batch.WriteSync()
+	b.pending = map[string][]byte{}
+}
+
+// Close discards the batch, any writes that haven't been written to the DB yet are dropped.
+func (b *ReadWriteBatch) Close() {
+	if closer, ok := b.batch.(interface{ Close() }); ok {
+		closer.Close()
+	}
+	b.pending = map[string][]byte{}
+}
@@ -0,0 +1,38 @@
+package db
+
+import (
+	"github.com/pkg/errors"
+)
+
+// DefaultCopyBatchSize is the default number of keys written per batch by CopyDB.
+const DefaultCopyBatchSize = 10000
+
+// CopyDB copies every key in the src DB to the dst DB, keys are read from a snapshot of the src
+// DB so that the copy is consistent. The dst DB is written to in batches of batchSize keys.
+// Returns the number of keys that were copied.
+func CopyDB(src DBWrapper, dst DBWrapper, batchSize int) (int64, error) {
+	if batchSize <= 0 {
+		return 0, errors.New("batch size must be greater than zero")
+	}
+
+	snap := src.GetSnapshot()
+	defer snap.Release()
+	iter := snap.NewIterator(nil, nil)
+	defer iter.Close()
+
+	var numKeys int64
+	batch := dst.NewBatch()
+	batchKeys := 0
+	for ; iter.Valid(); iter.Next() {
+		batch.Set(iter.Key(), iter.Value())
+		batchKeys++
+		numKeys++
+		if batchKeys == batchSize {
+			batch.Write()
+			batch = dst.NewBatch()
+			batchKeys = 0
+		}
+	}
+	batch.WriteSync()
+	return numKeys, nil
+}
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "copydb")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src, err := LoadMemDB()
+	require.NoError(t, err)
+	for i := 0; i < 25; i++ {
+		src.Set([]byte(fmt.Sprintf("key%02d", i)), []byte(fmt.Sprintf("val%d", i)))
+	}
+	src.Set([]byte("empty"), []byte{})
+
+	dst, err := LoadDB(BadgerDBBackend, "dst", dir, 0, 0, false)
+	require.NoError(t, err)
+	defer dst.Close()
+
+	_, err = CopyDB(src, dst, 0)
+	require.Error(t, err)
+
+	// use a small batch size to make sure the copy spans multiple batches
+	numKeys, err := CopyDB(src, dst, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(26), numKeys)
+
+	srcIter := src.Iterator(nil, nil)
+	defer srcIter.Close()
+	dstIter := dst.Iterator(nil, nil)
+	defer dstIter.Close()
+	for ; srcIter.Valid(); srcIter.Next() {
+		require.True(t, dstIter.Valid())
+		require.Equal(t, srcIter.Key(), dstIter.Key())
+		require.Equal(t, srcIter.Value(), dstIter.Value())
+		dstIter.Next()
+	}
+	require.False(t, dstIter.Valid())
+}
@@ -0,0 +1,167 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// LegacyGoLevelDB is a DBWrapper for a goleveldb DB stored at an arbitrary path, rather than in
+// <dir>/<name>.db like the DBs loaded via LoadDB. It's used for DBs that predate LoadDB, such as
+// receipts_db, so that existing nodes don't have to move them.
+type LegacyGoLevelDB struct {
+	db *leveldb.DB
+}
+
+var _ DBWrapper = &LegacyGoLevelDB{}
+
+// LoadLegacyGoLevelDB opens (or creates) a goleveldb DB at the given path.
+func LoadLegacyGoLevelDB(path string, o *opt.Options) (*LegacyGoLevelDB, error) {
+	db, err := leveldb.OpenFile(path, o)
+	if err != nil {
+		return nil, err
+	}
+	return &LegacyGoLevelDB{db: db}, nil
+}
+
+// DB returns the underlying goleveldb DB.
+func (g *LegacyGoLevelDB) DB() *leveldb.DB {
+	return g.db
+}
+
+func (g *LegacyGoLevelDB) Get(key []byte) []byte {
+	if key == nil {
+		key = []byte{}
+	}
+	val, err := g.db.Get(key, nil)
+	if err != nil {
+		if err == errors.ErrNotFound {
+			return nil
+		}
+		panic(err)
+	}
+	return val
+}
+
+func (g *LegacyGoLevelDB) Has(key []byte) bool {
+	return g.Get(key) != nil
+}
+
+func (g *LegacyGoLevelDB) Set(key, value []byte) {
+	g.put(key, value, nil)
+}
+
+func (g *LegacyGoLevelDB) SetSync(key, value []byte) {
+	g.put(key, value, &opt.WriteOptions{Sync: true})
+}
+
+func (g *LegacyGoLevelDB) Delete(key []byte) {
+	g.delete(key, nil)
+}
+
+func (g *LegacyGoLevelDB) DeleteSync(key []byte) {
+	g.delete(key, &opt.WriteOptions{Sync: true})
+}
+
+func (g *LegacyGoLevelDB) Iterator(start, end []byte) dbm.Iterator {
+	return dbm.NewGoLevelDBIterator(g.db.NewIterator(nil, nil), start, end, false)
+}
+
+func (g *LegacyGoLevelDB) ReverseIterator(start, end []byte) dbm.Iterator {
+	return dbm.NewGoLevelDBIterator(g.db.NewIterator(nil, nil), start, end, true)
+}
+
+func (g *LegacyGoLevelDB) Close() {
+	g.db.Close()
+}
+
+func (g *LegacyGoLevelDB) NewBatch() dbm.Batch {
+	return &legacyGoLevelDBBatch{
+		db:    g.db,
+		batch: new(leveldb.Batch),
+	}
+}
+
+func (g *LegacyGoLevelDB) Print() {
+	itr := g.db.NewIterator(nil, nil)
+	defer itr.Release()
+	for itr.Next() {
+		fmt.Printf("[%X]:\t[%X]\n", itr.Key(), itr.Value())
+	}
+}
+
+func (g *LegacyGoLevelDB) Stats() map[string]string {
+	stats := map[string]string{}
+	for _, key := range []string{"leveldb.stats", "leveldb.sstables", "leveldb.iostats"} {
+		if val, err := g.db.GetProperty(key); err == nil {
+			stats[key] = val
+		}
+	}
+	return stats
+}
+
+func (g *LegacyGoLevelDB) Compact() error {
+	return g.db.CompactRange(util.Range{})
+}
+
+func (g *LegacyGoLevelDB) GetSnapshot() Snapshot {
+	snap, err := g.db.GetSnapshot()
+	if err != nil {
+		panic(err)
+	}
+	return &GoLevelDBSnapshot{
+		Snapshot: snap,
+	}
+}
+
+func (g *LegacyGoLevelDB) put(key, value []byte, wo *opt.WriteOptions) {
+	if key == nil {
+		key = []byte{}
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	if err := g.db.Put(key, value, wo); err != nil {
+		panic(err)
+	}
+}
+
+func (g *LegacyGoLevelDB) delete(key []byte, wo *opt.WriteOptions) {
+	if key == nil {
+		key = []byte{}
+	}
+	if err := g.db.Delete(key, wo); err != nil {
+		panic(err)
+	}
+}
+
+type legacyGoLevelDBBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *legacyGoLevelDBBatch) Set(key, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *legacyGoLevelDBBatch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+func (b *legacyGoLevelDBBatch) Write() {
+	if err := b.db.Write(b.batch, nil); err != nil {
+		panic(err)
+	}
+}
+
+func (b *legacyGoLevelDBBatch) WriteSync() {
+	if err := b.db.Write(b.batch, &opt.WriteOptions{Sync: true}); err != nil {
+		panic(err)
+	}
+}
+
+func (b *legacyGoLevelDBBatch) Close() {}
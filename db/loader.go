@@ -10,10 +10,15 @@ const (
 	GoLevelDBBackend = "goleveldb"
 	CLevelDBBackend  = "cleveldb"
 	MemDBackend      = "memdb"
+	BadgerDBBackend  = "badgerdb"
 )
 
+// DBWrapper is the interface every DB backend must implement, on top of the basic key/value
+// operations provided by dbm.DB (including batches & iterators) backends must support compaction
+// and consistent snapshots.
 type DBWrapper interface {
 	dbm.DB
+	// Compact should reclaim any disk space taken up by deleted or overwritten keys.
 	Compact() error
 	// GetSnapshot creates a new snapshot in a thread-safe manner.
 	GetSnapshot() Snapshot
@@ -37,6 +42,8 @@ func LoadDB(
 		return LoadCLevelDB(name, directory)
 	case MemDBackend:
 		return LoadMemDB()
+	case BadgerDBBackend:
+		return LoadBadgerDB(name, directory)
 	default:
 		return nil, fmt.Errorf("unknown db backend: %s", dbBackend)
 	}
@@ -382,7 +382,12 @@ func checkAppHash(nodes map[string]*node.Node) error {
 
 	for i := 1; i < len(blockInfo); i++ {
 		if blockInfo[i-1].apphash != blockInfo[i].apphash {
-			return errors.Errorf("app hash mismatch\n%s", sprintAppHashes(blockInfo))
+			return errors.Errorf(
+				"app hash mismatch\n%s"+
+					"run 'loom db diff %s %s --left-height %s --right-height %s' once the nodes are stopped "+
+					"to find the keys that differ",
+				sprintAppHashes(blockInfo), blockInfo[i-1].node.Dir, blockInfo[i].node.Dir, blockHeight, blockHeight,
+			)
 		}
 	}
 	return nil
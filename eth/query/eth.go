@@ -22,6 +22,7 @@ import (
 func QueryChain(
 	blockStore store.BlockStore, state loomchain.ReadOnlyState, ethFilter eth.EthFilter,
 	readReceipts loomchain.ReadReceiptHandler, evmAuxStore *evmaux.EvmAuxStore, maxBlockRange uint64,
+	maxResults uint64,
 ) ([]*ptypes.EthFilterLog, error) {
 	start, err := eth.DecBlockHeight(state.Block().Height, ethFilter.FromBlock)
 	if err != nil {
@@ -35,11 +36,25 @@ func QueryChain(
 		return nil, errors.New("invalid block range")
 	}
 
+	// Large block ranges take too long to scan, but if the filter is selective enough the matching
+	// logs can be looked up via the log index instead.
 	if end-start > maxBlockRange {
-		return nil, fmt.Errorf("max allowed block range (%d) exceeded", maxBlockRange)
+		if !canUseLogIndex(ethFilter.EthBlockFilter, start, evmAuxStore) {
+			return nil, fmt.Errorf("max allowed block range (%d) exceeded", maxBlockRange)
+		}
+		return getIndexedLogs(
+			blockStore, start, end, ethFilter.EthBlockFilter, readReceipts, evmAuxStore, maxResults,
+		)
 	}
 
-	return GetBlockLogRange(blockStore, state, start, end, ethFilter.EthBlockFilter, readReceipts, evmAuxStore)
+	logs, err := GetBlockLogRange(blockStore, state, start, end, ethFilter.EthBlockFilter, readReceipts, evmAuxStore)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkMaxResults(logs, maxResults); err != nil {
+		return nil, err
+	}
+	return logs, nil
 }
 
 func DeprecatedQueryChain(
@@ -0,0 +1,141 @@
+// +build evm
+
+package query
+
+import (
+	"bytes"
+	"sort"
+
+	ptypes "github.com/loomnetwork/go-loom/plugin/types"
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/receipts/common"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+	evmaux "github.com/loomnetwork/loomchain/store/evm_aux"
+	"github.com/pkg/errors"
+)
+
+// Max number of log index entries that will be loaded for a single filter dimension (the address,
+// or one of the topic positions), dimensions that match more logs than this aren't used to plan
+// a query.
+const maxLogIndexCandidates = 100000
+
+// canUseLogIndex checks if the logs matching the given filter in the given block range can be looked
+// up via the log index. The filter must constrain the address or at least one of the topics, and
+// the logs in the range must have been indexed.
+func canUseLogIndex(filter eth.EthBlockFilter, from uint64, evmAuxStore *evmaux.EvmAuxStore) bool {
+	hasConstraint := len(filter.Addresses) > 0
+	for _, topics := range filter.Topics {
+		if len(topics) > 0 {
+			hasConstraint = true
+		}
+	}
+	if !hasConstraint {
+		return false
+	}
+	startHeight, ok := evmAuxStore.LogIndexStartHeight()
+	return ok && from >= startHeight
+}
+
+// planLogIndexQuery loads the log index entries for each dimension constrained by the filter and
+// returns the entries of the most selective dimension, sorted by height, tx index, and log index.
+func planLogIndexQuery(
+	filter eth.EthBlockFilter, from, to uint64, evmAuxStore *evmaux.EvmAuxStore,
+) ([]evmaux.LogRef, error) {
+	var best []evmaux.LogRef
+	found := false
+	// lookup loads the index entries for the i-th value the filter allows in a dimension
+	consider := func(numValues int, lookup func(i int) []evmaux.LogRef) {
+		var refs []evmaux.LogRef
+		for i := 0; i < numValues; i++ {
+			refs = append(refs, lookup(i)...)
+			if len(refs) > maxLogIndexCandidates {
+				return
+			}
+		}
+		if !found || len(refs) < len(best) {
+			best = refs
+			found = true
+		}
+	}
+
+	if len(filter.Addresses) > 0 {
+		consider(len(filter.Addresses), func(i int) []evmaux.LogRef {
+			return evmAuxStore.AddressLogRefs(filter.Addresses[i], from, to, maxLogIndexCandidates+1)
+		})
+	}
+	for pos, topics := range filter.Topics {
+		if len(topics) > 0 {
+			consider(len(topics), func(i int) []evmaux.LogRef {
+				return evmAuxStore.TopicLogRefs(pos, topics[i], from, to, maxLogIndexCandidates+1)
+			})
+		}
+	}
+
+	if !found {
+		return nil, errors.Errorf(
+			"filter matches more than %d logs, narrow down the block range or the filter",
+			maxLogIndexCandidates,
+		)
+	}
+	sort.Slice(best, func(i, j int) bool {
+		if best[i].Height != best[j].Height {
+			return best[i].Height < best[j].Height
+		}
+		if best[i].TxIndex != best[j].TxIndex {
+			return best[i].TxIndex < best[j].TxIndex
+		}
+		return best[i].LogIndex < best[j].LogIndex
+	})
+	return best, nil
+}
+
+// getIndexedLogs looks up the logs matching the given filter in the given block range via the log
+// index, rather than scanning every block in the range.
+func getIndexedLogs(
+	blockStore store.BlockStore,
+	from, to uint64,
+	filter eth.EthBlockFilter,
+	readReceipts loomchain.ReadReceiptHandler,
+	evmAuxStore *evmaux.EvmAuxStore,
+	maxResults uint64,
+) ([]*ptypes.EthFilterLog, error) {
+	refs, err := planLogIndexQuery(filter, from, to, evmAuxStore)
+	if err != nil {
+		return nil, err
+	}
+
+	eventLogs := []*ptypes.EthFilterLog{}
+	var prevTxHash []byte
+	for _, ref := range refs {
+		// the refs are sorted, so all the refs to logs emitted by a tx are next to each other
+		if bytes.Equal(ref.TxHash, prevTxHash) {
+			continue
+		}
+		prevTxHash = ref.TxHash
+
+		txReceipt, err := readReceipts.GetReceipt(ref.TxHash)
+		if errors.Cause(err) == common.ErrTxReceiptNotFound {
+			continue
+		} else if err != nil {
+			return nil, errors.Wrap(err, "failed to load receipt")
+		}
+		logsTx, err := getTxHashLogs(blockStore, txReceipt, filter, ref.TxHash)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load tx logs")
+		}
+		eventLogs = append(eventLogs, logsTx...)
+		if err := checkMaxResults(eventLogs, maxResults); err != nil {
+			return nil, err
+		}
+	}
+	return eventLogs, nil
+}
+
+// checkMaxResults returns an error if there are more than maxResults logs, zero means no limit.
+func checkMaxResults(logs []*ptypes.EthFilterLog, maxResults uint64) error {
+	if maxResults > 0 && uint64(len(logs)) > maxResults {
+		return errors.Errorf("query returned more than %d results", maxResults)
+	}
+	return nil
+}
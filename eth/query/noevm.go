@@ -68,7 +68,7 @@ func GetTxByBlockAndIndex(_ store.BlockStore, _, _ uint64, _ *evmaux.EvmAuxStore
 
 func QueryChain(
 	_ store.BlockStore, _ loomchain.ReadOnlyState, _ eth.EthFilter,
-	_ loomchain.ReadReceiptHandler, _ *evmaux.EvmAuxStore, _ uint64,
+	_ loomchain.ReadReceiptHandler, _ *evmaux.EvmAuxStore, _ uint64, _ uint64,
 ) ([]*types.EthFilterLog, error) {
 	return nil, nil
 }
@@ -100,15 +100,103 @@ func TestQueryChain(t *testing.T) {
 	require.NoError(t, err)
 	ethFilter2, err := utils.UnmarshalEthFilter([]byte(getFilter("1", "10")))
 	require.NoError(t, err)
-	filterLogs1, err := QueryChain(blockStore, state30, ethFilter1, receiptHandler, evmAuxStore, 100000)
+	filterLogs1, err := QueryChain(blockStore, state30, ethFilter1, receiptHandler, evmAuxStore, 100000, 0)
 	require.NoError(t, err, "error query chain, filter is %s", ethFilter1)
-	filterLogs2, err := QueryChain(blockStore, state30, ethFilter2, receiptHandler, evmAuxStore, 100000)
+	filterLogs2, err := QueryChain(blockStore, state30, ethFilter2, receiptHandler, evmAuxStore, 100000, 0)
 	require.NoError(t, err, "error query chain, filter is %s", ethFilter2)
 	require.Equal(t, 2, len(filterLogs1)+len(filterLogs2), "wrong number of logs returned")
 
 	require.NoError(t, receiptHandler.Close())
 }
 
+func TestQueryChainLogIndex(t *testing.T) {
+	evmAuxStore, err := common.NewMockEvmAuxStore()
+	require.NoError(t, err)
+	eventDispatcher := events.NewLogEventDispatcher()
+	eventHandler := loomchain.NewDefaultEventHandler(eventDispatcher)
+	receiptHandler := handler.NewReceiptHandler(eventHandler, handler.DefaultMaxReceipts, evmAuxStore)
+	var writer loomchain.WriteReceiptHandler = receiptHandler
+	blockStore := store.NewMockBlockStore()
+	state := common.MockState(0)
+
+	for _, height := range []uint64{4, 150} {
+		eventData := []*types.EventData{
+			{
+				Topics:      []string{"topic1", "topic2"},
+				EncodedBody: []byte("somedata"),
+				Address:     addr1.MarshalPB(),
+			},
+			{
+				Topics:      []string{"topic3"},
+				EncodedBody: []byte("somedata"),
+				Address:     addr2.MarshalPB(),
+			},
+		}
+		evmTxHash, err := writer.CacheReceipt(common.MockStateAt(state, height), addr1, addr2, eventData, nil, []byte{})
+		require.NoError(t, err)
+		receiptHandler.CommitCurrentReceipt()
+		require.NoError(t, receiptHandler.CommitBlock(int64(height)))
+		tx := mockSignedTx(t, ltypes.TxID_CALL, loom.Address{}, loom.Address{}, evmTxHash)
+		blockStore.SetBlockResults(store.MockBlockResults(int64(height), [][]byte{evmTxHash}))
+		blockStore.SetBlock(store.MockBlock(int64(height), evmTxHash, [][]byte{tx}))
+	}
+
+	startHeight, ok := evmAuxStore.LogIndexStartHeight()
+	require.True(t, ok)
+	require.Equal(t, uint64(4), startHeight)
+
+	state200 := common.MockStateAt(state, uint64(200))
+	filter := func(blockFilter eth.EthBlockFilter) eth.EthFilter {
+		return eth.EthFilter{EthBlockFilter: blockFilter, FromBlock: "0x1", ToBlock: "0xc8"}
+	}
+
+	// the block range is too large to scan, and the filter can't be used to query the index
+	_, err = QueryChain(blockStore, state200, filter(eth.EthBlockFilter{}), receiptHandler, evmAuxStore, 20, 0)
+	require.Error(t, err)
+
+	logs, err := QueryChain(
+		blockStore, state200, filter(eth.EthBlockFilter{Addresses: []loom.LocalAddress{addr1.Local}}),
+		receiptHandler, evmAuxStore, 20, 0,
+	)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.EqualValues(t, 4, logs[0].BlockNumber)
+	require.EqualValues(t, 150, logs[1].BlockNumber)
+	require.EqualValues(t, 0, logs[0].LogIndex)
+
+	logs, err = QueryChain(
+		blockStore, state200,
+		filter(eth.EthBlockFilter{
+			Addresses: []loom.LocalAddress{addr1.Local, addr2.Local},
+			Topics:    [][]string{{"topic3"}},
+		}),
+		receiptHandler, evmAuxStore, 20, 0,
+	)
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	require.EqualValues(t, 1, logs[0].LogIndex)
+	require.Equal(t, addr2.Local, loom.LocalAddress(logs[0].Address))
+
+	// topic2 only appears in the second position
+	logs, err = QueryChain(
+		blockStore, state200, filter(eth.EthBlockFilter{Topics: [][]string{{"topic2"}}}),
+		receiptHandler, evmAuxStore, 20, 0,
+	)
+	require.NoError(t, err)
+	require.Len(t, logs, 0)
+
+	// the result cap applies to both the indexed & scanned queries
+	_, err = QueryChain(
+		blockStore, state200, filter(eth.EthBlockFilter{Addresses: []loom.LocalAddress{addr1.Local}}),
+		receiptHandler, evmAuxStore, 20, 1,
+	)
+	require.Error(t, err)
+	_, err = QueryChain(blockStore, state200, filter(eth.EthBlockFilter{}), receiptHandler, evmAuxStore, 1000, 3)
+	require.Error(t, err)
+
+	require.NoError(t, receiptHandler.Close())
+}
+
 func TestMatchFilters(t *testing.T) {
 	addr3 := &ltypes.Address{
 		ChainId: "defult",
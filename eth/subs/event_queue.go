@@ -0,0 +1,203 @@
+package subs
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/phonkee/go-pubsub"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+
+	"github.com/loomnetwork/loomchain/log"
+)
+
+const (
+	// DropOnOverflow drops the events published to a subscriber while its queue is full.
+	DropOnOverflow = "drop"
+	// CloseOnOverflow disconnects the client of a subscriber whose queue is full, the client can
+	// then reconnect and resume the subscription from the last event it received.
+	CloseOnOverflow = "close"
+
+	defaultQueueSize = 1000
+)
+
+var droppedEventCount metrics.Counter
+
+func init() {
+	droppedEventCount = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "loomchain",
+		Subsystem: "eth_subscriptions",
+		Name:      "dropped_event_count",
+		Help:      "Number of events that couldn't be queued for slow subscribers.",
+	}, []string{"policy"})
+}
+
+// EventSender sends subscription events to a client.
+type EventSender interface {
+	// Send sends the given message to the client, returns false if the client has disconnected.
+	Send(msg []byte) bool
+	// Close disconnects the client.
+	Close()
+}
+
+// SubscriptionOptions control how events are delivered to a subscriber.
+type SubscriptionOptions struct {
+	// QueueSize is the max number of events that can be waiting to be sent to the subscriber.
+	QueueSize int
+	// OverflowPolicy determines what happens when the queue is full, either DropOnOverflow or
+	// CloseOnOverflow.
+	OverflowPolicy string
+	// Resume should be set if the subscriber missed some events, no events will be sent to the
+	// subscriber until the missed events are passed to EthSubscriptionSet.Replay.
+	Resume bool
+}
+
+// eventMessage is a published message that carries the position of the event in the chain.
+type eventMessage struct {
+	pubsub.Message
+	token ResumeToken
+}
+
+type queuedEvent struct {
+	token  *ResumeToken
+	result json.RawMessage
+}
+
+type replayBatch struct {
+	events []ReplayEvent
+	height uint64
+}
+
+// eventQueue buffers the events published to a subscriber until they're sent to the client, so slow
+// clients don't hold up the publisher.
+type eventQueue struct {
+	id          string
+	sender      EventSender
+	policy      string
+	events      chan queuedEvent
+	replay      chan replayBatch
+	done        chan struct{}
+	closeOnce   sync.Once
+	overflow    sync.Once
+	unsubscribe func()
+}
+
+// newEventQueue creates a queue for the subscriber with the given ID, and starts sending events to
+// the client. The given unsubscribe func will be called if the client disconnects.
+func newEventQueue(id string, sender EventSender, opts *SubscriptionOptions, unsubscribe func()) *eventQueue {
+	if opts == nil {
+		opts = &SubscriptionOptions{}
+	}
+	queueSize := opts.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	policy := opts.OverflowPolicy
+	if policy != CloseOnOverflow {
+		policy = DropOnOverflow
+	}
+	q := &eventQueue{
+		id:          id,
+		sender:      sender,
+		policy:      policy,
+		events:      make(chan queuedEvent, queueSize),
+		replay:      make(chan replayBatch, 1),
+		done:        make(chan struct{}),
+		unsubscribe: unsubscribe,
+	}
+	go q.run(opts.Resume)
+	return q
+}
+
+// push adds an event to the queue, if the queue is full the overflow policy is applied. This never
+// blocks, so it's safe to call while publishing.
+func (q *eventQueue) push(msg pubsub.Message) {
+	event := queuedEvent{result: msg.Body()}
+	if em, ok := msg.(eventMessage); ok {
+		event.token = &em.token
+	}
+	select {
+	case q.events <- event:
+	default:
+		droppedEventCount.With("policy", q.policy).Add(1)
+		if q.policy == CloseOnOverflow {
+			q.overflow.Do(func() {
+				log.Debug("Closing subscription with full event queue", "id", q.id)
+				go func() {
+					q.unsubscribe()
+					q.sender.Close()
+				}()
+			})
+		}
+	}
+}
+
+// startReplay sends the given events to the client before any queued events, queued events from
+// blocks up to & including the given height are skipped since they're expected to be replayed.
+func (q *eventQueue) startReplay(events []ReplayEvent, height uint64) {
+	select {
+	case q.replay <- replayBatch{events: events, height: height}:
+	default:
+		log.Error("Subscription events already replayed", "id", q.id)
+	}
+}
+
+func (q *eventQueue) close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+	})
+}
+
+func (q *eventQueue) run(resume bool) {
+	var replayedHeight uint64
+	if resume {
+		select {
+		case batch := <-q.replay:
+			for i := range batch.events {
+				if !q.send(queuedEvent{token: &batch.events[i].Token, result: batch.events[i].Result}) {
+					q.unsubscribe()
+					return
+				}
+			}
+			replayedHeight = batch.height
+		case <-q.done:
+			return
+		}
+	}
+
+	for {
+		select {
+		case event := <-q.events:
+			if resume && event.token != nil && event.token.Height <= replayedHeight {
+				continue
+			}
+			if !q.send(event) {
+				q.unsubscribe()
+				return
+			}
+		case <-q.done:
+			return
+		}
+	}
+}
+
+func (q *eventQueue) send(event queuedEvent) bool {
+	resp := ethWSJsonRpcResponse{
+		Params: ethWSJsonResult{
+			Result:       event.result,
+			Subscription: q.id,
+		},
+		Version: "2.0",
+		Method:  "eth_subscription",
+	}
+	if event.token != nil {
+		resp.Params.ResumeToken = event.token.String()
+	}
+	jsonBytes, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Error("Failed to marshal subscription event", "err", err, "id", q.id)
+		return true
+	}
+	return q.sender.Send(jsonBytes)
+}
@@ -31,13 +31,34 @@ func (h *ethResetHub) CloseSubscriber(subscriber pubsub.Subscriber) {
 	panic("should never be called")
 }
 
+func (h *ethResetHub) addClient(id string, sub pubsub.Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.clients[id] = sub
+	h.unsent[id] = true
+}
+
 func (h *ethResetHub) closeSubscription(id string) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
+	if sub, ok := h.clients[id]; ok {
+		sub.Close()
+	}
 	delete(h.clients, id)
 	delete(h.unsent, id)
 }
 
+// getQueue returns the event queue of the subscriber with the given ID, or nil if there's no such
+// subscriber.
+func (h *ethResetHub) getQueue(id string) *eventQueue {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	if sub, ok := h.clients[id].(interface{ getQueue() *eventQueue }); ok {
+		return sub.getQueue()
+	}
+	return nil
+}
+
 // Publish publishes message to subscribers
 func (h *ethResetHub) Publish(message pubsub.Message) int {
 	h.mutex.RLock()
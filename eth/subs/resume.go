@@ -0,0 +1,59 @@
+package subs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ResumeToken identifies the position of an event in the chain. Each event sent to an eth_subscribe
+// subscriber is accompanied by its resume token, a client that loses its connection can pass the
+// token of the last event it received to eth_subscribe to have the events it missed replayed.
+// Log indices are only unique within a tx, so the position of a log also includes its tx index.
+type ResumeToken struct {
+	Height   uint64
+	TxIndex  uint64
+	LogIndex uint64
+}
+
+func (t ResumeToken) String() string {
+	return fmt.Sprintf("%d.%d.%d", t.Height, t.TxIndex, t.LogIndex)
+}
+
+// After returns true if the event identified by this token comes after the event identified by the
+// other token.
+func (t ResumeToken) After(other ResumeToken) bool {
+	if t.Height != other.Height {
+		return t.Height > other.Height
+	}
+	if t.TxIndex != other.TxIndex {
+		return t.TxIndex > other.TxIndex
+	}
+	return t.LogIndex > other.LogIndex
+}
+
+// ParseResumeToken decodes a resume token previously encoded by ResumeToken.String().
+func ParseResumeToken(token string) (ResumeToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ResumeToken{}, fmt.Errorf("invalid resume token %s", token)
+	}
+	var values [3]uint64
+	for i, part := range parts {
+		value, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return ResumeToken{}, errors.Wrapf(err, "invalid resume token %s", token)
+		}
+		values[i] = value
+	}
+	return ResumeToken{Height: values[0], TxIndex: values[1], LogIndex: values[2]}, nil
+}
+
+// ReplayEvent is an event a resumed subscriber missed while it was disconnected.
+type ReplayEvent struct {
+	Token  ResumeToken
+	Result json.RawMessage
+}
@@ -5,8 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"sync"
+	"time"
 
-	"github.com/gorilla/websocket"
 	"github.com/loomnetwork/loomchain/eth/utils"
 	"github.com/loomnetwork/loomchain/rpc/eth"
 	"github.com/phonkee/go-pubsub"
@@ -32,53 +32,57 @@ func newHeadsResetHub() *headsResetHub {
 	}
 }
 
-func (pt *headsResetHub) addSubscriber(conn *websocket.Conn) string {
+func (pt *headsResetHub) addSubscriber(sender EventSender, opts *SubscriptionOptions) string {
 	id := utils.GetId()
-	sub := newTopicSubscriber(pt, id, NewHeads, conn)
-	pt.clients[id] = sub
-	pt.unsent[id] = true
+	queue := newEventQueue(id, sender, opts, func() { pt.closeSubscription(id) })
+	pt.addClient(id, newTopicSubscriber(pt, id, NewHeads, queue))
 	return id
 }
 
+// EncNewHead returns the block object sent to newHeads subscribers for the given block.
+func EncNewHead(height int64, blockTime time.Time, proposerAddress []byte, parentHash []byte) eth.JsonBlockObject {
+	var proposer eth.Data
+	if proposerAddress != nil {
+		proposer = eth.EncBytes(proposerAddress)
+	} else {
+		proposer = eth.ZeroedData20Bytes
+	}
+	return eth.JsonBlockObject{
+		Difficulty:       eth.ZeroedQuantity,
+		ExtraData:        eth.ZeroedData,
+		GasLimit:         eth.EncInt(0),
+		GasUsed:          eth.EncInt(0),
+		LogsBloom:        eth.ZeroedData256Bytes,
+		Miner:            proposer,
+		Nonce:            eth.ZeroedData20Bytes,
+		Number:           eth.EncInt(height),
+		ParentHash:       eth.EncBytes(parentHash),
+		ReceiptsRoot:     eth.ZeroedData32Bytes,
+		Sha3Uncles:       eth.ZeroedData32Bytes,
+		StateRoot:        eth.ZeroedData32Bytes,
+		Timestamp:        eth.EncInt(blockTime.Unix()),
+		TransactionsRoot: eth.ZeroedData32Bytes,
+		Uncles:           []eth.Data{},
+		Transactions:     make([]interface{}, 0),
+	}
+}
+
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getblockbyhash and
 // https://github.com/ethereum/go-ethereum/wiki/RPC-PUB-SUB
 // both suggest we should not show the block's hash and details of the blocks transactions
 // however we could do, as the information is available at this point.
 func (nh *headsResetHub) emitBlockEvent(header abci.Header) (err error) {
 	if len(nh.clients) > 0 {
-		var proposalAddress eth.Data
-
-		if header.ProposerAddress != nil {
-			proposalAddress = eth.EncBytes(header.ProposerAddress)
-		} else {
-			proposalAddress = eth.ZeroedData20Bytes
-		}
-		blockinfo := eth.JsonBlockObject{
-			Difficulty:       eth.ZeroedQuantity,
-			ExtraData:        eth.ZeroedData,
-			GasLimit:         eth.EncInt(0),
-			GasUsed:          eth.EncInt(0),
-			LogsBloom:        eth.ZeroedData256Bytes,
-			Miner:            proposalAddress,
-			Nonce:            eth.ZeroedData20Bytes,
-			Number:           eth.EncInt(header.Height),
-			ParentHash:       eth.EncBytes(header.LastBlockId.Hash),
-			ReceiptsRoot:     eth.ZeroedData32Bytes,
-			Sha3Uncles:       eth.ZeroedData32Bytes,
-			StateRoot:        eth.ZeroedData32Bytes,
-			Timestamp:        eth.EncInt(header.Time.Unix()),
-			TransactionsRoot: eth.ZeroedData32Bytes,
-			Uncles:           []eth.Data{},
-		}
-
-		if len(blockinfo.Transactions) == 0 {
-			blockinfo.Transactions = make([]interface{}, 0)
-		}
-
+		blockinfo := EncNewHead(
+			header.Height, header.Time, header.ProposerAddress, header.LastBlockId.Hash,
+		)
 		emitMsg, err := json.Marshal(&blockinfo)
 		if err == nil {
 			nh.Reset()
-			nh.Publish(pubsub.NewMessage(NewHeads, emitMsg))
+			nh.Publish(eventMessage{
+				Message: pubsub.NewMessage(NewHeads, emitMsg),
+				token:   ResumeToken{Height: uint64(header.Height)},
+			})
 		}
 	}
 	return nil
@@ -95,11 +99,10 @@ func newPendingTxsResetHub() *pendingTxsResetHub {
 	}
 }
 
-func (pt *pendingTxsResetHub) addSubscriber(conn *websocket.Conn) string {
+func (pt *pendingTxsResetHub) addSubscriber(sender EventSender, opts *SubscriptionOptions) string {
 	id := utils.GetId()
-	sub := newTopicSubscriber(pt, id, NewPendingTransactions, conn)
-	pt.clients[id] = sub
-	pt.unsent[id] = true
+	queue := newEventQueue(id, sender, opts, func() { pt.closeSubscription(id) })
+	pt.addClient(id, newTopicSubscriber(pt, id, NewPendingTransactions, queue))
 	return id
 }
 
@@ -142,10 +145,9 @@ func (l *logsResetHub) getFilter(id string) (*eth.EthFilter, error) {
 	}
 }
 
-func (l *logsResetHub) addSubscriber(filter eth.EthFilter, conn *websocket.Conn) string {
+func (l *logsResetHub) addSubscriber(filter eth.EthFilter, sender EventSender, opts *SubscriptionOptions) string {
 	id := utils.GetId()
-	sub := newLogSubscriber(l, id, filter, conn)
-	l.clients[id] = sub
-	l.unsent[id] = true
+	queue := newEventQueue(id, sender, opts, func() { l.closeSubscription(id) })
+	l.addClient(id, newLogSubscriber(l, id, filter, queue))
 	return id
 }
@@ -1,9 +1,11 @@
 package subs
 
 import (
+	"encoding/json"
 	"fmt"
+	"sync"
+
 	"github.com/gogo/protobuf/proto"
-	"github.com/gorilla/websocket"
 	"github.com/loomnetwork/go-loom/plugin/types"
 	"github.com/loomnetwork/loomchain/rpc/eth"
 	"github.com/phonkee/go-pubsub"
@@ -15,6 +17,10 @@ type EthSubscriptionSet struct {
 	logsHub      logsResetHub
 	newHeadsHub  headsResetHub
 	pendingTxHub pendingTxsResetHub
+
+	// Tracks the index of the next log of each tx in the block that's being emitted.
+	logIndexMutex sync.Mutex
+	logIndexes    map[uint64]uint64
 }
 
 func NewEthSubscriptionSet() *EthSubscriptionSet {
@@ -22,22 +28,27 @@ func NewEthSubscriptionSet() *EthSubscriptionSet {
 		logsHub:      *newLogsResetHubResetHub(),
 		newHeadsHub:  *newHeadsResetHub(),
 		pendingTxHub: *newPendingTxsResetHub(),
+		logIndexes:   map[uint64]uint64{},
 	}
 	return s
 }
 
+// AddSubscription subscribes the client to the given events, the events will be sent to the client
+// via the given sender. If opts is nil the default options will be used.
 func (s *EthSubscriptionSet) AddSubscription(
 	method string,
 	filter eth.EthFilter,
-	conn *websocket.Conn) (string, error) {
+	sender EventSender,
+	opts *SubscriptionOptions,
+) (string, error) {
 	var id string
 	switch method {
 	case Logs:
-		id = s.logsHub.addSubscriber(filter, conn)
+		id = s.logsHub.addSubscriber(filter, sender, opts)
 	case NewHeads:
-		id = s.newHeadsHub.addSubscriber(conn)
+		id = s.newHeadsHub.addSubscriber(sender, opts)
 	case NewPendingTransactions:
-		id = s.pendingTxHub.addSubscriber(conn)
+		id = s.pendingTxHub.addSubscriber(sender, opts)
 	case Syncing:
 		return "", fmt.Errorf("syncing not supported")
 	default:
@@ -46,6 +57,24 @@ func (s *EthSubscriptionSet) AddSubscription(
 	return id, nil
 }
 
+// Replay sends the given events to a subscriber that was added with the Resume option, before any
+// new events. New events from blocks up to and including the given height are expected to be among
+// the replayed events, so they won't be sent to the subscriber again.
+func (s *EthSubscriptionSet) Replay(id string, events []ReplayEvent, height uint64) error {
+	queue := s.logsHub.getQueue(id)
+	if queue == nil {
+		queue = s.newHeadsHub.getQueue(id)
+	}
+	if queue == nil {
+		queue = s.pendingTxHub.getQueue(id)
+	}
+	if queue == nil {
+		return fmt.Errorf("subscription %s not found", id)
+	}
+	queue.startReplay(events, height)
+	return nil
+}
+
 func (s *EthSubscriptionSet) EmitBlockEvent(header abci.Header) (err error) {
 	return s.newHeadsHub.emitBlockEvent(header)
 }
@@ -59,12 +88,40 @@ func (s *EthSubscriptionSet) EmitEvent(data types.EventData) error {
 	if err != nil {
 		return errors.Wrapf(err, "marshaling event %v", data)
 	}
-	s.logsHub.Publish(pubsub.NewMessage(string(ethMsg), eth.EncEvent(data)))
+	token := ResumeToken{
+		Height:   data.BlockHeight,
+		TxIndex:  data.TransactionIndex,
+		LogIndex: s.nextLogIndex(data.TransactionIndex),
+	}
+	jsonLog := eth.EncEvent(data)
+	jsonLog.LogIndex = eth.EncInt(int64(token.LogIndex))
+	emitMsg, err := json.Marshal(&jsonLog)
+	if err != nil {
+		return errors.Wrapf(err, "marshaling log %v", jsonLog)
+	}
+	s.logsHub.Publish(eventMessage{
+		Message: pubsub.NewMessage(string(ethMsg), emitMsg),
+		token:   token,
+	})
 	return nil
 }
 
+// nextLogIndex returns the index of the next log emitted by the tx with the given index, this
+// matches the index of the log in the tx receipt.
+func (s *EthSubscriptionSet) nextLogIndex(txIndex uint64) uint64 {
+	s.logIndexMutex.Lock()
+	defer s.logIndexMutex.Unlock()
+	logIndex := s.logIndexes[txIndex]
+	s.logIndexes[txIndex] = logIndex + 1
+	return logIndex
+}
+
+// Reset should be called before the events of the next block are emitted.
 func (s *EthSubscriptionSet) Reset() {
 	s.logsHub.Reset()
+	s.logIndexMutex.Lock()
+	s.logIndexes = map[uint64]uint64{}
+	s.logIndexMutex.Unlock()
 }
 
 func (s *EthSubscriptionSet) Remove(id string) {
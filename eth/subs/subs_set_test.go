@@ -0,0 +1,154 @@
+package subs
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+type mockEventSender struct {
+	mutex  sync.Mutex
+	events []ethWSJsonRpcResponse
+	block  chan struct{}
+	closed bool
+}
+
+func (s *mockEventSender) Send(msg []byte) bool {
+	if s.block != nil {
+		<-s.block
+	}
+	var resp ethWSJsonRpcResponse
+	if err := json.Unmarshal(msg, &resp); err != nil {
+		return false
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, resp)
+	return !s.closed
+}
+
+func (s *mockEventSender) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+}
+
+func (s *mockEventSender) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+func (s *mockEventSender) resumeTokens() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tokens := make([]string, 0, len(s.events))
+	for _, event := range s.events {
+		tokens = append(tokens, event.Params.ResumeToken)
+	}
+	return tokens
+}
+
+// waitFor waits for the given condition to be met by the subscriber goroutines.
+func waitFor(t *testing.T, condition func() bool) {
+	for i := 0; i < 100; i++ {
+		if condition() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("timed out waiting for subscriber")
+}
+
+func emitBlocks(t *testing.T, set *EthSubscriptionSet, from, to int64) {
+	for h := from; h <= to; h++ {
+		require.NoError(t, set.EmitBlockEvent(abci.Header{Height: h, Time: time.Unix(h, 0)}))
+	}
+}
+
+func TestResumeToken(t *testing.T) {
+	token, err := ParseResumeToken("10.2.3")
+	require.NoError(t, err)
+	require.Equal(t, ResumeToken{Height: 10, TxIndex: 2, LogIndex: 3}, token)
+	require.Equal(t, "10.2.3", token.String())
+
+	require.True(t, ResumeToken{Height: 11}.After(token))
+	require.True(t, ResumeToken{Height: 10, TxIndex: 3}.After(token))
+	require.True(t, ResumeToken{Height: 10, TxIndex: 2, LogIndex: 4}.After(token))
+	require.False(t, token.After(token))
+	require.False(t, ResumeToken{Height: 10, TxIndex: 1, LogIndex: 9}.After(token))
+
+	for _, invalid := range []string{"", "10", "10.2", "10.2.x", "-1.0.0", "1.2.3.4"} {
+		_, err := ParseResumeToken(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestEventQueueDropOnOverflow(t *testing.T) {
+	set := NewEthSubscriptionSet()
+	sender := &mockEventSender{block: make(chan struct{})}
+	_, err := set.AddSubscription(NewHeads, eth.EthFilter{}, sender, &SubscriptionOptions{
+		QueueSize:      2,
+		OverflowPolicy: DropOnOverflow,
+	})
+	require.NoError(t, err)
+
+	// the first event is held up by the sender, the next two fill up the queue, the rest are dropped
+	emitBlocks(t, set, 1, 1)
+	time.Sleep(50 * time.Millisecond)
+	emitBlocks(t, set, 2, 10)
+	close(sender.block)
+	waitFor(t, func() bool { return len(sender.resumeTokens()) == 3 })
+	require.Equal(t, []string{"1.0.0", "2.0.0", "3.0.0"}, sender.resumeTokens())
+	require.False(t, sender.isClosed())
+
+	// the subscriber keeps receiving new events once it catches up
+	emitBlocks(t, set, 11, 11)
+	waitFor(t, func() bool { return len(sender.resumeTokens()) == 4 })
+	require.Equal(t, "11.0.0", sender.resumeTokens()[3])
+}
+
+func TestEventQueueCloseOnOverflow(t *testing.T) {
+	set := NewEthSubscriptionSet()
+	sender := &mockEventSender{block: make(chan struct{})}
+	id, err := set.AddSubscription(NewHeads, eth.EthFilter{}, sender, &SubscriptionOptions{
+		QueueSize:      2,
+		OverflowPolicy: CloseOnOverflow,
+	})
+	require.NoError(t, err)
+
+	emitBlocks(t, set, 1, 1)
+	time.Sleep(50 * time.Millisecond)
+	emitBlocks(t, set, 2, 10)
+	waitFor(t, sender.isClosed)
+	close(sender.block)
+	waitFor(t, func() bool { return set.newHeadsHub.getQueue(id) == nil })
+}
+
+func TestEventQueueReplay(t *testing.T) {
+	set := NewEthSubscriptionSet()
+	sender := &mockEventSender{}
+	id, err := set.AddSubscription(NewHeads, eth.EthFilter{}, sender, &SubscriptionOptions{Resume: true})
+	require.NoError(t, err)
+
+	// new events are held back until the missed events are replayed
+	emitBlocks(t, set, 4, 6)
+	time.Sleep(50 * time.Millisecond)
+	require.Empty(t, sender.resumeTokens())
+
+	var events []ReplayEvent
+	for h := uint64(2); h <= 5; h++ {
+		events = append(events, ReplayEvent{Token: ResumeToken{Height: h}, Result: json.RawMessage(`{}`)})
+	}
+	require.NoError(t, set.Replay(id, events, 5))
+	require.Error(t, set.Replay("0xbad", events, 5))
+
+	// events 4 & 5 were queued & replayed, but should only be sent once
+	waitFor(t, func() bool { return len(sender.resumeTokens()) == 5 })
+	require.Equal(t, []string{"2.0.0", "3.0.0", "4.0.0", "5.0.0", "6.0.0"}, sender.resumeTokens())
+}
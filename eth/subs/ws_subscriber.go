@@ -2,15 +2,15 @@ package subs
 
 import (
 	"encoding/json"
-	"github.com/gorilla/websocket"
-	"github.com/loomnetwork/loomchain/log"
-	"github.com/phonkee/go-pubsub"
 	"sync"
+
+	"github.com/phonkee/go-pubsub"
 )
 
 type ethWSJsonResult struct {
 	Result       json.RawMessage `json:"result"`
 	Subscription string          `json:"subscription"`
+	ResumeToken  string          `json:"resumeToken,omitempty"`
 }
 
 type ethWSJsonRpcResponse struct {
@@ -24,37 +24,27 @@ type wsSubscriber struct {
 	mutex *sync.RWMutex
 	sf    pubsub.SubscriberFunc
 	id    string
-	conn  *websocket.Conn
+	queue *eventQueue
 }
 
-func newWsSubscriber(hub pubsub.ResetHub, conn *websocket.Conn, id string) *wsSubscriber {
-	sf := func(msg pubsub.Message) {
-		resp := ethWSJsonRpcResponse{
-			Params:  ethWSJsonResult{msg.Body(), id},
-			Version: "2.0",
-			Method:  "eth_subscription",
-		}
-
-		jsonBytes, err := json.MarshalIndent(resp, "", "  ")
-		if err != nil {
-			log.Error("error %v marshalling event %v, id %s", err, msg, id)
-		}
-		if err := conn.WriteMessage(websocket.TextMessage, jsonBytes); err != nil {
-			log.Error("error %v writing event %v to websocket, id %s ", err, jsonBytes, id)
-		}
-	}
-
+func newWsSubscriber(hub pubsub.ResetHub, queue *eventQueue, id string) *wsSubscriber {
 	return &wsSubscriber{
 		hub:   hub,
 		mutex: &sync.RWMutex{},
 		id:    id,
-		sf:    sf,
-		conn:  conn,
+		sf:    queue.push,
+		queue: queue,
 	}
 }
 
-// Do nothing. Closing websocket connection is done by the handler not here.
+// Close stops sending events to the client. Closing websocket connection is done by the handler
+// not here.
 func (s wsSubscriber) Close() {
+	s.queue.close()
+}
+
+func (s wsSubscriber) getQueue() *eventQueue {
+	return s.queue
 }
 
 // Do sets subscriber function that will be called when message arrives
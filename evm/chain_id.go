@@ -0,0 +1,17 @@
+package evm
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	sha3 "github.com/miguelmota/go-solidity-sha3"
+)
+
+// EthChainID returns the numeric ID Ethereum clients use to identify the Loom chain with the given
+// ID, it's the value returned by net_version, and by the CHAINID opcode once Istanbul is enabled.
+func EthChainID(chainID string) *big.Int {
+	hash := sha3.SoliditySHA3(sha3.String(chainID))
+	ethChainID := new(big.Int)
+	ethChainID.SetString(hex.EncodeToString(hash)[0:13], 16)
+	return ethChainID
+}
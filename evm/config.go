@@ -12,15 +12,29 @@ type EvmStoreConfig struct {
 	WriteBufferMegs int
 	// NumCachedRoots defines a number of in-memory cached EVM roots
 	NumCachedRoots int
+	// Number of the latest EVM state versions to keep, if zero old versions will never be deleted.
+	// If non-zero unreachable trie nodes will be periodically deleted from the EVM store.
+	MaxVersions int64
+	// Number of seconds to wait between each run of the EVM store pruner.
+	PruneInterval int64
+	// Maximum number of keys the EVM store pruner should delete at a time.
+	PruneBatchSize int
+	// Maximum number of reachable trie nodes the EVM store pruner should keep track of in memory,
+	// if the EVM state contains more nodes than this the pruner will give up.
+	PruneMaxMarkedKeys int
 }
 
 func DefaultEvmStoreConfig() *EvmStoreConfig {
 	return &EvmStoreConfig{
-		DBName:          "evm",
-		DBBackend:       "goleveldb",
-		CacheSizeMegs:   256,
-		WriteBufferMegs: 4,
-		NumCachedRoots:  100,
+		DBName:             "evm",
+		DBBackend:          "goleveldb",
+		CacheSizeMegs:      256,
+		WriteBufferMegs:    4,
+		NumCachedRoots:     100,
+		MaxVersions:        0,
+		PruneInterval:      600,
+		PruneBatchSize:     10000,
+		PruneMaxMarkedKeys: 10000000,
 	}
 }
 
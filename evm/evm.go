@@ -145,17 +145,20 @@ type Evm struct {
 	vmConfig        vm.Config
 	validateTxValue bool
 	gasLimit        uint64
+	// Tracks the gas used by the current tx, nil when the EVM isn't executing a tx in a block.
+	gasMeter *loomchain.GasMeter
+	// Limits the gas available to each call to the gas remaining in the gas meter.
+	meterGas bool
 }
 
 func NewEvm(sdb vm.StateDB, lstate loomchain.State, abm *evmAccountBalanceManager, debug bool) *Evm {
 	p := new(Evm)
 	p.sdb = sdb
-	p.gasLimit = lstate.Config().GetEvm().GetGasLimit()
-	if p.gasLimit == 0 {
-		p.gasLimit = defaultGasLimit
-	}
+	p.gasLimit = evmGasLimit(lstate)
+	p.gasMeter = loomchain.GasMeterFromContext(lstate.Context())
+	p.meterGas = lstate.FeatureEnabled(features.EvmGasMeteringFeature, false)
 
-	p.chainConfig = defaultChainConfig(lstate.FeatureEnabled(features.EvmConstantinopleFeature, false))
+	p.chainConfig = defaultChainConfig(lstate)
 
 	p.vmConfig = defaultVmConfig(debug)
 	p.validateTxValue = lstate.FeatureEnabled(features.CheckTxValueFeature, false)
@@ -171,6 +174,19 @@ func NewEvm(sdb vm.StateDB, lstate loomchain.State, abm *evmAccountBalanceManage
 		Difficulty:  new(big.Int),
 		GasLimit:    p.gasLimit,
 		GasPrice:    big.NewInt(0),
+		Precompiles: loomPrecompiles(lstate),
+	}
+	if lstate.FeatureEnabled(features.EvmBlockContextFeature, false) {
+		p.context.GetHash = func(n uint64) common.Hash {
+			return common.BytesToHash(loomchain.GetRecentBlockHash(lstate, n))
+		}
+		p.context.Coinbase = common.BytesToAddress(loomchain.GetBlockProposer(lstate))
+	}
+	if p.meterGas {
+		// GASLIMIT should return the block gas limit, if there is one
+		if blockGasLimit := lstate.OnChainSettings().EvmBlockGasLimit; blockGasLimit > 0 {
+			p.context.GasLimit = blockGasLimit
+		}
 	}
 	if abm != nil {
 		p.context.CanTransfer = func(db vm.StateDB, addr common.Address, amount *big.Int) bool {
@@ -206,8 +222,14 @@ func (e Evm) Create(caller loom.Address, code []byte, value *loom.BigUInt) ([]by
 		}
 	}
 
-	runCode, address, leftOverGas, err := vmenv.Create(vm.AccountRef(origin), code, e.gasLimit, val)
-	usedGas = e.gasLimit - leftOverGas
+	gas, err := e.callGasLimit()
+	if err != nil {
+		return nil, loom.Address{}, err
+	}
+	runCode, address, leftOverGas, err := vmenv.Create(vm.AccountRef(origin), code, gas, val)
+	usedGas = gas - leftOverGas
+	e.consumeGas(usedGas)
+	err = wrapRevertError(runCode, err)
 	loomAddress := loom.Address{
 		ChainID: caller.ChainID,
 		Local:   address.Bytes(),
@@ -242,17 +264,52 @@ func (e Evm) Call(caller, addr loom.Address, input []byte, value *loom.BigUInt)
 			return nil, errors.Errorf("value %v must be non negative", value)
 		}
 	}
-	ret, leftOverGas, err := vmenv.Call(vm.AccountRef(origin), contract, input, e.gasLimit, val)
-	usedGas = e.gasLimit - leftOverGas
+	gas, err := e.callGasLimit()
+	if err != nil {
+		return nil, err
+	}
+	ret, leftOverGas, err := vmenv.Call(vm.AccountRef(origin), contract, input, gas, val)
+	usedGas = gas - leftOverGas
+	e.consumeGas(usedGas)
+	err = wrapRevertError(ret, err)
 	return ret, err
 }
 
+// execute runs the given call (or contract deployment if addr is nil) with the given amount of gas,
+// and returns the output of the call along with the amount of gas that was used. Unlike Create &
+// Call this doesn't record any tx metrics, since it's only used to simulate & replay txs.
+func (e Evm) execute(
+	caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt, gas uint64,
+) ([]byte, uint64, error) {
+	origin := common.BytesToAddress(caller.Local)
+	vmenv := e.NewEnv(origin)
+
+	val := common.Big0
+	if value != nil && value.Int != nil {
+		val = value.Int
+		if e.validateTxValue && val.Cmp(common.Big0) < 0 {
+			return nil, 0, errors.Errorf("value %v must be non negative", value)
+		}
+	}
+
+	var ret []byte
+	var leftOverGas uint64
+	var err error
+	if addr == nil {
+		ret, _, leftOverGas, err = vmenv.Create(vm.AccountRef(origin), input, gas, val)
+	} else {
+		contract := common.BytesToAddress(addr.Local)
+		ret, leftOverGas, err = vmenv.Call(vm.AccountRef(origin), contract, input, gas, val)
+	}
+	return ret, gas - leftOverGas, wrapRevertError(ret, err)
+}
+
 func (e Evm) StaticCall(caller, addr loom.Address, input []byte) ([]byte, error) {
 	origin := common.BytesToAddress(caller.Local)
 	contract := common.BytesToAddress(addr.Local)
 	vmenv := e.NewEnv(origin)
 	ret, _, err := vmenv.StaticCall(vm.AccountRef(origin), contract, input, e.gasLimit)
-	return ret, err
+	return ret, wrapRevertError(ret, err)
 }
 
 func (e Evm) GetCode(addr loom.Address) []byte {
@@ -264,39 +321,120 @@ func (e Evm) GetStorageAt(addr loom.Address, key []byte) ([]byte, error) {
 	return result.Bytes(), nil
 }
 
+// wrapRevertError attaches the data returned by the REVERT opcode to the error returned by the EVM
+// when a call is reverted, any other error is returned as is.
+func wrapRevertError(ret []byte, err error) error {
+	// The EVM doesn't export the revert error, so it can only be identified by its message.
+	if err != nil && err.Error() == errExecutionReverted {
+		return &RevertError{Data: common.CopyBytes(ret)}
+	}
+	return err
+}
+
+// callGasLimit returns the maximum amount of gas the next call (or contract deployment) can use.
+func (e Evm) callGasLimit() (uint64, error) {
+	if e.gasMeter == nil || !e.meterGas {
+		return e.gasLimit, nil
+	}
+	gas := e.gasMeter.GasRemaining()
+	if gas == 0 {
+		return 0, loomchain.ErrOutOfGas
+	}
+	if gas > e.gasLimit {
+		gas = e.gasLimit
+	}
+	return gas, nil
+}
+
+// consumeGas adds the gas used by a call (or contract deployment) to the gas used by the current tx.
+func (e Evm) consumeGas(gas uint64) {
+	if e.gasMeter != nil {
+		// callGasLimit ensures a call can't use more gas than is left in the meter, so this can't fail
+		_ = e.gasMeter.ConsumeGas(gas)
+	}
+}
+
+// evmGasLimit returns the maximum amount of gas a single EVM tx can use.
+func evmGasLimit(lstate loomchain.State) uint64 {
+	gasLimit := lstate.Config().GetEvm().GetGasLimit()
+	if gasLimit == 0 {
+		return defaultGasLimit
+	}
+	return gasLimit
+}
+
 // TODO: this doesn't need to be exported, rename to newEVM
 func (e Evm) NewEnv(origin common.Address) *vm.EVM {
 	e.context.Origin = origin
 	return vm.NewEVM(e.context, e.sdb, &e.chainConfig, e.vmConfig)
 }
 
-func defaultChainConfig(enableConstantinople bool) params.ChainConfig {
+// evmHardForks lists the EVM hard forks that can be enabled via on-chain feature flags, in the order
+// in which they must be activated.
+var evmHardForks = []struct {
+	feature string
+	enable  func(cfg *params.ChainConfig, block *big.Int)
+}{
+	{
+		feature: features.EvmConstantinopleFeature,
+		enable:  func(cfg *params.ChainConfig, block *big.Int) { cfg.ConstantinopleBlock = block },
+	},
+	{
+		feature: features.EvmPetersburgFeature,
+		enable:  func(cfg *params.ChainConfig, block *big.Int) { cfg.PetersburgBlock = block },
+	},
+	{
+		feature: features.EvmIstanbulFeature,
+		enable:  func(cfg *params.ChainConfig, block *big.Int) { cfg.IstanbulBlock = block },
+	},
+}
+
+// defaultChainConfig returns the EVM config for the current block. Feature flags only show up in
+// the app state from the height at which they're activated, so a hard fork whose feature is
+// enabled is switched on for the current block, and stays on for all the blocks that follow.
+func defaultChainConfig(state loomchain.ReadOnlyState) params.ChainConfig {
 	cliqueCfg := params.CliqueConfig{
 		Period: 10,   // Number of seconds between blocks to enforce
 		Epoch:  1000, // Epoch length to reset votes and checkpoint
 	}
 
-	var constantinopleBlock *big.Int
-	if enableConstantinople {
-		constantinopleBlock = big.NewInt(0)
-	}
-
-	return params.ChainConfig{
+	cfg := params.ChainConfig{
 		ChainID:        big.NewInt(0), // Chain id identifies the current chain and is used for replay protection
 		HomesteadBlock: nil,           // Homestead switch block (nil = no fork, 0 = already homestead)
 		DAOForkBlock:   nil,           // TheDAO hard-fork switch block (nil = no fork)
 		DAOForkSupport: true,          // Whether the nodes supports or opposes the DAO hard-fork
 		// EIP150 implements the Gas price changes (https://github.com/ethereum/EIPs/issues/150)
-		EIP150Block:         nil,                                  // EIP150 HF block (nil = no fork)
-		EIP150Hash:          common.BytesToHash([]byte("myHash")), // EIP150 HF hash (needed for header only clients as only gas pricing changed)
-		EIP155Block:         big.NewInt(0),                        // EIP155 HF block
-		EIP158Block:         big.NewInt(0),                        // EIP158 HF block
-		ByzantiumBlock:      big.NewInt(0),                        // Byzantium switch block (nil = no fork, 0 = already on byzantium)
-		ConstantinopleBlock: constantinopleBlock,                  // Constantinople switch block (nil = no fork, 0 = already activated)
+		EIP150Block:    nil,                                  // EIP150 HF block (nil = no fork)
+		EIP150Hash:     common.BytesToHash([]byte("myHash")), // EIP150 HF hash (needed for header only clients as only gas pricing changed)
+		EIP155Block:    big.NewInt(0),                        // EIP155 HF block
+		EIP158Block:    big.NewInt(0),                        // EIP158 HF block
+		ByzantiumBlock: big.NewInt(0),                        // Byzantium switch block (nil = no fork, 0 = already on byzantium)
 		// Various consensus engines
 		Ethash: new(params.EthashConfig),
 		Clique: &cliqueCfg,
 	}
+
+	// Each hard fork builds on the ones before it, so enabling a fork also enables all the
+	// preceding forks that haven't been enabled yet.
+	enabled := false
+	for i := len(evmHardForks) - 1; i >= 0; i-- {
+		enabled = enabled || state.FeatureEnabled(evmHardForks[i].feature, false)
+		if enabled {
+			evmHardForks[i].enable(&cfg, big.NewInt(0))
+		}
+	}
+	// go-ethereum treats a nil PetersburgBlock as Petersburg being enabled along with
+	// Constantinople, which would remove the EIP-1283 SSTORE gas metering from chains that enabled
+	// Constantinople before Petersburg was available.
+	if cfg.PetersburgBlock == nil {
+		cfg.PetersburgBlock = big.NewInt(math.MaxInt64)
+	}
+
+	// The CHAINID opcode introduced in Istanbul should return the same chain ID as net_version.
+	if cfg.IstanbulBlock != nil {
+		cfg.ChainID = EthChainID(state.Block().ChainID)
+	}
+	return cfg
 }
 
 func defaultVmConfig(evmDebuggingEnabled bool) vm.Config {
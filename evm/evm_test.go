@@ -16,18 +16,28 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/common"
 	ethvm "github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/params"
 	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/common/evmcompat"
+	goloomplugin "github.com/loomnetwork/go-loom/plugin"
+	"github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/loomnetwork/go-loom/types"
 	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/builtin/plugins/address_mapper"
+	"github.com/loomnetwork/loomchain/builtin/plugins/coin"
+	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
 	"github.com/loomnetwork/loomchain/features"
 	"github.com/loomnetwork/loomchain/store"
 	lvm "github.com/loomnetwork/loomchain/vm"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	abci "github.com/tendermint/tendermint/abci/types"
+	"github.com/tendermint/tendermint/crypto/ed25519"
 )
 
 const (
-	BlockHeight        = int64(34)
-	numLoomPreCompiles = 2
+	BlockHeight = int64(34)
 )
 
 var (
@@ -115,42 +125,115 @@ func (p TestPrecompiledFunction) Run(input []byte) ([]byte, error) {
 	return []byte("TestPrecompiledFunction"), nil
 }
 
-// Test that we can access the loom precompiles using solidity assembly block
-// and return an output value.
-func TestPrecompilesAssembly(t *testing.T) {
-	caller := loom.Address{
-		ChainID: "myChainID",
-		Local:   []byte("myCaller"),
+func TestLoomPrecompiles(t *testing.T) {
+	owner := loom.MustParseAddress("chain:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	validator := loom.MustParseAddress("chain:0xfa4c7920accfd66b86f5fd0e69682a79f762d49e")
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ethAddr := loom.Address{ChainID: "eth", Local: crypto.PubkeyToAddress(ethKey.PublicKey).Bytes()}
+
+	// set up the Go contracts queried by the precompiles
+	fakeCtx := goloomplugin.CreateFakeContext(owner, owner)
+	coinCtx := contractpb.WrapPluginContext(fakeCtx.WithAddress(fakeCtx.CreateContract(coin.Contract)))
+	require.NoError(t, (&coin.Coin{}).Init(coinCtx, &coin.InitRequest{
+		Accounts: []*coin.InitialAccount{{Owner: owner.MarshalPB(), Balance: 5}},
+	}))
+	amCtx := contractpb.WrapPluginContext(fakeCtx.WithAddress(fakeCtx.CreateContract(address_mapper.Contract)))
+	am := &address_mapper.AddressMapper{}
+	require.NoError(t, am.Init(amCtx, &address_mapper.InitRequest{}))
+	sig, err := address_mapper.SignIdentityMapping(ethAddr, owner, ethKey, evmcompat.SignatureType_EIP712)
+	require.NoError(t, err)
+	require.NoError(t, am.AddIdentityMapping(amCtx, &address_mapper.AddIdentityMappingRequest{
+		From:      ethAddr.MarshalPB(),
+		To:        owner.MarshalPB(),
+		Signature: sig,
+	}))
+	dposCtx := contractpb.WrapPluginContext(fakeCtx.WithAddress(fakeCtx.CreateContract(dposv3.Contract)))
+	require.NoError(t, dposv3.SetStatistic(dposCtx, &dposv3.ValidatorStatistic{
+		Address:         validator.MarshalPB(),
+		DelegationTotal: &types.BigUInt{Value: *loom.NewBigUIntFromInt(1234)},
+	}))
+
+	goContracts := map[string]contractpb.StaticContext{
+		"coin":          coinCtx,
+		"addressmapper": amCtx,
+		"dposV3":        dposCtx,
+	}
+	AddLoomPrecompiles(func(state loomchain.State, contractName string) (contractpb.StaticContext, error) {
+		return goContracts[contractName], nil
+	})
+	defer func() { provideGoContractCtx = nil }()
+
+	header := abci.Header{ChainID: "chain", Height: BlockHeight, Time: blockTime}
+	state := loomchain.NewStoreState(context.Background(), store.NewMemStore(), header, nil, nil)
+	staticCall := func(precompile common.Address, arg loom.Address) ([]byte, error) {
+		vm := NewLoomVm(state, nil, nil, nil, false)
+		input := common.LeftPadBytes(arg.Local, 32)
+		return vm.StaticCall(owner, loom.Address{ChainID: "chain", Local: precompile.Bytes()}, input)
 	}
 
-	manager := lvm.NewManager()
-	manager.Register(lvm.VMType_EVM, LoomVmFactory)
-	state := mockState()
-	vm, _ := manager.InitVM(lvm.VMType_EVM, state)
-	abiPc, pcAddr := deploySolContract(t, caller, "CallPrecompiles", vm)
-
-	numEthPreCompiles := len(ethvm.PrecompiledContractsByzantium)
-	AddLoomPrecompiles()
-	require.Equal(t, numEthPreCompiles+numLoomPreCompiles, len(ethvm.PrecompiledContractsByzantium))
-
-	msg := []byte("TestInput")
-	input, err := abiPc.Pack("callPFAssembly", uint64(numEthPreCompiles+1), &msg)
-	require.NoError(t, err, "packing parameters")
-	ret, err := vm.StaticCall(caller, pcAddr, input)
-	require.NoError(t, err, "callPFAssembly method on CallPrecompiles")
-	expected := []byte("TransferWithBlockchain")
-	require.True(t, len(expected) <= len(ret))
-	actual := ret[:len(expected)]
-	require.Equal(t, 0, bytes.Compare(expected, actual))
-
-	input, err = abiPc.Pack("callPFAssembly", uint64(numEthPreCompiles+2), &msg)
-	require.NoError(t, err, "packing parameters")
-	ret, err = vm.StaticCall(caller, pcAddr, input)
-	require.NoError(t, err, "callPFAssembly method on CallPrecompiles")
-	expected = []byte("TransferPlasmaToken")
-	require.True(t, len(expected) <= len(ret))
-	actual = ret[:len(expected)]
-	require.Equal(t, 0, bytes.Compare(expected, actual))
+	// calls to the precompiles should behave like calls to empty accounts until the feature is enabled
+	ret, err := staticCall(CoinBalancePrecompileAddress, owner)
+	require.NoError(t, err)
+	require.Empty(t, ret)
+
+	state.SetFeature(features.EvmGoPrecompilesFeature, true)
+
+	ret, err = staticCall(CoinBalancePrecompileAddress, owner)
+	require.NoError(t, err)
+	expectedBalance := new(big.Int).Mul(big.NewInt(5), new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil))
+	require.Equal(t, expectedBalance, new(big.Int).SetBytes(ret))
+	ret, err = staticCall(CoinBalancePrecompileAddress, validator)
+	require.NoError(t, err)
+	require.Equal(t, common.Hash{}.Bytes(), ret)
+
+	ret, err = staticCall(AddressMappingPrecompileAddress, owner)
+	require.NoError(t, err)
+	require.Equal(t, common.LeftPadBytes(ethAddr.Local, 32), ret)
+	ret, err = staticCall(AddressMappingPrecompileAddress, validator)
+	require.NoError(t, err)
+	require.Equal(t, common.Hash{}.Bytes(), ret)
+
+	ret, err = staticCall(ValidatorStakePrecompileAddress, validator)
+	require.NoError(t, err)
+	require.Equal(t, int64(1234), new(big.Int).SetBytes(ret).Int64())
+	ret, err = staticCall(ValidatorStakePrecompileAddress, owner)
+	require.NoError(t, err)
+	require.Equal(t, common.Hash{}.Bytes(), ret)
+
+	// input that isn't an ABI encoded address should be rejected
+	vm := NewLoomVm(state, nil, nil, nil, false)
+	precompileAddr := loom.Address{ChainID: "chain", Local: CoinBalancePrecompileAddress.Bytes()}
+	_, err = vm.StaticCall(owner, precompileAddr, owner.Local)
+	require.Error(t, err)
+
+	// each call should be charged a fixed amount of gas
+	gasMeter := loomchain.NewInfiniteGasMeter(0)
+	vm = NewLoomVm(state.WithContext(loomchain.WithGasMeter(state.Context(), gasMeter)), nil, nil, nil, false)
+	_, err = vm.Call(owner, precompileAddr, common.LeftPadBytes(owner.Local, 32), loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+	require.Equal(t, uint64(CoinBalancePrecompileGas), gasMeter.GasUsed())
+
+	// the precompiles should be callable from contracts, runtime code:
+	// calldatacopy(0, 0, 32)
+	// mstore(32, call(gas(), 0x4c01, 0, 0, 32, 0, 32))
+	// return(0, 64)
+	callerCode, err := hex.DecodeString(
+		"601e600c600039601e6000f3" + "60206000600037" + "60206000602060006000614c015af1" + "602052" + "60406000f3",
+	)
+	require.NoError(t, err)
+	vm = NewLoomVm(state, nil, nil, nil, false)
+	_, callerAddr, err := vm.Create(owner, callerCode, loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+	ret, err = vm.StaticCall(owner, callerAddr, common.LeftPadBytes(owner.Local, 32))
+	require.NoError(t, err)
+	require.Len(t, ret, 64)
+	require.Equal(t, expectedBalance, new(big.Int).SetBytes(ret[:32]))
+	require.Equal(t, int64(1), new(big.Int).SetBytes(ret[32:]).Int64(), "call should succeed")
+	// a failed call to a precompile should only fail the call, not the contract
+	ret, err = vm.StaticCall(owner, callerAddr, owner.Local)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), new(big.Int).SetBytes(ret[32:]).Int64(), "call should fail")
 }
 
 func TestValue(t *testing.T) {
@@ -311,3 +394,213 @@ func deploySolContract(t *testing.T, caller loom.Address, filename string, vm lv
 	require.NoError(t, err, "reading abi")
 	return ethAbi, addr
 }
+
+func TestEstimateGas(t *testing.T) {
+	caller := loom.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	state := mockState()
+	bytetext, err := ioutil.ReadFile("testdata/GlobalProperties.bin")
+	require.NoError(t, err, "reading GlobalProperties.bin")
+	bytecode, err := hex.DecodeString(string(bytetext))
+	require.NoError(t, err, "decoding bytecode")
+
+	gas, err := EstimateGas(state, nil, caller, nil, bytecode, nil, 0)
+	require.NoError(t, err)
+	// the estimate shouldn't modify the state
+	require.Len(t, state.Range(vmPrefix), 0)
+
+	// the estimate should be the exact amount of gas required to deploy the contract
+	intrinsicGas, err := IntrinsicGas(state, bytecode, true)
+	require.NoError(t, err)
+	levm, err := NewLoomEvm(state, nil, nil, false)
+	require.NoError(t, err)
+	_, _, err = levm.execute(caller, nil, bytecode, nil, gas-intrinsicGas)
+	require.NoError(t, err)
+	levm, err = NewLoomEvm(state, nil, nil, false)
+	require.NoError(t, err)
+	_, _, err = levm.execute(caller, nil, bytecode, nil, gas-intrinsicGas-1)
+	require.Error(t, err)
+
+	_, err = EstimateGas(state, nil, caller, nil, bytecode, nil, 1000)
+	require.Error(t, err)
+}
+
+func TestRevertError(t *testing.T) {
+	reason := "insufficient allowance"
+	data := append([]byte{}, revertReasonSelector...)
+	data = append(data, common.LeftPadBytes([]byte{0x20}, 32)...)
+	data = append(data, common.LeftPadBytes(big.NewInt(int64(len(reason))).Bytes(), 32)...)
+	data = append(data, common.RightPadBytes([]byte(reason), 64)...)
+
+	decoded, err := UnpackRevertReason(data)
+	require.NoError(t, err)
+	require.Equal(t, reason, decoded)
+
+	_, err = UnpackRevertReason(data[:40])
+	require.Error(t, err)
+	_, err = UnpackRevertReason([]byte{0x01, 0x02, 0x03, 0x04})
+	require.Error(t, err)
+
+	// the revert data should be recoverable from the message of a wrapped error
+	err = wrapRevertError(data, errors.New(errExecutionReverted))
+	require.Equal(t, data, err.(*RevertError).RevertData())
+	parsed, ok := ParseRevertError(errors.Wrap(err, "failed to call contract").Error())
+	require.True(t, ok)
+	require.Equal(t, data, parsed)
+
+	_, ok = ParseRevertError(errExecutionReverted)
+	require.False(t, ok)
+	require.Equal(t, ethvm.ErrOutOfGas, wrapRevertError(nil, ethvm.ErrOutOfGas))
+}
+
+// Tests that the BLOCKHASH & COINBASE opcodes return the values recorded at the start of each block
+// once the block context feature is enabled.
+func TestBlockContext(t *testing.T) {
+	caller := loom.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	proposerKey := ed25519.GenPrivKey().PubKey().(ed25519.PubKeyEd25519)
+	getValidatorSet := func(state loomchain.State) (loom.ValidatorSet, error) {
+		return loom.NewValidatorSet(&loom.Validator{PubKey: proposerKey[:], Power: 10}), nil
+	}
+	kvStore := store.NewMemStore()
+	var state loomchain.State
+	for h := int64(1); h <= BlockHeight; h++ {
+		header := abci.Header{Height: h, Time: blockTime, ProposerAddress: proposerKey.Address()}
+		header.LastBlockId.Hash = crypto.Keccak256([]byte{byte(h - 1)})
+		state = loomchain.NewStoreState(context.Background(), kvStore, header, nil, getValidatorSet)
+		state.SetFeature(features.EvmBlockContextFeature, true)
+		loomchain.RecordBlockContext(state, header, nil)
+	}
+
+	// runtime code: return blockhash(calldata[0:32])
+	blockHashCode, err := hex.DecodeString("600c600c600039600c6000f3" + "6000354060005260206000f3")
+	require.NoError(t, err)
+	// runtime code: return block.coinbase
+	coinbaseCode, err := hex.DecodeString("6009600c6000396009" + "6000f3" + "4160005260206000f3")
+	require.NoError(t, err)
+
+	vm := NewLoomVm(state, nil, nil, nil, false)
+	_, blockHashAddr, err := vm.Create(caller, blockHashCode, loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+	_, coinbaseAddr, err := vm.Create(caller, coinbaseCode, loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+
+	prevHeight := common.BigToHash(big.NewInt(BlockHeight - 1)).Bytes()
+	ret, err := vm.StaticCall(caller, blockHashAddr, prevHeight)
+	require.NoError(t, err)
+	require.Equal(t, crypto.Keccak256([]byte{byte(BlockHeight - 1)}), ret)
+	ret, err = vm.StaticCall(caller, coinbaseAddr, nil)
+	require.NoError(t, err)
+	proposer := loom.LocalAddressFromPublicKey(proposerKey[:])
+	require.Equal(t, common.BytesToAddress(proposer).Hash().Bytes(), ret)
+
+	// the placeholder values should be returned while the feature is disabled
+	state.SetFeature(features.EvmBlockContextFeature, false)
+	vm = NewLoomVm(state, nil, nil, nil, false)
+	ret, err = vm.StaticCall(caller, blockHashAddr, prevHeight)
+	require.NoError(t, err)
+	require.Equal(t, crypto.Keccak256([]byte(fmt.Sprint(BlockHeight-1))), ret)
+	ret, err = vm.StaticCall(caller, coinbaseAddr, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.BytesToAddress([]byte("myCoinBase")).Hash().Bytes(), ret)
+}
+
+func TestHardForks(t *testing.T) {
+	caller := loom.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	header := abci.Header{ChainID: "default", Height: BlockHeight, Time: blockTime}
+	state := loomchain.NewStoreState(context.Background(), store.NewMemStore(), header, nil, nil)
+
+	cfg := defaultChainConfig(state)
+	require.False(t, cfg.IsConstantinople(big.NewInt(BlockHeight)))
+	require.False(t, cfg.IsIstanbul(big.NewInt(BlockHeight)))
+
+	state.SetFeature(features.EvmConstantinopleFeature, true)
+	cfg = defaultChainConfig(state)
+	require.True(t, cfg.IsConstantinople(big.NewInt(BlockHeight)))
+	require.False(t, cfg.IsPetersburg(big.NewInt(BlockHeight)))
+	require.False(t, cfg.IsIstanbul(big.NewInt(BlockHeight)))
+
+	state.SetFeature(features.EvmPetersburgFeature, true)
+	cfg = defaultChainConfig(state)
+	require.True(t, cfg.IsConstantinople(big.NewInt(BlockHeight)))
+	require.True(t, cfg.IsPetersburg(big.NewInt(BlockHeight)))
+	require.False(t, cfg.IsIstanbul(big.NewInt(BlockHeight)))
+	state.SetFeature(features.EvmPetersburgFeature, false)
+
+	// enabling a fork should enable all the forks preceding it
+	state.SetFeature(features.EvmConstantinopleFeature, false)
+	state.SetFeature(features.EvmIstanbulFeature, true)
+	cfg = defaultChainConfig(state)
+	require.True(t, cfg.IsConstantinople(big.NewInt(BlockHeight)))
+	require.True(t, cfg.IsPetersburg(big.NewInt(BlockHeight)))
+	require.True(t, cfg.IsIstanbul(big.NewInt(BlockHeight)))
+
+	// runtime code: return chainid()
+	chainIDCode, err := hex.DecodeString("6009600c6000396009" + "6000f3" + "4660005260206000f3")
+	require.NoError(t, err)
+	vm := NewLoomVm(state, nil, nil, nil, false)
+	_, chainIDAddr, err := vm.Create(caller, chainIDCode, loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+	ret, err := vm.StaticCall(caller, chainIDAddr, nil)
+	require.NoError(t, err)
+	require.Equal(t, common.BigToHash(EthChainID("default")).Bytes(), ret)
+
+	// CHAINID isn't a valid opcode prior to Istanbul
+	state.SetFeature(features.EvmIstanbulFeature, false)
+	vm = NewLoomVm(state, nil, nil, nil, false)
+	_, err = vm.StaticCall(caller, chainIDAddr, nil)
+	require.Error(t, err)
+}
+
+func TestIntrinsicGas(t *testing.T) {
+	header := abci.Header{ChainID: "default", Height: BlockHeight, Time: blockTime}
+	state := loomchain.NewStoreState(context.Background(), store.NewMemStore(), header, nil, nil)
+	data := []byte{0, 1, 2}
+
+	gas, err := IntrinsicGas(state, data, false)
+	require.NoError(t, err)
+	require.Equal(t, params.TxGas+params.TxDataZeroGas+2*params.TxDataNonZeroGasFrontier, gas)
+
+	// Istanbul reprices non-zero calldata bytes (EIP-2028)
+	state.SetFeature(features.EvmIstanbulFeature, true)
+	gas, err = IntrinsicGas(state, data, false)
+	require.NoError(t, err)
+	require.Equal(t, params.TxGas+params.TxDataZeroGas+2*params.TxDataNonZeroGasEIP2028, gas)
+}
+
+func TestGasMetering(t *testing.T) {
+	caller := loom.Address{
+		ChainID: "myChainID",
+		Local:   []byte("myCaller"),
+	}
+	// runtime code: loop forever
+	loopCode, err := hex.DecodeString("6004600c600039" + "60046000f3" + "5b600056")
+	require.NoError(t, err)
+
+	// gas used should be tracked even if the gas limits aren't enforced
+	state := mockState()
+	gasMeter := loomchain.NewInfiniteGasMeter(0)
+	vm := NewLoomVm(state.WithContext(loomchain.WithGasMeter(state.Context(), gasMeter)), nil, nil, nil, false)
+	_, loopAddr, err := vm.Create(caller, loopCode, loom.NewBigUIntFromInt(0))
+	require.NoError(t, err)
+	require.True(t, gasMeter.GasUsed() > 0)
+
+	state.SetFeature(features.EvmGasMeteringFeature, true)
+	gasMeter = loomchain.NewGasMeter(100000, 500)
+	vm = NewLoomVm(state.WithContext(loomchain.WithGasMeter(state.Context(), gasMeter)), nil, nil, nil, false)
+	_, err = vm.Call(caller, loopAddr, nil, loom.NewBigUIntFromInt(0))
+	require.Error(t, err)
+	require.Equal(t, uint64(100000), gasMeter.GasUsed())
+	require.Equal(t, uint64(100500), gasMeter.CumulativeGasUsed())
+
+	// no more calls should be possible once all the gas is used up
+	_, err = vm.Call(caller, loopAddr, nil, loom.NewBigUIntFromInt(0))
+	require.Equal(t, loomchain.ErrOutOfGas, errors.Cause(err))
+}
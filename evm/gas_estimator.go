@@ -0,0 +1,116 @@
+// +build evm
+
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/params"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain"
+	"github.com/pkg/errors"
+)
+
+// gasEstimator executes the same call over & over again with different gas limits.
+type gasEstimator struct {
+	state      loomchain.State
+	provideABM ABMFactoryProvider
+	caller     loom.Address
+	addr       *loom.Address
+	input      []byte
+	value      *loom.BigUInt
+}
+
+// execute runs the call with the given amount of gas against a throwaway copy of the state, and
+// returns the amount of gas used by the call. If the call fails the error returned by the EVM is
+// returned in vmErr, err is only set if the call couldn't be executed at all.
+func (g *gasEstimator) execute(gas uint64) (usedGas uint64, vmErr error, err error) {
+	levm, err := newLoomEvmWithABM(loomchain.NewCachedState(g.state), g.provideABM)
+	if err != nil {
+		return 0, nil, err
+	}
+	_, usedGas, vmErr = levm.execute(g.caller, g.addr, g.input, g.value, gas)
+	return usedGas, vmErr, nil
+}
+
+// EstimateGas returns the amount of gas a tx should be sent with in order to successfully execute
+// the given call (or contract deployment if addr is nil). The call is executed against a copy of
+// the given state, so the state itself is never modified.
+//
+// The estimate is found by executing the call with different gas limits, up to gasCap (or the
+// EVM gas limit, whichever is lower), and includes the intrinsic gas Ethereum charges for a tx
+// so that it's compatible with the gas limits Web3 clients expect.
+func EstimateGas(
+	state loomchain.State, provideABM ABMFactoryProvider,
+	caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt, gasCap uint64,
+) (uint64, error) {
+	g := &gasEstimator{
+		state:      state,
+		provideABM: provideABM,
+		caller:     caller,
+		addr:       addr,
+		input:      input,
+		value:      value,
+	}
+
+	hi := evmGasLimit(state)
+	if gasCap != 0 && gasCap < hi {
+		hi = gasCap
+	}
+	usedGas, vmErr, err := g.execute(hi)
+	if err != nil {
+		return 0, err
+	}
+	if vmErr != nil {
+		return 0, errors.Wrapf(vmErr, "execution failed with gas limit %d", hi)
+	}
+
+	// The call can't succeed with less gas than it used, but it may need more than it used, e.g.
+	// when only 63/64 of the available gas is passed on to a nested call, so try a slightly higher
+	// limit first since that's usually enough, and then narrow the limit down with a binary search.
+	lo := usedGas
+	if usedGas > 0 {
+		lo = usedGas - 1
+	} else {
+		// e.g. a plain value transfer, only the intrinsic gas is needed
+		hi = 0
+	}
+	if optimistic := (usedGas + params.CallStipend) * 64 / 63; optimistic < hi {
+		_, vmErr, err := g.execute(optimistic)
+		if err != nil {
+			return 0, err
+		}
+		if vmErr == nil {
+			hi = optimistic
+		} else {
+			lo = optimistic
+		}
+	}
+	for lo+1 < hi {
+		mid := lo + (hi-lo)/2
+		_, vmErr, err := g.execute(mid)
+		if err != nil {
+			return 0, err
+		}
+		if vmErr == nil {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	intrinsicGas, err := IntrinsicGas(state, input, addr == nil)
+	if err != nil {
+		return 0, err
+	}
+	return hi + intrinsicGas, nil
+}
+
+// IntrinsicGas returns the gas Ethereum charges for a tx before any EVM code is executed, the
+// tx calldata is priced according to the EVM hard forks that are enabled in the given state.
+func IntrinsicGas(state loomchain.ReadOnlyState, data []byte, contractCreation bool) (uint64, error) {
+	cfg := defaultChainConfig(state)
+	isIstanbul := cfg.IsIstanbul(big.NewInt(state.Block().Height))
+	return core.IntrinsicGas(data, contractCreation, true, isIstanbul)
+}
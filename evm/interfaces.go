@@ -2,6 +2,8 @@ package evm
 
 import (
 	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/loomnetwork/loomchain"
 )
 
 // AccountBalanceManager can be implemented to override the builtin account balance management in the EVM.
@@ -14,3 +16,11 @@ type AccountBalanceManager interface {
 }
 
 type AccountBalanceManagerFactoryFunc func(readOnly bool) AccountBalanceManager
+
+// ABMFactoryProvider returns a factory that creates account balance managers bound to the given
+// state, the factory may be nil if the EVM shouldn't use an account balance manager.
+type ABMFactoryProvider func(state loomchain.State) (AccountBalanceManagerFactoryFunc, error)
+
+// GoContractContextProvider returns a read-only context for the built-in Go contract with the given
+// name, bound to the given state. It's used by the Loom precompiles to query Go contracts.
+type GoContractContextProvider func(state loomchain.State, contractName string) (contractpb.StaticContext, error)
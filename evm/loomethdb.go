@@ -13,6 +13,7 @@ import (
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/loomnetwork/loomchain"
 	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
 )
 
 var (
@@ -21,7 +22,10 @@ var (
 	loggerStarted = false
 )
 
-// implements ethdb.Database
+var errEthdbIterationNotSupported = errors.New("ethdb iteration is not supported")
+
+// LoomEthdb implements ethdb.KeyValueStore on top of the EVM section of the app state, it must be
+// wrapped with rawdb.NewDatabase before it's passed to the EVM.
 type LoomEthdb struct {
 	state      store.KVStore
 	lock       sync.RWMutex
@@ -53,7 +57,30 @@ func (s *LoomEthdb) Delete(key []byte) error {
 	return nil
 }
 
-func (s *LoomEthdb) Close() {
+func (s *LoomEthdb) Close() error {
+	return nil
+}
+
+// The EVM never iterates over the DB, and the app state doesn't support iteration over arbitrary
+// key ranges, so the iterators always fail.
+func (s *LoomEthdb) NewIterator() ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *LoomEthdb) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *LoomEthdb) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *LoomEthdb) Stat(property string) (string, error) {
+	return "", errors.Errorf("unknown ethdb property %s", property)
+}
+
+func (s *LoomEthdb) Compact(start []byte, limit []byte) error {
+	return nil
 }
 
 func (s *LoomEthdb) NewBatch() ethdb.Batch {
@@ -123,6 +150,19 @@ func (b *batch) Delete(key []byte) error {
 	return nil
 }
 
+func (b *batch) Replay(w ethdb.KeyValueWriter) error {
+	for _, kv := range b.cache {
+		if kv.value == nil {
+			if err := w.Delete(kv.key); err != nil {
+				return err
+			}
+		} else if err := w.Put(kv.key, kv.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (b *batch) Dump(logger *log.Logger) {
 	b.parentStore.lock.Lock()
 	defer b.parentStore.lock.Unlock()
@@ -258,6 +298,32 @@ func (b *LogBatch) Reset() {
 	b.batch.Reset()
 }
 
+func (b *LogBatch) Replay(w ethdb.KeyValueWriter) error {
+	return b.batch.Replay(w)
+}
+
+// unsupportedIterator is an ethdb.Iterator that's exhausted from the start.
+type unsupportedIterator struct{}
+
+func (it *unsupportedIterator) Next() bool {
+	return false
+}
+
+func (it *unsupportedIterator) Error() error {
+	return errEthdbIterationNotSupported
+}
+
+func (it *unsupportedIterator) Key() []byte {
+	return nil
+}
+
+func (it *unsupportedIterator) Value() []byte {
+	return nil
+}
+
+func (it *unsupportedIterator) Release() {
+}
+
 // sortKeys sorts prefixed keys, it will sort the postfix of the key in ascending lexographical order
 func sortKeys(prefix []byte, kvs []kvPair) []kvPair {
 	var unsorted, sorted []int
@@ -8,10 +8,10 @@ import (
 	"math/big"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
 	"github.com/ethereum/go-ethereum/core/state"
 	"github.com/ethereum/go-ethereum/core/types"
 	ethvm "github.com/ethereum/go-ethereum/core/vm"
-	"github.com/ethereum/go-ethereum/crypto/sha3"
 	"github.com/ethereum/go-ethereum/ethdb"
 	"github.com/gogo/protobuf/proto"
 	"github.com/loomnetwork/go-loom"
@@ -24,6 +24,7 @@ import (
 	"github.com/loomnetwork/loomchain/receipts/handler"
 	"github.com/loomnetwork/loomchain/vm"
 	"github.com/pkg/errors"
+	"golang.org/x/crypto/sha3"
 )
 
 var (
@@ -36,6 +37,7 @@ type StateDB interface {
 	Database() state.Database
 	Logs() []*types.Log
 	Commit(bool) (common.Hash, error)
+	RawDump(excludeCode, excludeStorage, excludeMissingPreimages bool) state.Dump
 }
 
 type ethdbLogContext struct {
@@ -57,7 +59,7 @@ func NewLoomEvm(
 	logContext *ethdbLogContext, debug bool,
 ) (*LoomEvm, error) {
 	p := new(LoomEvm)
-	p.db = NewLoomEthdb(loomState, logContext)
+	p.db = rawdb.NewDatabase(NewLoomEthdb(loomState, logContext))
 	oldRoot, err := p.db.Get(rootKey)
 	if err != nil {
 		return nil, err
@@ -78,6 +80,23 @@ func NewLoomEvm(
 	return p, nil
 }
 
+// newLoomEvmWithABM creates a LoomEvm that uses an account balance manager bound to the given state
+// (if provideABM returns one) to transfer ETH between accounts. The ETH balances in the state will
+// be modified by the EVM, so the state should be a throwaway copy of the app state.
+func newLoomEvmWithABM(loomState loomchain.State, provideABM ABMFactoryProvider) (*LoomEvm, error) {
+	var abm AccountBalanceManager
+	if provideABM != nil {
+		createABM, err := provideABM(loomState)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create account balance manager")
+		}
+		if createABM != nil {
+			abm = createABM(false)
+		}
+	}
+	return NewLoomEvm(loomState, abm, nil, false)
+}
+
 func (levm LoomEvm) Commit() (common.Hash, error) {
 	root, err := levm.sdb.Commit(true)
 	if err != nil {
@@ -93,7 +112,7 @@ func (levm LoomEvm) Commit() (common.Hash, error) {
 }
 
 func (levm LoomEvm) RawDump() []byte {
-	d := levm.sdb.RawDump()
+	d := levm.sdb.RawDump(false, false, false)
 	output, err := json.MarshalIndent(d, "", "  ")
 	if err != nil {
 		panic(err)
@@ -286,7 +305,7 @@ func (lvm LoomVm) GetStorageAt(addr loom.Address, key []byte) ([]byte, error) {
 }
 
 func getLoomEvmTxHash(ethTxHash []byte, from loom.LocalAddress) []byte {
-	h := sha3.NewKeccak256()
+	h := sha3.NewLegacyKeccak256()
 	h.Write(append(ethTxHash, from...))
 	return h.Sum(nil)
 }
@@ -3,7 +3,11 @@
 package evm
 
 import (
+	"errors"
+
+	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/db"
 	lvm "github.com/loomnetwork/loomchain/vm"
 )
 
@@ -24,4 +28,43 @@ func NewLoomVm(
 	return nil
 }
 
-func AddLoomPrecompiles() {}
+func AddLoomPrecompiles(provideGoContractCtx GoContractContextProvider) {}
+
+func GetAccountProof(
+	loomState loomchain.State, root []byte, addr loom.Address, storageKeys [][]byte,
+) (*AccountProof, error) {
+	return nil, errors.New("EVM not enabled")
+}
+
+func WalkEvmState(snap db.Snapshot, root []byte, visit func(key []byte) bool) error {
+	return errors.New("EVM not enabled")
+}
+
+func DiffEvmState(
+	left db.Snapshot, leftRoot []byte, right db.Snapshot, rightRoot []byte, onDiff func(*AccountDiff),
+) error {
+	return errors.New("EVM not enabled")
+}
+
+func EstimateGas(
+	state loomchain.State, provideABM ABMFactoryProvider,
+	caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt, gasCap uint64,
+) (uint64, error) {
+	return 0, errors.New("EVM not enabled")
+}
+
+type TxReplayer struct{}
+
+func NewTxReplayer(state loomchain.State, provideABM ABMFactoryProvider, gasCap uint64) *TxReplayer {
+	return &TxReplayer{}
+}
+
+func (r *TxReplayer) Apply(caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt) error {
+	return errors.New("EVM not enabled")
+}
+
+func (r *TxReplayer) Trace(
+	caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt, gas uint64, cfg *TraceConfig,
+) (*ExecutionTrace, error) {
+	return nil, errors.New("EVM not enabled")
+}
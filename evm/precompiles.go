@@ -3,33 +3,172 @@
 package evm
 
 import (
+	"math/big"
+
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/vm"
+	"github.com/loomnetwork/go-loom"
+	contract "github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/pkg/errors"
+
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/builtin/plugins/address_mapper"
+	"github.com/loomnetwork/loomchain/builtin/plugins/coin"
+	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
+	"github.com/loomnetwork/loomchain/features"
+)
+
+// Addresses of the Loom precompiles, these are well clear of the addresses used by the Ethereum
+// precompiles so they won't clash with any precompiles added in future hard forks.
+//
+// Each precompile takes a single ABI encoded address as input, i.e. abi.encode(address), and
+// returns a single ABI encoded 32-byte word:
+//   - CoinBalancePrecompileAddress returns the LOOM balance (uint256) of the given account.
+//   - AddressMappingPrecompileAddress returns the address (address) the given account is mapped to
+//     in the address mapper, or the zero address if the account isn't mapped to another address.
+//   - ValidatorStakePrecompileAddress returns the total amount of LOOM (uint256) delegated to the
+//     given validator in DPOSv3, or zero if the address doesn't belong to a validator.
+var (
+	CoinBalancePrecompileAddress    = common.HexToAddress("0x0000000000000000000000000000000000004c01")
+	AddressMappingPrecompileAddress = common.HexToAddress("0x0000000000000000000000000000000000004c02")
+	ValidatorStakePrecompileAddress = common.HexToAddress("0x0000000000000000000000000000000000004c03")
+)
+
+// Gas charged for each call to the Loom precompiles, this is roughly the cost of the storage reads
+// performed by each precompile.
+const (
+	CoinBalancePrecompileGas    = 800
+	AddressMappingPrecompileGas = 1600
+	ValidatorStakePrecompileGas = 800
+)
+
+var (
+	// ErrInvalidPrecompileInput is returned when the input to a Loom precompile isn't an ABI encoded
+	// address.
+	ErrInvalidPrecompileInput = errors.New("invalid precompile input")
+
+	provideGoContractCtx GoContractContextProvider
 )
 
-func AddLoomPrecompiles() {
-	index := len(vm.PrecompiledContractsByzantium) + 1
-	vm.PrecompiledContractsByzantium[common.BytesToAddress([]byte{byte(index)})] = &TransferWithBlockchain{}
-	index++
-	vm.PrecompiledContractsByzantium[common.BytesToAddress([]byte{byte(index)})] = &TransferPlasmaToken{}
+// AddLoomPrecompiles enables the Loom precompiles, the given provider is used by the precompiles to
+// access the built-in Go contracts. The precompiles can only be called once the
+// EvmGoPrecompilesFeature is enabled, until then calls to them behave like calls to an empty
+// account.
+func AddLoomPrecompiles(provideCtx GoContractContextProvider) {
+	provideGoContractCtx = provideCtx
 }
 
-type TransferWithBlockchain struct{}
+// loomPrecompiles returns the Loom precompiles bound to the given state, or nil if the precompiles
+// aren't enabled. Each EVM instance gets its own set of precompiles, so EVMs executing against
+// different states don't interfere with each other. vm.Context.Precompiles is added to go-ethereum
+// by patches/go-ethereum.
+func loomPrecompiles(state loomchain.State) map[common.Address]vm.PrecompiledContract {
+	if provideGoContractCtx == nil || !state.FeatureEnabled(features.EvmGoPrecompilesFeature, false) {
+		return nil
+	}
+	return map[common.Address]vm.PrecompiledContract{
+		CoinBalancePrecompileAddress: &goContractPrecompile{
+			state: state,
+			gas:   CoinBalancePrecompileGas,
+			run:   coinBalance,
+		},
+		AddressMappingPrecompileAddress: &goContractPrecompile{
+			state: state,
+			gas:   AddressMappingPrecompileGas,
+			run:   addressMapping,
+		},
+		ValidatorStakePrecompileAddress: &goContractPrecompile{
+			state: state,
+			gas:   ValidatorStakePrecompileGas,
+			run:   validatorStake,
+		},
+	}
+}
+
+// goContractPrecompile is a precompile that queries one of the built-in Go contracts.
+type goContractPrecompile struct {
+	state loomchain.State
+	gas   uint64
+	run   func(state loomchain.State, arg loom.Address) ([]byte, error)
+}
+
+func (p *goContractPrecompile) RequiredGas(input []byte) uint64 {
+	return p.gas
+}
+
+func (p *goContractPrecompile) Run(input []byte) ([]byte, error) {
+	arg, err := decodeAddressArg(input)
+	if err != nil {
+		return nil, err
+	}
+	return p.run(p.state, loom.Address{
+		ChainID: p.state.Block().ChainID,
+		Local:   arg.Bytes(),
+	})
+}
 
-func (t TransferWithBlockchain) RequiredGas(input []byte) uint64 {
-	return uint64(0)
+func coinBalance(state loomchain.State, owner loom.Address) ([]byte, error) {
+	ctx, err := provideGoContractCtx(state, "coin")
+	if err != nil {
+		return nil, err
+	}
+	balance, err := coin.BalanceOf(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	return encodeUint256(balance.Int), nil
 }
 
-func (t TransferWithBlockchain) Run(input []byte) ([]byte, error) {
-	return []byte("TransferWithBlockchain"), nil
+func addressMapping(state loomchain.State, from loom.Address) ([]byte, error) {
+	ctx, err := provideGoContractCtx(state, "addressmapper")
+	if err != nil {
+		return nil, err
+	}
+	am := &address_mapper.AddressMapper{}
+	hasResp, err := am.HasMapping(ctx, &address_mapper.HasMappingRequest{From: from.MarshalPB()})
+	if err != nil {
+		return nil, err
+	}
+	if !hasResp.HasMapping {
+		return common.Hash{}.Bytes(), nil
+	}
+	resp, err := am.GetMapping(ctx, &address_mapper.GetMappingRequest{From: from.MarshalPB()})
+	if err != nil {
+		return nil, err
+	}
+	return common.BytesToHash(loom.UnmarshalAddressPB(resp.To).Local).Bytes(), nil
 }
 
-type TransferPlasmaToken struct{}
+func validatorStake(state loomchain.State, validator loom.Address) ([]byte, error) {
+	ctx, err := provideGoContractCtx(state, "dposV3")
+	if err != nil {
+		return nil, err
+	}
+	statistic, err := dposv3.GetStatistic(ctx, validator)
+	if err == contract.ErrNotFound || (err == nil && statistic.DelegationTotal == nil) {
+		return common.Hash{}.Bytes(), nil
+	} else if err != nil {
+		return nil, err
+	}
+	return encodeUint256(statistic.DelegationTotal.Value.Int), nil
+}
 
-func (t TransferPlasmaToken) RequiredGas(input []byte) uint64 {
-	return uint64(0)
+// decodeAddressArg decodes an ABI encoded address.
+func decodeAddressArg(input []byte) (common.Address, error) {
+	if len(input) != common.HashLength {
+		return common.Address{}, ErrInvalidPrecompileInput
+	}
+	for _, b := range input[:common.HashLength-common.AddressLength] {
+		if b != 0 {
+			return common.Address{}, ErrInvalidPrecompileInput
+		}
+	}
+	return common.BytesToAddress(input), nil
 }
 
-func (t TransferPlasmaToken) Run(input []byte) ([]byte, error) {
-	return []byte("TransferPlasmaToken"), nil
+func encodeUint256(value *big.Int) []byte {
+	if value == nil {
+		return common.Hash{}.Bytes()
+	}
+	return common.BigToHash(value).Bytes()
 }
@@ -0,0 +1,109 @@
+// +build evm
+
+package evm
+
+import (
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/rawdb"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain"
+	"github.com/pkg/errors"
+)
+
+// proofList collects the trie nodes that make up a Merkle proof, in the order they're visited.
+type proofList [][]byte
+
+func (n *proofList) Put(key []byte, value []byte) error {
+	*n = append(*n, value)
+	return nil
+}
+
+func (n *proofList) Delete(key []byte) error {
+	return errors.New("proofList doesn't support deletion")
+}
+
+// GetAccountProof builds a Merkle proof of the EVM account at the given address from the Patricia
+// trie with the given root, along with Merkle proofs of the given storage slots of the account.
+// If the account (or a storage slot) doesn't exist the corresponding proof is a proof of absence.
+// The trie nodes are read from the given state, so it must contain the trie with the given root.
+func GetAccountProof(
+	loomState loomchain.State, root []byte, addr loom.Address, storageKeys [][]byte,
+) (*AccountProof, error) {
+	db := state.NewDatabase(rawdb.NewDatabase(NewLoomEthdb(loomState, nil)))
+	accountTrie, err := db.OpenTrie(common.BytesToHash(root))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open state trie with root %x", root)
+	}
+
+	// The state trie is a secure trie, so the path to each account is the hash of its address.
+	addrHash := crypto.Keccak256Hash(addr.Local)
+	var accountProof proofList
+	if err := accountTrie.Prove(addrHash[:], 0, &accountProof); err != nil {
+		return nil, errors.Wrapf(err, "failed to prove account %s", addr.Local.String())
+	}
+
+	account := state.Account{
+		Balance:  new(big.Int),
+		Root:     types.EmptyRootHash,
+		CodeHash: crypto.Keccak256(nil),
+	}
+	enc, err := accountTrie.TryGet(addr.Local)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load account %s", addr.Local.String())
+	}
+	if len(enc) > 0 {
+		if err := rlp.DecodeBytes(enc, &account); err != nil {
+			return nil, errors.Wrapf(err, "failed to decode account %s", addr.Local.String())
+		}
+	}
+
+	proof := &AccountProof{
+		Address:      addr.Local,
+		Balance:      account.Balance,
+		Nonce:        account.Nonce,
+		CodeHash:     account.CodeHash,
+		StorageHash:  account.Root.Bytes(),
+		Proof:        accountProof,
+		StorageProof: make([]*StorageProof, 0, len(storageKeys)),
+	}
+	if len(storageKeys) == 0 {
+		return proof, nil
+	}
+
+	storageTrie, err := db.OpenStorageTrie(addrHash, account.Root)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open storage trie of account %s", addr.Local.String())
+	}
+	for _, key := range storageKeys {
+		slot := common.BytesToHash(key)
+		var slotProof proofList
+		if err := storageTrie.Prove(crypto.Keccak256(slot[:]), 0, &slotProof); err != nil {
+			return nil, errors.Wrapf(err, "failed to prove storage slot %s", slot.Hex())
+		}
+		enc, err := storageTrie.TryGet(slot[:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load storage slot %s", slot.Hex())
+		}
+		var value []byte
+		if len(enc) > 0 {
+			// storage values are stored RLP encoded in the trie
+			_, content, _, err := rlp.Split(enc)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decode storage slot %s", slot.Hex())
+			}
+			value = content
+		}
+		proof.StorageProof = append(proof.StorageProof, &StorageProof{
+			Key:   slot[:],
+			Value: value,
+			Proof: slotProof,
+		})
+	}
+	return proof, nil
+}
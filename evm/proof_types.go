@@ -0,0 +1,26 @@
+package evm
+
+import (
+	"math/big"
+)
+
+// AccountProof is a Merkle proof of an EVM account in the Patricia trie of the EVM state, along with
+// Merkle proofs of some of the account's storage slots in the storage trie of the account.
+type AccountProof struct {
+	Address     []byte
+	Balance     *big.Int
+	Nonce       uint64
+	CodeHash    []byte
+	StorageHash []byte
+	// Trie nodes on the path from the state root to the account, starting with the root node.
+	Proof        [][]byte
+	StorageProof []*StorageProof
+}
+
+// StorageProof is a Merkle proof of a storage slot in the storage trie of an EVM account.
+type StorageProof struct {
+	Key   []byte
+	Value []byte
+	// Trie nodes on the path from the storage root to the slot, starting with the root node.
+	Proof [][]byte
+}
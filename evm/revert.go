@@ -0,0 +1,86 @@
+package evm
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	errExecutionReverted = "evm: execution reverted"
+	revertDataLabel      = "revert data: 0x"
+)
+
+// Selector of the Error(string) function, Solidity ABI-encodes the message passed to revert() &
+// require() as a call to this function.
+var revertReasonSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// RevertError is returned when an EVM call or contract deployment is reverted, it carries the data
+// returned by the REVERT opcode.
+type RevertError struct {
+	Data []byte
+}
+
+func (e *RevertError) Error() string {
+	if len(e.Data) == 0 {
+		return errExecutionReverted
+	}
+	// The revert data is included verbatim so it can be recovered from the error message by clients
+	// that only get to see the message, e.g. from the log of a failed tx.
+	if reason, err := UnpackRevertReason(e.Data); err == nil {
+		return fmt.Sprintf("%s: %s, %s%x", errExecutionReverted, reason, revertDataLabel, e.Data)
+	}
+	return fmt.Sprintf("%s, %s%x", errExecutionReverted, revertDataLabel, e.Data)
+}
+
+// RevertData returns the data returned by the REVERT opcode.
+func (e *RevertError) RevertData() []byte {
+	return e.Data
+}
+
+// UnpackRevertReason decodes the message from the ABI-encoded Error(string) payload returned by
+// a reverted call.
+func UnpackRevertReason(data []byte) (string, error) {
+	if len(data) < len(revertReasonSelector) || !bytes.Equal(data[:4], revertReasonSelector) {
+		return "", errors.New("revert data doesn't contain a revert reason")
+	}
+	data = data[4:]
+	if len(data) < 32 {
+		return "", errors.New("revert reason offset is missing")
+	}
+	offset := new(big.Int).SetBytes(data[:32])
+	if !offset.IsUint64() || offset.Uint64() > uint64(len(data)-32) {
+		return "", errors.Errorf("invalid revert reason offset %v", offset)
+	}
+	data = data[offset.Uint64():]
+	length := new(big.Int).SetBytes(data[:32])
+	if !length.IsUint64() || length.Uint64() > uint64(len(data)-32) {
+		return "", errors.Errorf("invalid revert reason length %v", length)
+	}
+	return string(data[32 : 32+length.Uint64()]), nil
+}
+
+// ParseRevertError extracts the revert data from the message of a RevertError, the message may
+// have been wrapped by other errors.
+func ParseRevertError(msg string) ([]byte, bool) {
+	idx := strings.LastIndex(msg, revertDataLabel)
+	if idx < 0 || !strings.Contains(msg, errExecutionReverted) {
+		return nil, false
+	}
+	hexData := msg[idx+len(revertDataLabel):]
+	end := strings.IndexFunc(hexData, func(r rune) bool {
+		return !strings.ContainsRune("0123456789abcdefABCDEF", r)
+	})
+	if end >= 0 {
+		hexData = hexData[:end]
+	}
+	data, err := hex.DecodeString(hexData)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
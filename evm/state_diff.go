@@ -0,0 +1,192 @@
+// +build evm
+
+package evm
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/pkg/errors"
+)
+
+// stateTrie is a Patricia trie loaded from an evm.db snapshot.
+type stateTrie struct {
+	db     *trie.Database
+	trie   *trie.Trie
+	secure *trie.SecureTrie
+}
+
+func openStateTrie(trieDB *trie.Database, root common.Hash) (*stateTrie, error) {
+	t, err := trie.New(root, trieDB)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open trie %x", root)
+	}
+	secure, err := trie.NewSecure(root, trieDB, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open trie %x", root)
+	}
+	return &stateTrie{db: trieDB, trie: t, secure: secure}, nil
+}
+
+// DiffEvmState compares the EVM state with the given root in the left evm.db snapshot with the EVM
+// state with the given root in the right evm.db snapshot, and calls onDiff for each account that
+// differs between the two, in order of address hash. Sub-tries that are identical in both versions
+// are skipped, so the cost of the comparison is proportional to the size of the difference.
+func DiffEvmState(
+	left db.Snapshot, leftRoot []byte, right db.Snapshot, rightRoot []byte, onDiff func(*AccountDiff),
+) error {
+	leftDB := trie.NewDatabase(&snapshotEthdb{snap: left})
+	leftTrie, err := openStateTrie(leftDB, common.BytesToHash(leftRoot))
+	if err != nil {
+		return err
+	}
+	rightDB := trie.NewDatabase(&snapshotEthdb{snap: right})
+	rightTrie, err := openStateTrie(rightDB, common.BytesToHash(rightRoot))
+	if err != nil {
+		return err
+	}
+
+	return diffTries(leftTrie, rightTrie, func(addrHash, leftValue, rightValue []byte) error {
+		diff := &AccountDiff{
+			Address:     findPreimage(leftTrie, rightTrie, addrHash),
+			AddressHash: addrHash,
+		}
+		leftRoot, rightRoot := types.EmptyRootHash, types.EmptyRootHash
+		if leftValue != nil {
+			account, err := decodeAccount(leftValue)
+			if err != nil {
+				return err
+			}
+			diff.Left = toAccount(account)
+			leftRoot = account.Root
+		}
+		if rightValue != nil {
+			account, err := decodeAccount(rightValue)
+			if err != nil {
+				return err
+			}
+			diff.Right = toAccount(account)
+			rightRoot = account.Root
+		}
+
+		if leftRoot != rightRoot {
+			leftStorage, err := openStateTrie(leftTrie.db, leftRoot)
+			if err != nil {
+				return err
+			}
+			rightStorage, err := openStateTrie(rightTrie.db, rightRoot)
+			if err != nil {
+				return err
+			}
+			err = diffTries(leftStorage, rightStorage, func(keyHash, leftValue, rightValue []byte) error {
+				leftSlot, err := decodeStorageValue(leftValue)
+				if err != nil {
+					return err
+				}
+				rightSlot, err := decodeStorageValue(rightValue)
+				if err != nil {
+					return err
+				}
+				diff.Storage = append(diff.Storage, &StorageDiff{
+					Key:     findPreimage(leftStorage, rightStorage, keyHash),
+					KeyHash: keyHash,
+					Left:    leftSlot,
+					Right:   rightSlot,
+				})
+				return nil
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to diff storage of account %x", addrHash)
+			}
+		}
+		onDiff(diff)
+		return nil
+	})
+}
+
+// diffTries calls onDiff with the key & values of every leaf that differs between the two tries,
+// in key order. A nil value indicates the leaf doesn't exist in that trie.
+func diffTries(left, right *stateTrie, onDiff func(key, leftValue, rightValue []byte) error) error {
+	keys := map[string]struct{}{}
+	collectKeys := func(a, b *stateTrie) error {
+		// iterates over the nodes in b that aren't in a
+		diffIt, _ := trie.NewDifferenceIterator(a.trie.NodeIterator(nil), b.trie.NodeIterator(nil))
+		it := trie.NewIterator(diffIt)
+		for it.Next() {
+			keys[string(it.Key)] = struct{}{}
+		}
+		return it.Err
+	}
+	if err := collectKeys(left, right); err != nil {
+		return err
+	}
+	if err := collectKeys(right, left); err != nil {
+		return err
+	}
+
+	sortedKeys := make([]string, 0, len(keys))
+	for key := range keys {
+		sortedKeys = append(sortedKeys, key)
+	}
+	sort.Strings(sortedKeys)
+
+	for _, key := range sortedKeys {
+		leftValue, err := left.trie.TryGet([]byte(key))
+		if err != nil {
+			return err
+		}
+		rightValue, err := right.trie.TryGet([]byte(key))
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(leftValue, rightValue) {
+			continue
+		}
+		if err := onDiff([]byte(key), leftValue, rightValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPreimage looks up the preimage of the given hashed trie key, returns nil if not found.
+func findPreimage(left, right *stateTrie, keyHash []byte) []byte {
+	if key := left.secure.GetKey(keyHash); key != nil {
+		return key
+	}
+	return right.secure.GetKey(keyHash)
+}
+
+func decodeAccount(value []byte) (*state.Account, error) {
+	var account state.Account
+	if err := rlp.DecodeBytes(value, &account); err != nil {
+		return nil, errors.Wrap(err, "failed to decode account")
+	}
+	return &account, nil
+}
+
+func toAccount(account *state.Account) *Account {
+	return &Account{
+		Nonce:       account.Nonce,
+		Balance:     account.Balance,
+		CodeHash:    account.CodeHash,
+		StorageRoot: account.Root.Bytes(),
+	}
+}
+
+func decodeStorageValue(value []byte) ([]byte, error) {
+	if value == nil {
+		return nil, nil
+	}
+	_, content, _, err := rlp.Split(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode storage value")
+	}
+	return common.LeftPadBytes(content, 32), nil
+}
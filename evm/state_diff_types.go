@@ -0,0 +1,36 @@
+package evm
+
+import (
+	"math/big"
+)
+
+// Account is the state of an EVM account as stored in the Patricia trie of the EVM state.
+type Account struct {
+	Nonce       uint64
+	Balance     *big.Int
+	CodeHash    []byte
+	StorageRoot []byte
+}
+
+// AccountDiff describes an EVM account that differs between two versions of the EVM state.
+type AccountDiff struct {
+	// Address of the account, nil if the preimage of the address hash couldn't be found.
+	Address     []byte
+	AddressHash []byte
+	// State of the account in each version, nil if the account doesn't exist in that version.
+	Left  *Account
+	Right *Account
+	// Storage slots that differ between the two versions of the account.
+	Storage []*StorageDiff
+}
+
+// StorageDiff describes a storage slot of an EVM account that differs between two versions of the
+// EVM state.
+type StorageDiff struct {
+	// Storage key, nil if the preimage of the key hash couldn't be found.
+	Key     []byte
+	KeyHash []byte
+	// Value of the slot in each version, nil if the slot is empty in that version.
+	Left  []byte
+	Right []byte
+}
@@ -0,0 +1,143 @@
+// +build evm
+
+package evm
+
+import (
+	"bytes"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/state"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/pkg/errors"
+)
+
+var (
+	errReadOnlyEthdb = errors.New("ethdb is read-only")
+	emptyCodeHash    = crypto.Keccak256(nil)
+)
+
+// WalkEvmState walks the Patricia trie with the given root, along with the storage tries & code of
+// all the accounts in it, and calls visit with the evm.db key of every trie node & code blob it
+// encounters. If visit returns false the children of the node aren't visited, which makes it
+// possible to skip sub-tries that have already been visited via another root.
+func WalkEvmState(snap db.Snapshot, root []byte, visit func(key []byte) bool) error {
+	trieDB := trie.NewDatabase(&snapshotEthdb{snap: snap})
+	return walkTrie(trieDB, common.BytesToHash(root), func(leaf []byte) error {
+		var account state.Account
+		if err := rlp.DecodeBytes(leaf, &account); err != nil {
+			return errors.Wrap(err, "failed to decode account")
+		}
+		if !bytes.Equal(account.CodeHash, emptyCodeHash) {
+			visit(util.PrefixKey(vmPrefix, account.CodeHash))
+		}
+		if account.Root != types.EmptyRootHash {
+			if err := walkTrie(trieDB, account.Root, nil, visit); err != nil {
+				return errors.Wrapf(err, "failed to walk storage trie %x", account.Root)
+			}
+		}
+		return nil
+	}, visit)
+}
+
+func walkTrie(
+	trieDB *trie.Database, root common.Hash, onLeaf func(leaf []byte) error, visit func(key []byte) bool,
+) error {
+	t, err := trie.New(root, trieDB)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open trie %x", root)
+	}
+	it := t.NodeIterator(nil)
+	descend := true
+	for it.Next(descend) {
+		descend = true
+		if hash := it.Hash(); hash != (common.Hash{}) {
+			descend = visit(util.PrefixKey(vmPrefix, hash[:]))
+		}
+		if it.Leaf() && onLeaf != nil {
+			if err := onLeaf(it.LeafBlob()); err != nil {
+				return err
+			}
+		}
+	}
+	return it.Error()
+}
+
+// snapshotEthdb is a read-only ethdb.KeyValueStore that reads trie nodes from an evm.db snapshot.
+type snapshotEthdb struct {
+	snap db.Snapshot
+}
+
+func (s *snapshotEthdb) Put(key []byte, value []byte) error {
+	return errReadOnlyEthdb
+}
+
+func (s *snapshotEthdb) Get(key []byte) ([]byte, error) {
+	return s.snap.Get(util.PrefixKey(vmPrefix, key)), nil
+}
+
+func (s *snapshotEthdb) Has(key []byte) (bool, error) {
+	return s.snap.Has(util.PrefixKey(vmPrefix, key)), nil
+}
+
+func (s *snapshotEthdb) Delete(key []byte) error {
+	return errReadOnlyEthdb
+}
+
+func (s *snapshotEthdb) Close() error {
+	return nil
+}
+
+func (s *snapshotEthdb) NewIterator() ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *snapshotEthdb) NewIteratorWithStart(start []byte) ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *snapshotEthdb) NewIteratorWithPrefix(prefix []byte) ethdb.Iterator {
+	return &unsupportedIterator{}
+}
+
+func (s *snapshotEthdb) Stat(property string) (string, error) {
+	return "", errors.Errorf("unknown ethdb property %s", property)
+}
+
+func (s *snapshotEthdb) Compact(start []byte, limit []byte) error {
+	return nil
+}
+
+func (s *snapshotEthdb) NewBatch() ethdb.Batch {
+	return &readOnlyBatch{}
+}
+
+type readOnlyBatch struct{}
+
+func (b *readOnlyBatch) Put(key, value []byte) error {
+	return errReadOnlyEthdb
+}
+
+func (b *readOnlyBatch) Delete(key []byte) error {
+	return errReadOnlyEthdb
+}
+
+func (b *readOnlyBatch) ValueSize() int {
+	return 0
+}
+
+func (b *readOnlyBatch) Write() error {
+	return errReadOnlyEthdb
+}
+
+func (b *readOnlyBatch) Reset() {
+}
+
+func (b *readOnlyBatch) Replay(w ethdb.KeyValueWriter) error {
+	return nil
+}
@@ -0,0 +1,46 @@
+package evm
+
+import (
+	"encoding/json"
+	"math/big"
+	"time"
+)
+
+// TraceConfig specifies how the execution of an EVM tx should be traced.
+type TraceConfig struct {
+	// Name (e.g. "callTracer") or JavaScript source of the tracer to use, if empty the struct logger
+	// is used to record the state of the EVM before each opcode is executed.
+	Tracer string
+	// Maximum amount of time a trace is allowed to run for, zero means the default.
+	Timeout time.Duration
+	// Struct logger settings.
+	DisableStorage bool
+	DisableMemory  bool
+	DisableStack   bool
+	// Maximum number of opcodes the struct logger should record, zero means the default.
+	Limit int
+}
+
+// StructLog is the state of the EVM recorded by the struct logger right before an opcode is executed.
+type StructLog struct {
+	PC      uint64
+	Op      string
+	Gas     uint64
+	GasCost uint64
+	Depth   int
+	Error   string
+	Stack   []*big.Int
+	Memory  []byte
+	// Storage slots of the current contract that were accessed so far.
+	Storage map[[32]byte][32]byte
+}
+
+// ExecutionTrace is the result of tracing the execution of an EVM tx.
+type ExecutionTrace struct {
+	Gas         uint64
+	Failed      bool
+	ReturnValue []byte
+	StructLogs  []StructLog
+	// Result returned by the JavaScript tracer, nil if the struct logger was used.
+	TracerResult json.RawMessage
+}
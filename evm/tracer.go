@@ -0,0 +1,177 @@
+// +build evm
+
+package evm
+
+import (
+	"sync/atomic"
+	"time"
+
+	ethvm "github.com/ethereum/go-ethereum/core/vm"
+	"github.com/ethereum/go-ethereum/eth/tracers"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultTraceTimeout = 5 * time.Second
+	// Maximum number of opcodes the struct logger records if the trace config doesn't specify a limit.
+	defaultStructLogLimit = 10000
+)
+
+// TxReplayer re-executes EVM txs one after another, the changes made by each tx are visible to the
+// txs executed after it. Since the given state is modified it should be a throwaway copy of the
+// app state, e.g. one created by loomchain.NewCachedState.
+type TxReplayer struct {
+	state      loomchain.State
+	provideABM ABMFactoryProvider
+	gasCap     uint64
+}
+
+// NewTxReplayer creates a new replayer, if gasCap is non-zero no tx will be executed with more
+// than that amount of gas.
+func NewTxReplayer(state loomchain.State, provideABM ABMFactoryProvider, gasCap uint64) *TxReplayer {
+	return &TxReplayer{
+		state:      state,
+		provideABM: provideABM,
+		gasCap:     gasCap,
+	}
+}
+
+// Apply executes the given call (or contract deployment if addr is nil), the changes made by the
+// call are only kept if it succeeds. An error is only returned if the call couldn't be executed.
+func (r *TxReplayer) Apply(caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt) error {
+	_, err := r.Trace(caller, addr, input, value, 0, nil)
+	return err
+}
+
+// Trace executes the given call (or contract deployment if addr is nil) with the given amount of
+// gas (zero means the EVM gas limit, or the gas cap of the replayer if it's lower), and traces the
+// execution as specified by the config. The
+// changes made by the call are only kept if it succeeds. An error is only returned if the call
+// couldn't be executed or traced, failed calls are indicated by ExecutionTrace.Failed.
+func (r *TxReplayer) Trace(
+	caller loom.Address, addr *loom.Address, input []byte, value *loom.BigUInt, gas uint64, cfg *TraceConfig,
+) (*ExecutionTrace, error) {
+	levm, err := newLoomEvmWithABM(r.state, r.provideABM)
+	if err != nil {
+		return nil, err
+	}
+	if gas == 0 || gas > levm.gasLimit {
+		gas = levm.gasLimit
+	}
+	if r.gasCap > 0 && gas > r.gasCap {
+		gas = r.gasCap
+	}
+
+	var structLogger *ethvm.StructLogger
+	var jsTracer *tracers.Tracer
+	var tracer *timeoutTracer
+	if cfg != nil {
+		if cfg.Tracer == "" {
+			limit := cfg.Limit
+			if limit == 0 {
+				limit = defaultStructLogLimit
+			}
+			structLogger = ethvm.NewStructLogger(&ethvm.LogConfig{
+				DisableMemory:  cfg.DisableMemory,
+				DisableStack:   cfg.DisableStack,
+				DisableStorage: cfg.DisableStorage,
+				Limit:          limit,
+			})
+			tracer = &timeoutTracer{Tracer: structLogger}
+		} else {
+			jsTracer, err = tracers.New(cfg.Tracer)
+			if err != nil {
+				return nil, errors.Wrap(err, "failed to create tracer")
+			}
+			tracer = &timeoutTracer{Tracer: jsTracer}
+		}
+		timeout := cfg.Timeout
+		if timeout == 0 {
+			timeout = defaultTraceTimeout
+		}
+		deadline := time.AfterFunc(timeout, func() {
+			atomic.StoreInt32(&tracer.timedOut, 1)
+			if jsTracer != nil {
+				jsTracer.Stop(errors.New("execution timeout"))
+			}
+		})
+		defer deadline.Stop()
+		levm.vmConfig = ethvm.Config{
+			Debug:  true,
+			Tracer: tracer,
+		}
+	}
+
+	ret, usedGas, vmErr := levm.execute(caller, addr, input, value, gas)
+	if tracer != nil && atomic.LoadInt32(&tracer.timedOut) == 1 {
+		return nil, errors.New("execution timeout")
+	}
+	if vmErr == nil {
+		if _, err := levm.Commit(); err != nil {
+			return nil, errors.Wrap(err, "failed to commit EVM state")
+		}
+	}
+
+	trace := &ExecutionTrace{
+		Gas:         usedGas,
+		Failed:      vmErr != nil,
+		ReturnValue: ret,
+	}
+	if structLogger != nil {
+		trace.StructLogs = convertStructLogs(structLogger.StructLogs())
+	}
+	if jsTracer != nil {
+		trace.TracerResult, err = jsTracer.GetResult()
+		if err != nil {
+			return nil, errors.Wrap(err, "tracer failed")
+		}
+	}
+	return trace, nil
+}
+
+// timeoutTracer wraps a tracer, and aborts the execution of the EVM it's tracing once the trace
+// times out. Tracers can't stop the EVM by themselves, so without this a call could keep running
+// until it runs out of gas long after the trace has timed out.
+type timeoutTracer struct {
+	ethvm.Tracer
+	timedOut int32 // set to 1 when the trace times out
+}
+
+func (t *timeoutTracer) CaptureState(
+	env *ethvm.EVM, pc uint64, op ethvm.OpCode, gas, cost uint64, memory *ethvm.Memory,
+	stack *ethvm.Stack, contract *ethvm.Contract, depth int, err error,
+) error {
+	if atomic.LoadInt32(&t.timedOut) == 1 {
+		env.Cancel()
+		return errors.New("execution timeout")
+	}
+	return t.Tracer.CaptureState(env, pc, op, gas, cost, memory, stack, contract, depth, err)
+}
+
+func convertStructLogs(logs []ethvm.StructLog) []StructLog {
+	result := make([]StructLog, 0, len(logs))
+	for _, log := range logs {
+		entry := StructLog{
+			PC:      log.Pc,
+			Op:      log.Op.String(),
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+			Stack:   log.Stack,
+			Memory:  log.Memory,
+		}
+		if log.Err != nil {
+			entry.Error = log.Err.Error()
+		}
+		if log.Storage != nil {
+			entry.Storage = make(map[[32]byte][32]byte, len(log.Storage))
+			for key, value := range log.Storage {
+				entry.Storage[key] = value
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
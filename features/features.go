@@ -142,4 +142,26 @@ const (
 
 	// Enables Constantinople hard fork in EVM interpreter
 	EvmConstantinopleFeature = "evm:constantinople"
+
+	// Enables Petersburg hard fork in EVM interpreter, implies Constantinople
+	EvmPetersburgFeature = "evm:petersburg"
+
+	// Enables Istanbul hard fork in EVM interpreter, implies Petersburg
+	EvmIstanbulFeature = "evm:istanbul"
+
+	// Enforces the gas limit specified in Ethereum txs, and the EVM block gas limit in the
+	// on-chain config.
+	EvmGasMeteringFeature = "evm:gas-metering"
+
+	// Makes the BLOCKHASH & COINBASE opcodes return the hashes of recent blocks, and the address of
+	// the block proposer, instead of made up values.
+	EvmBlockContextFeature = "evm:block-context"
+
+	// Charges tx fees as specified by the TxFee settings in the on-chain config, and distributes the
+	// collected fees to validators via DPOSv3.
+	TxFeeFeature = "tx:fee"
+
+	// Enables the Loom EVM precompiles that provide EVM contracts with read-only access to the
+	// built-in Go contracts.
+	EvmGoPrecompilesFeature = "evm:go-precompiles"
 )
@@ -0,0 +1,178 @@
+package fees
+
+import (
+	"math/big"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/pkg/errors"
+
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/auth"
+	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
+	"github.com/loomnetwork/loomchain/features"
+	"github.com/loomnetwork/loomchain/log"
+	"github.com/loomnetwork/loomchain/registry"
+	"github.com/loomnetwork/loomchain/store"
+)
+
+var (
+	// ErrInsufficientFunds indicates that the tx sender can't afford to pay the minimum tx fee.
+	ErrInsufficientFunds = errors.New("[TxFeeMiddleware] insufficient funds to pay tx fee")
+)
+
+// BalanceManager provides access to the balances of the token tx fees are paid in.
+type BalanceManager interface {
+	GetBalance(addr loom.Address) (*loom.BigUInt, error)
+	Transfer(from, to loom.Address, amount *loom.BigUInt) error
+}
+
+// BalanceManagerFactory returns a BalanceManager for the contract with the given name, which will
+// be either dposv3.LoomFeeToken or dposv3.EthFeeToken.
+type BalanceManagerFactory func(state loomchain.State, token string) (BalanceManager, error)
+
+// NewTxFeeMiddleware returns a middleware that charges the tx sender a fee for each tx, the fee is
+// equal to the amount of gas used by the tx multiplied by the gas price in the on-chain settings,
+// with txs being charged for at least the minimum amount of gas specified in the on-chain settings.
+// Depending on the on-chain settings the fees are paid in either LOOM or ETH, and are transferred to
+// the DPOSv3 contract which distributes them to validators at the end of each election cycle.
+//
+// In CheckTx the middleware only verifies the sender can afford the minimum fee. Failed txs are
+// charged in DeliverTx too, but since any state changes they make are discarded the fee is paid in
+// a separate store tx that's committed to the given store straight away. No fees are charged until
+// the DPOSv3 contract is deployed.
+func NewTxFeeMiddleware(
+	kvStore store.KVStore,
+	createBalanceManager BalanceManagerFactory,
+	createDPOSCtx func(state loomchain.State) (contractpb.Context, error),
+) loomchain.TxMiddlewareFunc {
+	return loomchain.TxMiddlewareFunc(func(
+		state loomchain.State,
+		txBytes []byte,
+		next loomchain.TxHandlerFunc,
+		isCheckTx bool,
+	) (res loomchain.TxHandlerResult, err error) {
+		if !state.FeatureEnabled(features.TxFeeFeature, false) {
+			return next(state, txBytes, isCheckTx)
+		}
+
+		settings := state.OnChainSettings()
+		if settings.TxFeeGasPrice == 0 {
+			return next(state, txBytes, isCheckTx)
+		}
+
+		dposCtx, err := createDPOSCtx(state)
+		if errors.Cause(err) == registry.ErrNotFound {
+			return next(state, txBytes, isCheckTx)
+		}
+		if err != nil {
+			return res, errors.Wrap(err, "failed to create DPOSv3 contract context")
+		}
+
+		token := feeToken(settings)
+		balances, err := createBalanceManager(state, token)
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to load %s balances", token)
+		}
+
+		origin := auth.Origin(state.Context())
+		balance, err := balances.GetBalance(origin)
+		if err != nil {
+			return res, errors.Wrapf(err, "failed to load %s balance of %s", token, origin.String())
+		}
+		if balance.Cmp(calculateFee(settings.TxFeeMinGas, settings.TxFeeGasPrice)) < 0 {
+			return res, ErrInsufficientFunds
+		}
+
+		if isCheckTx {
+			return next(state, txBytes, isCheckTx)
+		}
+
+		res, err = next(state, txBytes, isCheckTx)
+
+		gasUsed := settings.TxFeeMinGas
+		if gasMeter := loomchain.GasMeterFromContext(state.Context()); gasMeter != nil {
+			if gasMeter.GasUsed() > gasUsed {
+				gasUsed = gasMeter.GasUsed()
+			}
+		}
+		fee := calculateFee(gasUsed, settings.TxFeeGasPrice)
+
+		if err == nil {
+			if err = payTxFee(balances, dposCtx, token, origin, fee); err == nil {
+				return res, nil
+			}
+			err = errors.Wrap(err, "failed to pay tx fee")
+		}
+
+		// The state changes made by the tx will be discarded, including any fee paid above.
+		feeErr := chargeFailedTx(state, kvStore, createBalanceManager, createDPOSCtx, token, origin, fee)
+		if feeErr != nil {
+			log.Error("Failed to charge fee for failed tx", "origin", origin.String(), "err", feeErr)
+		}
+		return res, err
+	})
+}
+
+// chargeFailedTx charges the tx sender for a failed tx in a new store tx, which is committed to the
+// given store once the fee is paid. The sender is charged the given fee, or their whole balance if
+// it's less than that.
+func chargeFailedTx(
+	txState loomchain.State,
+	kvStore store.KVStore,
+	createBalanceManager BalanceManagerFactory,
+	createDPOSCtx func(state loomchain.State) (contractpb.Context, error),
+	token string, origin loom.Address, fee *loom.BigUInt,
+) error {
+	storeTx := store.WrapAtomic(kvStore).BeginTx()
+	state := loomchain.NewStoreStateWithStore(txState, storeTx)
+	balances, err := createBalanceManager(state, token)
+	if err != nil {
+		storeTx.Rollback()
+		return err
+	}
+	balance, err := balances.GetBalance(origin)
+	if err != nil {
+		storeTx.Rollback()
+		return err
+	}
+	if balance.Cmp(fee) < 0 {
+		fee = balance
+	}
+	dposCtx, err := createDPOSCtx(state)
+	if err != nil {
+		storeTx.Rollback()
+		return err
+	}
+	if err := payTxFee(balances, dposCtx, token, origin, fee); err != nil {
+		storeTx.Rollback()
+		return err
+	}
+	storeTx.Commit()
+	return nil
+}
+
+// feeToken returns the name of the contract that manages the balances of the token tx fees are paid in.
+func feeToken(settings *store.OnChainSettings) string {
+	if settings.TxFeePayInEthCoin {
+		return dposv3.EthFeeToken
+	}
+	return dposv3.LoomFeeToken
+}
+
+// payTxFee transfers the fee from the tx sender to the DPOSv3 contract, and adds it to the pool of
+// fees that will be distributed to validators.
+func payTxFee(
+	balances BalanceManager, dposCtx contractpb.Context, token string, origin loom.Address, fee *loom.BigUInt,
+) error {
+	if err := balances.Transfer(origin, dposCtx.ContractAddress(), fee); err != nil {
+		return err
+	}
+	return dposv3.AddTxFees(dposCtx, token, fee)
+}
+
+func calculateFee(gas, gasPrice uint64) *loom.BigUInt {
+	fee := new(big.Int).SetUint64(gas)
+	fee.Mul(fee, new(big.Int).SetUint64(gasPrice))
+	return loom.NewBigUInt(fee)
+}
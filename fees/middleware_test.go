@@ -0,0 +1,221 @@
+package fees
+
+import (
+	"context"
+	"testing"
+
+	"github.com/loomnetwork/go-loom"
+	goloomplugin "github.com/loomnetwork/go-loom/plugin"
+	"github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/auth"
+	"github.com/loomnetwork/loomchain/builtin/plugins/dposv3"
+	"github.com/loomnetwork/loomchain/features"
+	"github.com/loomnetwork/loomchain/registry"
+	"github.com/loomnetwork/loomchain/store"
+)
+
+var (
+	sender   = loom.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	dposAddr = loom.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01ab")
+)
+
+type fakeBalanceManager map[string]*loom.BigUInt
+
+func (m fakeBalanceManager) GetBalance(addr loom.Address) (*loom.BigUInt, error) {
+	if balance, ok := m[addr.String()]; ok {
+		return balance, nil
+	}
+	return loom.NewBigUIntFromInt(0), nil
+}
+
+func (m fakeBalanceManager) Transfer(from, to loom.Address, amount *loom.BigUInt) error {
+	fromBalance, _ := m.GetBalance(from)
+	if fromBalance.Cmp(amount) < 0 {
+		return errors.New("insufficient balance")
+	}
+	newFromBalance := loom.NewBigUIntFromInt(0)
+	newFromBalance.Sub(fromBalance, amount)
+	m[from.String()] = newFromBalance
+	toBalance, _ := m.GetBalance(to)
+	newToBalance := loom.NewBigUIntFromInt(0)
+	newToBalance.Add(toBalance, amount)
+	m[to.String()] = newToBalance
+	return nil
+}
+
+func TestTxFeeMiddleware(t *testing.T) {
+	balances := map[string]fakeBalanceManager{
+		dposv3.LoomFeeToken: {sender.String(): loom.NewBigUIntFromInt(2500)},
+		dposv3.EthFeeToken:  {sender.String(): loom.NewBigUIntFromInt(100)},
+	}
+	dposCtx := contractpb.WrapPluginContext(goloomplugin.CreateFakeContext(sender, dposAddr))
+	dposDeployed := true
+	middleware := NewTxFeeMiddleware(
+		store.NewMemStore(),
+		func(state loomchain.State, token string) (BalanceManager, error) {
+			return balances[token], nil
+		},
+		func(state loomchain.State) (contractpb.Context, error) {
+			if !dposDeployed {
+				return nil, registry.ErrNotFound
+			}
+			return dposCtx, nil
+		},
+	)
+
+	settings := &store.OnChainSettings{
+		TxFeeGasPrice: 10,
+		TxFeeMinGas:   100,
+	}
+	gasUsed := uint64(0)
+	txFailed := false
+	processTx := func(isCheckTx bool) error {
+		gasMeter := loomchain.NewInfiniteGasMeter(0)
+		ctx := context.WithValue(loomchain.WithGasMeter(context.Background(), gasMeter), auth.ContextKeyOrigin, sender)
+		state := loomchain.NewStoreState(ctx, store.NewMemStore(), abci.Header{}, nil, nil).WithOnChainSettings(settings)
+		state.SetFeature(features.TxFeeFeature, true)
+		_, err := middleware.ProcessTx(state, nil,
+			func(state loomchain.State, txBytes []byte, isCheckTx bool) (loomchain.TxHandlerResult, error) {
+				if err := gasMeter.ConsumeGas(gasUsed); err != nil {
+					return loomchain.TxHandlerResult{}, err
+				}
+				if txFailed {
+					return loomchain.TxHandlerResult{}, errors.New("tx failed")
+				}
+				return loomchain.TxHandlerResult{}, nil
+			}, isCheckTx,
+		)
+		return err
+	}
+	requireBalance := func(token string, addr loom.Address, expected int64) {
+		balance, err := balances[token].GetBalance(addr)
+		require.NoError(t, err)
+		require.Equal(t, expected, balance.Int64())
+	}
+
+	// CheckTx shouldn't charge any fees
+	require.NoError(t, processTx(true))
+	requireBalance(dposv3.LoomFeeToken, sender, 2500)
+
+	// txs are charged for the min gas if they use less than that
+	require.NoError(t, processTx(false))
+	requireBalance(dposv3.LoomFeeToken, sender, 1500)
+	requireBalance(dposv3.LoomFeeToken, dposAddr, 1000)
+
+	gasUsed = 120
+	require.NoError(t, processTx(false))
+	requireBalance(dposv3.LoomFeeToken, sender, 300)
+	requireBalance(dposv3.LoomFeeToken, dposAddr, 2200)
+	pool, err := dposv3.TxFeePool(dposCtx, dposv3.LoomFeeToken)
+	require.NoError(t, err)
+	require.Equal(t, int64(2200), pool.Int64())
+
+	// the sender can't afford the min fee anymore
+	require.Equal(t, ErrInsufficientFunds, processTx(true))
+	require.Equal(t, ErrInsufficientFunds, processTx(false))
+
+	// failed txs are charged too
+	settings.TxFeePayInEthCoin = true
+	settings.TxFeeGasPrice = 1
+	gasUsed = 0
+	txFailed = true
+	require.Error(t, processTx(false))
+	requireBalance(dposv3.EthFeeToken, sender, 0)
+	requireBalance(dposv3.EthFeeToken, dposAddr, 100)
+	pool, err = dposv3.TxFeePool(dposCtx, dposv3.EthFeeToken)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pool.Int64())
+
+	// failed txs that used more gas than the sender can pay for are charged the sender's balance
+	balances[dposv3.EthFeeToken][sender.String()] = loom.NewBigUIntFromInt(150)
+	gasUsed = 200
+	require.Error(t, processTx(false))
+	requireBalance(dposv3.EthFeeToken, sender, 0)
+	requireBalance(dposv3.EthFeeToken, dposAddr, 250)
+
+	// no fees should be charged until DPOSv3 is deployed
+	txFailed = false
+	dposDeployed = false
+	require.NoError(t, processTx(false))
+	dposDeployed = true
+
+	// no fees should be charged when the gas price is zero
+	settings.TxFeeGasPrice = 0
+	require.NoError(t, processTx(false))
+}
+
+// stateBalanceManager keeps balances in the state it was created with.
+type stateBalanceManager struct {
+	state loomchain.State
+}
+
+func (m *stateBalanceManager) GetBalance(addr loom.Address) (*loom.BigUInt, error) {
+	balance := loom.NewBigUIntFromInt(0)
+	if data := m.state.Get([]byte(addr.String())); data != nil {
+		balance.SetBytes(data)
+	}
+	return balance, nil
+}
+
+func (m *stateBalanceManager) Transfer(from, to loom.Address, amount *loom.BigUInt) error {
+	fromBalance, _ := m.GetBalance(from)
+	if fromBalance.Cmp(amount) < 0 {
+		return errors.New("insufficient balance")
+	}
+	toBalance, _ := m.GetBalance(to)
+	m.state.Set([]byte(from.String()), fromBalance.Sub(fromBalance, amount).Bytes())
+	m.state.Set([]byte(to.String()), toBalance.Add(toBalance, amount).Bytes())
+	return nil
+}
+
+func TestChargeFailedTx(t *testing.T) {
+	kvStore := store.NewMemStore()
+	kvStore.Set([]byte(sender.String()), loom.NewBigUIntFromInt(150).Bytes())
+	dposCtx := contractpb.WrapPluginContext(goloomplugin.CreateFakeContext(sender, dposAddr))
+	createBalanceManager := func(state loomchain.State, token string) (BalanceManager, error) {
+		return &stateBalanceManager{state: state}, nil
+	}
+	createDPOSCtx := func(state loomchain.State) (contractpb.Context, error) {
+		return dposCtx, nil
+	}
+	requireBalance := func(addr loom.Address, expected int64) {
+		balance := loom.NewBigUIntFromInt(0)
+		balance.SetBytes(kvStore.Get([]byte(addr.String())))
+		require.Equal(t, expected, balance.Int64())
+	}
+
+	// the fee should be committed to the store even though the state of the failed tx is discarded
+	txState := loomchain.NewStoreState(context.Background(), store.NewMemStore(), abci.Header{}, nil, nil)
+	fee := loom.NewBigUIntFromInt(100)
+	require.NoError(t, chargeFailedTx(
+		txState, kvStore, createBalanceManager, createDPOSCtx, dposv3.LoomFeeToken, sender, fee,
+	))
+	requireBalance(sender, 50)
+	requireBalance(dposAddr, 100)
+	pool, err := dposv3.TxFeePool(dposCtx, dposv3.LoomFeeToken)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pool.Int64())
+
+	// the sender should be charged their whole balance if they can't afford the fee
+	require.NoError(t, chargeFailedTx(
+		txState, kvStore, createBalanceManager, createDPOSCtx, dposv3.LoomFeeToken, sender, fee,
+	))
+	requireBalance(sender, 0)
+	requireBalance(dposAddr, 150)
+
+	// nothing should be committed if the fee can't be paid
+	kvStore.Set([]byte(sender.String()), loom.NewBigUIntFromInt(150).Bytes())
+	failingDPOSCtx := func(state loomchain.State) (contractpb.Context, error) {
+		return nil, errors.New("DPOSv3 unavailable")
+	}
+	require.Error(t, chargeFailedTx(
+		txState, kvStore, createBalanceManager, failingDPOSCtx, dposv3.LoomFeeToken, sender, fee,
+	))
+	requireBalance(sender, 150)
+	requireBalance(dposAddr, 150)
+}
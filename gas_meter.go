@@ -0,0 +1,98 @@
+package loomchain
+
+import (
+	"context"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+type contextKey string
+
+func (c contextKey) String() string {
+	return "loomchain context key " + string(c)
+}
+
+var contextKeyGasMeter = contextKey("gasMeter")
+
+// ErrOutOfGas is returned when a tx attempts to use more gas than it's allowed to.
+var ErrOutOfGas = errors.New("out of gas")
+
+// GasMeter keeps track of the gas used by a tx, and the gas used by all the txs in the block
+// preceding it.
+type GasMeter struct {
+	limit        uint64
+	used         uint64
+	blockGasUsed uint64
+}
+
+// NewGasMeter creates a meter for a tx that can use up to limit gas, blockGasUsed is the amount of
+// gas used by the txs that precede the tx in the current block.
+func NewGasMeter(limit, blockGasUsed uint64) *GasMeter {
+	return &GasMeter{
+		limit:        limit,
+		blockGasUsed: blockGasUsed,
+	}
+}
+
+// NewInfiniteGasMeter creates a meter that tracks the gas used by a tx without limiting it.
+func NewInfiniteGasMeter(blockGasUsed uint64) *GasMeter {
+	return NewGasMeter(math.MaxUint64, blockGasUsed)
+}
+
+// Limit returns the max amount of gas the tx can use.
+func (m *GasMeter) Limit() uint64 {
+	return m.limit
+}
+
+// SetLimit lowers the max amount of gas the tx can use, the limit can't be raised.
+func (m *GasMeter) SetLimit(limit uint64) {
+	if limit < m.limit {
+		m.limit = limit
+	}
+}
+
+// GasRemaining returns the amount of gas the tx can use before it runs out of gas.
+func (m *GasMeter) GasRemaining() uint64 {
+	if m.used >= m.limit {
+		return 0
+	}
+	return m.limit - m.used
+}
+
+// ConsumeGas adds the given amount to the gas used by the tx, if this exceeds the limit the gas
+// used is capped at the limit, and ErrOutOfGas is returned.
+func (m *GasMeter) ConsumeGas(amount uint64) error {
+	if amount > m.GasRemaining() {
+		m.used = m.limit
+		return ErrOutOfGas
+	}
+	m.used += amount
+	return nil
+}
+
+// GasUsed returns the amount of gas used by the tx so far.
+func (m *GasMeter) GasUsed() uint64 {
+	return m.used
+}
+
+// CumulativeGasUsed returns the amount of gas used by the tx, and all the txs preceding it in the
+// current block.
+func (m *GasMeter) CumulativeGasUsed() uint64 {
+	return m.blockGasUsed + m.used
+}
+
+// WithGasMeter returns a copy of the given context that carries the given gas meter.
+func WithGasMeter(ctx context.Context, meter *GasMeter) context.Context {
+	return context.WithValue(ctx, contextKeyGasMeter, meter)
+}
+
+// GasMeterFromContext returns the gas meter carried by the given context, or nil if the context
+// doesn't carry one, which is the case when txs are simulated rather than executed in a block.
+func GasMeterFromContext(ctx context.Context) *GasMeter {
+	if ctx == nil {
+		return nil
+	}
+	meter, _ := ctx.Value(contextKeyGasMeter).(*GasMeter)
+	return meter
+}
@@ -0,0 +1,31 @@
+package loomchain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasMeter(t *testing.T) {
+	require.Nil(t, GasMeterFromContext(context.Background()))
+
+	meter := NewGasMeter(1000, 200)
+	require.Equal(t, meter, GasMeterFromContext(WithGasMeter(context.Background(), meter)))
+
+	// the limit can be lowered but not raised
+	meter.SetLimit(2000)
+	require.Equal(t, uint64(1000), meter.Limit())
+	meter.SetLimit(800)
+	require.Equal(t, uint64(800), meter.Limit())
+
+	require.NoError(t, meter.ConsumeGas(300))
+	require.Equal(t, uint64(300), meter.GasUsed())
+	require.Equal(t, uint64(500), meter.GasRemaining())
+	require.Equal(t, uint64(500), meter.CumulativeGasUsed())
+
+	require.Equal(t, ErrOutOfGas, meter.ConsumeGas(501))
+	require.Equal(t, uint64(800), meter.GasUsed())
+	require.Equal(t, uint64(0), meter.GasRemaining())
+	require.Equal(t, uint64(1000), meter.CumulativeGasUsed())
+}
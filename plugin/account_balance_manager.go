@@ -4,6 +4,7 @@ import (
 	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/go-loom/plugin"
 	contract "github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/loomnetwork/loomchain/builtin/plugins/coin"
 	"github.com/loomnetwork/loomchain/builtin/plugins/ethcoin"
 	"github.com/loomnetwork/loomchain/evm"
 )
@@ -56,3 +57,27 @@ func NewAccountBalanceManagerFactory(pvm *PluginVM) (evm.AccountBalanceManagerFa
 		return NewAccountBalanceManager(ctx)
 	}, nil
 }
+
+// CoinBalanceManager provides access to the balances held in the built-in coin contract, unlike
+// the AccountBalanceManager it doesn't allow balances to be modified directly, so it can't be used
+// by the EVM.
+type CoinBalanceManager struct {
+	// coin contract context
+	ctx  contract.Context
+	sctx contract.StaticContext
+}
+
+func NewCoinBalanceManager(ctx plugin.Context) *CoinBalanceManager {
+	return &CoinBalanceManager{
+		ctx:  contract.WrapPluginContext(ctx),
+		sctx: contract.WrapPluginStaticContext(ctx),
+	}
+}
+
+func (m *CoinBalanceManager) GetBalance(addr loom.Address) (*loom.BigUInt, error) {
+	return coin.BalanceOf(m.sctx, addr)
+}
+
+func (m *CoinBalanceManager) Transfer(from, to loom.Address, amount *loom.BigUInt) error {
+	return coin.Transfer(m.ctx, from, to, amount)
+}
@@ -0,0 +1,51 @@
+package plugin
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/contractpb"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/store"
+)
+
+// cachingStaticContext is a read-only Go contract context that reads the decoded contract state
+// through a StateCache.
+type cachingStaticContext struct {
+	contractpb.StaticContext
+	cache  *store.StateCache
+	height int64
+}
+
+// NewCachingStaticContext wraps the given contract context so that values read from the contract
+// state are looked up in the given cache before they're loaded from the store & decoded.
+// The context must only have access to state that was committed at the given height, i.e. it
+// should be created from a read-only snapshot of the app state, and the height must be the version
+// of the store the snapshot was taken from (rather than the height in the block header of the
+// snapshot), otherwise stale values may end up in the cache.
+func NewCachingStaticContext(
+	ctx contractpb.StaticContext, cache *store.StateCache, height int64,
+) contractpb.StaticContext {
+	return &cachingStaticContext{
+		StaticContext: ctx,
+		cache:         cache,
+		height:        height,
+	}
+}
+
+func (c *cachingStaticContext) Get(key []byte, pb proto.Message) error {
+	stateKey := util.PrefixKey(loom.DataPrefix(c.ContractAddress()), key)
+	if exists, ok := c.cache.Get(c.height, stateKey, pb); ok {
+		if !exists {
+			return contractpb.ErrNotFound
+		}
+		return nil
+	}
+
+	err := c.StaticContext.Get(key, pb)
+	if err == nil {
+		c.cache.Set(c.height, stateKey, pb, true)
+	} else if err == contractpb.ErrNotFound {
+		c.cache.Set(c.height, stateKey, pb, false)
+	}
+	return err
+}
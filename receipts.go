@@ -15,10 +15,16 @@ type ReadReceiptHandler interface {
 	GetPendingReceipt(txHash []byte) (types.EvmTxReceipt, error)
 	GetPendingTxHashList() [][]byte
 	GetCurrentReceipt() *types.EvmTxReceipt
+	// GetRevertData returns the data returned by a reverted EVM tx, or nil if the tx wasn't reverted.
+	GetRevertData(txHash []byte) ([]byte, error)
 }
 
 type ReceiptHandlerStore interface {
 	CommitBlock(height int64) error
+	// Rollback reverts the receipts of all the blocks above the given height.
+	Rollback(height int64) error
+	// LastCommittedHeight returns the height of the most recent block that had receipts committed.
+	LastCommittedHeight() int64
 	CommitCurrentReceipt()
 	DiscardCurrentReceipt()
 	ClearData() error
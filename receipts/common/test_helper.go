@@ -9,10 +9,10 @@ import (
 	"github.com/gogo/protobuf/proto"
 	"github.com/loomnetwork/go-loom/plugin/types"
 	"github.com/loomnetwork/loomchain"
+	cdb "github.com/loomnetwork/loomchain/db"
 	"github.com/loomnetwork/loomchain/store"
 	evmaux "github.com/loomnetwork/loomchain/store/evm_aux"
 	"github.com/stretchr/testify/require"
-	goleveldb "github.com/syndtr/goleveldb/leveldb"
 	abci "github.com/tendermint/tendermint/abci/types"
 )
 
@@ -72,7 +72,7 @@ func MockStateAt(state loomchain.State, newHeight uint64) loomchain.State {
 
 func NewMockEvmAuxStore() (*evmaux.EvmAuxStore, error) {
 	os.RemoveAll(evmaux.EvmAuxDBName)
-	evmAuxDB, err := goleveldb.OpenFile(evmaux.EvmAuxDBName, nil)
+	evmAuxDB, err := cdb.LoadLegacyGoLevelDB(evmaux.EvmAuxDBName, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"bytes"
+	"math"
 	"sync"
 
 	"github.com/loomnetwork/go-loom"
@@ -32,6 +33,14 @@ type ReceiptHandler struct {
 	receiptsCache  []*types.EvmTxReceipt
 	txHashList     [][]byte
 	currentReceipt *types.EvmTxReceipt
+	// data returned by reverted txs in the current block, keyed by tx hash
+	revertDataCache   map[string][]byte
+	currentRevertData []byte
+}
+
+// revertError is implemented by errors that carry the data returned by a reverted EVM call.
+type revertError interface {
+	RevertData() []byte
 }
 
 func NewReceiptHandler(
@@ -43,6 +52,7 @@ func NewReceiptHandler(
 		receiptsCache:   []*types.EvmTxReceipt{},
 		txHashList:      [][]byte{},
 		currentReceipt:  nil,
+		revertDataCache: map[string][]byte{},
 		mutex:           &sync.RWMutex{},
 		leveldbReceipts: leveldb.NewLevelDbReceipts(evmAuxStore, maxReceipts),
 		evmAuxStore:     evmAuxStore,
@@ -74,6 +84,17 @@ func (r *ReceiptHandler) GetReceipt(txHash []byte) (types.EvmTxReceipt, error) {
 	return receipt, nil
 }
 
+// GetRevertData looks up the data returned by a reverted EVM tx, the tx hash can either be the hash
+// of the Tendermint tx within which the EVM tx was embedded or, the hash of the embedded EVM tx itself.
+// Returns nil if the tx wasn't reverted, or didn't return any data.
+func (r *ReceiptHandler) GetRevertData(txHash []byte) ([]byte, error) {
+	evmTxHash, err := r.evmAuxStore.GetChildTxHash(txHash)
+	if len(evmTxHash) > 0 && err == nil {
+		txHash = evmTxHash
+	}
+	return r.leveldbReceipts.GetRevertData(txHash), nil
+}
+
 func (r *ReceiptHandler) GetPendingReceipt(txHash []byte) (types.EvmTxReceipt, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
@@ -121,7 +142,11 @@ func (r *ReceiptHandler) CommitCurrentReceipt() {
 	if r.currentReceipt != nil {
 		r.receiptsCache = append(r.receiptsCache, r.currentReceipt)
 		r.txHashList = append(r.txHashList, r.currentReceipt.TxHash)
+		if r.currentRevertData != nil {
+			r.revertDataCache[string(r.currentReceipt.TxHash)] = r.currentRevertData
+		}
 		r.currentReceipt = nil
+		r.currentRevertData = nil
 	}
 }
 
@@ -130,18 +155,38 @@ func (r *ReceiptHandler) DiscardCurrentReceipt() {
 	defer r.mutex.Unlock()
 
 	r.currentReceipt = nil
+	r.currentRevertData = nil
 }
 
 func (r *ReceiptHandler) CommitBlock(height int64) error {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
-	err := r.leveldbReceipts.CommitBlock(r.receiptsCache, uint64(height))
+	err := r.leveldbReceipts.CommitBlock(r.receiptsCache, r.revertDataCache, uint64(height))
 	r.txHashList = [][]byte{}
 	r.receiptsCache = []*types.EvmTxReceipt{}
+	r.revertDataCache = map[string][]byte{}
 	return err
 }
 
+// Rollback reverts the receipts of all the blocks above the given height, this is used to bring
+// the receipts DB back in line with app.db if the node crashed while committing a block.
+func (r *ReceiptHandler) Rollback(height int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.leveldbReceipts.Rollback(uint64(height))
+}
+
+// LastCommittedHeight returns the height of the most recent block that had receipts committed to
+// the receipts DB, or zero if there are no recent receipts.
+func (r *ReceiptHandler) LastCommittedHeight() int64 {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return int64(r.leveldbReceipts.LastCommittedHeight())
+}
+
 // TODO: this doesn't need the entire state passed in, just the block header
 func (r *ReceiptHandler) CacheReceipt(
 	state loomchain.State, caller, addr loom.Address, events []*types.EventData, txErr error, txHash []byte,
@@ -157,6 +202,7 @@ func (r *ReceiptHandler) CacheReceipt(
 			r.currentReceipt.Logs,
 			leveldb.CreateEventLogs(r.currentReceipt, state.Block(), events, r.eventHandler)...,
 		)
+		setReceiptGas(r.currentReceipt, state)
 		return r.currentReceipt.TxHash, nil
 	}
 
@@ -173,6 +219,30 @@ func (r *ReceiptHandler) CacheReceipt(
 	if err != nil {
 		return []byte{}, errors.Wrap(err, "receipt not written, returning empty hash")
 	}
+	// The gas used must be set after the receipt is written, since it's not included in the hash
+	// that's generated for the receipt when no tx hash is provided.
+	setReceiptGas(&receipt, state)
 	r.currentReceipt = &receipt
+	if revErr, ok := errors.Cause(txErr).(revertError); ok {
+		r.currentRevertData = revErr.RevertData()
+	}
 	return r.currentReceipt.TxHash, err
 }
+
+// setReceiptGas copies the gas used by the current tx, and the gas used by all the txs in the block
+// up to & including the current tx, from the gas meter of the current tx to the given receipt.
+func setReceiptGas(receipt *types.EvmTxReceipt, state loomchain.State) {
+	if gasMeter := loomchain.GasMeterFromContext(state.Context()); gasMeter != nil {
+		receipt.GasUsed = receiptGas(gasMeter.GasUsed())
+		receipt.CumulativeGasUsed = receiptGas(gasMeter.CumulativeGasUsed())
+	}
+}
+
+// receiptGas converts an amount of gas to the type used by the receipt, since the receipt fields are
+// only 32 bits wide larger amounts are capped.
+func receiptGas(gas uint64) int32 {
+	if gas > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int32(gas)
+}
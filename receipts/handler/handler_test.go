@@ -108,3 +108,25 @@ func TestReceiptsHandlerChain(t *testing.T) {
 	require.NoError(t, receiptHandler.Close())
 	require.NoError(t, receiptHandler.ClearData())
 }
+
+func TestReceiptGasUsed(t *testing.T) {
+	evmAuxStore, err := common.NewMockEvmAuxStore()
+	require.NoError(t, err)
+	handler := NewReceiptHandler(&loomchain.DefaultEventHandler{}, DefaultMaxReceipts, evmAuxStore)
+
+	state := common.MockStateTx(common.MockState(1), 1, 1)
+	gasMeter := loomchain.NewInfiniteGasMeter(1000)
+	state = state.WithContext(loomchain.WithGasMeter(state.Context(), gasMeter))
+	require.NoError(t, gasMeter.ConsumeGas(300))
+	_, err = handler.CacheReceipt(state, addr1, addr2, []*types.EventData{}, nil, []byte{})
+	require.NoError(t, err)
+	require.EqualValues(t, 300, handler.GetCurrentReceipt().GasUsed)
+	require.EqualValues(t, 1300, handler.GetCurrentReceipt().CumulativeGasUsed)
+
+	// the receipt should reflect the gas used by internal calls made after it was created
+	require.NoError(t, gasMeter.ConsumeGas(200))
+	_, err = handler.CacheReceipt(state, addr1, addr2, []*types.EventData{}, nil, []byte{})
+	require.NoError(t, err)
+	require.EqualValues(t, 500, handler.GetCurrentReceipt().GasUsed)
+	require.EqualValues(t, 1500, handler.GetCurrentReceipt().CumulativeGasUsed)
+}
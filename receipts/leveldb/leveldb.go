@@ -3,27 +3,46 @@ package leveldb
 import (
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
+	"math"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/go-loom/plugin/types"
 	loom_types "github.com/loomnetwork/go-loom/types"
 	"github.com/loomnetwork/loomchain"
+	cdb "github.com/loomnetwork/loomchain/db"
 	"github.com/loomnetwork/loomchain/eth/bloom"
 	"github.com/loomnetwork/loomchain/log"
 	"github.com/loomnetwork/loomchain/receipts/common"
 	evmaux "github.com/loomnetwork/loomchain/store/evm_aux"
 	"github.com/pkg/errors"
-	"github.com/syndtr/goleveldb/leveldb"
 )
 
 var (
 	headKey          = []byte("leveldb:head")
 	tailKey          = []byte("leveldb:tail")
 	currentDbSizeKey = []byte("leveldb:size")
+	undoKeyPrefix    = []byte("leveldb:undo:")
 )
 
+// Number of undo records to keep, this limits how many blocks can be rolled back.
+const maxUndoRecords = 100
+
+func undoKey(height uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, height)
+	return append(append([]byte{}, undoKeyPrefix...), b...)
+}
+
+// undoEntry stores the value a key had before a block was committed.
+type undoEntry struct {
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value"`
+	Exists bool   `json:"exists"`
+}
+
 func WriteReceipt(
 	block loom_types.BlockHeader,
 	caller, addr loom.Address,
@@ -86,19 +105,30 @@ func CreateEventLogs(
 }
 
 func (lr *LevelDbReceipts) GetReceipt(txHash []byte) (types.EvmTxReceipt, error) {
-	txReceiptProto, err := lr.evmAuxStore.DB().Get(txHash, nil)
-	if err != nil {
-		return types.EvmTxReceipt{}, errors.Wrapf(err, "get receipt for %s", string(txHash))
+	txReceiptProto := lr.evmAuxStore.DB().Get(txHash)
+	if txReceiptProto == nil {
+		return types.EvmTxReceipt{}, errors.Errorf("get receipt for %s: not found", string(txHash))
 	}
 	txReceipt := types.EvmTxReceiptListItem{}
-	err = proto.Unmarshal(txReceiptProto, &txReceipt)
-	return *txReceipt.Receipt, err
+	if err := proto.Unmarshal(txReceiptProto, &txReceipt); err != nil {
+		return types.EvmTxReceipt{}, err
+	}
+	if txReceipt.Receipt == nil {
+		return types.EvmTxReceipt{}, errors.Errorf("get receipt for %s: empty receipt", string(txHash))
+	}
+	return *txReceipt.Receipt, nil
+}
+
+// GetRevertData returns the data returned by the reverted tx with the given hash, or nil if the tx
+// wasn't reverted, or didn't return any data.
+func (lr *LevelDbReceipts) GetRevertData(txHash []byte) []byte {
+	return lr.evmAuxStore.GetRevertData(txHash)
 }
 
 type LevelDbReceipts struct {
 	MaxDbSize   uint64
 	evmAuxStore *evmaux.EvmAuxStore
-	tran        *leveldb.Transaction
+	tran        *cdb.ReadWriteBatch
 }
 
 func NewLevelDbReceipts(evmAuxStore *evmaux.EvmAuxStore, maxReceipts uint64) *LevelDbReceipts {
@@ -116,7 +146,11 @@ func (lr LevelDbReceipts) Close() error {
 	return nil
 }
 
-func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height uint64) error {
+// CommitBlock persists the receipts of the block at the given height, along with the data returned
+// by any reverted txs in the block (keyed by tx hash).
+func (lr *LevelDbReceipts) CommitBlock(
+	receipts []*types.EvmTxReceipt, revertData map[string][]byte, height uint64,
+) error {
 	if len(receipts) == 0 {
 		return nil
 	}
@@ -126,19 +160,14 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 		return errors.Wrap(err, "getting db params.")
 	}
 
-	db := lr.evmAuxStore.DB()
-
-	lr.tran, err = db.OpenTransaction()
-	if err != nil {
-		return errors.Wrap(err, "opening leveldb transaction")
-	}
+	lr.tran = cdb.NewReadWriteBatch(lr.evmAuxStore.DB())
 	defer lr.closeTransaction()
 
 	tailReceiptItem := types.EvmTxReceiptListItem{}
 	if len(headHash) > 0 {
-		tailItemProto, err := lr.tran.Get(tailHash, nil)
-		if err != nil {
-			return errors.Wrap(err, "cannot find tail")
+		tailItemProto := lr.tran.Get(tailHash)
+		if tailItemProto == nil {
+			return errors.New("cannot find tail")
 		}
 		if err = proto.Unmarshal(tailItemProto, &tailReceiptItem); err != nil {
 			return errors.Wrap(err, "unmarshalling tail")
@@ -162,15 +191,9 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 				log.Error(fmt.Sprintf("commit block receipts: marshal receipt item: %s", err.Error()))
 				continue
 			}
-			updating, err := lr.tran.Has(tailHash, nil)
-			if err != nil {
-				return errors.Wrap(err, "cannot find tail hash")
-			}
-
-			if err := lr.tran.Put(tailHash, protoTail, nil); err != nil {
-				log.Error(fmt.Sprintf("commit block receipts: put receipt in db: %s", err.Error()))
-				continue
-			} else if !updating {
+			updating := lr.tran.Has(tailHash)
+			lr.tran.Set(tailHash, protoTail)
+			if !updating {
 				size++
 			}
 		}
@@ -179,6 +202,11 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 		tailHash = txReceipt.TxHash
 		tailReceiptItem = types.EvmTxReceiptListItem{Receipt: txReceipt, NextTxHash: nil}
 
+		if data, ok := revertData[string(txReceipt.TxHash)]; ok {
+			lr.evmAuxStore.SetRevertData(lr.tran, txReceipt.TxHash, data)
+		}
+		lr.evmAuxStore.IndexLogs(lr.tran, txReceipt)
+
 		// only upload hashes to app db if transaction successful
 		if txReceipt.Status == common.StatusTxSuccess {
 			txHashArray = append(txHashArray, txReceipt.TxHash)
@@ -191,13 +219,9 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 		if err != nil {
 			log.Error(fmt.Sprintf("commit block receipts: marshal receipt item: %s", err.Error()))
 		} else {
-			updating, err := lr.tran.Has(tailHash, nil)
-			if err != nil {
-				return errors.Wrap(err, "cannot find tail hash")
-			}
-			if err := lr.tran.Put(tailHash, protoTail, nil); err != nil {
-				log.Error(fmt.Sprintf("commit block receipts: putting receipt in db: %s", err.Error()))
-			} else if !updating {
+			updating := lr.tran.Has(tailHash)
+			lr.tran.Set(tailHash, protoTail)
+			if !updating {
 				size++
 			}
 		}
@@ -205,7 +229,7 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 
 	if lr.MaxDbSize < size {
 		var numDeleted uint64
-		headHash, numDeleted, err = removeOldEntries(lr.tran, headHash, size-lr.MaxDbSize)
+		headHash, numDeleted, err = removeOldEntries(lr.tran, lr.evmAuxStore, headHash, size-lr.MaxDbSize)
 		if err != nil {
 			return errors.Wrap(err, "removing old receipts")
 		}
@@ -225,37 +249,113 @@ func (lr *LevelDbReceipts) CommitBlock(receipts []*types.EvmTxReceipt, height ui
 	if err := lr.evmAuxStore.SetBloomFilter(lr.tran, filter, height); err != nil {
 		return errors.Wrap(err, "set bloom filter")
 	}
-
-	if err := lr.tran.Commit(); err != nil {
-		return errors.Wrap(err, "committing level db transaction")
+	if err := lr.saveUndoRecord(height); err != nil {
+		return errors.Wrap(err, "saving receipts undo record")
 	}
+
+	lr.tran.Write()
 	lr.tran = nil
 	return nil
 }
 
+// saveUndoRecord adds a record of the previous values of all the keys that are about to be modified
+// by the current transaction to the transaction itself, so the block at the given height can be
+// rolled back later. Undo records of old blocks are deleted.
+func (lr *LevelDbReceipts) saveUndoRecord(height uint64) error {
+	db := lr.evmAuxStore.DB()
+	keys := lr.tran.PendingKeys()
+	entries := make([]undoEntry, 0, len(keys))
+	for _, key := range keys {
+		value := db.Get(key)
+		entries = append(entries, undoEntry{Key: key, Value: value, Exists: value != nil})
+	}
+	record, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	if height > maxUndoRecords {
+		iter := db.Iterator(undoKey(0), undoKey(height-maxUndoRecords+1))
+		for ; iter.Valid(); iter.Next() {
+			lr.tran.Delete(iter.Key())
+		}
+		iter.Close()
+	}
+	lr.tran.Set(undoKey(height), record)
+	return nil
+}
+
+// LastCommittedHeight returns the height of the most recent block that had receipts committed to
+// the DB, or zero if the height is unknown.
+func (lr *LevelDbReceipts) LastCommittedHeight() uint64 {
+	iter := lr.evmAuxStore.DB().ReverseIterator(undoKeyPrefix, undoKey(math.MaxUint64))
+	defer iter.Close()
+	if !iter.Valid() {
+		return 0
+	}
+	return binary.BigEndian.Uint64(iter.Key()[len(undoKeyPrefix):])
+}
+
+// Rollback reverts the receipts of all the blocks above the given height.
+func (lr *LevelDbReceipts) Rollback(height uint64) error {
+	lastHeight := lr.LastCommittedHeight()
+	if lastHeight > height && lastHeight-height >= maxUndoRecords {
+		return errors.Errorf(
+			"can't roll back receipts from height %d to %d, at most %d blocks can be rolled back",
+			lastHeight, height, maxUndoRecords-1,
+		)
+	}
+
+	db := lr.evmAuxStore.DB()
+	for ; lastHeight > height; lastHeight = lr.LastCommittedHeight() {
+		key := undoKey(lastHeight)
+		var entries []undoEntry
+		if err := json.Unmarshal(db.Get(key), &entries); err != nil {
+			return errors.Wrapf(err, "failed to decode receipts undo record at height %d", lastHeight)
+		}
+		batch := db.NewBatch()
+		for _, entry := range entries {
+			if entry.Exists {
+				batch.Set(entry.Key, entry.Value)
+			} else {
+				batch.Delete(entry.Key)
+			}
+		}
+		batch.Delete(key)
+		batch.WriteSync()
+	}
+	return nil
+}
+
 func (lr *LevelDbReceipts) ClearData() {
 	lr.evmAuxStore.ClearData()
 }
 
 func (lr *LevelDbReceipts) closeTransaction() {
 	if lr.tran != nil {
-		lr.tran.Discard()
+		lr.tran.Close()
 		lr.tran = nil
 	}
 }
 
-func removeOldEntries(tran *leveldb.Transaction, head []byte, number uint64) ([]byte, uint64, error) {
+func removeOldEntries(
+	tran *cdb.ReadWriteBatch, evmAuxStore *evmaux.EvmAuxStore, head []byte, number uint64,
+) ([]byte, uint64, error) {
 	itemsDeleted := uint64(0)
 	for i := uint64(0); i < number && len(head) > 0; i++ {
-		headItem, err := tran.Get(head, nil)
-		if err != nil {
-			return head, itemsDeleted, errors.Wrapf(err, "get head %s", string(head))
+		headItem := tran.Get(head)
+		if headItem == nil {
+			return head, itemsDeleted, errors.Errorf("get head %s: not found", string(head))
 		}
 		txHeadReceiptItem := types.EvmTxReceiptListItem{}
 		if err := proto.Unmarshal(headItem, &txHeadReceiptItem); err != nil {
 			return head, itemsDeleted, errors.Wrapf(err, "unmarshal head %s", string(headItem))
 		}
-		tran.Delete(head, nil)
+		tran.Delete(head)
+		evmAuxStore.DeleteRevertData(tran, head)
+		if txHeadReceiptItem.Receipt != nil {
+			evmAuxStore.UnindexLogs(tran, txHeadReceiptItem.Receipt)
+		}
 		itemsDeleted++
 		head = txHeadReceiptItem.NextTxHash
 	}
@@ -267,35 +367,24 @@ func removeOldEntries(tran *leveldb.Transaction, head []byte, number uint64) ([]
 }
 
 func getDBParams(db *evmaux.EvmAuxStore) (size uint64, head, tail []byte, err error) {
-	notEmpty, err := db.DB().Has(currentDbSizeKey, nil)
-	if err != nil {
-		return size, head, tail, err
-	}
-	if !notEmpty {
+	sizeB := db.DB().Get(currentDbSizeKey)
+	if sizeB == nil {
 		return 0, []byte{}, []byte{}, nil
 	}
-
-	sizeB, err := db.DB().Get(currentDbSizeKey, nil)
-	if err != nil {
-		return size, head, tail, err
+	if len(sizeB) != 8 {
+		return size, head, tail, errors.New("invalid receipt db size")
 	}
 	size = binary.LittleEndian.Uint64(sizeB)
 	if size == 0 {
 		return 0, []byte{}, []byte{}, nil
 	}
 
-	head, err = db.DB().Get(headKey, nil)
-	if err != nil {
-		return size, head, tail, err
-	}
+	head = db.DB().Get(headKey)
 	if len(head) == 0 {
 		return 0, []byte{}, []byte{}, errors.New("no head for non zero size receipt db")
 	}
 
-	tail, err = db.DB().Get(tailKey, nil)
-	if err != nil {
-		return size, head, tail, err
-	}
+	tail = db.DB().Get(tailKey)
 	if len(tail) == 0 {
 		return 0, []byte{}, []byte{}, errors.New("no tail for non zero size receipt db")
 	}
@@ -303,16 +392,11 @@ func getDBParams(db *evmaux.EvmAuxStore) (size uint64, head, tail []byte, err er
 	return size, head, tail, nil
 }
 
-func setDBParams(tr *leveldb.Transaction, size uint64, head, tail []byte) error {
-	if err := tr.Put(headKey, head, nil); err != nil {
-		return err
-	}
-
-	if err := tr.Put(tailKey, tail, nil); err != nil {
-		return err
-	}
-
+func setDBParams(tr *cdb.ReadWriteBatch, size uint64, head, tail []byte) error {
+	tr.Set(headKey, head)
+	tr.Set(tailKey, tail)
 	sizeB := make([]byte, 8)
 	binary.LittleEndian.PutUint64(sizeB, size)
-	return tr.Put(currentDbSizeKey, sizeB, nil)
+	tr.Set(currentDbSizeKey, sizeB)
+	return nil
 }
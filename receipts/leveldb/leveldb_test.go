@@ -28,7 +28,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	receipts1 := common.MakeDummyReceipts(t, 5, height)
 	commit := 1 // number of commits
 	// store 5 receipts
-	require.NoError(t, handler.CommitBlock(receipts1, height))
+	require.NoError(t, handler.CommitBlock(receipts1, nil, height))
 	confirmDbConsistency(t, handler, 5, receipts1[0].TxHash, receipts1[4].TxHash, receipts1, commit)
 	confirmStateConsistency(t, evmAuxStore, receipts1, height)
 	// db reaching max
@@ -36,7 +36,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	receipts2 := common.MakeDummyReceipts(t, 7, height)
 	commit = 2
 	// store another 7 receipts
-	require.NoError(t, handler.CommitBlock(receipts2, height))
+	require.NoError(t, handler.CommitBlock(receipts2, nil, height))
 	confirmDbConsistency(t, handler, maxSize, receipts1[2].TxHash, receipts2[6].TxHash, append(receipts1[2:5], receipts2...), commit)
 	confirmStateConsistency(t, evmAuxStore, receipts2, height)
 
@@ -45,7 +45,7 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	receipts3 := common.MakeDummyReceipts(t, 5, height)
 	commit = 3
 	// store another 5 receipts
-	require.NoError(t, handler.CommitBlock(receipts3, height))
+	require.NoError(t, handler.CommitBlock(receipts3, nil, height))
 	confirmDbConsistency(t, handler, maxSize, receipts2[2].TxHash, receipts3[4].TxHash, append(receipts2[2:7], receipts3...), commit)
 	confirmStateConsistency(t, evmAuxStore, receipts3, height)
 
@@ -58,6 +58,26 @@ func TestReceiptsCyclicDB(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestReceiptsRevertData(t *testing.T) {
+	evmAuxStore, err := common.NewMockEvmAuxStore()
+	require.NoError(t, err)
+
+	handler := NewLevelDbReceipts(evmAuxStore, 4)
+	receipts1 := common.MakeDummyReceipts(t, 3, 1)
+	revertData := map[string][]byte{string(receipts1[1].TxHash): []byte("reverted")}
+	require.NoError(t, handler.CommitBlock(receipts1, revertData, 1))
+	require.Nil(t, handler.GetRevertData(receipts1[0].TxHash))
+	require.Equal(t, []byte("reverted"), handler.GetRevertData(receipts1[1].TxHash))
+
+	// revert data should be removed along with the receipt
+	receipts2 := common.MakeDummyReceipts(t, 3, 2)
+	require.NoError(t, handler.CommitBlock(receipts2, nil, 2))
+	require.Nil(t, handler.GetRevertData(receipts1[1].TxHash))
+
+	require.NoError(t, handler.Close())
+	handler.ClearData()
+}
+
 func TestReceiptsCommitAllInOneBlock(t *testing.T) {
 	evmAuxStore, err := common.NewMockEvmAuxStore()
 	require.NoError(t, err)
@@ -69,7 +89,7 @@ func TestReceiptsCommitAllInOneBlock(t *testing.T) {
 	receipts1 := common.MakeDummyReceipts(t, maxSize+1, height)
 	commit := 1
 	// store 11 receipts, which is more than max that can be stored
-	require.NoError(t, handler.CommitBlock(receipts1, height))
+	require.NoError(t, handler.CommitBlock(receipts1, nil, height))
 
 	confirmDbConsistency(t, handler, maxSize, receipts1[1].TxHash, receipts1[10].TxHash, receipts1[1:], commit)
 	confirmStateConsistency(t, evmAuxStore, receipts1, height)
@@ -83,6 +103,40 @@ func TestReceiptsCommitAllInOneBlock(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestReceiptsRollback(t *testing.T) {
+	evmAuxStore, err := common.NewMockEvmAuxStore()
+	require.NoError(t, err)
+
+	maxSize := uint64(10)
+	handler := NewLevelDbReceipts(evmAuxStore, maxSize)
+	receipts1 := common.MakeDummyReceipts(t, 5, 1)
+	require.NoError(t, handler.CommitBlock(receipts1, nil, 1))
+	require.EqualValues(t, 1, handler.LastCommittedHeight())
+
+	// store another 7 receipts, which evicts some of the receipts from the first block
+	receipts2 := common.MakeDummyReceipts(t, 7, 2)
+	require.NoError(t, handler.CommitBlock(receipts2, nil, 2))
+	require.EqualValues(t, 2, handler.LastCommittedHeight())
+
+	// rolling back to the current height should have no effect
+	require.NoError(t, handler.Rollback(2))
+	confirmDbConsistency(t, handler, maxSize, receipts1[2].TxHash, receipts2[6].TxHash, append(receipts1[2:5], receipts2...), 2)
+
+	require.NoError(t, handler.Rollback(1))
+	require.EqualValues(t, 1, handler.LastCommittedHeight())
+	confirmDbConsistency(t, handler, 5, receipts1[0].TxHash, receipts1[4].TxHash, receipts1, 1)
+	confirmStateConsistency(t, evmAuxStore, receipts1, 1)
+	require.Nil(t, evmAuxStore.GetBloomFilter(2))
+
+	// the block should commit cleanly after being rolled back
+	require.NoError(t, handler.CommitBlock(receipts2, nil, 2))
+	confirmDbConsistency(t, handler, maxSize, receipts1[2].TxHash, receipts2[6].TxHash, append(receipts1[2:5], receipts2...), 2)
+	confirmStateConsistency(t, evmAuxStore, receipts2, 2)
+
+	require.NoError(t, handler.Close())
+	handler.ClearData()
+}
+
 func confirmDbConsistency(t *testing.T, handler *LevelDbReceipts,
 	size uint64, head, tail []byte, receipts []*types.EvmTxReceipt, commit int) {
 	var err error
@@ -109,7 +163,8 @@ func confirmDbConsistency(t *testing.T, handler *LevelDbReceipts,
 		require.EqualValues(t, receipts[i].BlockNumber, getDBReceipt.BlockNumber)
 		require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, getDBReceipt.TxHash))
 	}
-	metadataCount := uint64(commit * 2)
+	// tx hash list, bloom filter & undo record of each block
+	metadataCount := uint64(commit * 3)
 
 	dbActualSize, err := countDbEntries(handler.evmAuxStore)
 	require.NoError(t, err)
@@ -123,8 +178,8 @@ func confirmDbConsistency(t *testing.T, handler *LevelDbReceipts,
 		if previous.Receipt != nil {
 			require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, previous.NextTxHash))
 		}
-		txReceiptItemProto, err := handler.evmAuxStore.DB().Get(receipts[i].TxHash, nil)
-		require.NoError(t, err)
+		txReceiptItemProto := handler.evmAuxStore.DB().Get(receipts[i].TxHash)
+		require.NotNil(t, txReceiptItemProto)
 		require.NoError(t, proto.Unmarshal(txReceiptItemProto, &previous))
 		require.EqualValues(t, 0, bytes.Compare(receipts[i].TxHash, previous.Receipt.TxHash))
 	}
@@ -146,7 +201,7 @@ func TestConfirmTransactionReceipts(t *testing.T) {
 	height := uint64(1)
 	receipts1 := common.MakeDummyReceipts(t, 5, height)
 	// store 5 receipts
-	require.NoError(t, handler.CommitBlock(receipts1, height))
+	require.NoError(t, handler.CommitBlock(receipts1, nil, height))
 	txHashes, err := evmAuxStore.GetTxHashList(height)
 	require.NoError(t, err)
 	a := []byte("0xf0675dc27bC62b584Ab2E8E1D483a55CFac9E960")
@@ -177,22 +232,22 @@ func TestConfirmTransactionReceipts(t *testing.T) {
 func dumpDbEntries(evmAuxStore *evmaux.EvmAuxStore) error {
 	fmt.Println("\nDumping leveldb")
 	db := evmAuxStore.DB()
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-	for iter.Next() {
+	iter := db.Iterator(nil, nil)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
 		fmt.Printf("key %s\t\tvalue %s", iter.Key(), iter.Value())
 	}
 	fmt.Println()
-	return iter.Error()
+	return nil
 }
 
 func countDbEntries(evmAuxStore *evmaux.EvmAuxStore) (uint64, error) {
 	count := uint64(0)
 	db := evmAuxStore.DB()
-	iter := db.NewIterator(nil, nil)
-	defer iter.Release()
-	for iter.Next() {
+	iter := db.Iterator(nil, nil)
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
 		count++
 	}
-	return count, iter.Error()
+	return count, nil
 }
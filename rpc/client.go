@@ -8,10 +8,12 @@ import (
 	"encoding/json"
 	"io"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 
+	"github.com/loomnetwork/loomchain/eth/subs"
 	"github.com/loomnetwork/loomchain/log"
 	"github.com/loomnetwork/loomchain/rpc/eth"
 )
@@ -49,6 +51,80 @@ type Client struct {
 
 	// Buffered channel of outbound messages.
 	send chan []byte
+
+	// Limits the rate at which the client can call methods, nil if rate limiting is disabled.
+	limiter *eth.RateLimiter
+	// Identifies the client to the rate limiter.
+	clientID string
+
+	// Held while a message from the client is handled & the response is queued, and while a
+	// subscription event is queued, so events for a new subscription are never sent before the
+	// response with the subscription ID.
+	sendMutex sync.Mutex
+	// Closed when the client disconnects.
+	disconnected chan struct{}
+}
+
+// wsClients maps websocket connections to their clients, so RPC functions that are passed a
+// connection can send messages to it via its client.
+var wsClients sync.Map
+
+func newClient(hub *Hub, conn *websocket.Conn, limiter *eth.RateLimiter, clientID string) *Client {
+	client := &Client{
+		hub:          hub,
+		conn:         conn,
+		send:         make(chan []byte, 256),
+		limiter:      limiter,
+		clientID:     clientID,
+		disconnected: make(chan struct{}),
+	}
+	wsClients.Store(conn, client)
+	return client
+}
+
+// Send queues a subscription event to be sent to the client, returns false if the client has
+// disconnected.
+func (c *Client) Send(msg []byte) bool {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	select {
+	case <-c.disconnected:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	case <-c.disconnected:
+		return false
+	}
+}
+
+// Close disconnects the client.
+func (c *Client) Close() {
+	_ = c.conn.Close()
+}
+
+// connSender sends subscription events directly to a websocket connection that isn't managed by
+// a Client.
+type connSender struct {
+	conn *websocket.Conn
+}
+
+func (s connSender) Send(msg []byte) bool {
+	return s.conn.WriteMessage(websocket.TextMessage, msg) == nil
+}
+
+func (s connSender) Close() {
+	_ = s.conn.Close()
+}
+
+// eventSender returns the sender subscription events should be sent to the given connection with.
+func eventSender(conn *websocket.Conn) subs.EventSender {
+	if client, ok := wsClients.Load(conn); ok {
+		return client.(*Client)
+	}
+	return connSender{conn: conn}
 }
 
 // readPump pumps messages from the websocket connection.
@@ -61,6 +137,8 @@ func (c *Client) readPump(funcMap map[string]eth.RPCFunc, logger log.TMLogger) {
 		if r := recover(); r != nil {
 			logger.Error("WebSocket read panicked", "err", r)
 		}
+		close(c.disconnected)
+		wsClients.Delete(c.conn)
 		c.hub.unregister <- c
 		if err := c.conn.Close(); err != nil {
 			logger.Error("Failed to close WebSocket (read pump)", "err", err)
@@ -85,22 +163,30 @@ func (c *Client) readPump(funcMap map[string]eth.RPCFunc, logger log.TMLogger) {
 			return
 		}
 
-		outBytes, ethError := handleMessage(message, funcMap, c.conn)
+		c.handleMessage(message, funcMap, logger)
+	}
+}
 
-		if ethError != nil {
-			logger.Error("Failed to handle WebSocket message (read pump)", "err", ethError.Error())
-			resp := eth.JsonRpcErrorResponse{
-				Version: "2.0",
-				Error:   *ethError,
-			}
-			outBytes, err = json.MarshalIndent(resp, "", "  ")
-			if err != nil {
-				continue
-			}
-		}
+func (c *Client) handleMessage(message []byte, funcMap map[string]eth.RPCFunc, logger log.TMLogger) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+
+	outBytes, ethError := handleMessage(message, funcMap, c.conn, c.limiter, c.clientID)
 
-		c.send <- outBytes
+	if ethError != nil {
+		logger.Error("Failed to handle WebSocket message (read pump)", "err", ethError.Error())
+		resp := eth.JsonRpcErrorResponse{
+			Version: "2.0",
+			Error:   *ethError,
+		}
+		var err error
+		outBytes, err = json.MarshalIndent(resp, "", "  ")
+		if err != nil {
+			return
+		}
 	}
+
+	c.send <- outBytes
 }
 
 // writePump pumps messages to the websocket connection.
@@ -0,0 +1,312 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	"github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/auth"
+	"github.com/loomnetwork/loomchain/eth/query"
+	levm "github.com/loomnetwork/loomchain/evm"
+	"github.com/loomnetwork/loomchain/features"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/pkg/errors"
+	abci "github.com/tendermint/tendermint/abci/types"
+)
+
+// DebugTraceTransaction replays the EVM tx with the given hash, and traces its execution.
+// The tx is executed on top of the state left behind by the previous block and the EVM txs that
+// precede it in the same block. Only EVM txs can be replayed, so an error is returned if the tx is
+// preceded by txs whose state changes can't be reproduced, i.e. txs that call Go contracts, or any
+// txs that paid tx fees.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#debug_tracetransaction
+func (s *QueryServer) DebugTraceTransaction(hash eth.Data, config *eth.JsonTraceConfig) (interface{}, error) {
+	txHash, err := eth.DecDataToBytes(hash)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decTraceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var height, txIndex int64
+	if receipt, err := s.ReceiptHandlerProvider.Reader().GetReceipt(txHash); err == nil {
+		height = receipt.BlockNumber
+		txIndex = int64(receipt.TransactionIndex)
+	} else {
+		// the hash might be a Tendermint tx hash
+		txResult, err := s.BlockStore.GetTxResult(txHash)
+		if err != nil || txResult == nil {
+			return nil, errors.Errorf("failed to find tx with hash %v", hash)
+		}
+		height = txResult.Height
+		txIndex = int64(txResult.Index)
+	}
+
+	traces, err := s.traceBlock(height, txIndex, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(traces) == 0 {
+		return nil, errors.Errorf("tx %v isn't an EVM tx", hash)
+	}
+	if len(traces[0].Error) > 0 {
+		return nil, errors.New(traces[0].Error)
+	}
+	return traces[0].Result, nil
+}
+
+// DebugTraceBlockByNumber replays all the EVM txs in the block at the given height, and traces
+// their execution. Only EVM txs can be replayed, so an error is returned if any EVM tx in the block
+// is preceded by txs whose state changes can't be reproduced, i.e. txs that call Go contracts, or
+// any txs that paid tx fees.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#debug_traceblockbynumber
+func (s *QueryServer) DebugTraceBlockByNumber(
+	block eth.BlockHeight, config *eth.JsonTraceConfig,
+) ([]eth.JsonTxTrace, error) {
+	cfg, err := decTraceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	lastHeight := snapshot.Block().Height
+	snapshot.Release()
+
+	height, err := eth.DecBlockHeight(lastHeight, block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid block height %s", block)
+	}
+	if int64(height) > lastHeight {
+		return nil, errors.Errorf("block %d hasn't been committed yet", height)
+	}
+	return s.traceBlock(int64(height), -1, cfg)
+}
+
+// DebugTraceCall executes the given call (or contract deployment if no "to" address is specified)
+// on top of the state at the given block height, and traces its execution. None of the changes
+// made by the call are persisted.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#debug_tracecall
+func (s *QueryServer) DebugTraceCall(
+	query eth.JsonTxCallObject, block eth.BlockHeight, config *eth.JsonTraceConfig,
+) (interface{}, error) {
+	cfg, err := decTraceConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := s.getStateAt(block)
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Release()
+
+	tx, err := s.decodeTxCallObject(snapshot, query)
+	if err != nil {
+		return nil, err
+	}
+	var gas uint64
+	if len(query.Gas) > 0 {
+		gas, err = eth.DecQuantityToUint(query.Gas)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid gas %v", query.Gas)
+		}
+	}
+
+	replayer := levm.NewTxReplayer(loomchain.NewCachedState(snapshot), s.createABMFactory, s.Web3Cfg.EstimateGasCap)
+	trace, err := replayer.Trace(tx.caller, tx.to, tx.input, tx.value, gas, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return encExecutionTrace(trace), nil
+}
+
+// traceBlock replays the EVM txs in the block at the given height on top of the state left behind
+// by the previous block, and traces their execution. If txIndex is non-negative only the tx at that
+// index in the block is traced, otherwise all the EVM txs in the block are traced. An error is
+// returned if the state changes made by the txs that precede a traced tx can't be reproduced.
+func (s *QueryServer) traceBlock(height, txIndex int64, cfg *levm.TraceConfig) ([]eth.JsonTxTrace, error) {
+	if height < 2 {
+		return nil, errors.Errorf("txs in block %d can't be traced", height)
+	}
+	blockResult, err := s.BlockStore.GetBlockByHeight(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load block %d", height)
+	}
+	blockResults, err := s.BlockStore.GetBlockResults(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load results of block %d", height)
+	}
+	txs := blockResult.Block.Data.Txs
+	if txIndex >= int64(len(txs)) {
+		return nil, errors.Errorf("tx index %d out of bounds for txs in block %d", txIndex, height)
+	}
+
+	header := blockResult.Block.Header
+	snapshot, err := s.StateProvider.ReadOnlyStateAt(abci.Header{
+		ChainID: header.ChainID,
+		Height:  height - 1,
+		Time:    header.Time,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state at height %d", height-1)
+	}
+	defer snapshot.Release()
+
+	state := loomchain.NewCachedStateAt(snapshot, types.BlockHeader{
+		ChainID: header.ChainID,
+		Height:  height,
+		Time:    header.Time.Unix(),
+	})
+	replayer := levm.NewTxReplayer(state, s.createABMFactory, s.Web3Cfg.EstimateGasCap)
+	// Every tx is charged a fee, even if it fails, and the fees modify balances the EVM can access,
+	// but the replayer doesn't charge any fees.
+	feesCharged := state.FeatureEnabled(features.TxFeeFeature, false) && state.OnChainSettings().TxFeeGasPrice > 0
+	// Hash of the first tx that modified the state, but couldn't be replayed.
+	var skippedTxHash []byte
+
+	var traces []eth.JsonTxTrace
+	for i, tx := range txs {
+		if txIndex >= 0 && int64(i) > txIndex {
+			break
+		}
+		// Unless fees are charged txs that failed didn't modify the state, so there's no need to
+		// replay them, unless they need to be traced.
+		var txResult *abci.ResponseDeliverTx
+		if i < len(blockResults.Results.DeliverTx) {
+			txResult = blockResults.Results.DeliverTx[i]
+		}
+		failed := txResult == nil || txResult.Code != abci.CodeTypeOK
+		traced := txIndex < 0 || int64(i) == txIndex
+		if failed && !traced {
+			continue
+		}
+
+		evmTx, err := decodeEvmTx(tx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode tx, hash %X", tx.Hash())
+		}
+		if evmTx == nil {
+			if !failed && skippedTxHash == nil {
+				skippedTxHash = tx.Hash()
+			}
+			continue
+		}
+		if skippedTxHash != nil {
+			return nil, errors.Errorf(
+				"EVM txs in block %d can't be replayed, they depend on non-EVM tx %X", height, skippedTxHash,
+			)
+		}
+		if feesCharged && i > 0 {
+			return nil, errors.Errorf(
+				"EVM txs in block %d can't be replayed, they depend on the tx fees paid by the preceding txs", height,
+			)
+		}
+		caller, err := auth.ResolveAccountAddress(evmTx.caller, state, s.AuthCfg, s.createAddressMapperCtx)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve caller of tx, hash %X", tx.Hash())
+		}
+
+		if !traced {
+			if err := replayer.Apply(caller, evmTx.to, evmTx.input, evmTx.value); err != nil {
+				return nil, errors.Wrapf(err, "failed to replay tx, hash %X", tx.Hash())
+			}
+			continue
+		}
+
+		txTrace := eth.JsonTxTrace{
+			TxHash: eth.EncBytes(tx.Hash()),
+		}
+		if txResult != nil {
+			txObj, _, err := query.GetTxObjectFromBlockResult(blockResult, txResult.Data, int64(i), s.EvmAuxStore)
+			if err == nil {
+				txTrace.TxHash = txObj.Hash
+			}
+		}
+		txReplayer := replayer
+		if failed {
+			// The tx didn't modify the state when it was originally executed, so whatever changes it
+			// makes now mustn't be visible to the txs after it.
+			txReplayer = levm.NewTxReplayer(loomchain.NewCachedState(state), s.createABMFactory, s.Web3Cfg.EstimateGasCap)
+		}
+		trace, err := txReplayer.Trace(caller, evmTx.to, evmTx.input, evmTx.value, 0, cfg)
+		if err != nil {
+			txTrace.Error = err.Error()
+		} else {
+			txTrace.Result = encExecutionTrace(trace)
+		}
+		traces = append(traces, txTrace)
+	}
+	return traces, nil
+}
+
+func decTraceConfig(config *eth.JsonTraceConfig) (*levm.TraceConfig, error) {
+	cfg := &levm.TraceConfig{}
+	if config == nil {
+		return cfg, nil
+	}
+	cfg.Tracer = config.Tracer
+	cfg.DisableStorage = config.DisableStorage
+	cfg.DisableMemory = config.DisableMemory
+	cfg.DisableStack = config.DisableStack
+	cfg.Limit = config.Limit
+	if len(config.Timeout) > 0 {
+		timeout, err := time.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid timeout %s", config.Timeout)
+		}
+		cfg.Timeout = timeout
+	}
+	return cfg, nil
+}
+
+// encExecutionTrace converts the given trace to the same format Geth returns traces in.
+func encExecutionTrace(trace *levm.ExecutionTrace) interface{} {
+	if trace.TracerResult != nil {
+		return trace.TracerResult
+	}
+	structLogs := make([]eth.JsonStructLog, 0, len(trace.StructLogs))
+	for _, log := range trace.StructLogs {
+		entry := eth.JsonStructLog{
+			Pc:      log.PC,
+			Op:      log.Op,
+			Gas:     log.Gas,
+			GasCost: log.GasCost,
+			Depth:   log.Depth,
+			Error:   log.Error,
+		}
+		if log.Stack != nil {
+			entry.Stack = make([]string, 0, len(log.Stack))
+			for _, value := range log.Stack {
+				entry.Stack = append(entry.Stack, hex.EncodeToString(math.PaddedBigBytes(value, 32)))
+			}
+		}
+		if log.Memory != nil {
+			entry.Memory = make([]string, 0, (len(log.Memory)+31)/32)
+			for i := 0; i < len(log.Memory); i += 32 {
+				end := i + 32
+				if end > len(log.Memory) {
+					end = len(log.Memory)
+				}
+				entry.Memory = append(entry.Memory, fmt.Sprintf("%x", log.Memory[i:end]))
+			}
+		}
+		if log.Storage != nil {
+			entry.Storage = make(map[string]string, len(log.Storage))
+			for key, value := range log.Storage {
+				entry.Storage[hex.EncodeToString(key[:])] = hex.EncodeToString(value[:])
+			}
+		}
+		structLogs = append(structLogs, entry)
+	}
+	return &eth.JsonExecutionTrace{
+		Gas:         trace.Gas,
+		Failed:      trace.Failed,
+		ReturnValue: hex.EncodeToString(trace.ReturnValue),
+		StructLogs:  structLogs,
+	}
+}
@@ -5,10 +5,145 @@ package eth
 type Web3Config struct {
 	// GetLogsMaxBlockRange specifies the maximum number of blocks eth_getLogs will query per request
 	GetLogsMaxBlockRange uint64
+	// GetLogsMaxResults specifies the maximum number of logs eth_getLogs will return per request,
+	// zero means no limit
+	GetLogsMaxResults uint64
+	// EstimateGasCap specifies the maximum amount of gas eth_estimateGas will execute a call with,
+	// zero means the call can use up to the EVM gas limit
+	EstimateGasCap uint64
+	// GasPrice specifies the gas price (in wei) returned by eth_gasPrice, if gas price sampling is
+	// enabled this is the lowest price eth_gasPrice will return
+	GasPrice uint64
+	// GasPriceBlocks specifies the number of recent blocks eth_gasPrice will sample the gas prices
+	// of Ethereum txs from, zero disables sampling
+	GasPriceBlocks uint64
+	// GasPricePercentile specifies which percentile of the sampled gas prices eth_gasPrice returns
+	GasPricePercentile uint64
+	// DebugAPIEnabled exposes the debug_trace* methods, these replay EVM txs so they're expensive
+	// to serve and shouldn't be enabled on public nodes
+	DebugAPIEnabled bool
+	// GraphQLEnabled exposes the EIP-1767 GraphQL endpoint at /graphql
+	GraphQLEnabled bool
+	// SubscriptionQueueSize specifies the max number of events that can be waiting to be sent to
+	// an eth_subscribe subscriber
+	SubscriptionQueueSize int
+	// SubscriptionOverflowPolicy specifies what happens when the queue of a subscriber is full,
+	// "drop" discards new events, "close" disconnects the client so it can resume the subscription
+	SubscriptionOverflowPolicy string
+	// SubscriptionMaxReplayBlocks specifies the max number of blocks that can be replayed when a
+	// client resumes a subscription
+	SubscriptionMaxReplayBlocks uint64
 }
 
 func DefaultWeb3Config() *Web3Config {
 	return &Web3Config{
 		GetLogsMaxBlockRange: 20,
+		GetLogsMaxResults:    10000,
+		EstimateGasCap:       50000000,
+		GasPrice:             0,
+		GasPriceBlocks:       20,
+		GasPricePercentile:   60,
+		DebugAPIEnabled:      false,
+		GraphQLEnabled:       false,
+
+		SubscriptionQueueSize:       1000,
+		SubscriptionOverflowPolicy:  "drop",
+		SubscriptionMaxReplayBlocks: 100,
+	}
+}
+
+// RateLimit specifies a token bucket, tokens are added to the bucket at a fixed rate up to the
+// capacity of the bucket, each request takes a token, and requests are rejected while the bucket
+// is empty.
+type RateLimit struct {
+	// Rate specifies the number of tokens added to the bucket per second, i.e. the number of
+	// requests per second that can be sustained
+	Rate float64
+	// Burst specifies the capacity of the bucket, i.e. the number of requests that can be made
+	// in quick succession
+	Burst int64
+}
+
+// MethodRateLimit specifies the rate limit for a JSON-RPC method.
+type MethodRateLimit struct {
+	Method string
+	Rate   float64
+	Burst  int64
+}
+
+// APIKeyRateLimit specifies the rate limit for clients that provide an API key.
+type APIKeyRateLimit struct {
+	Key   string
+	Rate  float64
+	Burst int64
+}
+
+// RateLimiterConfig contains settings that control the rate at which clients can call the JSON-RPC
+// methods exposed via the /eth & /query endpoints.
+type RateLimiterConfig struct {
+	Enabled bool
+	// APIKeyHeader specifies the HTTP header clients should send their API key in
+	APIKeyHeader string
+	// ClientIPHeader specifies the HTTP header the client IP should be read from, e.g. X-Real-IP,
+	// this should only be set if the node is behind a proxy that sets the header, otherwise the IP
+	// the request was received from is used
+	ClientIPHeader string
+	// DefaultLimit specifies the limit applied per client IP to each method that doesn't have its
+	// own limit in MethodLimits, nil means no limit
+	DefaultLimit *RateLimit
+	// MethodLimits specifies the limits applied per client IP to specific methods
+	MethodLimits []*MethodRateLimit
+	// APIKeys specifies the limit applied to each method called by clients that provide an API key,
+	// these clients are limited per API key rather than per IP
+	APIKeys []*APIKeyRateLimit
+	// DeniedMethods lists the methods that can't be called by any client
+	DeniedMethods []string
+}
+
+func DefaultRateLimiterConfig() *RateLimiterConfig {
+	return &RateLimiterConfig{
+		Enabled:      false,
+		APIKeyHeader: "X-Api-Key",
+		DefaultLimit: &RateLimit{
+			Rate:  20,
+			Burst: 100,
+		},
+		MethodLimits: []*MethodRateLimit{
+			{
+				Method: "eth_getLogs",
+				Rate:   2,
+				Burst:  10,
+			},
+		},
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *RateLimiterConfig) Clone() *RateLimiterConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	if c.DefaultLimit != nil {
+		defaultLimit := *c.DefaultLimit
+		clone.DefaultLimit = &defaultLimit
+	}
+	if c.MethodLimits != nil {
+		clone.MethodLimits = make([]*MethodRateLimit, 0, len(c.MethodLimits))
+		for _, limit := range c.MethodLimits {
+			limitClone := *limit
+			clone.MethodLimits = append(clone.MethodLimits, &limitClone)
+		}
+	}
+	if c.APIKeys != nil {
+		clone.APIKeys = make([]*APIKeyRateLimit, 0, len(c.APIKeys))
+		for _, limit := range c.APIKeys {
+			limitClone := *limit
+			clone.APIKeys = append(clone.APIKeys, &limitClone)
+		}
+	}
+	if c.DeniedMethods != nil {
+		clone.DeniedMethods = append([]string{}, c.DeniedMethods...)
 	}
+	return &clone
 }
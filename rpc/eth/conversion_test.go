@@ -48,4 +48,19 @@ func TestEncBigInt(t *testing.T) {
 	var bigLots big.Int
 	bigLots.Mul(bigMaxInt, bigMaxInt)
 	require.Equal(t, Quantity("0x3fffffffffffffff0000000000000001"), EncBigInt(bigLots))
-}
\ No newline at end of file
+}
+
+func TestDecQuantityToBigInt(t *testing.T) {
+	val, err := DecQuantityToBigInt("0x3fffffffffffffff0000000000000001")
+	require.NoError(t, err)
+	require.Equal(t, Quantity("0x3fffffffffffffff0000000000000001"), EncBigInt(*val))
+
+	val, err = DecQuantityToBigInt("0x0")
+	require.NoError(t, err)
+	require.Equal(t, 0, val.Sign())
+
+	_, err = DecQuantityToBigInt("25")
+	require.Error(t, err)
+	_, err = DecQuantityToBigInt("0xnonsense")
+	require.Error(t, err)
+}
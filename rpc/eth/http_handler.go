@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
 )
 
 type HttpRPCFunc struct {
@@ -82,6 +83,13 @@ func (m *HttpRPCFunc) call(inValues []reflect.Value) (resp json.RawMessage, json
 	outValues := m.method.Call(inValues)
 
 	if outValues[1].Interface() != nil {
+		if dataErr, ok := errors.Cause(outValues[1].Interface().(error)).(DataError); ok {
+			return resp, &Error{
+				Code:    dataErr.ErrorCode(),
+				Message: dataErr.Error(),
+				Data:    dataErr.ErrorData(),
+			}
+		}
 		return resp, NewError(EcServer, fmt.Sprintf("loom error: %v", outValues[1].Interface()), "")
 	}
 
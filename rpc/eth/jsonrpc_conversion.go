@@ -59,6 +59,19 @@ type JsonTxReceipt struct {
 	Logs              []JsonLog `json:"logs"`
 	LogsBloom         Data      `json:"logsBloom,omitempty"`
 	Status            Quantity  `json:"status,omitempty"`
+	// ABI-encoded data returned by a reverted tx, usually an Error(string) call
+	RevertReason Data `json:"revertReason,omitempty"`
+}
+
+// JsonTxPoolContent lists the txs in the mempool grouped by sender address & nonce.
+type JsonTxPoolContent struct {
+	Pending map[Data]map[string]JsonTxObject `json:"pending"`
+	Queued  map[Data]map[string]JsonTxObject `json:"queued"`
+}
+
+type JsonTxPoolStatus struct {
+	Pending Quantity `json:"pending"`
+	Queued  Quantity `json:"queued"`
 }
 
 type JsonTxObject struct {
@@ -115,6 +128,70 @@ type JsonFilter struct {
 	BlockHash Data          `json:"blockhash,omitempty"`
 }
 
+// JsonSubscribeOptions contains the optional settings that can be passed to eth_subscribe.
+type JsonSubscribeOptions struct {
+	// Resume token of the last event the client received on a previous subscription, the events
+	// emitted after that one will be replayed before any new events.
+	ResumeToken string `json:"resumeToken,omitempty"`
+}
+
+// https://github.com/ethereum/EIPs/blob/master/EIPS/eip-1186.md
+type JsonAccountProof struct {
+	Address      Data               `json:"address"`
+	AccountProof []Data             `json:"accountProof"`
+	Balance      Quantity           `json:"balance"`
+	CodeHash     Data               `json:"codeHash"`
+	Nonce        Quantity           `json:"nonce"`
+	StorageHash  Data               `json:"storageHash"`
+	StorageProof []JsonStorageProof `json:"storageProof"`
+}
+
+type JsonStorageProof struct {
+	Key   Data     `json:"key"`
+	Value Quantity `json:"value"`
+	Proof []Data   `json:"proof"`
+}
+
+// JsonTraceConfig specifies how the debug_trace* methods should trace the execution of EVM txs.
+type JsonTraceConfig struct {
+	DisableStorage bool `json:"disableStorage,omitempty"`
+	DisableMemory  bool `json:"disableMemory,omitempty"`
+	DisableStack   bool `json:"disableStack,omitempty"`
+	Limit          int  `json:"limit,omitempty"`
+	// Name or JavaScript source of the tracer to use instead of the struct logger.
+	Tracer string `json:"tracer,omitempty"`
+	// Time limit for JavaScript tracers, e.g. "10s"
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// JsonExecutionTrace is the result of tracing an EVM tx with the struct logger.
+type JsonExecutionTrace struct {
+	Gas         uint64          `json:"gas"`
+	Failed      bool            `json:"failed"`
+	ReturnValue string          `json:"returnValue"`
+	StructLogs  []JsonStructLog `json:"structLogs"`
+}
+
+// JsonStructLog is the state of the EVM right before an opcode was executed.
+type JsonStructLog struct {
+	Pc      uint64            `json:"pc"`
+	Op      string            `json:"op"`
+	Gas     uint64            `json:"gas"`
+	GasCost uint64            `json:"gasCost"`
+	Depth   int               `json:"depth"`
+	Error   string            `json:"error,omitempty"`
+	Stack   []string          `json:"stack,omitempty"`
+	Memory  []string          `json:"memory,omitempty"`
+	Storage map[string]string `json:"storage,omitempty"`
+}
+
+// JsonTxTrace is the trace of a single tx in a block, the result is nil if the tx couldn't be traced.
+type JsonTxTrace struct {
+	TxHash Data        `json:"txHash"`
+	Result interface{} `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
 func EncTxReceipt(receipt types.EvmTxReceipt) JsonTxReceipt {
 	return JsonTxReceipt{
 		TransactionIndex:  EncInt(int64(receipt.TransactionIndex)),
@@ -400,6 +477,17 @@ func DecQuantityToUint(value Quantity) (uint64, error) {
 	return strconv.ParseUint(string(value), 0, 64)
 }
 
+func DecQuantityToBigInt(value Quantity) (*big.Int, error) {
+	if len(value) <= 2 || value[0:2] != "0x" {
+		return nil, errors.Errorf("invalid quantity format: %v", value)
+	}
+	result, ok := new(big.Int).SetString(string(value[2:]), 16)
+	if !ok {
+		return nil, errors.Errorf("invalid quantity: %v", value)
+	}
+	return result, nil
+}
+
 func DecDataToBytes(value Data) ([]byte, error) {
 	if len(value) <= 2 || value[0:2] != "0x" {
 		return []byte{}, errors.Errorf("invalid data format: %v", value)
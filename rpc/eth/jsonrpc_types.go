@@ -3,6 +3,7 @@ package eth
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 
 	"github.com/gorilla/websocket"
 )
@@ -12,6 +13,13 @@ type RPCFunc interface {
 	GetResponse(result json.RawMessage, ID *json.RawMessage) (*JsonRpcResponse, *Error)
 }
 
+// URIRPCFunc is implemented by RPC functions that can also be called via a URI request, with the
+// method in the path, and the params in the query args.
+type URIRPCFunc interface {
+	RPCFunc
+	URIParams(args url.Values) (json.RawMessage, *Error)
+}
+
 type JsonRpcRequest struct {
 	Version string          `json:"jsonrpc"`
 	Method  string          `json:"method"`
@@ -45,6 +53,9 @@ const (
 	EcInvalidParams  ErrorCode = -32602 // Invalid method parameter(s).
 	EcInternal       ErrorCode = -32603 // Internal JSON-RPC error.
 	EcServer         ErrorCode = -32000 // Reserved for implementation-defined server-errors.
+	EcRateLimited    ErrorCode = -32005 // Request rate limit exceeded, the JSON-RPC equivalent of HTTP 429.
+
+	EcExecutionReverted ErrorCode = 3 // EVM execution was reverted, same code as used by Geth.
 )
 
 type Error struct {
@@ -68,3 +79,11 @@ func NewErrorf(code ErrorCode, message, format string, args ...interface{}) *Err
 func (e *Error) Error() string {
 	return e.Message
 }
+
+// DataError is implemented by errors that should be returned to the client with a specific error
+// code, and additional information in the data field of the JSON-RPC error object.
+type DataError interface {
+	error
+	ErrorCode() ErrorCode
+	ErrorData() interface{}
+}
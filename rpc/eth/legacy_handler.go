@@ -0,0 +1,218 @@
+package eth
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	amino "github.com/tendermint/go-amino"
+)
+
+// Legacy /query clients encode params & decode results with the same amino codec Tendermint's
+// RPC server uses.
+var legacyCodec = amino.NewCodec()
+
+var reInt = regexp.MustCompile(`^-?[0-9]+$`)
+
+// LegacyRPCFunc wraps a method served on the legacy /query endpoint. Unlike HttpRPCFunc it accepts
+// params by name as well as by position, and encodes params & results the same way as
+// Tendermint's RPC server so existing clients of the endpoint keep working.
+type LegacyRPCFunc struct {
+	method     reflect.Value
+	signature  []reflect.Type
+	paramNames []string
+	ws         bool
+}
+
+func NewLegacyRPCFunc(method interface{}, paramNamesString string) RPCFunc {
+	return newLegacyRPCFunc(method, paramNamesString, false)
+}
+
+// NewLegacyWSRPCFunc wraps a method that can only be called via a websocket connection, the first
+// parameter of the method must be a websocket connection.
+func NewLegacyWSRPCFunc(method interface{}, paramNamesString string) RPCFunc {
+	return newLegacyRPCFunc(method, paramNamesString, true)
+}
+
+func newLegacyRPCFunc(method interface{}, paramNamesString string, ws bool) *LegacyRPCFunc {
+	paramNames := []string{}
+	if len(paramNamesString) > 0 {
+		paramNames = strings.Split(paramNamesString, ",")
+	}
+
+	rMethod := reflect.TypeOf(method)
+	firstParam := 0
+	if ws {
+		firstParam = 1
+	}
+	if len(paramNames) != rMethod.NumIn()-firstParam {
+		panic("parameter count mismatch making loom api method")
+	}
+	signature := []reflect.Type{}
+	for p := firstParam; p < rMethod.NumIn(); p++ {
+		signature = append(signature, rMethod.In(p))
+	}
+
+	return &LegacyRPCFunc{
+		method:     reflect.ValueOf(method),
+		signature:  signature,
+		paramNames: paramNames,
+		ws:         ws,
+	}
+}
+
+func (m *LegacyRPCFunc) getInputValues(input JsonRpcRequest) ([]reflect.Value, *Error) {
+	paramsBytes := make([]json.RawMessage, len(m.signature))
+	if len(input.Params) > 0 {
+		if input.Params[0] == '{' {
+			namedParams := map[string]json.RawMessage{}
+			if err := json.Unmarshal(input.Params, &namedParams); err != nil {
+				return nil, NewError(EcParseError, "Parse params", err.Error())
+			}
+			for i, name := range m.paramNames {
+				paramsBytes[i] = namedParams[name]
+			}
+		} else {
+			var positionalParams []json.RawMessage
+			if err := json.Unmarshal(input.Params, &positionalParams); err != nil {
+				return nil, NewError(EcParseError, "Parse params", err.Error())
+			}
+			if len(positionalParams) > len(m.signature) {
+				return nil, NewErrorf(
+					EcInvalidParams, "Parse params", "excess input arguments, expected %v got %v",
+					len(m.signature), len(positionalParams),
+				)
+			}
+			copy(paramsBytes, positionalParams)
+		}
+	}
+
+	inValues := make([]reflect.Value, 0, len(m.signature))
+	for i, paramBytes := range paramsBytes {
+		paramValue := reflect.New(m.signature[i])
+		if len(paramBytes) > 0 && string(paramBytes) != "null" {
+			if err := legacyCodec.UnmarshalJSON(paramBytes, paramValue.Interface()); err != nil {
+				return nil, NewErrorf(EcInvalidParams, "Parse params", "unmarshal input parameter %s", m.paramNames[i])
+			}
+		}
+		inValues = append(inValues, paramValue.Elem())
+	}
+	return inValues, nil
+}
+
+// URIParams converts the query args of a URI request to named JSON-RPC params. Args are encoded
+// the same way Tendermint's URI clients encode them: byte slices as 0x-prefixed hex strings, and
+// everything else as JSON, integers don't have to be quoted.
+func (m *LegacyRPCFunc) URIParams(args url.Values) (json.RawMessage, *Error) {
+	params := map[string]json.RawMessage{}
+	for i, name := range m.paramNames {
+		arg := args.Get(name)
+		if arg == "" {
+			continue
+		}
+		value, err := uriArgToJSON(m.signature[i], arg)
+		if err != nil {
+			return nil, NewErrorf(EcInvalidParams, "Parse params", "invalid value for %s: %v", name, err)
+		}
+		params[name] = value
+	}
+	outBytes, err := json.Marshal(params)
+	if err != nil {
+		return nil, NewError(EcServer, fmt.Sprintf("error marshalling params: %v", err), "")
+	}
+	return outBytes, nil
+}
+
+func uriArgToJSON(rt reflect.Type, arg string) (json.RawMessage, error) {
+	if rt.Kind() == reflect.Ptr {
+		rt = rt.Elem()
+	}
+	switch rt.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if reInt.MatchString(arg) {
+			// amino expects 64-bit integers to be quoted
+			arg = `"` + arg + `"`
+		}
+	case reflect.String:
+		if strings.HasPrefix(strings.ToLower(arg), "0x") {
+			value, err := hex.DecodeString(arg[2:])
+			if err != nil {
+				return nil, err
+			}
+			return legacyCodec.MarshalJSON(string(value))
+		}
+	case reflect.Slice:
+		if rt.Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		if strings.HasPrefix(strings.ToLower(arg), "0x") {
+			value, err := hex.DecodeString(arg[2:])
+			if err != nil {
+				return nil, err
+			}
+			return legacyCodec.MarshalJSON(value)
+		}
+		if strings.HasPrefix(arg, `"`) && strings.HasSuffix(arg, `"`) {
+			var value string
+			if err := json.Unmarshal([]byte(arg), &value); err != nil {
+				return nil, err
+			}
+			return legacyCodec.MarshalJSON([]byte(value))
+		}
+	}
+	if !json.Valid([]byte(arg)) {
+		return nil, errors.Errorf("%s is not valid JSON", arg)
+	}
+	return json.RawMessage(arg), nil
+}
+
+func (m *LegacyRPCFunc) UnmarshalParamsAndCall(input JsonRpcRequest, conn *websocket.Conn) (json.RawMessage, *Error) {
+	if m.ws && conn == nil {
+		return nil, NewErrorf(
+			EcMethodNotFound, "Method only available via websocket", "method %s requires a websocket connection",
+			input.Method,
+		)
+	}
+	inValues, jsonErr := m.getInputValues(input)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	if m.ws {
+		inValues = append([]reflect.Value{reflect.ValueOf(conn)}, inValues...)
+	}
+
+	outValues := m.method.Call(inValues)
+	if outValues[1].Interface() != nil {
+		err := outValues[1].Interface().(error)
+		if dataErr, ok := errors.Cause(err).(DataError); ok {
+			return nil, &Error{
+				Code:    dataErr.ErrorCode(),
+				Message: dataErr.Error(),
+				Data:    dataErr.ErrorData(),
+			}
+		}
+		return nil, NewError(EcInternal, "Internal error", err.Error())
+	}
+
+	value := outValues[0].Interface()
+	outBytes, err := legacyCodec.MarshalJSON(value)
+	if err != nil {
+		return nil, NewError(EcServer, fmt.Sprintf("failed to marshal return value: %v", value), "")
+	}
+	return json.RawMessage(outBytes), nil
+}
+
+func (m *LegacyRPCFunc) GetResponse(result json.RawMessage, ID *json.RawMessage) (*JsonRpcResponse, *Error) {
+	return &JsonRpcResponse{
+		Result:  result,
+		Version: "2.0",
+		ID:      ID,
+	}, nil
+}
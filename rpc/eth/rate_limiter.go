@@ -0,0 +1,180 @@
+package eth
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+// Once the limiter is tracking this many buckets it'll start discarding idle ones.
+const maxRateLimiterBuckets = 100000
+
+var rejectedRequestCount metrics.Counter
+
+func init() {
+	rejectedRequestCount = kitprometheus.NewCounterFrom(stdprometheus.CounterOpts{
+		Namespace: "loomchain",
+		Subsystem: "rate_limiter",
+		Name:      "rejected_request_count",
+		Help:      "Number of requests rejected by the rate limiter.",
+	}, []string{"method", "reason"})
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastUpdate time.Time
+}
+
+func (b *tokenBucket) refill(limit RateLimit, now time.Time) {
+	elapsed := now.Sub(b.lastUpdate).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.lastUpdate = now
+}
+
+// RateLimiter limits the rate at which each client can call each JSON-RPC method, clients are
+// identified by API key if they provide a known one, and by IP otherwise.
+type RateLimiter struct {
+	cfg          *RateLimiterConfig
+	methodLimits map[string]RateLimit
+	apiKeyLimits map[string]RateLimit
+	denied       map[string]bool
+
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+	now     func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter from the given config, returns nil if rate limiting is
+// disabled. A nil rate limiter allows all requests.
+func NewRateLimiter(cfg *RateLimiterConfig) *RateLimiter {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	rl := &RateLimiter{
+		cfg:          cfg,
+		methodLimits: map[string]RateLimit{},
+		apiKeyLimits: map[string]RateLimit{},
+		denied:       map[string]bool{},
+		buckets:      map[string]*tokenBucket{},
+		now:          time.Now,
+	}
+	for _, limit := range cfg.MethodLimits {
+		rl.methodLimits[limit.Method] = RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	for _, limit := range cfg.APIKeys {
+		rl.apiKeyLimits[limit.Key] = RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+	}
+	for _, method := range cfg.DeniedMethods {
+		rl.denied[method] = true
+	}
+	return rl
+}
+
+// ClientID returns the ID the limits of the client that sent the given request are tracked by.
+func (rl *RateLimiter) ClientID(req *http.Request) string {
+	if rl == nil {
+		return ""
+	}
+	if rl.cfg.APIKeyHeader != "" {
+		key := req.Header.Get(rl.cfg.APIKeyHeader)
+		if _, ok := rl.apiKeyLimits[key]; ok && key != "" {
+			return "key:" + key
+		}
+	}
+	if rl.cfg.ClientIPHeader != "" {
+		if ip := req.Header.Get(rl.cfg.ClientIPHeader); ip != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(ip, ",")[0])
+		}
+	}
+	ip, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		ip = req.RemoteAddr
+	}
+	return "ip:" + ip
+}
+
+// Allow checks if the given client is allowed to call the given method, returns an error that
+// should be sent back to the client if the method is denied, or the client is calling it too often.
+func (rl *RateLimiter) Allow(clientID, method string) *Error {
+	if rl == nil {
+		return nil
+	}
+	if rl.denied[method] {
+		rejectedRequestCount.With("method", method, "reason", "denied").Add(1)
+		return NewErrorf(EcMethodNotFound, "Method not available", "method %s is disabled on this node", method)
+	}
+
+	var limit RateLimit
+	var ok bool
+	if strings.HasPrefix(clientID, "key:") {
+		limit, ok = rl.apiKeyLimits[strings.TrimPrefix(clientID, "key:")]
+	} else if limit, ok = rl.methodLimits[method]; !ok && rl.cfg.DefaultLimit != nil {
+		limit, ok = *rl.cfg.DefaultLimit, true
+	}
+	if !ok {
+		return nil
+	}
+
+	if !rl.take(clientID+"/"+method, limit) {
+		rejectedRequestCount.With("method", method, "reason", "rate_limited").Add(1)
+		return NewErrorf(
+			EcRateLimited, "Too many requests",
+			"rate limit of %v requests per second exceeded for method %s", limit.Rate, method,
+		)
+	}
+	return nil
+}
+
+// take removes a token from the bucket with the given key, returns false if the bucket is empty.
+func (rl *RateLimiter) take(key string, limit RateLimit) bool {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	now := rl.now()
+	bucket, ok := rl.buckets[key]
+	if ok {
+		bucket.refill(limit, now)
+	} else {
+		if len(rl.buckets) >= maxRateLimiterBuckets {
+			rl.pruneBuckets(now)
+		}
+		bucket = &tokenBucket{tokens: float64(limit.Burst), lastUpdate: now}
+		rl.buckets[key] = bucket
+	}
+	if bucket.tokens < 1 {
+		return false
+	}
+	bucket.tokens--
+	return true
+}
+
+// pruneBuckets discards buckets that haven't been used for long enough to have been refilled,
+// since a new bucket starts out full dropping these doesn't change the outcome of future requests.
+func (rl *RateLimiter) pruneBuckets(now time.Time) {
+	for key, bucket := range rl.buckets {
+		limit := rl.limitForBucket(key)
+		bucket.refill(limit, now)
+		if bucket.tokens >= float64(limit.Burst) {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+func (rl *RateLimiter) limitForBucket(key string) RateLimit {
+	sep := strings.LastIndex(key, "/")
+	clientID, method := key[:sep], key[sep+1:]
+	if strings.HasPrefix(clientID, "key:") {
+		return rl.apiKeyLimits[strings.TrimPrefix(clientID, "key:")]
+	}
+	if limit, ok := rl.methodLimits[method]; ok {
+		return limit
+	}
+	return *rl.cfg.DefaultLimit
+}
@@ -0,0 +1,66 @@
+package eth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	require.Nil(t, NewRateLimiter(DefaultRateLimiterConfig()))
+
+	cfg := DefaultRateLimiterConfig()
+	cfg.Enabled = true
+	cfg.DefaultLimit = &RateLimit{Rate: 1, Burst: 2}
+	cfg.MethodLimits = []*MethodRateLimit{{Method: "eth_getLogs", Rate: 0.5, Burst: 1}}
+	cfg.APIKeys = []*APIKeyRateLimit{{Key: "secret", Rate: 10, Burst: 5}}
+	cfg.DeniedMethods = []string{"debug_traceTransaction"}
+	rl := NewRateLimiter(cfg)
+	now := time.Now()
+	rl.now = func() time.Time { return now }
+
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	client1 := rl.ClientID(req)
+	require.Equal(t, "ip:10.0.0.1", client1)
+	req.Header.Set("X-Api-Key", "unknown")
+	require.Equal(t, client1, rl.ClientID(req))
+	req.Header.Set("X-Api-Key", "secret")
+	keyClient := rl.ClientID(req)
+	require.Equal(t, "key:secret", keyClient)
+
+	// default limit
+	require.Nil(t, rl.Allow(client1, "eth_blockNumber"))
+	require.Nil(t, rl.Allow(client1, "eth_blockNumber"))
+	jsonErr := rl.Allow(client1, "eth_blockNumber")
+	require.NotNil(t, jsonErr)
+	require.Equal(t, EcRateLimited, jsonErr.Code)
+	// each method & client has its own bucket
+	require.Nil(t, rl.Allow(client1, "eth_getBalance"))
+	require.Nil(t, rl.Allow("ip:10.0.0.2", "eth_blockNumber"))
+
+	// method limit
+	require.Nil(t, rl.Allow(client1, "eth_getLogs"))
+	require.NotNil(t, rl.Allow(client1, "eth_getLogs"))
+
+	// API keys have their own limits
+	for i := 0; i < 5; i++ {
+		require.Nil(t, rl.Allow(keyClient, "eth_getLogs"))
+	}
+	require.NotNil(t, rl.Allow(keyClient, "eth_getLogs"))
+
+	// buckets refill over time
+	now = now.Add(2 * time.Second)
+	require.Nil(t, rl.Allow(client1, "eth_getLogs"))
+	require.Nil(t, rl.Allow(client1, "eth_blockNumber"))
+	require.Nil(t, rl.Allow(client1, "eth_blockNumber"))
+	require.NotNil(t, rl.Allow(client1, "eth_blockNumber"))
+
+	// denied methods can't be called by anyone
+	jsonErr = rl.Allow(keyClient, "debug_traceTransaction")
+	require.NotNil(t, jsonErr)
+	require.Equal(t, EcMethodNotFound, jsonErr.Code)
+}
@@ -84,6 +84,7 @@ func TestTendermintPRCFunc(t *testing.T) {
 		map[string]eth.RPCFunc{
 			"eth_sendRawTransaction": NewSendRawTransactionRPCFunc("default", mt.BroadcastTxSync),
 		},
+		nil,
 	)
 	ethChainID, err := evmcompat.ToEthereumChainID("default")
 	require.NoError(t, err)
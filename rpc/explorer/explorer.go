@@ -0,0 +1,293 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/types"
+	"github.com/pkg/errors"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+)
+
+const (
+	// Default number of blocks covered by a page of results.
+	defaultPageSize = 10
+	// Max number of blocks covered by a page of results, Tendermint doesn't return more than 20
+	// blocks at a time.
+	maxPageSize = 20
+)
+
+// Backend provides the chain data served by the explorer API.
+type Backend interface {
+	// GetBlockByHeight loads the block at the given height, or the latest block if height is nil.
+	GetBlockByHeight(height *int64) (*ctypes.ResultBlock, error)
+	// GetBlockRangeByHeight loads the headers of the blocks in the given height range.
+	GetBlockRangeByHeight(minHeight, maxHeight int64) (*ctypes.ResultBlockchainInfo, error)
+	// GetBlockResults loads the results of the txs in the block at the given height.
+	GetBlockResults(height *int64) (*ctypes.ResultBlockResults, error)
+	// GetTxResult looks up the height & index of the tx with the given hash.
+	GetTxResult(txHash []byte) (*ctypes.ResultTx, error)
+	// FilterEvents loads the contract events matching the given filter.
+	FilterEvents(filter store.EventFilter) ([]*types.EventData, error)
+	// ContractNames returns a func that looks up the name of the contract at the given address
+	// (or returns an empty string if the contract isn't registered under a name), and a func that
+	// must be called once the lookup func is no longer needed.
+	ContractNames() (func(loom.Address) string, func())
+}
+
+type handler struct {
+	backend Backend
+	limiter *eth.RateLimiter
+	mux     *http.ServeMux
+}
+
+// NewHandler creates an HTTP handler that serves the explorer API, all routes only accept GET requests:
+//   - /blocks?from=<height>&limit=<count>: headers of the blocks with heights in the range
+//     (from-limit, from], from defaults to the latest block.
+//   - /blocks/<height>: a block along with its decoded txs.
+//   - /txs/<hash>: a decoded tx.
+//   - /events?from=<height>&limit=<count>&contract=<name>: contract events emitted in the blocks
+//     with heights in the range (from-limit, from], optionally only those of the named contract.
+//
+// Paged results are returned newest block first, along with the height the next page starts at.
+// Each request counts as a call to the explorer method, so the given limiter (which may be nil)
+// applies the same rate limits & deny-list to the API as it does to the JSON-RPC methods.
+func NewHandler(backend Backend, limiter *eth.RateLimiter) http.Handler {
+	h := &handler{
+		backend: backend,
+		limiter: limiter,
+		mux:     http.NewServeMux(),
+	}
+	h.mux.HandleFunc("/blocks", h.handleBlocks)
+	h.mux.HandleFunc("/blocks/", h.handleBlock)
+	h.mux.HandleFunc("/txs/", h.handleTx)
+	h.mux.HandleFunc("/events", h.handleEvents)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodOptions {
+		return
+	}
+	if req.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errors.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	if err := h.limiter.Allow(h.limiter.ClientID(req), "explorer"); err != nil {
+		status := http.StatusForbidden
+		if err.Code == eth.EcRateLimited {
+			status = http.StatusTooManyRequests
+		}
+		writeError(w, status, err)
+		return
+	}
+	h.mux.ServeHTTP(w, req)
+}
+
+func (h *handler) handleBlocks(w http.ResponseWriter, req *http.Request) {
+	from, to, err := h.pageRange(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	page := &BlocksPage{Blocks: []*BlockHeader{}, Next: nextPage(to)}
+	if from > 0 {
+		info, err := h.backend.GetBlockRangeByHeight(to, from)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to load blocks"))
+			return
+		}
+		for _, meta := range info.BlockMetas {
+			page.Blocks = append(page.Blocks, encBlockHeader(meta.BlockID.Hash, &meta.Header))
+		}
+		sort.Slice(page.Blocks, func(i, j int) bool {
+			return page.Blocks[i].Height > page.Blocks[j].Height
+		})
+	}
+	writeResult(w, page)
+}
+
+func (h *handler) handleBlock(w http.ResponseWriter, req *http.Request) {
+	height, err := strconv.ParseInt(strings.TrimPrefix(req.URL.Path, "/blocks/"), 10, 64)
+	if err != nil || height < 1 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid block height"))
+		return
+	}
+	latest, err := h.latestHeight()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if height > latest {
+		writeError(w, http.StatusNotFound, errors.Errorf("block %d not found", height))
+		return
+	}
+	block, err := h.loadBlock(height)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeResult(w, block)
+}
+
+func (h *handler) handleTx(w http.ResponseWriter, req *http.Request) {
+	hash := strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, "/txs/"), "0x")
+	txHash, err := hex.DecodeString(hash)
+	if err != nil || len(txHash) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid tx hash"))
+		return
+	}
+	txResult, err := h.backend.GetTxResult(txHash)
+	if err != nil || txResult == nil {
+		writeError(w, http.StatusNotFound, errors.Errorf("tx %s not found", hash))
+		return
+	}
+	block, err := h.loadBlock(txResult.Height)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if int(txResult.Index) >= len(block.Txs) {
+		writeError(w, http.StatusNotFound, errors.Errorf("tx %s not found", hash))
+		return
+	}
+	writeResult(w, block.Txs[txResult.Index])
+}
+
+func (h *handler) handleEvents(w http.ResponseWriter, req *http.Request) {
+	from, to, err := h.pageRange(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	page := &EventsPage{Events: []*Event{}, Next: nextPage(to)}
+	if from > 0 {
+		events, err := h.backend.FilterEvents(store.EventFilter{
+			FromBlock: uint64(to),
+			ToBlock:   uint64(from),
+			Contract:  req.URL.Query().Get("contract"),
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, errors.Wrap(err, "failed to load events"))
+			return
+		}
+		for _, event := range events {
+			page.Events = append(page.Events, encEvent(event))
+		}
+		// events within a block are kept in the order they were emitted
+		sort.SliceStable(page.Events, func(i, j int) bool {
+			return page.Events[i].BlockHeight > page.Events[j].BlockHeight
+		})
+	}
+	writeResult(w, page)
+}
+
+// pageRange returns the heights of the first & last block in the page requested by the client,
+// the first block has the greatest height. If there are no blocks in the page both heights are 0.
+func (h *handler) pageRange(req *http.Request) (int64, int64, error) {
+	latest, err := h.latestHeight()
+	if err != nil {
+		return 0, 0, err
+	}
+	args := req.URL.Query()
+	from := latest
+	if arg := args.Get("from"); arg != "" {
+		from, err = strconv.ParseInt(arg, 10, 64)
+		if err != nil || from < 0 {
+			return 0, 0, errors.Errorf("invalid from height %s", arg)
+		}
+		if from > latest {
+			from = latest
+		}
+	}
+	limit := int64(defaultPageSize)
+	if arg := args.Get("limit"); arg != "" {
+		limit, err = strconv.ParseInt(arg, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return 0, 0, errors.Errorf("limit must be between 1 and %d", maxPageSize)
+		}
+	}
+	if from < 1 {
+		return 0, 0, nil
+	}
+	to := from - limit + 1
+	if to < 1 {
+		to = 1
+	}
+	return from, to, nil
+}
+
+func (h *handler) latestHeight() (int64, error) {
+	block, err := h.backend.GetBlockByHeight(nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to load latest block")
+	}
+	return block.Block.Height, nil
+}
+
+// loadBlock loads the block at the given height and decodes all the txs in it.
+func (h *handler) loadBlock(height int64) (*Block, error) {
+	blockResult, err := h.backend.GetBlockByHeight(&height)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load block %d", height)
+	}
+	// the results may have been pruned, in which case the txs are returned without them
+	var txResults *ctypes.ResultBlockResults
+	if len(blockResult.Block.Data.Txs) > 0 {
+		txResults, _ = h.backend.GetBlockResults(&height)
+	}
+
+	block := &Block{
+		BlockHeader: *encBlockHeader(blockResult.BlockMeta.BlockID.Hash, &blockResult.Block.Header),
+		Txs:         make([]*Tx, 0, len(blockResult.Block.Data.Txs)),
+	}
+	if len(blockResult.Block.Data.Txs) == 0 {
+		return block, nil
+	}
+	// all the txs in the block are decoded against the same snapshot of the app state
+	contractName, release := h.backend.ContractNames()
+	defer release()
+	for i, tx := range blockResult.Block.Data.Txs {
+		decodedTx := decodeTx(tx, contractName)
+		decodedTx.BlockHeight = height
+		decodedTx.Index = i
+		if txResults != nil && txResults.Results != nil && i < len(txResults.Results.DeliverTx) &&
+			txResults.Results.DeliverTx[i] != nil {
+			txResult := txResults.Results.DeliverTx[i]
+			decodedTx.Code = txResult.Code
+			if txResult.Code != 0 {
+				decodedTx.Error = txResult.Log
+			}
+		}
+		block.Txs = append(block.Txs, decodedTx)
+	}
+	return block, nil
+}
+
+func nextPage(to int64) int64 {
+	if to > 1 {
+		return to - 1
+	}
+	return 0
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&ErrorResponse{Error: err.Error()})
+}
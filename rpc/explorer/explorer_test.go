@@ -0,0 +1,183 @@
+package explorer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/auth"
+	"github.com/loomnetwork/go-loom/plugin"
+	"github.com/loomnetwork/go-loom/plugin/types"
+	ltypes "github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/go-loom/vm"
+	"github.com/stretchr/testify/require"
+	abci "github.com/tendermint/tendermint/abci/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
+
+	"github.com/loomnetwork/loomchain/store"
+)
+
+var (
+	testCaller   = loom.MustParseAddress("default:0xb16a379ec18d4093666f8f38b11a3071c920207d")
+	testContract = loom.MustParseAddress("default:0x5cecd1f7261e1f4c684e297be3edf03b825e01c4")
+)
+
+type fakeBackend struct {
+	*store.MockBlockStore
+	txResult *ctypes.ResultTx
+	events   []*types.EventData
+	filters  []store.EventFilter
+	// number of times ContractNames was called
+	snapshots int
+}
+
+func (b *fakeBackend) GetTxResult(_ []byte) (*ctypes.ResultTx, error) {
+	return b.txResult, nil
+}
+
+func (b *fakeBackend) FilterEvents(filter store.EventFilter) ([]*types.EventData, error) {
+	b.filters = append(b.filters, filter)
+	return b.events, nil
+}
+
+func (b *fakeBackend) ContractNames() (func(loom.Address) string, func()) {
+	b.snapshots++
+	return func(addr loom.Address) string {
+		if addr.Compare(testContract) == 0 {
+			return "coin"
+		}
+		return ""
+	}, func() {}
+}
+
+func marshalTx(t *testing.T, txID ltypes.TxID, nonce uint64, data proto.Message) []byte {
+	payload, err := proto.Marshal(data)
+	require.NoError(t, err)
+	msgTx, err := proto.Marshal(&vm.MessageTx{
+		From: testCaller.MarshalPB(),
+		To:   testContract.MarshalPB(),
+		Data: payload,
+	})
+	require.NoError(t, err)
+	loomTx, err := proto.Marshal(&ltypes.Transaction{Id: uint32(txID), Data: msgTx})
+	require.NoError(t, err)
+	nonceTx, err := proto.Marshal(&auth.NonceTx{Inner: loomTx, Sequence: nonce})
+	require.NoError(t, err)
+	signedTx, err := proto.Marshal(&auth.SignedTx{Inner: nonceTx, PublicKey: []byte{1, 2, 3}})
+	require.NoError(t, err)
+	return signedTx
+}
+
+func goCallTx(t *testing.T, method string) []byte {
+	body, err := proto.Marshal(&plugin.ContractMethodCall{Method: method})
+	require.NoError(t, err)
+	input, err := proto.Marshal(&plugin.Request{Body: body})
+	require.NoError(t, err)
+	return marshalTx(t, ltypes.TxID_CALL, 7, &vm.CallTx{VmType: vm.VMType_PLUGIN, Input: input})
+}
+
+func get(t *testing.T, handler http.Handler, target string, status int, result interface{}) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	require.Equal(t, status, rec.Code, rec.Body.String())
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), result))
+}
+
+func TestExplorerBlocks(t *testing.T) {
+	backend := &fakeBackend{MockBlockStore: store.NewMockBlockStore()}
+	handler := NewHandler(backend, nil)
+
+	var page BlocksPage
+	get(t, handler, "/blocks", http.StatusOK, &page)
+	require.Len(t, page.Blocks, defaultPageSize)
+	require.Equal(t, int64(50), page.Blocks[0].Height)
+	require.Equal(t, int64(41), page.Blocks[defaultPageSize-1].Height)
+	require.Equal(t, int64(40), page.Next)
+
+	get(t, handler, "/blocks?from=3&limit=5", http.StatusOK, &page)
+	require.Len(t, page.Blocks, 3)
+	require.Equal(t, int64(1), page.Blocks[2].Height)
+	require.Equal(t, int64(0), page.Next)
+
+	var errResp ErrorResponse
+	get(t, handler, "/blocks?limit=100", http.StatusBadRequest, &errResp)
+	get(t, handler, "/blocks/51", http.StatusNotFound, &errResp)
+	get(t, handler, "/blocks/abc", http.StatusBadRequest, &errResp)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/blocks", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestExplorerTxs(t *testing.T) {
+	backend := &fakeBackend{MockBlockStore: store.NewMockBlockStore()}
+	migrationTx := marshalTx(t, ltypes.TxID_MIGRATION, 8, &vm.MigrationTx{ID: 3})
+	backend.SetBlock(store.MockBlock(10, []byte{0xab}, [][]byte{goCallTx(t, "Transfer"), migrationTx, {0xff}}))
+	blockResults := store.MockBlockResults(10, [][]byte{nil, nil, nil})
+	blockResults.Results.DeliverTx[1] = &abci.ResponseDeliverTx{Code: 1, Log: "migration failed"}
+	backend.SetBlockResults(blockResults)
+	handler := NewHandler(backend, nil)
+
+	var block Block
+	get(t, handler, "/blocks/10", http.StatusOK, &block)
+	require.Equal(t, int64(10), block.Height)
+	require.Equal(t, "0xab", block.Hash)
+	require.Len(t, block.Txs, 3)
+
+	callTx := block.Txs[0]
+	require.Equal(t, "call", callTx.Type)
+	require.Equal(t, testCaller.String(), callTx.Signer)
+	require.Equal(t, "0x010203", callTx.SignerPublicKey)
+	require.Equal(t, uint64(7), callTx.Nonce)
+	require.Equal(t, "go", callTx.VMType)
+	require.Equal(t, testContract.String(), callTx.Contract)
+	require.Equal(t, "coin", callTx.ContractName)
+	require.Equal(t, "Transfer", callTx.Method)
+	require.Equal(t, uint32(0), callTx.Code)
+	require.Empty(t, callTx.DecodeError)
+
+	require.Equal(t, "migration", block.Txs[1].Type)
+	require.Equal(t, int32(3), block.Txs[1].MigrationID)
+	require.Equal(t, uint32(1), block.Txs[1].Code)
+	require.Equal(t, "migration failed", block.Txs[1].Error)
+
+	require.NotEmpty(t, block.Txs[2].DecodeError)
+	// contract names are looked up in a single snapshot per block
+	require.Equal(t, 1, backend.snapshots)
+
+	var tx Tx
+	var errResp ErrorResponse
+	get(t, handler, "/txs/0x1234", http.StatusNotFound, &errResp)
+	backend.txResult = &ctypes.ResultTx{Height: 10, Index: 1}
+	get(t, handler, "/txs/0x1234", http.StatusOK, &tx)
+	require.Equal(t, block.Txs[1], &tx)
+	get(t, handler, "/txs/xyz", http.StatusBadRequest, &errResp)
+}
+
+func TestExplorerEvents(t *testing.T) {
+	backend := &fakeBackend{
+		MockBlockStore: store.NewMockBlockStore(),
+		events: []*types.EventData{
+			{BlockHeight: 44, PluginName: "coin", Topics: []string{"event:Transfer"}, EncodedBody: []byte{1}},
+			{BlockHeight: 44, PluginName: "coin", Address: testContract.MarshalPB(), EncodedBody: []byte{2}},
+			{BlockHeight: 48, PluginName: "coin", Caller: testCaller.MarshalPB(), EncodedBody: []byte{3}},
+		},
+	}
+	handler := NewHandler(backend, nil)
+
+	var page EventsPage
+	get(t, handler, "/events?from=49&limit=20&contract=coin", http.StatusOK, &page)
+	require.Equal(t, []store.EventFilter{{FromBlock: 30, ToBlock: 49, Contract: "coin"}}, backend.filters)
+	require.Equal(t, int64(29), page.Next)
+	require.Len(t, page.Events, 3)
+	require.Equal(t, uint64(48), page.Events[0].BlockHeight)
+	require.Equal(t, testCaller.String(), page.Events[0].Caller)
+	require.Equal(t, "0x01", page.Events[1].Data)
+	require.Equal(t, []string{"event:Transfer"}, page.Events[1].Topics)
+	require.Equal(t, "0x02", page.Events[2].Data)
+	require.Equal(t, testContract.String(), page.Events[2].Contract)
+	require.Equal(t, []string{}, page.Events[2].Topics)
+}
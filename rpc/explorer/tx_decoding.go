@@ -0,0 +1,125 @@
+package explorer
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin"
+	ltypes "github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/go-loom/vm"
+	"github.com/pkg/errors"
+	tmtypes "github.com/tendermint/tendermint/types"
+
+	"github.com/loomnetwork/loomchain/rpc/loomtx"
+)
+
+// decodeTx unwraps the given Tendermint tx and decodes as much of the Loom tx within as possible,
+// the given func is used to look up the names of the contracts called by the tx.
+func decodeTx(tx tmtypes.Tx, contractName func(loom.Address) string) *Tx {
+	result := &Tx{Hash: encBytes(tx.Hash())}
+	if err := decodeLoomTx(tx, contractName, result); err != nil {
+		result.DecodeError = err.Error()
+	}
+	return result
+}
+
+func decodeLoomTx(tx tmtypes.Tx, contractName func(loom.Address) string, result *Tx) error {
+	unwrapped, err := loomtx.Unwrap(tx)
+	if err != nil {
+		return err
+	}
+	result.SignerPublicKey = encBytes(unwrapped.SignedTx.PublicKey)
+	result.Nonce = unwrapped.NonceTx.Sequence
+	loomTx, msgTx := unwrapped.Tx, unwrapped.Msg
+
+	if msgTx.From != nil {
+		result.Signer = loom.UnmarshalAddressPB(msgTx.From).String()
+	}
+	if msgTx.To != nil {
+		to := loom.UnmarshalAddressPB(msgTx.To)
+		result.Contract = to.String()
+		result.ContractName = contractName(to)
+	}
+
+	switch ltypes.TxID(loomTx.Id) {
+	case ltypes.TxID_DEPLOY:
+		result.Type = "deploy"
+		var deployTx vm.DeployTx
+		if err := proto.Unmarshal(msgTx.Data, &deployTx); err != nil {
+			return errors.Wrap(err, "failed to unmarshal DeployTx")
+		}
+		result.VMType = vmName(deployTx.VmType)
+		if deployTx.Name != "" {
+			result.ContractName = deployTx.Name
+		}
+		if deployTx.Value != nil {
+			result.Value = deployTx.Value.Value.String()
+		}
+
+	case ltypes.TxID_CALL:
+		result.Type = "call"
+		var callTx vm.CallTx
+		if err := proto.Unmarshal(msgTx.Data, &callTx); err != nil {
+			return errors.Wrap(err, "failed to unmarshal CallTx")
+		}
+		result.VMType = vmName(callTx.VmType)
+		if callTx.Value != nil {
+			result.Value = callTx.Value.Value.String()
+		}
+		switch callTx.VmType {
+		case vm.VMType_PLUGIN:
+			var req plugin.Request
+			if err := proto.Unmarshal(callTx.Input, &req); err != nil {
+				return errors.Wrap(err, "failed to unmarshal Request")
+			}
+			var methodCall plugin.ContractMethodCall
+			if err := proto.Unmarshal(req.Body, &methodCall); err != nil {
+				return errors.Wrap(err, "failed to unmarshal ContractMethodCall")
+			}
+			result.Method = methodCall.Method
+		case vm.VMType_EVM:
+			if len(callTx.Input) >= 4 {
+				result.MethodID = "0x" + hex.EncodeToString(callTx.Input[:4])
+			}
+		}
+
+	case ltypes.TxID_ETHEREUM:
+		result.Type = "ethereum"
+		result.VMType = vmName(vm.VMType_EVM)
+		var ethTx etypes.Transaction
+		if err := rlp.DecodeBytes(msgTx.Data, &ethTx); err != nil {
+			return errors.Wrap(err, "failed to decode Ethereum tx")
+		}
+		if ethTx.To() != nil && len(ethTx.Data()) >= 4 {
+			result.MethodID = "0x" + hex.EncodeToString(ethTx.Data()[:4])
+		}
+		result.Value = ethTx.Value().String()
+
+	case ltypes.TxID_MIGRATION:
+		result.Type = "migration"
+		var migrationTx vm.MigrationTx
+		if err := proto.Unmarshal(msgTx.Data, &migrationTx); err != nil {
+			return errors.Wrap(err, "failed to unmarshal MigrationTx")
+		}
+		result.MigrationID = int32(migrationTx.ID)
+
+	default:
+		result.Type = fmt.Sprint(loomTx.Id)
+	}
+	return nil
+}
+
+func vmName(vmType vm.VMType) string {
+	switch vmType {
+	case vm.VMType_PLUGIN:
+		return "go"
+	case vm.VMType_EVM:
+		return "evm"
+	default:
+		return vmType.String()
+	}
+}
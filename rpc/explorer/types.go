@@ -0,0 +1,116 @@
+package explorer
+
+import (
+	"encoding/hex"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/types"
+	tmtypes "github.com/tendermint/tendermint/types"
+)
+
+type BlockHeader struct {
+	Height     int64  `json:"height"`
+	Hash       string `json:"hash"`
+	ParentHash string `json:"parentHash"`
+	Time       int64  `json:"time"`
+	NumTxs     int64  `json:"numTxs"`
+	Proposer   string `json:"proposer"`
+	AppHash    string `json:"appHash"`
+}
+
+type Block struct {
+	BlockHeader
+	Txs []*Tx `json:"txs"`
+}
+
+// Tx is a Loom tx decoded into a readable form, fields that don't apply to the tx type, or couldn't
+// be decoded, are omitted.
+type Tx struct {
+	Hash        string `json:"hash"`
+	BlockHeight int64  `json:"blockHeight"`
+	Index       int    `json:"index"`
+	// Result code of the tx, zero if the tx succeeded.
+	Code  uint32 `json:"code"`
+	Error string `json:"error,omitempty"`
+	// One of deploy, call, ethereum, migration, or the numeric ID of an unknown tx type.
+	Type            string `json:"type,omitempty"`
+	Signer          string `json:"signer,omitempty"`
+	SignerPublicKey string `json:"signerPublicKey,omitempty"`
+	Nonce           uint64 `json:"nonce"`
+	// Either go or evm.
+	VMType       string `json:"vmType,omitempty"`
+	Contract     string `json:"contract,omitempty"`
+	ContractName string `json:"contractName,omitempty"`
+	// Name of the Go contract method called by the tx.
+	Method string `json:"method,omitempty"`
+	// First four bytes of the input of an EVM call, identifies the Solidity function called by the tx.
+	MethodID    string `json:"methodId,omitempty"`
+	Value       string `json:"value,omitempty"`
+	MigrationID int32  `json:"migrationId,omitempty"`
+	// Set if the tx couldn't be decoded.
+	DecodeError string `json:"decodeError,omitempty"`
+}
+
+type Event struct {
+	BlockHeight      uint64   `json:"blockHeight"`
+	TxHash           string   `json:"txHash"`
+	TransactionIndex uint64   `json:"transactionIndex"`
+	Contract         string   `json:"contract"`
+	PluginName       string   `json:"pluginName,omitempty"`
+	Caller           string   `json:"caller,omitempty"`
+	Topics           []string `json:"topics"`
+	Data             string   `json:"data"`
+}
+
+type BlocksPage struct {
+	Blocks []*BlockHeader `json:"blocks"`
+	// Height to pass as the from arg to get the next page, zero if this is the last page.
+	Next int64 `json:"next"`
+}
+
+type EventsPage struct {
+	Events []*Event `json:"events"`
+	// Height to pass as the from arg to get the next page, zero if this is the last page.
+	Next int64 `json:"next"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+func encBlockHeader(hash []byte, header *tmtypes.Header) *BlockHeader {
+	return &BlockHeader{
+		Height:     header.Height,
+		Hash:       encBytes(hash),
+		ParentHash: encBytes(header.LastBlockID.Hash),
+		Time:       header.Time.Unix(),
+		NumTxs:     header.NumTxs,
+		Proposer:   encBytes(header.ProposerAddress),
+		AppHash:    encBytes(header.AppHash),
+	}
+}
+
+func encEvent(event *types.EventData) *Event {
+	e := &Event{
+		BlockHeight:      event.BlockHeight,
+		TxHash:           encBytes(event.TxHash),
+		TransactionIndex: event.TransactionIndex,
+		PluginName:       event.PluginName,
+		Topics:           event.Topics,
+		Data:             encBytes(event.EncodedBody),
+	}
+	if e.Topics == nil {
+		e.Topics = []string{}
+	}
+	if event.Address != nil {
+		e.Contract = loom.UnmarshalAddressPB(event.Address).String()
+	}
+	if event.Caller != nil {
+		e.Caller = loom.UnmarshalAddressPB(event.Caller).String()
+	}
+	return e
+}
+
+func encBytes(value []byte) string {
+	return "0x" + hex.EncodeToString(value)
+}
@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/plugin/types"
+	"github.com/pkg/errors"
+
+	"github.com/loomnetwork/loomchain/rpc/explorer"
+	"github.com/loomnetwork/loomchain/store"
+)
+
+// The blocks served by the explorer API are loaded via the embedded block store.
+var _ explorer.Backend = &QueryServer{}
+
+// FilterEvents loads the contract events matching the given filter from the event store.
+func (s *QueryServer) FilterEvents(filter store.EventFilter) ([]*types.EventData, error) {
+	if s.EventStore == nil {
+		return nil, errors.New("event store is not available")
+	}
+	return s.EventStore.FilterEvents(filter)
+}
+
+// ContractNames returns a func that looks up the name the contract at the given address was
+// registered with (or returns an empty string if the contract isn't registered), all lookups are
+// done against the same snapshot of the app state, which is released by the second func.
+func (s *QueryServer) ContractNames() (func(loom.Address) string, func()) {
+	snapshot := s.StateProvider.ReadOnlyState()
+	reg := s.CreateRegistry(snapshot)
+	return func(addr loom.Address) string {
+		record, err := reg.GetRecord(addr)
+		if err != nil {
+			return ""
+		}
+		return record.Name
+	}, snapshot.Release
+}
@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"math/big"
+	"sort"
+	"sync"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
+)
+
+// GasPriceOracle suggests a gas price for Ethereum txs based on the gas prices of the Ethereum txs
+// in recent blocks, the suggested price is never lower than the price configured in Web3Config.
+type GasPriceOracle struct {
+	cfg        *eth.Web3Config
+	blockStore store.BlockStore
+
+	mutex sync.Mutex
+	// height of the last block the price was suggested at
+	lastHeight int64
+	lastPrice  *big.Int
+}
+
+func NewGasPriceOracle(cfg *eth.Web3Config, blockStore store.BlockStore) *GasPriceOracle {
+	return &GasPriceOracle{
+		cfg:        cfg,
+		blockStore: blockStore,
+	}
+}
+
+// SuggestPrice returns the gas price (in wei) that should be used by Ethereum txs submitted after the
+// block at the given height. The price is only computed once per block.
+func (o *GasPriceOracle) SuggestPrice(height int64) (*big.Int, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.lastPrice != nil && o.lastHeight == height {
+		return new(big.Int).Set(o.lastPrice), nil
+	}
+
+	minPrice := new(big.Int).SetUint64(o.cfg.GasPrice)
+	price := minPrice
+	if o.cfg.GasPriceBlocks > 0 {
+		prices, err := o.samplePrices(height)
+		if err != nil {
+			return nil, err
+		}
+		if len(prices) > 0 {
+			sort.Slice(prices, func(i, j int) bool {
+				return prices[i].Cmp(prices[j]) < 0
+			})
+			percentile := o.cfg.GasPricePercentile
+			if percentile > 100 {
+				percentile = 100
+			}
+			price = prices[(uint64(len(prices))-1)*percentile/100]
+			if price.Cmp(minPrice) < 0 {
+				price = minPrice
+			}
+		}
+	}
+
+	o.lastHeight = height
+	o.lastPrice = price
+	return new(big.Int).Set(price), nil
+}
+
+// samplePrices returns the gas prices of the Ethereum txs in the blocks leading up to, and
+// including, the block at the given height.
+func (o *GasPriceOracle) samplePrices(height int64) ([]*big.Int, error) {
+	var prices []*big.Int
+	for h := height; h > 0 && uint64(height-h) < o.cfg.GasPriceBlocks; h-- {
+		blockHeight := h
+		blockResult, err := o.blockStore.GetBlockByHeight(&blockHeight)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to load block %d", h)
+		}
+		for _, tx := range blockResult.Block.Data.Txs {
+			// txs that can't be decoded can't have come from Web3 clients, so just skip them
+			if ethTx, err := decodeEthTx(tx); err == nil && ethTx != nil {
+				prices = append(prices, ethTx.GasPrice())
+			}
+		}
+	}
+	return prices, nil
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGasPriceOracle(t *testing.T) {
+	wrapper := NewSendRawTransactionRPCFunc("default", nil).(*SendRawTransactionPRCFunc)
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	makeTx := func(nonce uint64, gasPrice int64) []byte {
+		tx, err := etypes.SignTx(
+			etypes.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(0), 0, big.NewInt(gasPrice), nil),
+			wrapper.ethSigner, ethKey,
+		)
+		require.NoError(t, err)
+		txBytes, err := rlp.EncodeToBytes(tx)
+		require.NoError(t, err)
+		tmTx, err := wrapper.ethereumToTendermintTx(txBytes)
+		require.NoError(t, err)
+		return tmTx
+	}
+
+	blockStore := store.NewMockBlockStore()
+	blockStore.SetBlock(store.MockBlock(8, []byte{8}, [][]byte{makeTx(0, 1000)}))
+	blockStore.SetBlock(store.MockBlock(9, []byte{9}, [][]byte{makeTx(1, 10), makeTx(2, 30)}))
+	blockStore.SetBlock(store.MockBlock(10, []byte{10}, [][]byte{makeTx(3, 20), []byte("not a tx")}))
+
+	cfg := eth.DefaultWeb3Config()
+	cfg.GasPriceBlocks = 2
+	cfg.GasPricePercentile = 50
+	price, err := NewGasPriceOracle(cfg, blockStore).SuggestPrice(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(20), price.Int64())
+
+	// the configured price is the lowest price that should be suggested
+	cfg.GasPrice = 25
+	price, err = NewGasPriceOracle(cfg, blockStore).SuggestPrice(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(25), price.Int64())
+
+	cfg.GasPriceBlocks = 3
+	cfg.GasPricePercentile = 100
+	price, err = NewGasPriceOracle(cfg, blockStore).SuggestPrice(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1000), price.Int64())
+
+	// the configured price should be suggested when sampling is disabled
+	cfg.GasPriceBlocks = 0
+	price, err = NewGasPriceOracle(cfg, blockStore).SuggestPrice(10)
+	require.NoError(t, err)
+	require.Equal(t, int64(25), price.Int64())
+}
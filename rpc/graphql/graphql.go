@@ -0,0 +1,84 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	gql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/relay"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/pkg/errors"
+)
+
+// Max depth of the fields selected by a query, prevents clients from constructing queries that
+// recursively walk the chain, e.g. block { parent { parent { ... } } }.
+const maxQueryDepth = 10
+
+// NewSchema parses the EIP-1767 schema and binds it to resolvers that load data from the given backend.
+func NewSchema(backend Backend) (*gql.Schema, error) {
+	s, err := gql.ParseSchema(schema, NewResolver(backend), gql.MaxDepth(maxQueryDepth))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse GraphQL schema")
+	}
+	return s, nil
+}
+
+// NewHandler creates an HTTP handler that executes GraphQL queries POSTed to it.
+// Each request counts as a call to the graphql method, and each logs query it executes as a call to
+// eth_getLogs, so the given limiter (which may be nil) applies the same rate limits & deny-list to
+// the endpoint as it does to the JSON-RPC methods.
+// https://eips.ethereum.org/EIPS/eip-1767
+func NewHandler(backend Backend, limiter *eth.RateLimiter) (http.Handler, error) {
+	s, err := NewSchema(backend)
+	if err != nil {
+		return nil, err
+	}
+	return &handler{
+		relay:   &relay.Handler{Schema: s},
+		limiter: limiter,
+	}, nil
+}
+
+type handler struct {
+	relay   *relay.Handler
+	limiter *eth.RateLimiter
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	clientID := h.limiter.ClientID(r)
+	if err := h.limiter.Allow(clientID, "graphql"); err != nil {
+		status := http.StatusForbidden
+		if err.Code == eth.EcRateLimited {
+			status = http.StatusTooManyRequests
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(&gql.Response{Errors: []*gqlerrors.QueryError{{Message: err.Error()}}})
+		return
+	}
+	ctx := context.WithValue(
+		r.Context(), clientLimiterKey{}, &clientLimiter{limiter: h.limiter, clientID: clientID},
+	)
+	h.relay.ServeHTTP(w, r.WithContext(ctx))
+}
+
+type clientLimiterKey struct{}
+
+// clientLimiter tracks the limits of the client that sent a GraphQL request.
+type clientLimiter struct {
+	limiter  *eth.RateLimiter
+	clientID string
+}
+
+// allowCall checks if the client that sent the request the given context belongs to is allowed to
+// call the given JSON-RPC method.
+func allowCall(ctx context.Context, method string) error {
+	if l, ok := ctx.Value(clientLimiterKey{}).(*clientLimiter); ok {
+		if err := l.limiter.Allow(l.clientID, method); err != nil {
+			return err
+		}
+	}
+	return nil
+}
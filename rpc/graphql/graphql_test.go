@@ -0,0 +1,272 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testBlockHash = "0x1111111111111111111111111111111111111111111111111111111111111111"
+	testTxHash    = "0x2222222222222222222222222222222222222222222222222222222222222222"
+	testTopic     = "0x3333333333333333333333333333333333333333333333333333333333333333"
+	testSender    = "0x4444444444444444444444444444444444444444"
+	testContract  = "0x5555555555555555555555555555555555555555"
+)
+
+type fakeBackend struct {
+	tx      eth.JsonTxObject
+	receipt eth.JsonTxReceipt
+	filters []eth.JsonFilter
+}
+
+func newFakeBackend() *fakeBackend {
+	to := eth.Data(testContract)
+	log := eth.JsonLog{
+		LogIndex:         "0x0",
+		TransactionIndex: "0x0",
+		TransactionHash:  testTxHash,
+		BlockHash:        testBlockHash,
+		BlockNumber:      "0x5",
+		Address:          testContract,
+		Data:             "0x01",
+		Topics:           []eth.Data{testTopic},
+	}
+	return &fakeBackend{
+		tx: eth.JsonTxObject{
+			Hash:             testTxHash,
+			Nonce:            "0x2",
+			BlockHash:        testBlockHash,
+			BlockNumber:      "0x5",
+			TransactionIndex: "0x0",
+			From:             testSender,
+			To:               &to,
+			Value:            "0x0",
+			GasPrice:         "0x0",
+			Gas:              "0x5208",
+			Input:            "0x",
+		},
+		receipt: eth.JsonTxReceipt{
+			TxHash:            testTxHash,
+			BlockHash:         testBlockHash,
+			BlockNumber:       "0x5",
+			CumulativeGasUsed: "0x64",
+			GasUsed:           "0x64",
+			Status:            "0x1",
+			Logs:              []eth.JsonLog{log},
+		},
+	}
+}
+
+func (b *fakeBackend) EthBlockNumber() (eth.Quantity, error) {
+	return "0x5", nil
+}
+
+func (b *fakeBackend) EthGetBlockByNumber(block eth.BlockHeight, full bool) (*eth.JsonBlockObject, error) {
+	height, err := eth.DecBlockHeight(5, block)
+	if err != nil {
+		return nil, err
+	}
+	if height > 5 {
+		return nil, nil
+	}
+	result := eth.JsonBlockObject{
+		Number:       eth.EncUint(height),
+		Hash:         eth.EncBytes([]byte{byte(height)}),
+		Timestamp:    "0x5d8b2b40",
+		Transactions: []interface{}{},
+	}
+	if height == 5 {
+		result.Hash = testBlockHash
+		result.Transactions = append(result.Transactions, b.tx)
+	}
+	return &result, nil
+}
+
+func (b *fakeBackend) EthGetBlockByHash(hash eth.Data, full bool) (eth.JsonBlockObject, error) {
+	if hash != testBlockHash {
+		return eth.JsonBlockObject{}, errors.New("block not found")
+	}
+	block, err := b.EthGetBlockByNumber("0x5", full)
+	return *block, err
+}
+
+func (b *fakeBackend) EthGetTransactionByHash(hash eth.Data) (eth.JsonTxObject, error) {
+	if hash != testTxHash {
+		return eth.JsonTxObject{}, errors.New("tx not found")
+	}
+	return b.tx, nil
+}
+
+func (b *fakeBackend) EthGetTransactionReceipt(hash eth.Data) (*eth.JsonTxReceipt, error) {
+	if hash != testTxHash {
+		return nil, nil
+	}
+	return &b.receipt, nil
+}
+
+func (b *fakeBackend) EthGetLogs(filter eth.JsonFilter) ([]eth.JsonLog, error) {
+	b.filters = append(b.filters, filter)
+	return b.receipt.Logs, nil
+}
+
+func (b *fakeBackend) EthGetBalance(address eth.Data, block eth.BlockHeight) (eth.Quantity, error) {
+	return "0x3e8", nil
+}
+
+func (b *fakeBackend) EthGetTransactionCount(address eth.Data, block eth.BlockHeight) (eth.Quantity, error) {
+	return "0x3", nil
+}
+
+func (b *fakeBackend) EthGetCode(address eth.Data, block eth.BlockHeight) (eth.Data, error) {
+	return "0x6080", nil
+}
+
+func (b *fakeBackend) EthGasPrice() (eth.Quantity, error) {
+	return "0x1", nil
+}
+
+func execQuery(t *testing.T, backend Backend, query string, result interface{}) {
+	s, err := NewSchema(backend)
+	require.NoError(t, err)
+	resp := s.Exec(context.Background(), query, "", nil)
+	require.Empty(t, resp.Errors)
+	require.NoError(t, json.Unmarshal(resp.Data, result))
+}
+
+func TestGraphQLNestedBlockQuery(t *testing.T) {
+	backend := newFakeBackend()
+	var result struct {
+		Block struct {
+			Number       int64
+			Hash         string
+			Parent       struct{ Number int64 }
+			Transactions []struct {
+				Hash   string
+				Nonce  int64
+				Status int64
+				From   struct {
+					Address string
+					Balance string
+				}
+				Logs []struct {
+					Index  int32
+					Topics []string
+				}
+			}
+		}
+	}
+	execQuery(t, backend, `{
+		block(number: 5) {
+			number
+			hash
+			parent { number }
+			transactions {
+				hash
+				nonce
+				status
+				from { address balance }
+				logs { index topics }
+			}
+		}
+	}`, &result)
+
+	require.Equal(t, int64(5), result.Block.Number)
+	require.Equal(t, testBlockHash, result.Block.Hash)
+	require.Equal(t, int64(4), result.Block.Parent.Number)
+	require.Len(t, result.Block.Transactions, 1)
+	tx := result.Block.Transactions[0]
+	require.Equal(t, testTxHash, tx.Hash)
+	require.Equal(t, int64(2), tx.Nonce)
+	require.Equal(t, int64(1), tx.Status)
+	require.Equal(t, testSender, tx.From.Address)
+	require.Equal(t, "0x3e8", tx.From.Balance)
+	require.Len(t, tx.Logs, 1)
+	require.Equal(t, []string{testTopic}, tx.Logs[0].Topics)
+}
+
+func TestGraphQLLogs(t *testing.T) {
+	backend := newFakeBackend()
+	var result struct {
+		Logs []struct {
+			Account     struct{ Address string }
+			Transaction struct{ Hash string }
+		}
+	}
+	execQuery(t, backend, `{
+		logs(filter: {fromBlock: 1, toBlock: "0x5", addresses: ["`+testContract+`"], topics: [[], ["`+testTopic+`"]]}) {
+			account { address }
+			transaction { hash }
+		}
+	}`, &result)
+
+	require.Len(t, result.Logs, 1)
+	require.Equal(t, testContract, result.Logs[0].Account.Address)
+	require.Equal(t, testTxHash, result.Logs[0].Transaction.Hash)
+
+	require.Len(t, backend.filters, 1)
+	filter, err := eth.DecLogFilter(backend.filters[0])
+	require.NoError(t, err)
+	require.Equal(t, eth.BlockHeight("0x1"), filter.FromBlock)
+	require.Equal(t, eth.BlockHeight("0x5"), filter.ToBlock)
+	require.Len(t, filter.Addresses, 1)
+	require.Equal(t, [][]string{{}, {testTopic}}, filter.Topics)
+}
+
+func TestGraphQLInvalidQueries(t *testing.T) {
+	s, err := NewSchema(newFakeBackend())
+	require.NoError(t, err)
+
+	// block hashes must be 32 bytes long
+	resp := s.Exec(context.Background(), `{ block(hash: "0x1234") { number } }`, "", nil)
+	require.NotEmpty(t, resp.Errors)
+
+	// the number of blocks that can be fetched at once is limited
+	resp = s.Exec(context.Background(), `{ blocks(from: 1, to: 1000) { number } }`, "", nil)
+	require.NotEmpty(t, resp.Errors)
+}
+
+func TestGraphQLHandlerLimits(t *testing.T) {
+	postQuery := func(h http.Handler, query string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(map[string]string{"query": query})
+		require.NoError(t, err)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/graphql", strings.NewReader(string(body))))
+		return rec
+	}
+	var resp struct {
+		Errors []struct{ Message string }
+	}
+
+	// the whole endpoint can be disabled via the deny-list
+	h, err := NewHandler(newFakeBackend(), eth.NewRateLimiter(&eth.RateLimiterConfig{
+		Enabled:       true,
+		DeniedMethods: []string{"graphql"},
+	}))
+	require.NoError(t, err)
+	rec := postQuery(h, `{ gasPrice }`)
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// logs queries are subject to the eth_getLogs limits
+	backend := newFakeBackend()
+	h, err = NewHandler(backend, eth.NewRateLimiter(&eth.RateLimiterConfig{
+		Enabled:       true,
+		DeniedMethods: []string{"eth_getLogs"},
+	}))
+	require.NoError(t, err)
+	rec = postQuery(h, `{ gasPrice }`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Empty(t, resp.Errors)
+	rec = postQuery(h, `{ logs(filter: {}) { index } }`)
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.NotEmpty(t, resp.Errors)
+	require.Len(t, backend.filters, 0)
+}
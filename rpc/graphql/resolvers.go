@@ -0,0 +1,482 @@
+package graphql
+
+import (
+	"context"
+	"sync"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/pkg/errors"
+)
+
+// Max number of blocks that can be fetched by a single blocks query.
+const maxBlockRange = 100
+
+// Backend is the subset of the Web3 JSON-RPC methods implemented by the query server that the
+// GraphQL resolvers are built on.
+type Backend interface {
+	EthBlockNumber() (eth.Quantity, error)
+	EthGetBlockByNumber(block eth.BlockHeight, full bool) (*eth.JsonBlockObject, error)
+	EthGetBlockByHash(hash eth.Data, full bool) (eth.JsonBlockObject, error)
+	EthGetTransactionByHash(hash eth.Data) (eth.JsonTxObject, error)
+	EthGetTransactionReceipt(hash eth.Data) (*eth.JsonTxReceipt, error)
+	EthGetLogs(filter eth.JsonFilter) ([]eth.JsonLog, error)
+	EthGetBalance(address eth.Data, block eth.BlockHeight) (eth.Quantity, error)
+	EthGetTransactionCount(address eth.Data, block eth.BlockHeight) (eth.Quantity, error)
+	EthGetCode(address eth.Data, block eth.BlockHeight) (eth.Data, error)
+	EthGasPrice() (eth.Quantity, error)
+}
+
+// Account resolves the fields of an account against the latest block.
+type Account struct {
+	backend Backend
+	address eth.Data
+}
+
+func (a *Account) Address() Address {
+	return Address(a.address)
+}
+
+func (a *Account) Balance() (BigInt, error) {
+	balance, err := a.backend.EthGetBalance(a.address, "latest")
+	return BigInt(balance), err
+}
+
+func (a *Account) TransactionCount() (Long, error) {
+	count, err := a.backend.EthGetTransactionCount(a.address, "latest")
+	if err != nil {
+		return 0, err
+	}
+	return quantityToLong(count)
+}
+
+func (a *Account) Code() (Bytes, error) {
+	code, err := a.backend.EthGetCode(a.address, "latest")
+	return Bytes(code), err
+}
+
+// Log resolves the fields of an event log.
+type Log struct {
+	backend Backend
+	log     eth.JsonLog
+}
+
+func (l *Log) Index() (int32, error) {
+	index, err := quantityToLong(l.log.LogIndex)
+	return int32(index), err
+}
+
+func (l *Log) Account() *Account {
+	return &Account{backend: l.backend, address: l.log.Address}
+}
+
+func (l *Log) Topics() []Bytes32 {
+	topics := make([]Bytes32, 0, len(l.log.Topics))
+	for _, topic := range l.log.Topics {
+		topics = append(topics, Bytes32(topic))
+	}
+	return topics
+}
+
+func (l *Log) Data() Bytes {
+	if l.log.Data == "" {
+		return "0x"
+	}
+	return Bytes(l.log.Data)
+}
+
+func (l *Log) Transaction() (*Transaction, error) {
+	tx, err := l.backend.EthGetTransactionByHash(l.log.TransactionHash)
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{backend: l.backend, tx: tx}, nil
+}
+
+// Transaction resolves the fields of a tx, the receipt of the tx is only loaded if one of the
+// fields that requires it is queried.
+type Transaction struct {
+	backend Backend
+	tx      eth.JsonTxObject
+
+	mutex         sync.Mutex
+	receipt       *eth.JsonTxReceipt
+	receiptLoaded bool
+}
+
+// isPending returns true if the tx hasn't been included in a block yet.
+func (t *Transaction) isPending() bool {
+	return t.tx.BlockNumber == ""
+}
+
+func (t *Transaction) getReceipt() (*eth.JsonTxReceipt, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.isPending() || t.receiptLoaded {
+		return t.receipt, nil
+	}
+	receipt, err := t.backend.EthGetTransactionReceipt(t.tx.Hash)
+	if err != nil {
+		return nil, err
+	}
+	t.receipt = receipt
+	t.receiptLoaded = true
+	return t.receipt, nil
+}
+
+func (t *Transaction) Hash() Bytes32 {
+	return Bytes32(t.tx.Hash)
+}
+
+func (t *Transaction) Nonce() (Long, error) {
+	return quantityToLong(t.tx.Nonce)
+}
+
+func (t *Transaction) Index() (*int32, error) {
+	if t.isPending() {
+		return nil, nil
+	}
+	index, err := quantityToLong(t.tx.TransactionIndex)
+	if err != nil {
+		return nil, err
+	}
+	result := int32(index)
+	return &result, nil
+}
+
+func (t *Transaction) From() *Account {
+	return &Account{backend: t.backend, address: t.tx.From}
+}
+
+func (t *Transaction) To() *Account {
+	if t.tx.To == nil {
+		return nil
+	}
+	return &Account{backend: t.backend, address: *t.tx.To}
+}
+
+func (t *Transaction) Value() BigInt {
+	return BigInt(t.tx.Value)
+}
+
+func (t *Transaction) GasPrice() BigInt {
+	return BigInt(t.tx.GasPrice)
+}
+
+func (t *Transaction) Gas() (Long, error) {
+	return quantityToLong(t.tx.Gas)
+}
+
+func (t *Transaction) InputData() Bytes {
+	if t.tx.Input == "" {
+		return "0x"
+	}
+	return Bytes(t.tx.Input)
+}
+
+func (t *Transaction) Block() (*Block, error) {
+	if t.isPending() {
+		return nil, nil
+	}
+	return getBlockByNumber(t.backend, eth.BlockHeight(t.tx.BlockNumber))
+}
+
+func (t *Transaction) Status() (*Long, error) {
+	receipt, err := t.getReceipt()
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	return optionalLong(receipt.Status)
+}
+
+func (t *Transaction) GasUsed() (*Long, error) {
+	receipt, err := t.getReceipt()
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	return optionalLong(receipt.GasUsed)
+}
+
+func (t *Transaction) CumulativeGasUsed() (*Long, error) {
+	receipt, err := t.getReceipt()
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	return optionalLong(receipt.CumulativeGasUsed)
+}
+
+func (t *Transaction) CreatedContract() (*Account, error) {
+	receipt, err := t.getReceipt()
+	if err != nil || receipt == nil || receipt.ContractAddress == nil {
+		return nil, err
+	}
+	return &Account{backend: t.backend, address: *receipt.ContractAddress}, nil
+}
+
+func (t *Transaction) Logs() (*[]*Log, error) {
+	receipt, err := t.getReceipt()
+	if err != nil || receipt == nil {
+		return nil, err
+	}
+	logs := newLogs(t.backend, receipt.Logs)
+	return &logs, nil
+}
+
+// Block resolves the fields of a block, all the txs in the block are loaded along with the block.
+type Block struct {
+	backend Backend
+	block   *eth.JsonBlockObject
+}
+
+func getBlockByNumber(backend Backend, height eth.BlockHeight) (*Block, error) {
+	block, err := backend.EthGetBlockByNumber(height, true)
+	if err != nil {
+		return nil, err
+	}
+	if block == nil {
+		return nil, nil
+	}
+	return &Block{backend: backend, block: block}, nil
+}
+
+func (b *Block) Number() (Long, error) {
+	return quantityToLong(b.block.Number)
+}
+
+func (b *Block) Hash() Bytes32 {
+	return Bytes32(b.block.Hash)
+}
+
+func (b *Block) Parent() (*Block, error) {
+	number, err := b.Number()
+	if err != nil || number == 0 {
+		return nil, err
+	}
+	return getBlockByNumber(b.backend, eth.BlockHeight(eth.EncInt(int64(number-1))))
+}
+
+func (b *Block) LogsBloom() Bytes {
+	if b.block.LogsBloom == "" {
+		return "0x"
+	}
+	return Bytes(b.block.LogsBloom)
+}
+
+func (b *Block) GasLimit() (Long, error) {
+	return quantityToLong(b.block.GasLimit)
+}
+
+func (b *Block) GasUsed() (Long, error) {
+	return quantityToLong(b.block.GasUsed)
+}
+
+func (b *Block) Timestamp() BigInt {
+	if b.block.Timestamp == "" {
+		return "0x0"
+	}
+	return BigInt(b.block.Timestamp)
+}
+
+func (b *Block) TransactionCount() *int32 {
+	count := int32(len(b.block.Transactions))
+	return &count
+}
+
+func (b *Block) Transactions() (*[]*Transaction, error) {
+	txs := make([]*Transaction, 0, len(b.block.Transactions))
+	for i := range b.block.Transactions {
+		tx, err := b.transactionAt(i)
+		if err != nil {
+			return nil, err
+		}
+		txs = append(txs, tx)
+	}
+	return &txs, nil
+}
+
+func (b *Block) TransactionAt(args struct{ Index int32 }) (*Transaction, error) {
+	if args.Index < 0 || int(args.Index) >= len(b.block.Transactions) {
+		return nil, nil
+	}
+	return b.transactionAt(int(args.Index))
+}
+
+func (b *Block) transactionAt(index int) (*Transaction, error) {
+	tx, ok := b.block.Transactions[index].(eth.JsonTxObject)
+	if !ok {
+		return nil, errors.Errorf("tx %d in block %s wasn't loaded", index, b.block.Number)
+	}
+	return &Transaction{backend: b.backend, tx: tx}, nil
+}
+
+// BlockFilterCriteria specifies the logs that should be returned from a block.
+type BlockFilterCriteria struct {
+	Addresses *[]Address
+	Topics    *[][]Bytes32
+}
+
+func (b *Block) Logs(ctx context.Context, args struct{ Filter BlockFilterCriteria }) ([]*Log, error) {
+	if err := allowCall(ctx, "eth_getLogs"); err != nil {
+		return nil, err
+	}
+	filter := newLogFilter(args.Filter.Addresses, args.Filter.Topics)
+	filter.FromBlock = eth.BlockHeight(b.block.Number)
+	filter.ToBlock = eth.BlockHeight(b.block.Number)
+	logs, err := b.backend.EthGetLogs(filter)
+	if err != nil {
+		return nil, err
+	}
+	return newLogs(b.backend, logs), nil
+}
+
+// FilterCriteria specifies the logs that should be returned from a range of blocks.
+type FilterCriteria struct {
+	FromBlock *Long
+	ToBlock   *Long
+	Addresses *[]Address
+	Topics    *[][]Bytes32
+}
+
+// Resolver is the root resolver of the GraphQL schema.
+type Resolver struct {
+	backend Backend
+}
+
+func NewResolver(backend Backend) *Resolver {
+	return &Resolver{backend: backend}
+}
+
+func (r *Resolver) Block(args struct {
+	Number *Long
+	Hash   *Bytes32
+}) (*Block, error) {
+	if args.Hash != nil {
+		block, err := r.backend.EthGetBlockByHash(eth.Data(*args.Hash), true)
+		if err != nil {
+			return nil, err
+		}
+		return &Block{backend: r.backend, block: &block}, nil
+	}
+	height := eth.BlockHeight("latest")
+	if args.Number != nil {
+		height = eth.BlockHeight(eth.EncInt(int64(*args.Number)))
+	}
+	return getBlockByNumber(r.backend, height)
+}
+
+func (r *Resolver) Blocks(args struct {
+	From Long
+	To   *Long
+}) ([]*Block, error) {
+	var to Long
+	if args.To != nil {
+		to = *args.To
+	} else {
+		latest, err := r.backend.EthBlockNumber()
+		if err != nil {
+			return nil, err
+		}
+		if to, err = quantityToLong(latest); err != nil {
+			return nil, err
+		}
+	}
+	if args.From < 0 || to < args.From {
+		return nil, errors.New("invalid block range")
+	}
+	if to-args.From >= maxBlockRange {
+		return nil, errors.Errorf("max allowed block range (%d) exceeded", maxBlockRange)
+	}
+
+	blocks := []*Block{}
+	for number := args.From; number <= to; number++ {
+		block, err := getBlockByNumber(r.backend, eth.BlockHeight(eth.EncInt(int64(number))))
+		if err != nil {
+			return nil, err
+		}
+		// blocks past the latest one don't exist yet
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+func (r *Resolver) Transaction(args struct{ Hash Bytes32 }) (*Transaction, error) {
+	tx, err := r.backend.EthGetTransactionByHash(eth.Data(args.Hash))
+	if err != nil {
+		return nil, err
+	}
+	return &Transaction{backend: r.backend, tx: tx}, nil
+}
+
+func (r *Resolver) Logs(ctx context.Context, args struct{ Filter FilterCriteria }) ([]*Log, error) {
+	if err := allowCall(ctx, "eth_getLogs"); err != nil {
+		return nil, err
+	}
+	filter := newLogFilter(args.Filter.Addresses, args.Filter.Topics)
+	filter.FromBlock = "latest"
+	if args.Filter.FromBlock != nil {
+		filter.FromBlock = eth.BlockHeight(eth.EncInt(int64(*args.Filter.FromBlock)))
+	}
+	filter.ToBlock = "latest"
+	if args.Filter.ToBlock != nil {
+		filter.ToBlock = eth.BlockHeight(eth.EncInt(int64(*args.Filter.ToBlock)))
+	}
+	logs, err := r.backend.EthGetLogs(filter)
+	if err != nil {
+		return nil, err
+	}
+	return newLogs(r.backend, logs), nil
+}
+
+func (r *Resolver) GasPrice() (BigInt, error) {
+	price, err := r.backend.EthGasPrice()
+	return BigInt(price), err
+}
+
+// newLogFilter converts the address & topic criteria of a GraphQL filter to the JSON-RPC format.
+func newLogFilter(addresses *[]Address, topics *[][]Bytes32) eth.JsonFilter {
+	filter := eth.JsonFilter{}
+	if addresses != nil && len(*addresses) > 0 {
+		addrs := make([]interface{}, 0, len(*addresses))
+		for _, addr := range *addresses {
+			addrs = append(addrs, string(addr))
+		}
+		filter.Address = addrs
+	}
+	if topics != nil {
+		for _, alternatives := range *topics {
+			// an empty list matches any topic
+			if len(alternatives) == 0 {
+				filter.Topics = append(filter.Topics, nil)
+				continue
+			}
+			values := make([]interface{}, 0, len(alternatives))
+			for _, topic := range alternatives {
+				values = append(values, string(topic))
+			}
+			filter.Topics = append(filter.Topics, values)
+		}
+	}
+	return filter
+}
+
+func newLogs(backend Backend, logs []eth.JsonLog) []*Log {
+	result := make([]*Log, 0, len(logs))
+	for _, log := range logs {
+		result = append(result, &Log{backend: backend, log: log})
+	}
+	return result
+}
+
+func optionalLong(value eth.Quantity) (*Long, error) {
+	if value == "" {
+		return nil, nil
+	}
+	result, err := quantityToLong(value)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
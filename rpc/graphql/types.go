@@ -0,0 +1,126 @@
+package graphql
+
+import (
+	"math/big"
+	"strconv"
+
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/pkg/errors"
+)
+
+// Bytes32 is a 32 byte binary string, represented as 0x-prefixed hexadecimal.
+type Bytes32 string
+
+func (Bytes32) ImplementsGraphQLType(name string) bool { return name == "Bytes32" }
+
+func (b *Bytes32) UnmarshalGraphQL(input interface{}) error {
+	value, err := unmarshalHexData(input, 32)
+	*b = Bytes32(value)
+	return err
+}
+
+// Address is a 20 byte Ethereum address, represented as 0x-prefixed hexadecimal.
+type Address string
+
+func (Address) ImplementsGraphQLType(name string) bool { return name == "Address" }
+
+func (a *Address) UnmarshalGraphQL(input interface{}) error {
+	value, err := unmarshalHexData(input, 20)
+	*a = Address(value)
+	return err
+}
+
+// Bytes is an arbitrary length binary string, represented as 0x-prefixed hexadecimal.
+type Bytes string
+
+func (Bytes) ImplementsGraphQLType(name string) bool { return name == "Bytes" }
+
+func (b *Bytes) UnmarshalGraphQL(input interface{}) error {
+	value, err := unmarshalHexData(input, -1)
+	*b = Bytes(value)
+	return err
+}
+
+// BigInt is a large integer, input can be either hexadecimal or decimal, output is always
+// 0x-prefixed hexadecimal.
+type BigInt string
+
+func (BigInt) ImplementsGraphQLType(name string) bool { return name == "BigInt" }
+
+func (b *BigInt) UnmarshalGraphQL(input interface{}) error {
+	var value *big.Int
+	switch input := input.(type) {
+	case string:
+		var ok bool
+		value, ok = new(big.Int).SetString(input, 0)
+		if !ok {
+			return errors.Errorf("invalid BigInt %v", input)
+		}
+	case int32:
+		value = big.NewInt(int64(input))
+	case float64:
+		value, _ = big.NewFloat(input).Int(nil)
+	default:
+		return errors.Errorf("unexpected type %T for BigInt", input)
+	}
+	*b = BigInt(eth.EncBigInt(*value))
+	return nil
+}
+
+// Long is a 64 bit signed integer.
+type Long int64
+
+func (Long) ImplementsGraphQLType(name string) bool { return name == "Long" }
+
+func (l *Long) UnmarshalGraphQL(input interface{}) error {
+	switch input := input.(type) {
+	case string:
+		value, err := strconv.ParseInt(input, 0, 64)
+		if err != nil {
+			return errors.Wrapf(err, "invalid Long %v", input)
+		}
+		*l = Long(value)
+	case int32:
+		*l = Long(input)
+	case int64:
+		*l = Long(input)
+	case float64:
+		*l = Long(input)
+	default:
+		return errors.Errorf("unexpected type %T for Long", input)
+	}
+	return nil
+}
+
+// unmarshalHexData validates the given hex string, and normalizes it to lower case. If size isn't
+// negative the decoded data must be exactly size bytes long.
+func unmarshalHexData(input interface{}, size int) (eth.Data, error) {
+	str, ok := input.(string)
+	if !ok {
+		return "", errors.Errorf("unexpected type %T for hex string", input)
+	}
+	data := []byte{}
+	if str != "0x" {
+		var err error
+		data, err = eth.DecDataToBytes(eth.Data(str))
+		if err != nil {
+			return "", err
+		}
+	}
+	if size >= 0 && len(data) != size {
+		return "", errors.Errorf("hex string %v should be %d bytes long", str, size)
+	}
+	return eth.EncBytes(data), nil
+}
+
+// quantityToLong decodes the given quantity, empty quantities are treated as zero.
+func quantityToLong(value eth.Quantity) (Long, error) {
+	if value == "" {
+		return 0, nil
+	}
+	result, err := eth.DecQuantityToInt(value)
+	if err != nil {
+		return 0, err
+	}
+	return Long(result), nil
+}
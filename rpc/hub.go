@@ -15,6 +15,9 @@ type Hub struct {
 
 	// Unregister requests from clients.
 	unregister chan *Client
+
+	// Called when a client disconnects, may be nil.
+	disconnected func(client *Client)
 }
 
 func newHub() *Hub {
@@ -33,7 +36,13 @@ func (h *Hub) run() {
 		case client := <-h.unregister:
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
+				// Client.Send holds the lock while sending to the channel
+				client.sendMutex.Lock()
 				close(client.send)
+				client.sendMutex.Unlock()
+				if h.disconnected != nil {
+					h.disconnected(client)
+				}
 			}
 		}
 	}
@@ -6,11 +6,13 @@ import (
 
 	"github.com/go-kit/kit/metrics"
 	"github.com/gorilla/websocket"
+	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/go-loom/plugin/types"
 	"github.com/loomnetwork/loomchain/config"
 	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
 	"github.com/loomnetwork/loomchain/vm"
-	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 )
 
 // InstrumentingMiddleware implements QuerySerice interface
@@ -69,12 +71,12 @@ func (m InstrumentingMiddleware) Nonce(key, account string) (resp uint64, err er
 	return
 }
 
-func (m InstrumentingMiddleware) Subscribe(wsCtx rpctypes.WSRPCContext, contracts []string) (*WSEmptyResult, error) {
-	return m.next.Subscribe(wsCtx, contracts)
+func (m InstrumentingMiddleware) Subscribe(conn *websocket.Conn, contracts []string) (*WSEmptyResult, error) {
+	return m.next.Subscribe(conn, contracts)
 }
 
-func (m InstrumentingMiddleware) UnSubscribe(wsCtx rpctypes.WSRPCContext, topic string) (*WSEmptyResult, error) {
-	return m.next.UnSubscribe(wsCtx, topic)
+func (m InstrumentingMiddleware) UnSubscribe(conn *websocket.Conn, topic string) (*WSEmptyResult, error) {
+	return m.next.UnSubscribe(conn, topic)
 }
 
 func (m InstrumentingMiddleware) Resolve(name string) (resp string, err error) {
@@ -139,6 +141,45 @@ func (m InstrumentingMiddleware) DPOSTotalStaked() (resp *DPOSTotalStakedRespons
 	return
 }
 
+func (m InstrumentingMiddleware) ContractStateProof(
+	contract, key string, height int64,
+) (resp *AppStateProof, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "ContractStateProof", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.ContractStateProof(contract, key, height)
+	return
+}
+
+func (m InstrumentingMiddleware) BalanceProof(
+	contract, owner string, height int64,
+) (resp *BalanceProof, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "BalanceProof", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.BalanceProof(contract, owner, height)
+	return
+}
+
+func (m InstrumentingMiddleware) EvmAccountProof(
+	address string, storageKeys []string, height int64,
+) (resp *EvmAccountProof, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "EvmAccountProof", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.EvmAccountProof(address, storageKeys, height)
+	return
+}
+
 func (m InstrumentingMiddleware) GetEvmCode(contract string) (resp []byte, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "GetEvmCode", "error", fmt.Sprint(err != nil)}
@@ -295,8 +336,8 @@ func (m InstrumentingMiddleware) GetEvmBlockByHash(hash []byte, full bool) (resp
 	return
 }
 
-func (m InstrumentingMiddleware) EvmSubscribe(wsCtx rpctypes.WSRPCContext, method, filter string) (string, error) {
-	return m.next.EvmSubscribe(wsCtx, method, filter)
+func (m InstrumentingMiddleware) EvmSubscribe(conn *websocket.Conn, method, filter string) (string, error) {
+	return m.next.EvmSubscribe(conn, method, filter)
 }
 
 func (m InstrumentingMiddleware) EvmUnSubscribe(id string) (resp bool, err error) {
@@ -482,7 +523,7 @@ func (m InstrumentingMiddleware) EthNewFilter(filter eth.JsonFilter) (resp eth.Q
 }
 
 func (m InstrumentingMiddleware) EthSubscribe(
-	conn *websocket.Conn, method eth.Data, filter eth.JsonFilter,
+	conn *websocket.Conn, method eth.Data, filter eth.JsonFilter, options *eth.JsonSubscribeOptions,
 ) (resp eth.Data, err error) {
 	defer func(begin time.Time) {
 		lvs := []string{"method", "EthSubscribe", "error", fmt.Sprint(err != nil)}
@@ -490,7 +531,7 @@ func (m InstrumentingMiddleware) EthSubscribe(
 		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	resp, err = m.next.EthSubscribe(conn, method, filter)
+	resp, err = m.next.EthSubscribe(conn, method, filter, options)
 	return
 }
 
@@ -583,3 +624,142 @@ func (m InstrumentingMiddleware) EthGetTransactionCount(
 	resp, err = m.next.EthGetTransactionCount(local, block)
 	return
 }
+
+func (m InstrumentingMiddleware) EthGetProof(
+	address eth.Data, storageKeys []eth.Data, block eth.BlockHeight,
+) (resp *eth.JsonAccountProof, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "EthGetProof", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.EthGetProof(address, storageKeys, block)
+	return
+}
+
+func (m InstrumentingMiddleware) TxPoolContent() (resp *eth.JsonTxPoolContent, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "TxPoolContent", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.TxPoolContent()
+	return
+}
+
+func (m InstrumentingMiddleware) TxPoolStatus() (resp *eth.JsonTxPoolStatus, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "TxPoolStatus", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.TxPoolStatus()
+	return
+}
+
+func (m InstrumentingMiddleware) DebugTraceTransaction(
+	hash eth.Data, config *eth.JsonTraceConfig,
+) (resp interface{}, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DebugTraceTransaction", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.DebugTraceTransaction(hash, config)
+	return
+}
+
+func (m InstrumentingMiddleware) DebugTraceBlockByNumber(
+	block eth.BlockHeight, config *eth.JsonTraceConfig,
+) (resp []eth.JsonTxTrace, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DebugTraceBlockByNumber", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.DebugTraceBlockByNumber(block, config)
+	return
+}
+
+func (m InstrumentingMiddleware) DebugTraceCall(
+	query eth.JsonTxCallObject, block eth.BlockHeight, config *eth.JsonTraceConfig,
+) (resp interface{}, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "DebugTraceCall", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.DebugTraceCall(query, block, config)
+	return
+}
+
+func (m InstrumentingMiddleware) GetBlockByHeight(height *int64) (resp *ctypes.ResultBlock, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetBlockByHeight", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.GetBlockByHeight(height)
+	return
+}
+
+func (m InstrumentingMiddleware) GetBlockRangeByHeight(minHeight, maxHeight int64) (resp *ctypes.ResultBlockchainInfo, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetBlockRangeByHeight", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.GetBlockRangeByHeight(minHeight, maxHeight)
+	return
+}
+
+func (m InstrumentingMiddleware) GetBlockResults(height *int64) (resp *ctypes.ResultBlockResults, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetBlockResults", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.GetBlockResults(height)
+	return
+}
+
+func (m InstrumentingMiddleware) GetTxResult(txHash []byte) (resp *ctypes.ResultTx, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "GetTxResult", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.GetTxResult(txHash)
+	return
+}
+
+func (m InstrumentingMiddleware) FilterEvents(filter store.EventFilter) (resp []*types.EventData, err error) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "FilterEvents", "error", fmt.Sprint(err != nil)}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	resp, err = m.next.FilterEvents(filter)
+	return
+}
+
+func (m InstrumentingMiddleware) ContractNames() (func(loom.Address) string, func()) {
+	defer func(begin time.Time) {
+		lvs := []string{"method", "ContractNames", "error", "false"}
+		m.requestCount.With(lvs...).Add(1)
+		m.requestLatency.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return m.next.ContractNames()
+}
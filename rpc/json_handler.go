@@ -13,15 +13,18 @@ import (
 	"github.com/loomnetwork/loomchain/rpc/eth"
 )
 
-func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger log.TMLogger, hub *Hub) {
+func RegisterRPCFuncs(
+	mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger log.TMLogger, hub *Hub, limiter *eth.RateLimiter,
+) {
 	mux.HandleFunc("/", func(writer http.ResponseWriter, reader *http.Request) {
+		clientID := limiter.ClientID(reader)
 		if isWebSocketConnection(reader) {
 			conn, err := upgrader.Upgrade(writer, reader, nil)
 			if err != nil {
 				logger.Error("JSON-RPC2 http request, message with no body received")
 				return
 			}
-			client := &Client{hub: hub, conn: conn, send: make(chan []byte, 256)}
+			client := newClient(hub, conn, limiter, clientID)
 			client.hub.register <- client
 
 			go client.readPump(funcMap, logger)
@@ -29,16 +32,31 @@ func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger
 			return
 		}
 
-		body, err := ioutil.ReadAll(reader.Body)
-		if err != nil {
-			WriteResponse(writer, eth.JsonRpcErrorResponse{
-				Version: "2.0",
-				Error:   *eth.NewErrorf(eth.EcInternal, "Http error", "error reading message body %v", err),
-			})
-			return
+		var body []byte
+		methodName := strings.Trim(reader.URL.Path, "/")
+		if uriMethod, ok := funcMap[methodName].(eth.URIRPCFunc); ok {
+			var ethError *eth.Error
+			body, ethError = uriRequestBody(reader, methodName, uriMethod)
+			if ethError != nil {
+				WriteResponse(writer, eth.JsonRpcErrorResponse{
+					Version: "2.0",
+					Error:   *ethError,
+				})
+				return
+			}
+		} else {
+			var err error
+			body, err = ioutil.ReadAll(reader.Body)
+			if err != nil {
+				WriteResponse(writer, eth.JsonRpcErrorResponse{
+					Version: "2.0",
+					Error:   *eth.NewErrorf(eth.EcInternal, "Http error", "error reading message body %v", err),
+				})
+				return
+			}
 		}
 
-		outBytes, ethError := handleMessage(body, funcMap, nil)
+		outBytes, ethError := handleMessage(body, funcMap, nil, limiter, clientID)
 
 		if ethError != nil {
 			WriteResponse(writer, eth.JsonRpcErrorResponse{
@@ -50,14 +68,17 @@ func RegisterRPCFuncs(mux *http.ServeMux, funcMap map[string]eth.RPCFunc, logger
 
 		writer.Header().Set("Content-Type", "application/json")
 		writer.WriteHeader(http.StatusOK)
-		_, err = writer.Write(outBytes)
-		if err != nil {
+		if _, err := writer.Write(outBytes); err != nil {
 			logger.Error("JSON-RPC2 http request, writing response", "err", err)
 		}
 	})
 }
 
-func handleMessage(body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.Conn) ([]byte, *eth.Error) {
+// handleMessage executes the JSON-RPC request(s) in the given message, requests are checked against
+// the rate limit of the client that sent them before the requested method is called.
+func handleMessage(
+	body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.Conn, limiter *eth.RateLimiter, clientID string,
+) ([]byte, *eth.Error) {
 	requestList, isBatch, reqListErr := getRequests(body)
 
 	if reqListErr != nil {
@@ -77,6 +98,15 @@ func handleMessage(body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.
 			continue
 		}
 
+		if jsonErr := limiter.Allow(clientID, jsonRequest.Method); jsonErr != nil {
+			outputList = append(outputList, eth.JsonRpcErrorResponse{
+				Version: "2.0",
+				ID:      jsonRequest.ID,
+				Error:   *jsonErr,
+			})
+			continue
+		}
+
 		rawResult, jsonErr := method.UnmarshalParamsAndCall(jsonRequest, conn)
 
 		if jsonErr != nil {
@@ -115,6 +145,30 @@ func handleMessage(body []byte, funcMap map[string]eth.RPCFunc, conn *websocket.
 	return outBytes, nil
 }
 
+// uriRequestBody converts a URI request to a JSON-RPC request, the method is taken from the path,
+// and the params from the query args (or the form values of a POST request).
+func uriRequestBody(req *http.Request, methodName string, method eth.URIRPCFunc) ([]byte, *eth.Error) {
+	if err := req.ParseForm(); err != nil {
+		return nil, eth.NewErrorf(eth.EcInvalidRequest, "Invalid request", "error parsing URI args %v", err)
+	}
+	params, jsonErr := method.URIParams(req.Form)
+	if jsonErr != nil {
+		return nil, jsonErr
+	}
+	// Tendermint's RPC server always responded to URI requests with an empty string ID
+	id := json.RawMessage(`""`)
+	body, err := json.Marshal(eth.JsonRpcRequest{
+		Version: "2.0",
+		Method:  methodName,
+		Params:  params,
+		ID:      &id,
+	})
+	if err != nil {
+		return nil, eth.NewError(eth.EcServer, fmt.Sprintf("error marshalling request: %v", err), "")
+	}
+	return body, nil
+}
+
 func getRequests(message []byte) ([]eth.JsonRpcRequest, bool, *eth.Error) {
 	var isBatchRequest bool = true
 	var inputList []eth.JsonRpcRequest
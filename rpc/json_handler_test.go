@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"encoding/json"
 	"net/http/httptest"
 	"strings"
 	"sync"
@@ -8,11 +9,13 @@ import (
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/phonkee/go-pubsub"
 	"github.com/posener/wstest"
 	"github.com/stretchr/testify/require"
 
 	"github.com/loomnetwork/loomchain"
 	"github.com/loomnetwork/loomchain/auth"
+	"github.com/loomnetwork/loomchain/eth/subs"
 	"github.com/loomnetwork/loomchain/events"
 	"github.com/loomnetwork/loomchain/log"
 	"github.com/loomnetwork/loomchain/registry/factory"
@@ -52,6 +55,12 @@ var (
 		{"eth_getTransactionCount", "EthGetTransactionCount", ``},
 		{"eth_accounts", "EthAccounts", ``},
 		{"eth_getStorageAt", "EthGetStorageAt", ``},
+		{"eth_getProof", "EthGetProof", ``},
+		{"txpool_content", "TxPoolContent", ``},
+		{"txpool_status", "TxPoolStatus", ``},
+		{"debug_traceTransaction", "DebugTraceTransaction", ``},
+		{"debug_traceBlockByNumber", "DebugTraceBlockByNumber", ``},
+		{"debug_traceCall", "DebugTraceCall", ``},
 	}
 )
 
@@ -64,11 +73,17 @@ func TestJsonRpcHandler(t *testing.T) {
 	t.Run("Multi Websocket JSON-RPC", testMultipleWebsocketConnections)
 	t.Run("Single Websocket JSON-RPC", testSingleWebsocketConnections)
 	t.Run("test eth_subscribe and eth_unsubscribe", testEthSubscribeEthUnSubscribe)
+	t.Run("Http legacy query JSON-RPC", testLegacyQueryHttpJsonHandler)
+	t.Run("Websocket legacy query subscriptions", testLegacyQuerySubscribe)
 }
 
 func testHttpJsonHandler(t *testing.T) {
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(testlog, nil, createDefaultEthRoutes(qs, "default"))
+	handler := MakeEthQueryServiceHandler(testlog, nil, createDefaultEthRoutes(qs, "default", true), nil)
+
+	// the debug methods should only be exposed if they're explicitly enabled
+	_, ok := createDefaultEthRoutes(qs, "default", false)["debug_traceCall"]
+	require.False(t, ok)
 
 	for _, test := range tests {
 		payload := `{"jsonrpc":"2.0","method":"` + test.method + `","params":[` + test.params + `],"id":99}`
@@ -82,7 +97,7 @@ func testHttpJsonHandler(t *testing.T) {
 
 func testBatchHttpJsonHandler(t *testing.T) {
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(testlog, nil, createDefaultEthRoutes(qs, "default"))
+	handler := MakeEthQueryServiceHandler(testlog, nil, createDefaultEthRoutes(qs, "default", true), nil)
 
 	blockPayload := "["
 	first := true
@@ -119,7 +134,7 @@ func testEthSubscribeEthUnSubscribe(t *testing.T) {
 		AuthCfg:          auth.DefaultConfig(),
 		EthSubscriptions: eventHandler.EthSubscriptionSet(),
 	}
-	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default"))
+	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default", true), nil)
 
 	dialer := wstest.NewDialer(handler)
 	conn, _, err := dialer.Dial("ws://localhost/eth", nil)
@@ -146,7 +161,7 @@ func testMultipleWebsocketConnections(t *testing.T) {
 	hub := newHub()
 	go hub.run()
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default"))
+	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default", true), nil)
 
 	conns := []*websocket.Conn{}
 	for _, test := range tests {
@@ -183,7 +198,7 @@ func testSingleWebsocketConnections(t *testing.T) {
 	hub := newHub()
 	go hub.run()
 	qs := &MockQueryService{}
-	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default"))
+	handler := MakeEthQueryServiceHandler(testlog, hub, createDefaultEthRoutes(qs, "default", true), nil)
 	dialer := wstest.NewDialer(handler)
 	conn, _, err := dialer.Dial("ws://localhost/eth", nil)
 	writeMutex := &sync.Mutex{}
@@ -218,3 +233,90 @@ func testSingleWebsocketConnections(t *testing.T) {
 	qs.mutex.RUnlock()
 	require.NoError(t, conn.Close())
 }
+
+func testLegacyQueryHttpJsonHandler(t *testing.T) {
+	qs := &MockQueryService{}
+	bus := &QueryEventBus{
+		Subs:    *loomchain.NewSubscriptionSet(),
+		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
+	}
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
+
+	// batches can mix named & positional params
+	payload := `[
+		{"jsonrpc":"2.0","method":"nonce","params":{"key":"abc"},"id":1},
+		{"jsonrpc":"2.0","method":"resolve","params":["name"],"id":2},
+		{"jsonrpc":"2.0","method":"subevents","params":{},"id":3},
+		{"jsonrpc":"2.0","method":"unknown","params":{},"id":4}
+	]`
+	req := httptest.NewRequest("POST", "http://localhost/", strings.NewReader(payload))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, 200, rec.Result().StatusCode)
+	require.Equal(t, "*", rec.Result().Header.Get("Access-Control-Allow-Origin"))
+
+	var resps []struct {
+		Result json.RawMessage `json:"result"`
+		Error  *eth.Error      `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resps))
+	require.Len(t, resps, 4)
+	// results are encoded the same way Tendermint's RPC server encoded them
+	require.Nil(t, resps[0].Error)
+	require.Equal(t, `"0"`, string(resps[0].Result))
+	require.Nil(t, resps[1].Error)
+	require.Equal(t, `""`, string(resps[1].Result))
+	// subscriptions can only be created via websocket
+	require.Equal(t, eth.EcMethodNotFound, resps[2].Error.Code)
+	require.Equal(t, eth.EcMethodNotFound, resps[3].Error.Code)
+	require.Equal(t, []string{"Resolve", "Nonce"}, qs.MethodsCalled)
+
+	// URI requests
+	req = httptest.NewRequest("GET", "http://localhost/getevmcode?contract=%220x1234%22", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, 200, rec.Result().StatusCode)
+	var resp eth.JsonRpcResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	require.Equal(t, `""`, string(*resp.ID))
+	require.Equal(t, "GetEvmCode", qs.MethodsCalled[0])
+
+	req = httptest.NewRequest("GET", "http://localhost/nonce?key=notjson", nil)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	var errResp eth.JsonRpcErrorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	require.Equal(t, eth.EcInvalidParams, errResp.Error.Code)
+}
+
+func testLegacyQuerySubscribe(t *testing.T) {
+	subscriptions := loomchain.NewSubscriptionSet()
+	var qs QueryService = &QueryServer{
+		StateProvider: &stateProvider{},
+		BlockStore:    store.NewMockBlockStore(),
+		Subscriptions: subscriptions,
+	}
+	bus := &QueryEventBus{
+		Subs:    *subscriptions,
+		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
+	}
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
+
+	dialer := wstest.NewDialer(handler)
+	conn, _, err := dialer.Dial("ws://localhost/queryws", nil)
+	require.NoError(t, err)
+
+	payload := `{"jsonrpc":"2.0","method":"subevents","params":{"topics":["contract:test"]},"id":"sub"}`
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(payload)))
+	var resp eth.JsonRpcResponse
+	require.NoError(t, conn.ReadJSON(&resp))
+	require.Equal(t, `"sub"`, string(*resp.ID))
+	require.Equal(t, "{}", string(resp.Result))
+
+	subscriptions.Publish(pubsub.NewMessage("contract:test", []byte(`{"block_height":"1"}`)))
+	require.NoError(t, conn.ReadJSON(&resp))
+	require.Equal(t, `"0"`, string(*resp.ID))
+	require.JSONEq(t, `{"block_height":"1"}`, string(resp.Result))
+
+	require.NoError(t, conn.Close())
+}
@@ -0,0 +1,50 @@
+// Package loomtx decodes the layers of protobufs Loom txs are wrapped in before they're committed
+// to a block.
+package loomtx
+
+import (
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom/auth"
+	ltypes "github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/go-loom/vm"
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+)
+
+// UnwrappedTx contains the layers a Tendermint tx was unwrapped into, from the outermost to the
+// innermost.
+type UnwrappedTx struct {
+	SignedTx *auth.SignedTx
+	NonceTx  *auth.NonceTx
+	Tx       *ltypes.Transaction
+	Msg      *vm.MessageTx
+}
+
+// Unwrap extracts the signed tx, nonce, Loom tx & message wrapped in the given Tendermint tx.
+func Unwrap(tx types.Tx) (*UnwrappedTx, error) {
+	var signedTx auth.SignedTx
+	if err := proto.Unmarshal([]byte(tx), &signedTx); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal SignedTx")
+	}
+
+	var nonceTx auth.NonceTx
+	if err := proto.Unmarshal(signedTx.Inner, &nonceTx); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal NonceTx")
+	}
+
+	var txTx ltypes.Transaction
+	if err := proto.Unmarshal(nonceTx.Inner, &txTx); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal Transaction")
+	}
+
+	var msg vm.MessageTx
+	if err := proto.Unmarshal(txTx.Data, &msg); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal MessageTx")
+	}
+	return &UnwrappedTx{
+		SignedTx: &signedTx,
+		NonceTx:  &nonceTx,
+		Tx:       &txTx,
+		Msg:      &msg,
+	}, nil
+}
@@ -4,12 +4,14 @@ import (
 	"sync"
 
 	"github.com/gorilla/websocket"
-	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
+	ctypes "github.com/tendermint/tendermint/rpc/core/types"
 
+	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/go-loom/plugin/types"
 
 	"github.com/loomnetwork/loomchain/config"
 	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
 	"github.com/loomnetwork/loomchain/vm"
 )
 
@@ -39,14 +41,14 @@ func (m *MockQueryService) Nonce(key, account string) (uint64, error) {
 	return 0, nil
 }
 
-func (m *MockQueryService) Subscribe(wsCtx rpctypes.WSRPCContext, topics []string) (*WSEmptyResult, error) {
+func (m *MockQueryService) Subscribe(conn *websocket.Conn, topics []string) (*WSEmptyResult, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.MethodsCalled = append([]string{"Subscribe"}, m.MethodsCalled...)
 	return nil, nil
 }
 
-func (m *MockQueryService) UnSubscribe(wsCtx rpctypes.WSRPCContext, topics string) (*WSEmptyResult, error) {
+func (m *MockQueryService) UnSubscribe(conn *websocket.Conn, topics string) (*WSEmptyResult, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.MethodsCalled = append([]string{"UnSubscribe"}, m.MethodsCalled...)
@@ -199,7 +201,7 @@ func (m *MockQueryService) EthNewFilter(filter eth.JsonFilter) (eth.Quantity, er
 }
 
 func (m *MockQueryService) EthSubscribe(
-	conn *websocket.Conn, method eth.Data, filter eth.JsonFilter,
+	conn *websocket.Conn, method eth.Data, filter eth.JsonFilter, options *eth.JsonSubscribeOptions,
 ) (id eth.Data, err error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -256,6 +258,54 @@ func (m *MockQueryService) EthAccounts() ([]eth.Data, error) {
 	return nil, nil
 }
 
+func (m *MockQueryService) EthGetProof(
+	address eth.Data, storageKeys []eth.Data, block eth.BlockHeight,
+) (*eth.JsonAccountProof, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"EthGetProof"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) TxPoolContent() (*eth.JsonTxPoolContent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"TxPoolContent"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) TxPoolStatus() (*eth.JsonTxPoolStatus, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"TxPoolStatus"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) DebugTraceTransaction(hash eth.Data, config *eth.JsonTraceConfig) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"DebugTraceTransaction"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) DebugTraceBlockByNumber(
+	block eth.BlockHeight, config *eth.JsonTraceConfig,
+) ([]eth.JsonTxTrace, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"DebugTraceBlockByNumber"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) DebugTraceCall(
+	query eth.JsonTxCallObject, block eth.BlockHeight, config *eth.JsonTraceConfig,
+) (interface{}, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"DebugTraceCall"}, m.MethodsCalled...)
+	return nil, nil
+}
+
 func (m *MockQueryService) ContractEvents(
 	fromBlock uint64, toBlock uint64, contract string,
 ) (*types.ContractEventsResult, error) {
@@ -275,6 +325,29 @@ func (m *MockQueryService) DPOSTotalStaked() (*DPOSTotalStakedResponse, error) {
 	return nil, nil
 }
 
+func (m *MockQueryService) ContractStateProof(contract, key string, height int64) (*AppStateProof, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"ContractStateProof"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) BalanceProof(contract, owner string, height int64) (*BalanceProof, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"BalanceProof"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) EvmAccountProof(
+	address string, storageKeys []string, height int64,
+) (*EvmAccountProof, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"EvmAccountProof"}, m.MethodsCalled...)
+	return nil, nil
+}
+
 // deprecated function
 func (m *MockQueryService) EvmTxReceipt(txHash []byte) ([]byte, error) {
 	m.mutex.Lock()
@@ -360,7 +433,7 @@ func (m *MockQueryService) GetEvmTransactionByHash(txHash []byte) ([]byte, error
 	return nil, nil
 }
 
-func (m *MockQueryService) EvmSubscribe(wsCtx rpctypes.WSRPCContext, method, filter string) (string, error) {
+func (m *MockQueryService) EvmSubscribe(conn *websocket.Conn, method, filter string) (string, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.MethodsCalled = append([]string{"EvmSubscribe"}, m.MethodsCalled...)
@@ -373,3 +446,45 @@ func (m *MockQueryService) EvmUnSubscribe(id string) (bool, error) {
 	m.MethodsCalled = append([]string{"EvmUnSubscribe"}, m.MethodsCalled...)
 	return true, nil
 }
+
+func (m *MockQueryService) GetBlockByHeight(height *int64) (*ctypes.ResultBlock, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"GetBlockByHeight"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) GetBlockRangeByHeight(minHeight, maxHeight int64) (*ctypes.ResultBlockchainInfo, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"GetBlockRangeByHeight"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) GetBlockResults(height *int64) (*ctypes.ResultBlockResults, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"GetBlockResults"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) GetTxResult(txHash []byte) (*ctypes.ResultTx, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"GetTxResult"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) FilterEvents(filter store.EventFilter) ([]*types.EventData, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"FilterEvents"}, m.MethodsCalled...)
+	return nil, nil
+}
+
+func (m *MockQueryService) ContractNames() (func(loom.Address) string, func()) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.MethodsCalled = append([]string{"ContractNames"}, m.MethodsCalled...)
+	return func(loom.Address) string { return "" }, func() {}
+}
@@ -1,7 +1,9 @@
 package rpc
 
 import (
+	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"math/big"
 	"strconv"
@@ -11,19 +13,20 @@ import (
 	"github.com/gorilla/websocket"
 
 	"github.com/gogo/protobuf/proto"
+	cointypes "github.com/loomnetwork/go-loom/builtin/types/coin"
 	gtypes "github.com/loomnetwork/go-loom/types"
-	sha3 "github.com/miguelmota/go-solidity-sha3"
 	"github.com/phonkee/go-pubsub"
 	"github.com/pkg/errors"
+	"github.com/tendermint/iavl"
 	abci "github.com/tendermint/tendermint/abci/types"
 	ctypes "github.com/tendermint/tendermint/rpc/core/types"
-	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/loomnetwork/go-loom"
 	"github.com/loomnetwork/go-loom/plugin"
 	"github.com/loomnetwork/go-loom/plugin/contractpb"
 	"github.com/loomnetwork/go-loom/plugin/types"
+	"github.com/loomnetwork/go-loom/util"
 	"github.com/loomnetwork/go-loom/vm"
 	"github.com/loomnetwork/loomchain"
 	"github.com/loomnetwork/loomchain/auth"
@@ -59,9 +62,21 @@ const (
 	StatusTxFail    = int32(0)
 )
 
+var (
+	// This is the same key as rootKey in store/multi_writer_app_store.go
+	evmRootKey = []byte("vmroot")
+	// This is the same key as rootHashKey in store/evmstore.go, the EVM root was stored under this
+	// key in app.db prior to the EvmDBFeature being enabled.
+	legacyEvmRootKey = util.PrefixKey([]byte("vm"), []byte("vmroot"))
+	// This is the same prefix as the one used for account keys in the coin & ethcoin contracts
+	coinAccountKeyPrefix = []byte("account")
+)
+
 // StateProvider interface is used by QueryServer to access the read-only application state
 type StateProvider interface {
 	ReadOnlyState() loomchain.State
+	// ReadOnlyStateAt returns the state as it was right after the given block was committed.
+	ReadOnlyStateAt(blockHeader abci.Header) (loomchain.State, error)
 }
 
 // QueryServer provides the ability to query the current state of the DAppChain via RPC.
@@ -136,6 +151,17 @@ type QueryServer struct {
 	Web3Cfg           *eth.Web3Config
 	totalStakedAmount *totalStakedAmount
 	DPOSCfg           *config.DPOSConfig
+	// If this is nil Merkle proofs of the app state won't be available.
+	ProvableStore store.ProvableStore
+	// If this is nil eth_getProof won't be available.
+	EvmRootReader store.EvmRootReader
+	// If this is nil the decoded state of internal Go contracts won't be cached.
+	StateCache *store.StateCache
+	// If this is nil eth_gasPrice will return the gas price specified in Web3Cfg.
+	GasPriceOracle *GasPriceOracle
+	// If this is nil pending txs won't be visible to clients.
+	Mempool    store.Mempool
+	pendingTxs pendingTxIndex
 }
 
 type totalStakedAmount struct {
@@ -261,30 +287,39 @@ func (s *QueryServer) queryEvm(state loomchain.State, caller, contract loom.Addr
 		return nil, errors.Wrap(err, "failed to resolve account address")
 	}
 
-	var createABM levm.AccountBalanceManagerFactoryFunc
-	if s.NewABMFactory != nil {
-		pvm := lcp.NewPluginVM(
-			s.Loader,
-			state,
-			s.CreateRegistry(state),
-			nil,
-			log.Default,
-			s.NewABMFactory,
-			nil,
-			nil,
-		)
-		createABM, err = s.NewABMFactory(pvm)
-		if err != nil {
-			return nil, err
-		}
+	createABM, err := s.createABMFactory(state)
+	if err != nil {
+		return nil, err
 	}
 	vm := levm.NewLoomVm(state, nil, nil, createABM, false)
 	return vm.StaticCall(callerAddr, contract, query)
 }
 
+// createABMFactory returns a factory that creates account balance managers bound to the given state,
+// or nil if the EVM shouldn't use an account balance manager.
+func (s *QueryServer) createABMFactory(state loomchain.State) (levm.AccountBalanceManagerFactoryFunc, error) {
+	if s.NewABMFactory == nil {
+		return nil, nil
+	}
+	pvm := lcp.NewPluginVM(
+		s.Loader,
+		state,
+		s.CreateRegistry(state),
+		nil,
+		log.Default,
+		s.NewABMFactory,
+		nil,
+		nil,
+	)
+	return s.NewABMFactory(pvm)
+}
+
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_call
 func (s *QueryServer) EthCall(query eth.JsonTxCallObject, block eth.BlockHeight) (resp eth.Data, err error) {
-	snapshot := s.StateProvider.ReadOnlyState()
+	snapshot, err := s.getStateAt(block)
+	if err != nil {
+		return resp, err
+	}
 	defer snapshot.Release()
 
 	var caller loom.Address
@@ -306,9 +341,33 @@ func (s *QueryServer) EthCall(query eth.JsonTxCallObject, block eth.BlockHeight)
 		return resp, err
 	}
 	bytes, err := s.queryEvm(snapshot, caller, contract, data)
+	if revertErr, ok := errors.Cause(err).(*levm.RevertError); ok {
+		return resp, &ethRevertError{revertErr}
+	}
 	return eth.EncBytes(bytes), err
 }
 
+// ethRevertError returns the revert reason & data of a reverted EVM call to Web3 clients in the same
+// format as Geth.
+type ethRevertError struct {
+	*levm.RevertError
+}
+
+func (e *ethRevertError) Error() string {
+	if reason, err := levm.UnpackRevertReason(e.Data); err == nil {
+		return "execution reverted: " + reason
+	}
+	return "execution reverted"
+}
+
+func (e *ethRevertError) ErrorCode() eth.ErrorCode {
+	return eth.EcExecutionReverted
+}
+
+func (e *ethRevertError) ErrorData() interface{} {
+	return eth.EncBytes(e.Data)
+}
+
 // GetCode returns the runtime byte-code of a contract running on a DAppChain's EVM.
 // Gives an error for non-EVM contracts.
 // contract - address of the contract in the form of a string. (Use loom.Address.String() to convert)
@@ -334,7 +393,10 @@ func (s *QueryServer) EthGetCode(address eth.Data, block eth.BlockHeight) (eth.D
 		return "", errors.Wrapf(err, "decoding input address parameter %v", address)
 	}
 
-	snapshot := s.StateProvider.ReadOnlyState()
+	snapshot, err := s.getStateAt(block)
+	if err != nil {
+		return "", err
+	}
 	defer snapshot.Release()
 
 	evm := levm.NewLoomVm(snapshot, nil, nil, nil, false)
@@ -376,6 +438,16 @@ func (s *QueryServer) createStaticContractCtx(state loomchain.State, name string
 	if err != nil {
 		return nil, errors.Wrapf(err, "failed to create %s context", name)
 	}
+	if s.StateCache != nil {
+		// The block header of a read-only state isn't obtained atomically with the underlying store
+		// snapshot, so the cache height must come from the snapshot, otherwise stale values could end
+		// up in the cache.
+		if snap, ok := state.(*loomchain.StoreStateSnapshot); ok {
+			if version, ok := snap.StoreVersion(); ok {
+				return lcp.NewCachingStaticContext(ctx, s.StateCache, version), nil
+			}
+		}
+	}
 	return ctx, nil
 }
 
@@ -437,75 +509,92 @@ func decodeHexAddress(s string) ([]byte, error) {
 
 type WSEmptyResult struct{}
 
-func writer(ctx rpctypes.WSRPCContext, subs *loomchain.SubscriptionSet) pubsub.SubscriberFunc {
-	clientCtx := ctx
-	log.Debug("Adding handler", "remote", clientCtx.GetRemoteAddr())
+// wsStringID returns the given string encoded as a JSON-RPC request ID.
+func wsStringID(id string) *json.RawMessage {
+	rawID := json.RawMessage(strconv.Quote(id))
+	return &rawID
+}
+
+// writeWSResponse sends a JSON-RPC response to a websocket connection via the given sender, legacy
+// subscribers receive events in the same way as eth_subscribe subscribers, just in a different
+// envelope. Events must not be written to the connection directly, since the connection may only
+// be written to by one goroutine at a time.
+func writeWSResponse(sender subs.EventSender, resp interface{}) {
+	outBytes, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		log.Error("Failed to marshal WSEvent", "err", err)
+		return
+	}
+	if !sender.Send(outBytes) {
+		log.Debug("Failed to write WSEvent, client disconnected")
+	}
+}
+
+func wsPanicHandler(conn *websocket.Conn, sender subs.EventSender, purge func(string)) {
+	if r := recover(); r != nil {
+		log.Error("Caught: WSEvent handler routine panic", "error", r)
+		writeWSResponse(sender, eth.JsonRpcErrorResponse{
+			Version: "2.0",
+			ID:      wsStringID(""),
+			Error:   *eth.NewError(eth.EcInternal, "Internal error", "Caught: WSEvent handler routine panic"),
+		})
+		go purge(conn.RemoteAddr().String())
+	}
+}
+
+func writer(conn *websocket.Conn, subs *loomchain.SubscriptionSet) pubsub.SubscriberFunc {
+	log.Debug("Adding handler", "remote", conn.RemoteAddr().String())
+	sender := eventSender(conn)
 	return func(msg pubsub.Message) {
-		log.Debug("Received published message", "msg", msg.Body(), "remote", clientCtx.GetRemoteAddr())
-		defer func() {
-			if r := recover(); r != nil {
-				log.Error("Caught: WSEvent handler routine panic", "error", r)
-				err := fmt.Errorf("Caught: WSEvent handler routine panic")
-				clientCtx.WriteRPCResponse(rpctypes.RPCInternalError(rpctypes.JSONRPCStringID(""), err))
-				go subs.Purge(clientCtx.GetRemoteAddr())
-			}
-		}()
-		resp := rpctypes.RPCResponse{
-			JSONRPC: "2.0",
-			ID:      rpctypes.JSONRPCStringID("0"),
-		}
-		resp.Result = msg.Body()
-		clientCtx.TryWriteRPCResponse(resp)
+		log.Debug("Received published message", "msg", msg.Body(), "remote", conn.RemoteAddr().String())
+		defer wsPanicHandler(conn, sender, subs.Purge)
+		writeWSResponse(sender, eth.JsonRpcResponse{
+			Version: "2.0",
+			ID:      wsStringID("0"),
+			Result:  msg.Body(),
+		})
 	}
 }
 
-func (s *QueryServer) Subscribe(wsCtx rpctypes.WSRPCContext, topics []string) (*WSEmptyResult, error) {
+func (s *QueryServer) Subscribe(conn *websocket.Conn, topics []string) (*WSEmptyResult, error) {
 	if len(topics) == 0 {
 		topics = append(topics, "contract")
 	}
-	caller := wsCtx.GetRemoteAddr()
+	caller := conn.RemoteAddr().String()
 	sub, existed := s.Subscriptions.For(caller)
 
 	if !existed {
-		sub.Do(writer(wsCtx, s.Subscriptions))
+		sub.Do(writer(conn, s.Subscriptions))
 	}
 	return &WSEmptyResult{}, s.Subscriptions.AddSubscription(caller, topics)
 }
 
-func (s *QueryServer) UnSubscribe(wsCtx rpctypes.WSRPCContext, topic string) (*WSEmptyResult, error) {
-	return &WSEmptyResult{}, s.Subscriptions.Remove(wsCtx.GetRemoteAddr(), topic)
+func (s *QueryServer) UnSubscribe(conn *websocket.Conn, topic string) (*WSEmptyResult, error) {
+	return &WSEmptyResult{}, s.Subscriptions.Remove(conn.RemoteAddr().String(), topic)
 }
 
-func ethWriter(ctx rpctypes.WSRPCContext, subs *subs.LegacyEthSubscriptionSet) pubsub.SubscriberFunc {
-	clientCtx := ctx
-	log.Debug("Adding handler", "remote", clientCtx.GetRemoteAddr())
+func ethWriter(conn *websocket.Conn, subs *subs.LegacyEthSubscriptionSet) pubsub.SubscriberFunc {
+	log.Debug("Adding handler", "remote", conn.RemoteAddr().String())
+	sender := eventSender(conn)
 	return func(msg pubsub.Message) {
-		log.Debug("Received published message", "msg", msg.Body(), "remote", clientCtx.GetRemoteAddr())
-		defer func() {
-			if r := recover(); r != nil {
-				log.Error("Caught: WSEvent handler routine panic", "error", r)
-				err := fmt.Errorf("Caught: WSEvent handler routine panic")
-				clientCtx.WriteRPCResponse(rpctypes.RPCInternalError(rpctypes.JSONRPCStringID(""), err))
-				go subs.Purge(clientCtx.GetRemoteAddr())
-			}
-		}()
+		log.Debug("Received published message", "msg", msg.Body(), "remote", conn.RemoteAddr().String())
+		defer wsPanicHandler(conn, sender, subs.Purge)
 		ethMsg := types.EthMessage{}
 		if err := proto.Unmarshal(msg.Body(), &ethMsg); err != nil {
 			return
 		}
-		resp := rpctypes.RPCResponse{
-			JSONRPC: "2.0",
-			ID:      rpctypes.JSONRPCStringID(ethMsg.Id),
-		}
-		resp.Result = ethMsg.Body
-		clientCtx.TryWriteRPCResponse(resp)
+		writeWSResponse(sender, eth.JsonRpcResponse{
+			Version: "2.0",
+			ID:      wsStringID(ethMsg.Id),
+			Result:  ethMsg.Body,
+		})
 	}
 }
 
-func (s *QueryServer) EvmSubscribe(wsCtx rpctypes.WSRPCContext, method, filter string) (string, error) {
-	caller := wsCtx.GetRemoteAddr()
+func (s *QueryServer) EvmSubscribe(conn *websocket.Conn, method, filter string) (string, error) {
+	caller := conn.RemoteAddr().String()
 	sub, id := s.EthLegacySubscriptions.For(caller)
-	sub.Do(ethWriter(wsCtx, s.EthLegacySubscriptions))
+	sub.Do(ethWriter(conn, s.EthLegacySubscriptions))
 	err := s.EthLegacySubscriptions.AddSubscription(id, method, filter)
 	if err != nil {
 		return "", err
@@ -640,6 +729,253 @@ func (s *QueryServer) DPOSTotalStaked() (*DPOSTotalStakedResponse, error) {
 	}, nil
 }
 
+// AppStateProof contains the value of an app.db key at a specific height, along with an IAVL range
+// proof of the existence (or absence) of the key. The proof can be verified against the app hash at
+// that height, which is included in the header of the next block.
+type AppStateProof struct {
+	Height  int64
+	AppHash []byte
+	Key     []byte
+	Value   []byte
+	Proof   *iavl.RangeProof
+}
+
+// BalanceProof contains the balance of an account in the coin or ethcoin contract, along with a
+// proof of the account record the balance was obtained from.
+type BalanceProof struct {
+	Balance *gtypes.BigUInt
+	Proof   *AppStateProof
+}
+
+// EvmAccountProof contains a proof of the Patricia root of the EVM state stored in app.db, and a
+// proof of an EVM account (and some of its storage slots) in the trie with that root.
+type EvmAccountProof struct {
+	RootProof *AppStateProof
+	Account   eth.JsonAccountProof
+}
+
+// ContractStateProof returns the value of a key in the state of a Go contract at the given height,
+// along with a proof that can be verified against the app hash at that height. If the height is
+// zero the last committed height is used.
+// contract - hex-encoded local address of the contract prefixed by 0x
+// key - hex-encoded key prefixed by 0x
+func (s *QueryServer) ContractStateProof(contract, key string, height int64) (*AppStateProof, error) {
+	localContractAddr, err := decodeHexAddress(contract)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid contract address")
+	}
+	contractAddr := loom.Address{
+		ChainID: s.ChainID,
+		Local:   localContractAddr,
+	}
+	k, err := decodeHexAddress(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid key")
+	}
+	return s.getAppStateProof(util.PrefixKey(loom.DataPrefix(contractAddr), k), height)
+}
+
+// BalanceProof returns the balance of the given account in the coin or ethcoin contract at the given
+// height, along with a proof that can be verified against the app hash at that height. If the height
+// is zero the last committed height is used.
+// contract - name of the contract, either coin or ethcoin
+// owner - address of the account in the form of a string (see loom.Address.String())
+func (s *QueryServer) BalanceProof(contract, owner string, height int64) (*BalanceProof, error) {
+	if contract != "coin" && contract != "ethcoin" {
+		return nil, fmt.Errorf("balance proofs are not supported for contract %s", contract)
+	}
+	ownerAddr, err := loom.ParseAddress(owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid owner address")
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	contractAddr, err := s.CreateRegistry(snapshot).Resolve(contract)
+	snapshot.Release()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to resolve %s contract address", contract)
+	}
+
+	key := util.PrefixKey(loom.DataPrefix(contractAddr), util.PrefixKey(coinAccountKeyPrefix, ownerAddr.Bytes()))
+	proof, err := s.getAppStateProof(key, height)
+	if err != nil {
+		return nil, err
+	}
+	balance := &gtypes.BigUInt{Value: *loom.NewBigUIntFromInt(0)}
+	if proof.Value != nil {
+		var account cointypes.Account
+		if err := proto.Unmarshal(proof.Value, &account); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal account")
+		}
+		if account.Balance != nil {
+			balance = account.Balance
+		}
+	}
+	return &BalanceProof{
+		Balance: balance,
+		Proof:   proof,
+	}, nil
+}
+
+// EvmAccountProof returns a proof of the EVM account at the given address, and of the given storage
+// slots of the account, at the given height. If the height is zero the last committed height is used.
+// The account proof can be verified against the EVM root in the returned root proof, which can in
+// turn be verified against the app hash at that height.
+// address - hex-encoded address of the account prefixed by 0x
+// storageKeys - hex-encoded storage slots prefixed by 0x
+func (s *QueryServer) EvmAccountProof(address string, storageKeys []string, height int64) (*EvmAccountProof, error) {
+	addr, err := eth.DecDataToAddress(s.ChainID, eth.Data(address))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid address")
+	}
+	keys := make([][]byte, 0, len(storageKeys))
+	for _, storageKey := range storageKeys {
+		key, err := eth.DecDataToBytes(eth.Data(storageKey))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid storage key")
+		}
+		keys = append(keys, key)
+	}
+
+	rootProof, err := s.getAppStateProof(evmRootKey, height)
+	if err != nil {
+		return nil, err
+	}
+	if rootProof.Value == nil {
+		legacyRootProof, err := s.getAppStateProof(legacyEvmRootKey, rootProof.Height)
+		if err != nil {
+			return nil, err
+		}
+		if legacyRootProof.Value != nil {
+			rootProof = legacyRootProof
+		}
+	}
+	root := rootProof.Value
+	// An empty EVM state is represented by []byte{1} in app.db
+	if bytes.Equal(root, []byte{1}) {
+		root = nil
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	defer snapshot.Release()
+
+	accountProof, err := levm.GetAccountProof(snapshot, root, addr, keys)
+	if err != nil {
+		return nil, err
+	}
+	return &EvmAccountProof{
+		RootProof: rootProof,
+		Account:   encAccountProof(accountProof),
+	}, nil
+}
+
+// EthGetProof returns a proof of the EVM account at the given address, and of the given storage
+// slots of the account, built from the Patricia trie of the EVM state at the given block height.
+// https://github.com/ethereum/EIPs/blob/master/EIPS/eip-1186.md
+func (s *QueryServer) EthGetProof(
+	address eth.Data, storageKeys []eth.Data, block eth.BlockHeight,
+) (*eth.JsonAccountProof, error) {
+	if s.EvmRootReader == nil {
+		return nil, errors.New("EVM state proofs are not available on this node")
+	}
+	addr, err := eth.DecDataToAddress(s.ChainID, address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding input address parameter %v", address)
+	}
+	keys := make([][]byte, 0, len(storageKeys))
+	for _, storageKey := range storageKeys {
+		key, err := eth.DecDataToBytes(storageKey)
+		if err != nil {
+			return nil, errors.Wrapf(err, "decoding storage key %v", storageKey)
+		}
+		keys = append(keys, key)
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	defer snapshot.Release()
+
+	lastHeight := snapshot.Block().Height
+	height, err := eth.DecBlockHeight(lastHeight, block)
+	if err != nil {
+		return nil, err
+	}
+	// the state of the pending block isn't available yet
+	if int64(height) > lastHeight {
+		height = uint64(lastHeight)
+	}
+
+	root, err := s.EvmRootReader.GetEvmRootAt(int64(height))
+	if err != nil {
+		return nil, err
+	}
+	proof, err := levm.GetAccountProof(snapshot, root, addr, keys)
+	if err != nil {
+		return nil, err
+	}
+	resp := encAccountProof(proof)
+	return &resp, nil
+}
+
+func (s *QueryServer) getAppStateProof(key []byte, height int64) (*AppStateProof, error) {
+	if s.ProvableStore == nil {
+		return nil, store.ErrProofsNotSupported
+	}
+
+	snapshot := s.StateProvider.ReadOnlyState()
+	lastHeight := snapshot.Block().Height
+	snapshot.Release()
+
+	// The app hash of the state at a given height is only committed to in the header of the next
+	// block, which may not exist yet for the last committed height.
+	if height == 0 {
+		height = lastHeight - 1
+	}
+	if height <= 0 || height > lastHeight {
+		return nil, fmt.Errorf("invalid height %d, last committed height is %d", height, lastHeight)
+	}
+	nextHeight := height + 1
+	nextBlock, err := s.BlockStore.GetBlockByHeight(&nextHeight)
+	if err != nil {
+		return nil, errors.Wrapf(err, "app hash at height %d hasn't been committed to a block yet", height)
+	}
+	appHash := nextBlock.Block.Header.AppHash
+
+	value, proof, err := s.ProvableStore.GetVersionedWithProof(key, height)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(proof.ComputeRootHash(), appHash) {
+		return nil, fmt.Errorf("proof root doesn't match the app hash committed at height %d", height)
+	}
+	return &AppStateProof{
+		Height:  height,
+		AppHash: appHash,
+		Key:     key,
+		Value:   value,
+		Proof:   proof,
+	}, nil
+}
+
+func encAccountProof(proof *levm.AccountProof) eth.JsonAccountProof {
+	storageProof := make([]eth.JsonStorageProof, 0, len(proof.StorageProof))
+	for _, sp := range proof.StorageProof {
+		storageProof = append(storageProof, eth.JsonStorageProof{
+			Key:   eth.EncBytes(sp.Key),
+			Value: eth.EncBigInt(*new(big.Int).SetBytes(sp.Value)),
+			Proof: eth.EncBytesArray(sp.Proof),
+		})
+	}
+	return eth.JsonAccountProof{
+		Address:      eth.EncBytes(proof.Address),
+		AccountProof: eth.EncBytesArray(proof.Proof),
+		Balance:      eth.EncBigInt(*proof.Balance),
+		CodeHash:     eth.EncBytes(proof.CodeHash),
+		Nonce:        eth.EncUint(proof.Nonce),
+		StorageHash:  eth.EncBytes(proof.StorageHash),
+		StorageProof: storageProof,
+	}
+}
+
 // Takes a filter and returns a list of data relative to transactions that satisfies the filter
 // Used to support eth_getLogs
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getlogs
@@ -836,11 +1172,12 @@ func (s *QueryServer) EthGetTransactionReceipt(hash eth.Data) (*eth.JsonTxReceip
 			}
 			return completeReceipt(
 				blockResults.Results.DeliverTx[txReceipt.TransactionIndex], blockResult, &txReceipt,
+				getRevertData(r, txHash),
 			), nil
 		}
 		return nil, err
 	}
-	return completeReceipt(&txResults.TxResult, blockResult, &txReceipt), nil
+	return completeReceipt(&txResults.TxResult, blockResult, &txReceipt, getRevertData(r, txHash)), nil
 }
 
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getblocktransactioncountbyhash
@@ -915,6 +1252,11 @@ func (s *QueryServer) EthGetTransactionByHash(hash eth.Data) (resp eth.JsonTxObj
 
 		txObj, err = getTxByTendermintHash(s.BlockStore, txHash, s.EvmAuxStore)
 		if err != nil {
+			// the tx may not have been committed to a block yet
+			ptx, errPending := s.getPendingTx(txHash)
+			if errPending == nil && ptx != nil {
+				return ptx.txObj, nil
+			}
 			return resp, errors.Wrapf(err, "failed to find tx with hash %v", txHash)
 		}
 	}
@@ -978,7 +1320,7 @@ func (s *QueryServer) EthGetLogs(filter eth.JsonFilter) (resp []eth.JsonLog, err
 	//       block store.
 	logs, err := query.QueryChain(
 		s.BlockStore, snapshot, ethFilter, s.ReceiptHandlerProvider.Reader(), s.EvmAuxStore,
-		s.Web3Cfg.GetLogsMaxBlockRange,
+		s.Web3Cfg.GetLogsMaxBlockRange, s.Web3Cfg.GetLogsMaxResults,
 	)
 	if err != nil {
 		return resp, err
@@ -1037,15 +1379,47 @@ func (s *QueryServer) EthNewFilter(filter eth.JsonFilter) (eth.Quantity, error)
 	return eth.Quantity(id), err
 }
 
-func (s *QueryServer) EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (eth.Data, error) {
+// EthSubscribe implements https://github.com/ethereum/go-ethereum/wiki/RPC-PUB-SUB#create-subscription
+// Each event sent to the subscriber includes a resume token, if the client reconnects it can pass
+// the token of the last event it received in the options to have any missed logs or newHeads
+// events replayed.
+func (s *QueryServer) EthSubscribe(
+	conn *websocket.Conn, method eth.Data, filter eth.JsonFilter, options *eth.JsonSubscribeOptions,
+) (eth.Data, error) {
 	f, err := eth.DecLogFilter(filter)
 	if err != nil {
 		return "", errors.Wrapf(err, "decode filter")
 	}
-	id, err := s.EthSubscriptions.AddSubscription(string(method), f, conn)
+	var token *subs.ResumeToken
+	if options != nil && options.ResumeToken != "" {
+		t, err := subs.ParseResumeToken(options.ResumeToken)
+		if err != nil {
+			return "", err
+		}
+		token = &t
+	}
+
+	// The subscription is added before the missed events are loaded so no events are lost in between,
+	// new events are held back until the missed events have been sent.
+	id, err := s.EthSubscriptions.AddSubscription(
+		string(method), f, eventSender(conn), s.subscriptionOptions(token != nil),
+	)
 	if err != nil {
 		return "", errors.Wrapf(err, "add subscription")
 	}
+	if token != nil {
+		snapshot := s.StateProvider.ReadOnlyState()
+		defer snapshot.Release()
+
+		events, err := s.loadMissedEvents(snapshot, string(method), f, *token)
+		if err == nil {
+			err = s.EthSubscriptions.Replay(id, events, uint64(snapshot.Block().Height))
+		}
+		if err != nil {
+			s.EthSubscriptions.Remove(id)
+			return "", errors.Wrap(err, "resume subscription")
+		}
+	}
 	return eth.Data(id), nil
 }
 
@@ -1058,31 +1432,32 @@ func (s *QueryServer) EthUnsubscribe(id eth.Quantity) (unsubscribed bool, err er
 // The input address is assumed to be an Ethereum account address, so it'll be mapped to a local
 // account, and the transaction count returned will be for that local account.
 func (s *QueryServer) EthGetTransactionCount(address eth.Data, block eth.BlockHeight) (eth.Quantity, error) {
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
-
-	resolvedAddr, err := s.getEthAccount(snapshot, address)
+	// Loom nodes don't expose pending state to clients, so when eth_getTransactionCount is called
+	// with "pending" the latest nonce based on the last committed block is adjusted to account for
+	// any txs from the same account that are still in the mempool.
+	snapshot, err := s.getStateAt(block)
 	if err != nil {
 		return eth.ZeroedQuantity, err
 	}
+	defer snapshot.Release()
 
-	// Currently loom nodes don't expose pending state to clients, but various web3 libs may call
-	// eth_getTransactionCount with "pending" so to make them work we just return the latest nonce
-	// based on the last committed block.
-	if block == "pending" {
-		block = "latest"
-	}
-
-	height, err := eth.DecBlockHeight(snapshot.Block().Height, block)
+	resolvedAddr, err := s.getEthAccount(snapshot, address)
 	if err != nil {
 		return eth.ZeroedQuantity, err
 	}
 
-	if height != uint64(snapshot.Block().Height) {
-		return eth.ZeroedQuantity, errors.New("transaction count only available for the latest block")
+	nonce := auth.Nonce(snapshot, resolvedAddr)
+	if block == "pending" {
+		ethAddr, err := eth.DecDataToAddress("eth", address)
+		if err != nil {
+			return eth.ZeroedQuantity, err
+		}
+		nonce, err = s.getPendingNonce(nonce, ethAddr, resolvedAddr)
+		if err != nil {
+			return eth.ZeroedQuantity, err
+		}
 	}
-
-	return eth.EncUint(auth.Nonce(snapshot, resolvedAddr)), nil
+	return eth.EncUint(nonce), nil
 }
 
 // https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_getbalance
@@ -1093,15 +1468,11 @@ func (s *QueryServer) EthGetBalance(address eth.Data, block eth.BlockHeight) (et
 		return "", errors.Wrapf(err, "decoding input address parameter %v", address)
 	}
 
-	snapshot := s.StateProvider.ReadOnlyState()
-	defer snapshot.Release()
-	height, err := eth.DecBlockHeight(snapshot.Block().Height, block)
+	snapshot, err := s.getStateAt(block)
 	if err != nil {
-		return "", errors.Wrapf(err, "invalid block height %s", block)
-	}
-	if int64(height) != snapshot.Block().Height {
-		return "", errors.Errorf("height %s not latest", block)
+		return "", err
 	}
+	defer snapshot.Release()
 
 	ctx, err := s.createStaticContractCtx(snapshot, "ethcoin")
 	if err != nil {
@@ -1128,42 +1499,153 @@ func (s *QueryServer) EthGetStorageAt(local eth.Data, position string, block eth
 		return "", errors.Wrapf(err, "failed to decode address parameter %v", local)
 	}
 
-	snapshot := s.StateProvider.ReadOnlyState()
+	snapshot, err := s.getStateAt(block)
+	if err != nil {
+		return "", err
+	}
 	defer snapshot.Release()
 
-	if block == "" {
+	evm := levm.NewLoomVm(snapshot, nil, nil, nil, false)
+	storage, err := evm.GetStorageAt(address, ethcommon.HexToHash(position).Bytes())
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get EVM storage at %v", address.Local.String())
+	}
+	return eth.EncBytes(storage), nil
+}
+
+// getStateAt returns a read-only snapshot of the app state as it was right after the block at the
+// given height was committed, the caller is responsible for releasing the snapshot.
+// The state of the pending block isn't exposed to clients, so "pending" (and an empty block height)
+// is treated the same way as "latest".
+func (s *QueryServer) getStateAt(block eth.BlockHeight) (loomchain.State, error) {
+	if block == "" || block == "pending" {
 		block = "latest"
 	}
 
-	height, err := eth.DecBlockHeight(snapshot.Block().Height, block)
+	snapshot := s.StateProvider.ReadOnlyState()
+	lastHeight := snapshot.Block().Height
+	height, err := eth.DecBlockHeight(lastHeight, block)
 	if err != nil {
-		return "", errors.Wrapf(err, "invalid block height %s", block)
+		snapshot.Release()
+		return nil, errors.Wrapf(err, "invalid block height %s", block)
 	}
-	if int64(height) != snapshot.Block().Height {
-		return "", errors.Wrapf(err, "unable to get storage at height %v", block)
+	if int64(height) == lastHeight {
+		return snapshot, nil
 	}
+	snapshot.Release()
 
-	evm := levm.NewLoomVm(snapshot, nil, nil, nil, false)
-	storage, err := evm.GetStorageAt(address, ethcommon.HexToHash(position).Bytes())
+	if int64(height) > lastHeight {
+		return nil, errors.Errorf("state at height %d isn't available yet, latest height is %d", height, lastHeight)
+	}
+
+	h := int64(height)
+	blockResult, err := s.BlockStore.GetBlockByHeight(&h)
 	if err != nil {
-		return "", errors.Wrapf(err, "failed to get EVM storage at %v", address.Local.String())
+		return nil, errors.Wrapf(err, "failed to load block %d", h)
 	}
-	return eth.EncBytes(storage), nil
+	state, err := s.StateProvider.ReadOnlyStateAt(abci.Header{
+		ChainID: blockResult.Block.Header.ChainID,
+		Height:  h,
+		Time:    blockResult.Block.Header.Time,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load state at height %d", h)
+	}
+	return state, nil
 }
 
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_estimategas
+// Executes the call (or contract deployment if no "to" address is specified) against the latest
+// app state, and returns the amount of gas the tx should be sent with, none of the changes made
+// by the call are persisted.
 func (s *QueryServer) EthEstimateGas(query eth.JsonTxCallObject) (eth.Quantity, error) {
-	return eth.Quantity("0x0"), nil
+	snapshot, err := s.getStateAt("latest")
+	if err != nil {
+		return eth.ZeroedQuantity, err
+	}
+	defer snapshot.Release()
+
+	tx, err := s.decodeTxCallObject(snapshot, query)
+	if err != nil {
+		return eth.ZeroedQuantity, err
+	}
+
+	gasCap := s.Web3Cfg.EstimateGasCap
+	if len(query.Gas) > 0 {
+		gas, err := eth.DecQuantityToUint(query.Gas)
+		if err != nil {
+			return eth.ZeroedQuantity, errors.Wrapf(err, "invalid gas %v", query.Gas)
+		}
+		if gasCap == 0 || (gas > 0 && gas < gasCap) {
+			gasCap = gas
+		}
+	}
+
+	gas, err := levm.EstimateGas(snapshot, s.createABMFactory, tx.caller, tx.to, tx.input, tx.value, gasCap)
+	if err != nil {
+		return eth.ZeroedQuantity, err
+	}
+	return eth.EncUint(gas), nil
+}
+
+// decodeTxCallObject extracts the EVM contract deployment or call from the given call object, the
+// caller address is resolved using the given state.
+func (s *QueryServer) decodeTxCallObject(state loomchain.State, query eth.JsonTxCallObject) (*evmTx, error) {
+	var err error
+	caller := loom.RootAddress(s.ChainID)
+	if len(query.From) > 0 {
+		caller, err = s.getEthAccount(state, query.From)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tx := &evmTx{}
+	tx.caller, err = auth.ResolveAccountAddress(caller, state, s.AuthCfg, s.createAddressMapperCtx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve account address")
+	}
+
+	if len(query.To) > 0 {
+		to, err := eth.DecDataToAddress(s.ChainID, query.To)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid to address %v", query.To)
+		}
+		tx.to = &to
+	}
+	if len(query.Data) > 2 {
+		tx.input, err = eth.DecDataToBytes(query.Data)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid data")
+		}
+	}
+	if len(query.Value) > 0 {
+		value, err := eth.DecQuantityToBigInt(query.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid value %v", query.Value)
+		}
+		tx.value = loom.NewBigUInt(value)
+	}
+	return tx, nil
 }
 
+// https://github.com/ethereum/wiki/wiki/JSON-RPC#eth_gasprice
 func (s *QueryServer) EthGasPrice() (eth.Quantity, error) {
-	return eth.Quantity("0x0"), nil
+	if s.GasPriceOracle == nil {
+		return eth.EncUint(s.Web3Cfg.GasPrice), nil
+	}
+	snapshot := s.StateProvider.ReadOnlyState()
+	height := snapshot.Block().Height
+	snapshot.Release()
+
+	price, err := s.GasPriceOracle.SuggestPrice(height)
+	if err != nil {
+		return eth.ZeroedQuantity, err
+	}
+	return eth.EncBigInt(*price), nil
 }
 
 func (s *QueryServer) EthNetVersion() (string, error) {
-	hash := sha3.SoliditySHA3(sha3.String(s.ChainID))
-	versionBigInt := new(big.Int)
-	versionBigInt.SetString(hex.EncodeToString(hash)[0:13], 16)
-	return versionBigInt.String(), nil
+	return levm.EthChainID(s.ChainID).String(), nil
 }
 
 func (s *QueryServer) EthAccounts() ([]eth.Data, error) {
@@ -1236,11 +1718,22 @@ func getReceiptByTendermintHash(
 
 		return &jsonReceipt, nil
 	}
-	return completeReceipt(&txResults.TxResult, blockResult, &txReceipt), nil
+	return completeReceipt(&txResults.TxResult, blockResult, &txReceipt, getRevertData(rh, txHash)), nil
+}
+
+// getRevertData returns the data returned by the reverted tx with the given hash, or nil if the tx
+// wasn't reverted or the data can't be loaded.
+func getRevertData(rh loomchain.ReadReceiptHandler, txHash []byte) []byte {
+	data, err := rh.GetRevertData(txHash)
+	if err != nil {
+		return nil
+	}
+	return data
 }
 
 func completeReceipt(
 	txResult *abci.ResponseDeliverTx, blockResult *ctypes.ResultBlock, txReceipt *types.EvmTxReceipt,
+	revertData []byte,
 ) *eth.JsonTxReceipt {
 	if len(txReceipt.Logs) > 0 {
 		timestamp := blockResult.Block.Header.Time.Unix()
@@ -1254,6 +1747,9 @@ func completeReceipt(
 		txReceipt.Status = StatusTxFail
 	}
 	jsonReceipt := eth.EncTxReceipt(*txReceipt)
+	if txReceipt.Status == StatusTxFail && len(revertData) > 0 {
+		jsonReceipt.RevertReason = eth.EncBytes(revertData)
+	}
 	if txResult.Info == utils.CallEVM && (jsonReceipt.To == nil || len(*jsonReceipt.To) == 0) {
 		jsonReceipt.To = jsonReceipt.ContractAddress
 		jsonReceipt.ContractAddress = nil
@@ -101,6 +101,10 @@ func (s *stateProvider) ReadOnlyState() loomchain.State {
 	)
 }
 
+func (s *stateProvider) ReadOnlyStateAt(blockHeader abci.Header) (loomchain.State, error) {
+	return nil, errors.New("historical state not available")
+}
+
 var testlog llog.TMLogger
 
 func TestQueryServer(t *testing.T) {
@@ -129,7 +133,7 @@ func testQueryServerContractQuery(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
@@ -184,7 +188,7 @@ func testQueryServerNonce(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
@@ -254,7 +258,7 @@ func testQueryMetric(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
@@ -333,7 +337,7 @@ func testQueryServerContractEvents(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
@@ -420,7 +424,7 @@ func testQueryServerContractEventsNoEventStore(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
@@ -453,7 +457,7 @@ func testQueryServerGetContractRecord(t *testing.T) {
 		Subs:    *loomchain.NewSubscriptionSet(),
 		EthSubs: *subs.NewLegacyEthSubscriptionSet(),
 	}
-	handler := MakeQueryServiceHandler(qs, testlog, bus)
+	handler := MakeQueryServiceHandler(qs, testlog, bus, nil)
 	ts := httptest.NewServer(handler)
 	defer ts.Close()
 	// give the server some time to spin up
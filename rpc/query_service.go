@@ -9,7 +9,6 @@ import (
 	"github.com/tendermint/tendermint/libs/pubsub"
 	rpccore "github.com/tendermint/tendermint/rpc/core"
 	rpcserver "github.com/tendermint/tendermint/rpc/lib/server"
-	rpctypes "github.com/tendermint/tendermint/rpc/lib/types"
 	"golang.org/x/net/context"
 
 	"github.com/loomnetwork/go-loom/plugin/types"
@@ -18,6 +17,7 @@ import (
 	"github.com/loomnetwork/loomchain/eth/subs"
 	"github.com/loomnetwork/loomchain/log"
 	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/rpc/explorer"
 	"github.com/loomnetwork/loomchain/vm"
 )
 
@@ -26,8 +26,8 @@ type QueryService interface {
 	Query(caller, contract string, query []byte, vmType vm.VMType) ([]byte, error)
 	Resolve(name string) (string, error)
 	Nonce(key, account string) (uint64, error)
-	Subscribe(wsCtx rpctypes.WSRPCContext, topics []string) (*WSEmptyResult, error)
-	UnSubscribe(wsCtx rpctypes.WSRPCContext, topics string) (*WSEmptyResult, error)
+	Subscribe(conn *websocket.Conn, topics []string) (*WSEmptyResult, error)
+	UnSubscribe(conn *websocket.Conn, topics string) (*WSEmptyResult, error)
 	QueryEnv() (*config.EnvInfo, error)
 	// New JSON web3 methods
 	EthBlockNumber() (eth.Quantity, error)
@@ -51,7 +51,9 @@ type QueryService interface {
 	EthGetFilterLogs(id eth.Quantity) (interface{}, error)
 
 	EthNewFilter(filter eth.JsonFilter) (eth.Quantity, error)
-	EthSubscribe(conn *websocket.Conn, method eth.Data, filter eth.JsonFilter) (id eth.Data, err error)
+	EthSubscribe(
+		conn *websocket.Conn, method eth.Data, filter eth.JsonFilter, options *eth.JsonSubscribeOptions,
+	) (id eth.Data, err error)
 	EthUnsubscribe(id eth.Quantity) (unsubscribed bool, err error)
 
 	EthGetBalance(address eth.Data, block eth.BlockHeight) (eth.Quantity, error)
@@ -60,11 +62,28 @@ type QueryService interface {
 	EthNetVersion() (string, error)
 	EthGetTransactionCount(local eth.Data, block eth.BlockHeight) (eth.Quantity, error)
 	EthAccounts() ([]eth.Data, error)
+	EthGetProof(address eth.Data, storageKeys []eth.Data, block eth.BlockHeight) (*eth.JsonAccountProof, error)
+
+	// Txs in the mempool
+	TxPoolContent() (*eth.JsonTxPoolContent, error)
+	TxPoolStatus() (*eth.JsonTxPoolStatus, error)
+
+	// Tracing of EVM txs
+	DebugTraceTransaction(hash eth.Data, config *eth.JsonTraceConfig) (interface{}, error)
+	DebugTraceBlockByNumber(block eth.BlockHeight, config *eth.JsonTraceConfig) ([]eth.JsonTxTrace, error)
+	DebugTraceCall(
+		query eth.JsonTxCallObject, block eth.BlockHeight, config *eth.JsonTraceConfig,
+	) (interface{}, error)
 
 	ContractEvents(fromBlock uint64, toBlock uint64, contract string) (*types.ContractEventsResult, error)
 	GetContractRecord(contractAddr string) (*types.ContractRecordResponse, error)
 	DPOSTotalStaked() (*DPOSTotalStakedResponse, error)
 
+	// Merkle proofs of the app state
+	ContractStateProof(contract, key string, height int64) (*AppStateProof, error)
+	BalanceProof(contract, owner string, height int64) (*BalanceProof, error)
+	EvmAccountProof(address string, storageKeys []string, height int64) (*EvmAccountProof, error)
+
 	// deprecated function
 	EvmTxReceipt(txHash []byte) ([]byte, error)
 	GetEvmCode(contract string) ([]byte, error)
@@ -78,8 +97,11 @@ type QueryService interface {
 	GetEvmBlockByNumber(number string, full bool) ([]byte, error)
 	GetEvmBlockByHash(hash []byte, full bool) ([]byte, error)
 	GetEvmTransactionByHash(txHash []byte) ([]byte, error)
-	EvmSubscribe(wsCtx rpctypes.WSRPCContext, method, filter string) (string, error)
+	EvmSubscribe(conn *websocket.Conn, method, filter string) (string, error)
 	EvmUnSubscribe(id string) (bool, error)
+
+	// Explorer API
+	explorer.Backend
 }
 
 type QueryEventBus struct {
@@ -103,58 +125,60 @@ func (b *QueryEventBus) UnsubscribeAll(ctx context.Context, subscriber string) e
 	return nil
 }
 
-// makeQueryServiceHandler returns a http handler mapping to query service
-func MakeQueryServiceHandler(svc QueryService, logger log.TMLogger, bus *QueryEventBus) http.Handler {
-	// set up websocket route
-	codec := amino.NewCodec()
-	wsmux := http.NewServeMux()
-	routes := map[string]*rpcserver.RPCFunc{}
-	routes["query"] = rpcserver.NewRPCFunc(svc.Query, "caller,contract,query,vmType")
-	routes["env"] = rpcserver.NewRPCFunc(svc.QueryEnv, "")
-	routes["nonce"] = rpcserver.NewRPCFunc(svc.Nonce, "key,account")
-	routes["subevents"] = rpcserver.NewWSRPCFunc(svc.Subscribe, "topics")
-	routes["unsubevents"] = rpcserver.NewWSRPCFunc(svc.UnSubscribe, "topic")
-	routes["resolve"] = rpcserver.NewRPCFunc(svc.Resolve, "name")
-	routes["evmtxreceipt"] = rpcserver.NewRPCFunc(svc.EvmTxReceipt, "txHash")
-	routes["getevmcode"] = rpcserver.NewRPCFunc(svc.GetEvmCode, "contract")
-	routes["getevmlogs"] = rpcserver.NewRPCFunc(svc.GetEvmLogs, "filter")
-	routes["newevmfilter"] = rpcserver.NewRPCFunc(svc.NewEvmFilter, "filter")
-	routes["newblockevmfilter"] = rpcserver.NewRPCFunc(svc.NewBlockEvmFilter, "")
-	routes["newpendingtransactionevmfilter"] = rpcserver.NewRPCFunc(svc.NewPendingTransactionEvmFilter, "")
-	routes["getevmfilterchanges"] = rpcserver.NewRPCFunc(svc.GetEvmFilterChanges, "id")
-	routes["evmunsubscribe"] = rpcserver.NewRPCFunc(svc.EvmUnSubscribe, "id")
-	routes["uninstallevmfilter"] = rpcserver.NewRPCFunc(svc.UninstallEvmFilter, "id")
-	routes["getblockheight"] = rpcserver.NewRPCFunc(svc.GetBlockHeight, "")
-	routes["getevmblockbynumber"] = rpcserver.NewRPCFunc(svc.GetEvmBlockByNumber, "number,full")
-	routes["getevmblockbyhash"] = rpcserver.NewRPCFunc(svc.GetEvmBlockByHash, "hash,full")
-	routes["getevmtransactionbyhash"] = rpcserver.NewRPCFunc(svc.GetEvmTransactionByHash, "txHash")
-	routes["evmsubscribe"] = rpcserver.NewWSRPCFunc(svc.EvmSubscribe, "method,filter")
-	routes["contractevents"] = rpcserver.NewRPCFunc(svc.ContractEvents, "fromBlock,toBlock,contract")
-	routes["contractrecord"] = rpcserver.NewRPCFunc(svc.GetContractRecord, "contract")
-	routes["dpos_total_staked"] = rpcserver.NewRPCFunc(svc.DPOSTotalStaked, "")
-	rpcserver.RegisterRPCFuncs(wsmux, routes, codec, logger)
-	wm := rpcserver.NewWebsocketManager(routes, codec, rpcserver.EventSubscriber(bus))
-	wsmux.HandleFunc("/queryws", wm.WebsocketHandler)
-
-	// setup default route
-	mux := http.NewServeMux()
-	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if req.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		wsmux.ServeHTTP(w, req)
-	})
+func createLegacyQueryRoutes(svc QueryService) map[string]eth.RPCFunc {
+	routes := map[string]eth.RPCFunc{}
+	routes["query"] = eth.NewLegacyRPCFunc(svc.Query, "caller,contract,query,vmType")
+	routes["env"] = eth.NewLegacyRPCFunc(svc.QueryEnv, "")
+	routes["nonce"] = eth.NewLegacyRPCFunc(svc.Nonce, "key,account")
+	routes["subevents"] = eth.NewLegacyWSRPCFunc(svc.Subscribe, "topics")
+	routes["unsubevents"] = eth.NewLegacyWSRPCFunc(svc.UnSubscribe, "topic")
+	routes["resolve"] = eth.NewLegacyRPCFunc(svc.Resolve, "name")
+	routes["evmtxreceipt"] = eth.NewLegacyRPCFunc(svc.EvmTxReceipt, "txHash")
+	routes["getevmcode"] = eth.NewLegacyRPCFunc(svc.GetEvmCode, "contract")
+	routes["getevmlogs"] = eth.NewLegacyRPCFunc(svc.GetEvmLogs, "filter")
+	routes["newevmfilter"] = eth.NewLegacyRPCFunc(svc.NewEvmFilter, "filter")
+	routes["newblockevmfilter"] = eth.NewLegacyRPCFunc(svc.NewBlockEvmFilter, "")
+	routes["newpendingtransactionevmfilter"] = eth.NewLegacyRPCFunc(svc.NewPendingTransactionEvmFilter, "")
+	routes["getevmfilterchanges"] = eth.NewLegacyRPCFunc(svc.GetEvmFilterChanges, "id")
+	routes["evmunsubscribe"] = eth.NewLegacyRPCFunc(svc.EvmUnSubscribe, "id")
+	routes["uninstallevmfilter"] = eth.NewLegacyRPCFunc(svc.UninstallEvmFilter, "id")
+	routes["getblockheight"] = eth.NewLegacyRPCFunc(svc.GetBlockHeight, "")
+	routes["getevmblockbynumber"] = eth.NewLegacyRPCFunc(svc.GetEvmBlockByNumber, "number,full")
+	routes["getevmblockbyhash"] = eth.NewLegacyRPCFunc(svc.GetEvmBlockByHash, "hash,full")
+	routes["getevmtransactionbyhash"] = eth.NewLegacyRPCFunc(svc.GetEvmTransactionByHash, "txHash")
+	routes["evmsubscribe"] = eth.NewLegacyWSRPCFunc(svc.EvmSubscribe, "method,filter")
+	routes["contractevents"] = eth.NewLegacyRPCFunc(svc.ContractEvents, "fromBlock,toBlock,contract")
+	routes["contractrecord"] = eth.NewLegacyRPCFunc(svc.GetContractRecord, "contract")
+	routes["dpos_total_staked"] = eth.NewLegacyRPCFunc(svc.DPOSTotalStaked, "")
+	routes["contractstateproof"] = eth.NewLegacyRPCFunc(svc.ContractStateProof, "contract,key,height")
+	routes["balanceproof"] = eth.NewLegacyRPCFunc(svc.BalanceProof, "contract,owner,height")
+	routes["evmaccountproof"] = eth.NewLegacyRPCFunc(svc.EvmAccountProof, "address,storageKeys,height")
+	return routes
+}
 
+// MakeQueryServiceHandler returns a http handler mapping to the legacy query service routes, these
+// are served by the same JSON-RPC handler as the /eth routes, but params & results are encoded the
+// same way as Tendermint's RPC server encoded them. The rate at which clients can call the routes
+// is limited by the given limiter (which may be nil).
+func MakeQueryServiceHandler(
+	svc QueryService, logger log.TMLogger, bus *QueryEventBus, limiter *eth.RateLimiter,
+) http.Handler {
+	hub := newHub()
+	// drop the event subscriptions of websocket clients when they disconnect
+	hub.disconnected = func(client *Client) {
+		_ = bus.UnsubscribeAll(context.Background(), client.conn.RemoteAddr().String())
+	}
+	go hub.run()
+
+	mux := http.NewServeMux()
+	mux.Handle("/", MakeEthQueryServiceHandler(logger, hub, createLegacyQueryRoutes(svc), limiter))
 	// setup metrics route
 	mux.Handle("/metrics", promhttp.Handler())
 
 	return mux
 }
 
-func createDefaultEthRoutes(svc QueryService, chainID string) map[string]eth.RPCFunc {
+func createDefaultEthRoutes(svc QueryService, chainID string, enableDebugAPI bool) map[string]eth.RPCFunc {
 	routes := map[string]eth.RPCFunc{}
 	routes["eth_blockNumber"] = eth.NewRPCFunc(svc.EthBlockNumber, "")
 	routes["eth_getBlockByNumber"] = eth.NewRPCFunc(svc.EthGetBlockByNumber, "block,full")
@@ -179,7 +203,7 @@ func createDefaultEthRoutes(svc QueryService, chainID string) map[string]eth.RPC
 	routes["eth_getFilterLogs"] = eth.NewRPCFunc(svc.EthGetFilterLogs, "id")
 
 	routes["eth_newFilter"] = eth.NewRPCFunc(svc.EthNewFilter, "filter")
-	routes["eth_subscribe"] = eth.NewWSRPCFunc(svc.EthSubscribe, "conn,method,filter")
+	routes["eth_subscribe"] = eth.NewWSRPCFunc(svc.EthSubscribe, "conn,method,filter,options")
 	routes["eth_unsubscribe"] = eth.NewRPCFunc(svc.EthUnsubscribe, "id")
 
 	routes["eth_accounts"] = eth.NewRPCFunc(svc.EthAccounts, "")
@@ -188,14 +212,25 @@ func createDefaultEthRoutes(svc QueryService, chainID string) map[string]eth.RPC
 	routes["eth_gasPrice"] = eth.NewRPCFunc(svc.EthGasPrice, "")
 	routes["net_version"] = eth.NewRPCFunc(svc.EthNetVersion, "")
 	routes["eth_getTransactionCount"] = eth.NewRPCFunc(svc.EthGetTransactionCount, "local,block")
+	routes["eth_getProof"] = eth.NewRPCFunc(svc.EthGetProof, "address,storageKeys,block")
+	routes["txpool_content"] = eth.NewRPCFunc(svc.TxPoolContent, "")
+	routes["txpool_status"] = eth.NewRPCFunc(svc.TxPoolStatus, "")
 	routes["eth_sendRawTransaction"] = NewSendRawTransactionRPCFunc(chainID, rpccore.BroadcastTxSync)
+	if enableDebugAPI {
+		routes["debug_traceTransaction"] = eth.NewRPCFunc(svc.DebugTraceTransaction, "hash,config")
+		routes["debug_traceBlockByNumber"] = eth.NewRPCFunc(svc.DebugTraceBlockByNumber, "block,config")
+		routes["debug_traceCall"] = eth.NewRPCFunc(svc.DebugTraceCall, "query,block,config")
+	}
 	return routes
 }
 
-// MakeEthQueryServiceHandler returns an http handler mapping to query service
-func MakeEthQueryServiceHandler(logger log.TMLogger, hub *Hub, routes map[string]eth.RPCFunc) http.Handler {
+// MakeEthQueryServiceHandler returns an http handler mapping to query service, the rate at which
+// clients can call the routes is limited by the given limiter (which may be nil).
+func MakeEthQueryServiceHandler(
+	logger log.TMLogger, hub *Hub, routes map[string]eth.RPCFunc, limiter *eth.RateLimiter,
+) http.Handler {
 	wsmux := http.NewServeMux()
-	RegisterRPCFuncs(wsmux, routes, logger, hub)
+	RegisterRPCFuncs(wsmux, routes, logger, hub, limiter)
 
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
@@ -6,6 +6,9 @@ import (
 	"strings"
 
 	"github.com/loomnetwork/loomchain/log"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/rpc/explorer"
+	"github.com/loomnetwork/loomchain/rpc/graphql"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	amino "github.com/tendermint/go-amino"
@@ -38,12 +41,16 @@ func init() {
 // RPCServer starts up HTTP servers that handle client requests.
 func RPCServer(
 	qsvc QueryService, chainID string, logger log.TMLogger, bus *QueryEventBus, bindAddr string,
-	enableUnsafeRPC bool, unsafeRPCBindAddress string,
+	enableUnsafeRPC bool, unsafeRPCBindAddress string, rateLimiterCfg *eth.RateLimiterConfig,
+	web3Cfg *eth.Web3Config,
 ) error {
-	queryHandler := MakeQueryServiceHandler(qsvc, logger, bus)
+	// nil if rate limiting is disabled
+	limiter := eth.NewRateLimiter(rateLimiterCfg)
+	queryHandler := MakeQueryServiceHandler(qsvc, logger, bus, limiter)
 	hub := newHub()
 	go hub.run()
-	ethHandler := MakeEthQueryServiceHandler(logger, hub, createDefaultEthRoutes(qsvc, chainID))
+	ethRoutes := createDefaultEthRoutes(qsvc, chainID, web3Cfg.DebugAPIEnabled)
+	ethHandler := MakeEthQueryServiceHandler(logger, hub, ethRoutes, limiter)
 
 	// Add the nonce route to the TM routes so clients can query the nonce from the /websocket
 	// and /rpc endpoints.
@@ -57,6 +64,15 @@ func RPCServer(
 	mux.Handle("/query", stripPrefix("/query", queryHandler)) //backwards compatibility
 	mux.Handle("/queryws", queryHandler)
 	mux.Handle("/eth", ethHandler)
+	if web3Cfg.GraphQLEnabled {
+		graphqlHandler, err := graphql.NewHandler(qsvc, limiter)
+		if err != nil {
+			return errors.Wrap(err, "failed to create GraphQL handler")
+		}
+		mux.Handle("/graphql", CORSMethodMiddleware(graphqlHandler))
+	}
+	explorerHandler := CORSMethodMiddleware(explorer.NewHandler(qsvc, limiter))
+	mux.Handle("/explorer/", stripPrefix("/explorer", explorerHandler))
 	rpcmux := http.NewServeMux()
 	rpcserver.RegisterRPCFuncs(rpcmux, rpccore.Routes, cdc, logger)
 	mux.Handle("/rpc/", stripPrefix("/rpc", CORSMethodMiddleware(rpcmux)))
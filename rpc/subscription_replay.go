@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"github.com/loomnetwork/loomchain"
+	"github.com/loomnetwork/loomchain/eth/query"
+	"github.com/loomnetwork/loomchain/eth/subs"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+)
+
+// subscriptionOptions returns the options for a new eth_subscribe subscriber.
+func (s *QueryServer) subscriptionOptions(resume bool) *subs.SubscriptionOptions {
+	opts := &subs.SubscriptionOptions{Resume: resume}
+	if s.Web3Cfg != nil {
+		opts.QueueSize = s.Web3Cfg.SubscriptionQueueSize
+		opts.OverflowPolicy = s.Web3Cfg.SubscriptionOverflowPolicy
+	}
+	return opts
+}
+
+// maxReplayBlocks returns the max number of blocks that can be replayed for a resumed subscription.
+func (s *QueryServer) maxReplayBlocks() uint64 {
+	if s.Web3Cfg != nil {
+		return s.Web3Cfg.SubscriptionMaxReplayBlocks
+	}
+	return eth.DefaultWeb3Config().SubscriptionMaxReplayBlocks
+}
+
+// loadMissedEvents loads the events that were emitted after the event identified by the given
+// token, up to & including the last block in the given snapshot.
+func (s *QueryServer) loadMissedEvents(
+	snapshot loomchain.ReadOnlyState, method string, filter eth.EthFilter, token subs.ResumeToken,
+) ([]subs.ReplayEvent, error) {
+	height := uint64(snapshot.Block().Height)
+	if token.Height > height {
+		return nil, errors.Errorf("resume token %s is ahead of the last block %d", token, height)
+	}
+	if height-token.Height > s.maxReplayBlocks() {
+		return nil, errors.Errorf(
+			"resume token %s is too old, at most %d blocks can be replayed", token, s.maxReplayBlocks(),
+		)
+	}
+
+	switch method {
+	case subs.NewHeads:
+		return s.loadMissedHeads(token, height)
+	case subs.Logs:
+		return s.loadMissedLogs(snapshot, filter, token, height)
+	default:
+		return nil, errors.Errorf("%s subscriptions can't be resumed", method)
+	}
+}
+
+func (s *QueryServer) loadMissedHeads(token subs.ResumeToken, height uint64) ([]subs.ReplayEvent, error) {
+	events := make([]subs.ReplayEvent, 0, height-token.Height)
+	for h := token.Height + 1; h <= height; h++ {
+		blockHeight := int64(h)
+		blockResult, err := s.BlockStore.GetBlockByHeight(&blockHeight)
+		if err != nil {
+			return nil, errors.Wrapf(err, "get block %d", h)
+		}
+		header := blockResult.Block.Header
+		result, err := json.Marshal(subs.EncNewHead(
+			header.Height, header.Time, header.ProposerAddress, header.LastBlockID.Hash,
+		))
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal block %d", h)
+		}
+		events = append(events, subs.ReplayEvent{
+			Token:  subs.ResumeToken{Height: h},
+			Result: result,
+		})
+	}
+	return events, nil
+}
+
+func (s *QueryServer) loadMissedLogs(
+	snapshot loomchain.ReadOnlyState, filter eth.EthFilter, token subs.ResumeToken, height uint64,
+) ([]subs.ReplayEvent, error) {
+	// The rest of the logs in the block of the last event the client received must be replayed too.
+	filter.FromBlock = eth.BlockHeight(eth.EncUint(token.Height))
+	filter.ToBlock = eth.BlockHeight(eth.EncUint(height))
+	maxResults := eth.DefaultWeb3Config().GetLogsMaxResults
+	if s.Web3Cfg != nil {
+		maxResults = s.Web3Cfg.GetLogsMaxResults
+	}
+	logs, err := query.QueryChain(
+		s.BlockStore, snapshot, filter, s.ReceiptHandlerProvider.Reader(), s.EvmAuxStore,
+		s.maxReplayBlocks(), maxResults,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "query logs")
+	}
+
+	events := make([]subs.ReplayEvent, 0, len(logs))
+	for i, jsonLog := range eth.EncLogs(logs) {
+		logToken := subs.ResumeToken{
+			Height:   uint64(logs[i].BlockNumber),
+			TxIndex:  uint64(logs[i].TransactionIndex),
+			LogIndex: uint64(logs[i].LogIndex),
+		}
+		if !logToken.After(token) {
+			continue
+		}
+		result, err := json.Marshal(&jsonLog)
+		if err != nil {
+			return nil, errors.Wrapf(err, "marshal log %s", logToken)
+		}
+		events = append(events, subs.ReplayEvent{Token: logToken, Result: result})
+	}
+	return events, nil
+}
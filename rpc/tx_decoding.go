@@ -0,0 +1,139 @@
+package rpc
+
+import (
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/auth"
+	ltypes "github.com/loomnetwork/go-loom/types"
+	"github.com/loomnetwork/go-loom/vm"
+	"github.com/loomnetwork/loomchain/rpc/loomtx"
+	"github.com/tendermint/tendermint/types"
+)
+
+// evmTx is an EVM contract deployment or call.
+type evmTx struct {
+	caller loom.Address
+	// nil for contract deployments
+	to    *loom.Address
+	input []byte
+	value *loom.BigUInt
+}
+
+// unwrapTx extracts the nonce, Loom tx & message wrapped in the given Tendermint tx.
+func unwrapTx(tx types.Tx) (*auth.NonceTx, *ltypes.Transaction, *vm.MessageTx, error) {
+	unwrapped, err := loomtx.Unwrap(tx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return unwrapped.NonceTx, unwrapped.Tx, unwrapped.Msg, nil
+}
+
+// decodeEthTx extracts the Ethereum tx wrapped in the given Tendermint tx, returns nil if the
+// Tendermint tx doesn't wrap an Ethereum tx.
+func decodeEthTx(tx types.Tx) (*etypes.Transaction, error) {
+	_, txTx, msg, err := unwrapTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	if ltypes.TxID(txTx.Id) != ltypes.TxID_ETHEREUM {
+		return nil, nil
+	}
+
+	var ethTx etypes.Transaction
+	if err := rlp.DecodeBytes(msg.Data, &ethTx); err != nil {
+		return nil, err
+	}
+	return &ethTx, nil
+}
+
+// decodeEvmTx extracts the EVM deployment or call from the given Tendermint tx, returns nil if the
+// Tendermint tx doesn't deploy or call an EVM contract.
+func decodeEvmTx(tx types.Tx) (*evmTx, error) {
+	_, txTx, msg, err := unwrapTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeTxPayload(txTx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil || payload.vmType != vm.VMType_EVM {
+		return nil, nil
+	}
+	return &evmTx{
+		caller: loom.UnmarshalAddressPB(msg.From),
+		to:     payload.to,
+		input:  payload.input,
+		value:  payload.value,
+	}, nil
+}
+
+// txPayload is the contract deployment, call, or migration wrapped in a Loom tx.
+type txPayload struct {
+	// VM the tx will be executed by, migrations aren't executed by either VM so this is left as
+	// the default.
+	vmType vm.VMType
+	// nil for contract deployments
+	to    *loom.Address
+	input []byte
+	// nil if the tx doesn't transfer any value
+	value *loom.BigUInt
+	// only set for Ethereum txs
+	ethTx *etypes.Transaction
+}
+
+// decodeTxPayload extracts the payload from the given Loom tx & message, returns nil if the tx
+// type is not recognised.
+func decodeTxPayload(txTx *ltypes.Transaction, msg *vm.MessageTx) (*txPayload, error) {
+	result := &txPayload{}
+	switch ltypes.TxID(txTx.Id) {
+	case ltypes.TxID_DEPLOY:
+		var deployTx vm.DeployTx
+		if err := proto.Unmarshal(msg.Data, &deployTx); err != nil {
+			return nil, err
+		}
+		result.vmType = deployTx.VmType
+		result.input = deployTx.Code
+		if deployTx.Value != nil {
+			result.value = &deployTx.Value.Value
+		}
+
+	case ltypes.TxID_CALL:
+		var callTx vm.CallTx
+		if err := proto.Unmarshal(msg.Data, &callTx); err != nil {
+			return nil, err
+		}
+		to := loom.UnmarshalAddressPB(msg.To)
+		result.vmType = callTx.VmType
+		result.to = &to
+		result.input = callTx.Input
+		if callTx.Value != nil {
+			result.value = &callTx.Value.Value
+		}
+
+	case ltypes.TxID_MIGRATION:
+		to := loom.UnmarshalAddressPB(msg.To)
+		result.to = &to
+		result.input = msg.Data
+
+	case ltypes.TxID_ETHEREUM:
+		var ethTx etypes.Transaction
+		if err := rlp.DecodeBytes(msg.Data, &ethTx); err != nil {
+			return nil, err
+		}
+		if ethTx.To() != nil {
+			to := loom.UnmarshalAddressPB(msg.To)
+			result.to = &to
+		}
+		result.vmType = vm.VMType_EVM
+		result.input = ethTx.Data()
+		result.value = loom.NewBigUInt(ethTx.Value())
+		result.ethTx = &ethTx
+
+	default:
+		return nil, nil
+	}
+	return result, nil
+}
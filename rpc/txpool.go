@@ -0,0 +1,243 @@
+package rpc
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Max number of txs that will be returned by txpool_content, a busy mempool can contain thousands of
+// txs so the response is capped to keep it to a reasonable size.
+const maxPendingTxs = 100
+
+// pendingTx is a tx in the mempool that hasn't been committed to a block yet.
+type pendingTx struct {
+	sender loom.Address
+	// NonceTx.Sequence of the tx, which is one higher than the nonce of an Ethereum tx.
+	sequence uint64
+	// Hash of the Ethereum tx wrapped in the Tendermint tx, nil for Loom txs.
+	ethTxHash []byte
+	txObj     eth.JsonTxObject
+}
+
+// decodePendingTx converts the given Tendermint tx to a tx object, since the tx hasn't been committed
+// yet the block hash, block number, and tx index are left empty, and the tx hash is the hash of the
+// Tendermint tx (which is what eth_sendRawTransaction returns).
+func decodePendingTx(tx types.Tx) (*pendingTx, error) {
+	nonceTx, txTx, msg, err := unwrapTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := decodeTxPayload(txTx, msg)
+	if err != nil {
+		return nil, err
+	}
+	if payload == nil {
+		return nil, errors.Errorf("unrecognised tx type %v", txTx.Id)
+	}
+
+	result := &pendingTx{
+		sender:   loom.UnmarshalAddressPB(msg.From),
+		sequence: nonceTx.Sequence,
+		txObj: eth.JsonTxObject{
+			Hash:     eth.EncBytes(tx.Hash()),
+			Nonce:    eth.EncUint(nonceTx.Sequence),
+			From:     eth.EncAddress(msg.From),
+			Value:    eth.EncInt(0),
+			GasPrice: eth.EncInt(0),
+			Gas:      eth.EncInt(0),
+			Input:    eth.EncBytes(payload.input),
+		},
+	}
+	txObj := &result.txObj
+	if payload.to != nil {
+		to := eth.EncAddress(msg.To)
+		txObj.To = &to
+	}
+	if payload.value != nil {
+		txObj.Value = eth.EncBigInt(*payload.value.Int)
+	}
+	if ethTx := payload.ethTx; ethTx != nil {
+		// Web3 clients expect to see the nonce they signed the tx with.
+		txObj.Nonce = eth.EncUint(ethTx.Nonce())
+		txObj.GasPrice = eth.EncBigInt(*ethTx.GasPrice())
+		txObj.Gas = eth.EncUint(ethTx.Gas())
+		result.ethTxHash = ethTx.Hash().Bytes()
+	}
+	return result, nil
+}
+
+// How long the pending tx index can be used for before it's rebuilt, even if the number of txs in
+// the mempool hasn't changed.
+const pendingTxIndexTTL = time.Second
+
+// pendingTxIndex indexes the txs in the mempool by hash & sender, so that clients polling for
+// pending txs & nonces don't have to lock & decode the whole mempool on every request. The index is
+// only rebuilt when the number of txs in the mempool changes (i.e. a tx is added to the mempool, or
+// a block is committed), or once it's older than pendingTxIndexTTL, and only the txs that weren't
+// in the mempool the last time the index was built are decoded.
+type pendingTxIndex struct {
+	mutex   sync.Mutex
+	builtAt time.Time
+	numTxs  int
+	// Txs in the order they were added to the mempool.
+	txs []*pendingTx
+	// Txs keyed by the hash of the Tendermint tx, nil for txs that couldn't be decoded.
+	decoded map[string]*pendingTx
+	// Txs keyed by the hash of the Ethereum tx wrapped in the Tendermint tx.
+	byEthTxHash map[string]*pendingTx
+	// Highest NonceTx.Sequence of the txs from each sender.
+	sequences map[string]uint64
+}
+
+// refresh rebuilds the index if the mempool has changed since it was last built.
+func (idx *pendingTxIndex) refresh(mempool store.Mempool) error {
+	numTxs, err := mempool.NumUnconfirmedTxs()
+	if err != nil {
+		return errors.Wrap(err, "failed to get number of txs in mempool")
+	}
+	if idx.decoded != nil && numTxs == idx.numTxs && time.Since(idx.builtAt) < pendingTxIndexTTL {
+		return nil
+	}
+	txs, err := mempool.GetUnconfirmedTxs(-1)
+	if err != nil {
+		return errors.Wrap(err, "failed to load txs from mempool")
+	}
+	decoded := make(map[string]*pendingTx, len(txs))
+	idx.txs = make([]*pendingTx, 0, len(txs))
+	idx.byEthTxHash = map[string]*pendingTx{}
+	idx.sequences = map[string]uint64{}
+	for _, tx := range txs {
+		hash := string(tx.Hash())
+		ptx, ok := idx.decoded[hash]
+		if !ok {
+			// txs that can't be decoded can't have come from Web3 or Loom clients, so just skip them
+			ptx, _ = decodePendingTx(tx)
+		}
+		decoded[hash] = ptx
+		if ptx == nil {
+			continue
+		}
+		idx.txs = append(idx.txs, ptx)
+		if ptx.ethTxHash != nil {
+			idx.byEthTxHash[string(ptx.ethTxHash)] = ptx
+		}
+		if sender := ptx.sender.String(); ptx.sequence > idx.sequences[sender] {
+			idx.sequences[sender] = ptx.sequence
+		}
+	}
+	idx.decoded = decoded
+	idx.numTxs = numTxs
+	idx.builtAt = time.Now()
+	return nil
+}
+
+// getPendingTxs returns up to limit txs from the mempool (or all of them if limit is negative),
+// txs that can't be decoded are skipped.
+func (s *QueryServer) getPendingTxs(limit int) ([]*pendingTx, error) {
+	if s.Mempool == nil {
+		return nil, nil
+	}
+	s.pendingTxs.mutex.Lock()
+	defer s.pendingTxs.mutex.Unlock()
+
+	if err := s.pendingTxs.refresh(s.Mempool); err != nil {
+		return nil, err
+	}
+	pendingTxs := s.pendingTxs.txs
+	if limit >= 0 && limit < len(pendingTxs) {
+		pendingTxs = pendingTxs[:limit]
+	}
+	return pendingTxs, nil
+}
+
+// getPendingTx looks up a tx in the mempool by the hash of the Tendermint tx, or the hash of the
+// Ethereum tx wrapped in it, returns nil if the tx isn't in the mempool.
+func (s *QueryServer) getPendingTx(txHash []byte) (*pendingTx, error) {
+	if s.Mempool == nil {
+		return nil, nil
+	}
+	s.pendingTxs.mutex.Lock()
+	defer s.pendingTxs.mutex.Unlock()
+
+	if err := s.pendingTxs.refresh(s.Mempool); err != nil {
+		return nil, err
+	}
+	if ptx := s.pendingTxs.decoded[string(txHash)]; ptx != nil {
+		return ptx, nil
+	}
+	return s.pendingTxs.byEthTxHash[string(txHash)], nil
+}
+
+// getPendingNonce returns the nonce the next tx from the given account should have, taking into
+// account the txs from the account that are still in the mempool. The account may have sent txs
+// using its Ethereum address, or the Loom address mapped to it, so txs from either are counted.
+func (s *QueryServer) getPendingNonce(nonce uint64, accounts ...loom.Address) (uint64, error) {
+	if s.Mempool == nil {
+		return nonce, nil
+	}
+	s.pendingTxs.mutex.Lock()
+	defer s.pendingTxs.mutex.Unlock()
+
+	if err := s.pendingTxs.refresh(s.Mempool); err != nil {
+		return 0, err
+	}
+	for _, account := range accounts {
+		// NonceTx.Sequence of the first tx sent by an account is 1, while the nonce is 0
+		if sequence := s.pendingTxs.sequences[account.String()]; sequence > nonce {
+			nonce = sequence
+		}
+	}
+	return nonce, nil
+}
+
+// TxPoolContent returns the txs in the mempool grouped by sender & nonce.
+// Txs are only added to the Tendermint mempool if their nonce follows on from the nonce of the
+// previous tx sent by the same account, so all the txs in the mempool are pending and none are queued.
+// Only the first maxPendingTxs txs in the mempool are returned.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#txpool_content
+func (s *QueryServer) TxPoolContent() (*eth.JsonTxPoolContent, error) {
+	pendingTxs, err := s.getPendingTxs(maxPendingTxs)
+	if err != nil {
+		return nil, err
+	}
+	content := &eth.JsonTxPoolContent{
+		Pending: map[eth.Data]map[string]eth.JsonTxObject{},
+		Queued:  map[eth.Data]map[string]eth.JsonTxObject{},
+	}
+	for _, ptx := range pendingTxs {
+		nonce, err := eth.DecQuantityToUint(ptx.txObj.Nonce)
+		if err != nil {
+			return nil, err
+		}
+		txs, ok := content.Pending[ptx.txObj.From]
+		if !ok {
+			txs = map[string]eth.JsonTxObject{}
+			content.Pending[ptx.txObj.From] = txs
+		}
+		txs[strconv.FormatUint(nonce, 10)] = ptx.txObj
+	}
+	return content, nil
+}
+
+// TxPoolStatus returns the number of txs in the mempool.
+// https://github.com/ethereum/go-ethereum/wiki/Management-APIs#txpool_status
+func (s *QueryServer) TxPoolStatus() (*eth.JsonTxPoolStatus, error) {
+	var numTxs int
+	if s.Mempool != nil {
+		var err error
+		if numTxs, err = s.Mempool.NumUnconfirmedTxs(); err != nil {
+			return nil, errors.Wrap(err, "failed to get number of txs in mempool")
+		}
+	}
+	return &eth.JsonTxPoolStatus{
+		Pending: eth.EncInt(int64(numTxs)),
+		Queued:  eth.EncInt(0),
+	}, nil
+}
@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	etypes "github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain/rpc/eth"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/types"
+)
+
+func TestTxPool(t *testing.T) {
+	wrapper := NewSendRawTransactionRPCFunc("default", nil).(*SendRawTransactionPRCFunc)
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	sender := crypto.PubkeyToAddress(ethKey.PublicKey)
+	makeTx := func(nonce uint64) (types.Tx, *etypes.Transaction) {
+		tx, err := etypes.SignTx(
+			etypes.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(5), 21000, big.NewInt(10), nil),
+			wrapper.ethSigner, ethKey,
+		)
+		require.NoError(t, err)
+		txBytes, err := rlp.EncodeToBytes(tx)
+		require.NoError(t, err)
+		tmTx, err := wrapper.ethereumToTendermintTx(txBytes)
+		require.NoError(t, err)
+		return tmTx, tx
+	}
+	tmTx1, ethTx1 := makeTx(3)
+	tmTx2, _ := makeTx(4)
+
+	qs := &QueryServer{
+		Mempool: store.NewMockMempool(tmTx1, types.Tx("not a tx"), tmTx2),
+	}
+
+	status, err := qs.TxPoolStatus()
+	require.NoError(t, err)
+	require.Equal(t, eth.EncInt(3), status.Pending)
+	require.Equal(t, eth.EncInt(0), status.Queued)
+
+	content, err := qs.TxPoolContent()
+	require.NoError(t, err)
+	require.Len(t, content.Queued, 0)
+	senderTxs := content.Pending[eth.EncBytes(sender.Bytes())]
+	require.Len(t, senderTxs, 2)
+	require.Equal(t, eth.EncBytes(tmTx1.Hash()), senderTxs["3"].Hash)
+	require.Equal(t, eth.EncInt(5), senderTxs["3"].Value)
+	require.Equal(t, eth.EncBytes(tmTx2.Hash()), senderTxs["4"].Hash)
+
+	// pending txs can be looked up by Tendermint & Ethereum tx hash
+	ptx, err := qs.getPendingTx(tmTx2.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, ptx)
+	require.Equal(t, eth.EncInt(4), ptx.txObj.Nonce)
+	ptx, err = qs.getPendingTx(ethTx1.Hash().Bytes())
+	require.NoError(t, err)
+	require.NotNil(t, ptx)
+	require.Equal(t, eth.EncBytes(tmTx1.Hash()), ptx.txObj.Hash)
+	ptx, err = qs.getPendingTx([]byte{1, 2, 3})
+	require.NoError(t, err)
+	require.Nil(t, ptx)
+
+	// the next nonce should follow on from the last pending tx
+	ethAddr := loom.Address{ChainID: "eth", Local: sender.Bytes()}
+	nonce, err := qs.getPendingNonce(3, ethAddr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(5), nonce)
+	nonce, err = qs.getPendingNonce(3, loom.Address{ChainID: "default", Local: sender.Bytes()})
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), nonce)
+
+	// txs beyond the txpool_content limit should still be counted
+	txs := make([]types.Tx, 0, maxPendingTxs+1)
+	for i := 0; i <= maxPendingTxs; i++ {
+		tmTx, _ := makeTx(uint64(i))
+		txs = append(txs, tmTx)
+	}
+	qs.Mempool = store.NewMockMempool(txs...)
+	status, err = qs.TxPoolStatus()
+	require.NoError(t, err)
+	require.Equal(t, eth.EncInt(maxPendingTxs+1), status.Pending)
+	content, err = qs.TxPoolContent()
+	require.NoError(t, err)
+	require.Len(t, content.Pending[eth.EncBytes(sender.Bytes())], maxPendingTxs)
+	nonce, err = qs.getPendingNonce(0, ethAddr)
+	require.NoError(t, err)
+	require.Equal(t, uint64(maxPendingTxs+1), nonce)
+}
+
+type countingMempool struct {
+	*store.MockMempool
+	numReads int
+}
+
+func (m *countingMempool) GetUnconfirmedTxs(limit int) ([]types.Tx, error) {
+	m.numReads++
+	return m.MockMempool.GetUnconfirmedTxs(limit)
+}
+
+func TestPendingTxIndex(t *testing.T) {
+	wrapper := NewSendRawTransactionRPCFunc("default", nil).(*SendRawTransactionPRCFunc)
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	sender := loom.Address{ChainID: "eth", Local: crypto.PubkeyToAddress(ethKey.PublicKey).Bytes()}
+	makeTx := func(nonce uint64) types.Tx {
+		tx, err := etypes.SignTx(
+			etypes.NewTransaction(nonce, common.HexToAddress("0x1"), big.NewInt(5), 21000, big.NewInt(10), nil),
+			wrapper.ethSigner, ethKey,
+		)
+		require.NoError(t, err)
+		txBytes, err := rlp.EncodeToBytes(tx)
+		require.NoError(t, err)
+		tmTx, err := wrapper.ethereumToTendermintTx(txBytes)
+		require.NoError(t, err)
+		return tmTx
+	}
+	tmTx1 := makeTx(0)
+	mempool := &countingMempool{MockMempool: store.NewMockMempool(tmTx1)}
+	qs := &QueryServer{Mempool: mempool}
+
+	// the mempool should only be read again once it changes
+	for i := 0; i < 3; i++ {
+		nonce, err := qs.getPendingNonce(0, sender)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), nonce)
+		ptx, err := qs.getPendingTx([]byte{1, 2, 3})
+		require.NoError(t, err)
+		require.Nil(t, ptx)
+	}
+	require.Equal(t, 1, mempool.numReads)
+
+	tmTx2 := makeTx(1)
+	mempool.Txs = append(mempool.Txs, tmTx2)
+	nonce, err := qs.getPendingNonce(0, sender)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), nonce)
+	ptx, err := qs.getPendingTx(tmTx2.Hash())
+	require.NoError(t, err)
+	require.NotNil(t, ptx)
+	require.Equal(t, 2, mempool.numReads)
+
+	// committed txs should be dropped from the index
+	mempool.Txs = mempool.Txs[1:]
+	ptx, err = qs.getPendingTx(tmTx1.Hash())
+	require.NoError(t, err)
+	require.Nil(t, ptx)
+	require.Equal(t, 3, mempool.numReads)
+}
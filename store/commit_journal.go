@@ -0,0 +1,159 @@
+package store
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// CommitPhase identifies the DBs that are being written to while a block is committed, phases are
+// listed in the order in which they occur.
+type CommitPhase int
+
+const (
+	// Receipts of the block are being written to receipts_db.
+	CommitPhaseReceipts CommitPhase = iota + 1
+	// Tendermint -> EVM tx hash refs are being written to receipts_db, then app.db & evm.db are
+	// being committed.
+	CommitPhaseAppStore
+	// app.db & evm.db have been committed, the block index is being updated.
+	CommitPhaseBlockIndex
+	// The block has been committed to all the DBs, events emitted by the block may be written
+	// to the event store after this point.
+	CommitPhaseDone
+)
+
+// CommitJournal records the progress of the commit of each block to the node DBs (app.db, evm.db,
+// receipts_db, etc.). These DBs are written to one after the other, so if the node crashes part way
+// through committing a block the DBs may be left out of step with each other. The journal makes it
+// possible to detect an interrupted commit when the node restarts, and to figure out which DBs
+// need to be brought back in line with app.db.
+//
+// The journal is stored in a small file that's replaced atomically every time it's updated. Only
+// the first write of each block is synced to disk, so a block commit costs a single fsync. The
+// later writes may be lost in a crash, in which case the journal will indicate that the commit
+// was interrupted at an earlier phase than it actually was. That's safe because the rollbacks
+// done at startup are also conditional on the height of app.db.
+type CommitJournal struct {
+	path  string
+	entry CommitJournalEntry
+}
+
+// CommitJournalEntry describes the block that was most recently committed, or is currently being
+// committed.
+type CommitJournalEntry struct {
+	Height    int64       `json:"height"`
+	BlockHash []byte      `json:"blockHash"`
+	Phase     CommitPhase `json:"phase"`
+	// Tendermint tx hashes of the tx hash refs saved by the block.
+	TxRefs [][]byte `json:"txRefs,omitempty"`
+}
+
+// ReachedAbove checks if the commit of any block above the given height may have reached the
+// given phase. Any blocks before the last one recorded in the journal have been fully committed.
+func (e CommitJournalEntry) ReachedAbove(height int64, phase CommitPhase) bool {
+	return (e.Height-1 > height) || (e.Height > height && e.Phase >= phase)
+}
+
+// OpenCommitJournal loads the journal from the given file, if the file doesn't exist yet an empty
+// journal is returned.
+func OpenCommitJournal(path string) (*CommitJournal, error) {
+	j := &CommitJournal{
+		path:  path,
+		entry: CommitJournalEntry{Phase: CommitPhaseDone},
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read commit journal %s", path)
+	}
+	if err := json.Unmarshal(data, &j.entry); err != nil {
+		return nil, errors.Wrapf(
+			err, "failed to parse commit journal %s, delete it to skip the startup consistency checks", path,
+		)
+	}
+	return j, nil
+}
+
+// Last returns the last entry written to the journal.
+func (j *CommitJournal) Last() CommitJournalEntry {
+	return j.entry
+}
+
+// Interrupted checks if the commit of the last block recorded in the journal was started but never
+// completed.
+func (j *CommitJournal) Interrupted() bool {
+	return j.entry.Phase != CommitPhaseDone
+}
+
+// Begin records that the block at the given height is about to be committed, this must be called
+// before any of the DBs are modified.
+func (j *CommitJournal) Begin(height int64, blockHash []byte, txRefs [][]byte) error {
+	return j.write(CommitJournalEntry{
+		Height:    height,
+		BlockHash: blockHash,
+		Phase:     CommitPhaseReceipts,
+		TxRefs:    txRefs,
+	}, true)
+}
+
+// SetPhase records that the commit of the block at the given height has reached the given phase.
+func (j *CommitJournal) SetPhase(height int64, phase CommitPhase) error {
+	if j.entry.Height != height {
+		return errors.Errorf("commit journal height %d doesn't match commit height %d", j.entry.Height, height)
+	}
+	entry := j.entry
+	entry.Phase = phase
+	return j.write(entry, false)
+}
+
+// Commit records that the block at the given height has been committed to all the DBs.
+func (j *CommitJournal) Commit(height int64) error {
+	return j.SetPhase(height, CommitPhaseDone)
+}
+
+// Reset records that all the DBs have been brought in line with each other at the given height.
+func (j *CommitJournal) Reset(height int64) error {
+	return j.write(CommitJournalEntry{Height: height, Phase: CommitPhaseDone}, true)
+}
+
+// write replaces the journal file with the given entry, the new entry is written to a temp file
+// which is then renamed so a crash can't leave a partially written journal behind.
+func (j *CommitJournal) write(entry CommitJournalEntry, sync bool) error {
+	data, err := json.Marshal(&entry)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(j.path), filepath.Base(j.path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create commit journal")
+	}
+	tmpPath := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to write commit journal")
+	}
+	if sync {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			os.Remove(tmpPath)
+			return errors.Wrap(err, "failed to sync commit journal")
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to close commit journal")
+	}
+	if err := os.Rename(tmpPath, j.path); err != nil {
+		os.Remove(tmpPath)
+		return errors.Wrap(err, "failed to replace commit journal")
+	}
+	j.entry = entry
+	return nil
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "commit_journal")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "commit.journal")
+
+	journal, err := OpenCommitJournal(path)
+	require.NoError(t, err)
+	require.False(t, journal.Interrupted())
+	require.EqualValues(t, 0, journal.Last().Height)
+
+	require.NoError(t, journal.Begin(1, []byte{1}, nil))
+	require.True(t, journal.Interrupted())
+	require.NoError(t, journal.Commit(1))
+	require.False(t, journal.Interrupted())
+	require.Error(t, journal.Commit(2))
+
+	// simulate a crash part way through a commit
+	require.NoError(t, journal.Begin(2, []byte{2}, [][]byte{{3}}))
+	require.NoError(t, journal.SetPhase(2, CommitPhaseAppStore))
+	journal, err = OpenCommitJournal(path)
+	require.NoError(t, err)
+	require.True(t, journal.Interrupted())
+	last := journal.Last()
+	require.Equal(t, CommitJournalEntry{
+		Height: 2, BlockHash: []byte{2}, Phase: CommitPhaseAppStore, TxRefs: [][]byte{{3}},
+	}, last)
+	require.True(t, last.ReachedAbove(1, CommitPhaseAppStore))
+	require.False(t, last.ReachedAbove(1, CommitPhaseBlockIndex))
+	require.False(t, last.ReachedAbove(2, CommitPhaseReceipts))
+	// blocks before the last one were fully committed
+	require.True(t, last.ReachedAbove(0, CommitPhaseDone))
+
+	require.NoError(t, journal.Reset(1))
+	journal, err = OpenCommitJournal(path)
+	require.NoError(t, err)
+	require.False(t, journal.Interrupted())
+	require.EqualValues(t, 1, journal.Last().Height)
+
+	// no temp files should be left behind
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+}
@@ -20,6 +20,10 @@ type AppStoreConfig struct {
 	// If set to zero every version will be written to disk unless overridden via the on-chain config.
 	// If set to -1 every version will always be written to disk, regardless of the on-chain config.
 	IAVLFlushInterval int64
+	// If true every app store version will be written to disk and retained indefinitely, so that
+	// historical state queries can be served for any block height. Overrides MaxVersions,
+	// PruneInterval & IAVLFlushInterval.
+	ArchiveMode bool
 }
 
 func DefaultConfig() *AppStoreConfig {
@@ -31,6 +35,7 @@ func DefaultConfig() *AppStoreConfig {
 		PruneBatchSize:     50,
 		SaveEVMStateToIAVL: false,
 		IAVLFlushInterval:  0, // allow override via on-chain config
+		ArchiveMode:        false,
 	}
 }
 
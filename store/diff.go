@@ -0,0 +1,231 @@
+package store
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	amino "github.com/tendermint/go-amino"
+	"github.com/tendermint/iavl"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// KeyDiff describes a key that has a different value in two versions of the app state, a nil
+// value indicates that the key doesn't exist in that version.
+type KeyDiff struct {
+	Key   []byte
+	Left  []byte
+	Right []byte
+}
+
+// DiffIAVLStores compares the given version of the left store with the given version of the right
+// store, and calls onDiff for every key that has a different value in the two versions.
+// Keys are reported in key order. Subtrees that are identical in both versions are skipped, so
+// the cost of the comparison depends on the number of differences rather than the size of the
+// stores.
+// The left & right store may be the same store, in which case two versions of it are compared.
+func DiffIAVLStores(
+	left *IAVLStore, leftVersion int64, right *IAVLStore, rightVersion int64, onDiff func(*KeyDiff),
+) error {
+	leftTree, releaseLeft, err := left.getImmutableTree(leftVersion)
+	if err != nil {
+		return err
+	}
+	defer releaseLeft()
+	rightTree, releaseRight, err := right.getImmutableTree(rightVersion)
+	if err != nil {
+		return err
+	}
+	defer releaseRight()
+	// identical trees have identical root hashes
+	if bytes.Equal(leftTree.Hash(), rightTree.Hash()) {
+		return nil
+	}
+
+	leftNodes := &diffNodeReader{db: left.db}
+	rightNodes := &diffNodeReader{db: right.db}
+	if !leftNodes.has(leftTree.Hash()) || !rightNodes.has(rightTree.Hash()) {
+		// versions that haven't been flushed to disk can't be walked node by node
+		diffIAVLTrees(leftTree, rightTree, onDiff)
+		return nil
+	}
+	return diffIAVLNodes(leftNodes, leftTree.Hash(), rightNodes, rightTree.Hash(), onDiff)
+}
+
+// diffIAVLTrees compares two trees by iterating over all the keys in both of them.
+func diffIAVLTrees(leftTree, rightTree *iavl.ImmutableTree, onDiff func(*KeyDiff)) {
+	var diffs []*KeyDiff
+	leftTree.Iterate(func(key, leftValue []byte) bool {
+		_, rightValue := rightTree.Get(key)
+		if !bytes.Equal(leftValue, rightValue) {
+			diffs = append(diffs, &KeyDiff{Key: key, Left: leftValue, Right: rightValue})
+		}
+		return false
+	})
+	i := 0
+	rightTree.Iterate(func(key, rightValue []byte) bool {
+		if leftTree.Has(key) {
+			return false
+		}
+		// keep the diffs in key order
+		for ; i < len(diffs) && bytes.Compare(diffs[i].Key, key) < 0; i++ {
+			onDiff(diffs[i])
+		}
+		onDiff(&KeyDiff{Key: key, Right: rightValue})
+		return false
+	})
+	for ; i < len(diffs); i++ {
+		onDiff(diffs[i])
+	}
+}
+
+// diffIAVLNodes walks two trees in key order, loading their nodes from the DB, and skips any
+// subtrees that have the same hash in both trees.
+func diffIAVLNodes(
+	leftNodes *diffNodeReader, leftRoot []byte, rightNodes *diffNodeReader, rightRoot []byte,
+	onDiff func(*KeyDiff),
+) error {
+	// The nodes on top of each stack cover the lowest keys that haven't been compared yet, so if the
+	// top nodes have the same hash they can be skipped together.
+	leftStack := []*diffNode{{hash: leftRoot}}
+	rightStack := []*diffNode{{hash: rightRoot}}
+	for len(leftStack) > 0 && len(rightStack) > 0 {
+		l, r := leftStack[len(leftStack)-1], rightStack[len(rightStack)-1]
+		if bytes.Equal(l.hash, r.hash) {
+			leftStack = leftStack[:len(leftStack)-1]
+			rightStack = rightStack[:len(rightStack)-1]
+			continue
+		}
+		if err := leftNodes.load(l); err != nil {
+			return err
+		}
+		if err := rightNodes.load(r); err != nil {
+			return err
+		}
+		// expand the taller node until both top nodes are leaves
+		if !l.isLeaf() && l.height >= r.height {
+			leftStack = l.expand(leftStack[:len(leftStack)-1])
+			continue
+		}
+		if !r.isLeaf() {
+			rightStack = r.expand(rightStack[:len(rightStack)-1])
+			continue
+		}
+		switch bytes.Compare(l.key, r.key) {
+		case 0:
+			if !bytes.Equal(l.value, r.value) {
+				onDiff(&KeyDiff{Key: l.key, Left: l.value, Right: r.value})
+			}
+			leftStack = leftStack[:len(leftStack)-1]
+			rightStack = rightStack[:len(rightStack)-1]
+		case -1:
+			onDiff(&KeyDiff{Key: l.key, Left: l.value})
+			leftStack = leftStack[:len(leftStack)-1]
+		default:
+			onDiff(&KeyDiff{Key: r.key, Right: r.value})
+			rightStack = rightStack[:len(rightStack)-1]
+		}
+	}
+	// whatever remains only exists on one side
+	for _, s := range []struct {
+		nodes *diffNodeReader
+		stack []*diffNode
+		left  bool
+	}{{leftNodes, leftStack, true}, {rightNodes, rightStack, false}} {
+		stack := s.stack
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if err := s.nodes.load(n); err != nil {
+				return err
+			}
+			if !n.isLeaf() {
+				stack = n.expand(stack)
+				continue
+			}
+			if s.left {
+				onDiff(&KeyDiff{Key: n.key, Left: n.value})
+			} else {
+				onDiff(&KeyDiff{Key: n.key, Right: n.value})
+			}
+		}
+	}
+	return nil
+}
+
+// diffNode is an IAVL tree node, only the hash is known until the node is loaded from the DB.
+type diffNode struct {
+	hash      []byte
+	loaded    bool
+	height    int8
+	key       []byte
+	value     []byte
+	leftHash  []byte
+	rightHash []byte
+}
+
+func (n *diffNode) isLeaf() bool {
+	return n.height == 0
+}
+
+// expand pushes the children of the node onto the given stack so that the left child is on top.
+func (n *diffNode) expand(stack []*diffNode) []*diffNode {
+	return append(stack, &diffNode{hash: n.rightHash}, &diffNode{hash: n.leftHash})
+}
+
+// diffNodeReader loads IAVL nodes directly from the DB the tree is stored in.
+type diffNodeReader struct {
+	db dbm.DB
+}
+
+// Must match the node key format used by the IAVL node DB.
+func (r *diffNodeReader) nodeKey(hash []byte) []byte {
+	return append([]byte{'n'}, hash...)
+}
+
+func (r *diffNodeReader) has(hash []byte) bool {
+	return r.db.Has(r.nodeKey(hash))
+}
+
+// load decodes the node stored in the DB under the node's hash, the encoding must match
+// iavl.MakeNode.
+func (r *diffNodeReader) load(node *diffNode) error {
+	if node.loaded {
+		return nil
+	}
+	buf := r.db.Get(r.nodeKey(node.hash))
+	if buf == nil {
+		return errors.Errorf("IAVL node %X not found", node.hash)
+	}
+	var err error
+	var n int
+	if node.height, n, err = amino.DecodeInt8(buf); err != nil {
+		return errors.Wrap(err, "failed to decode node height")
+	}
+	buf = buf[n:]
+	// skip the size & version
+	for i := 0; i < 2; i++ {
+		if _, n, err = amino.DecodeVarint(buf); err != nil {
+			return errors.Wrap(err, "failed to decode node header")
+		}
+		buf = buf[n:]
+	}
+	if node.key, n, err = amino.DecodeByteSlice(buf); err != nil {
+		return errors.Wrap(err, "failed to decode node key")
+	}
+	buf = buf[n:]
+	if node.isLeaf() {
+		if node.value, _, err = amino.DecodeByteSlice(buf); err != nil {
+			return errors.Wrap(err, "failed to decode node value")
+		}
+	} else {
+		if node.leftHash, n, err = amino.DecodeByteSlice(buf); err != nil {
+			return errors.Wrap(err, "failed to decode left node hash")
+		}
+		buf = buf[n:]
+		if node.rightHash, _, err = amino.DecodeByteSlice(buf); err != nil {
+			return errors.Wrap(err, "failed to decode right node hash")
+		}
+	}
+	node.loaded = true
+	return nil
+}
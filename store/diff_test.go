@@ -0,0 +1,69 @@
+package store
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+func TestDiffIAVLStores(t *testing.T) {
+	store, err := NewIAVLStore(dbm.NewMemDB(), 0, 0, 0)
+	require.NoError(t, err)
+	store.Set([]byte("a"), []byte("1"))
+	store.Set([]byte("b"), []byte("2"))
+	store.Set([]byte("c"), []byte("3"))
+	_, _, err = store.SaveVersion()
+	require.NoError(t, err)
+	store.Set([]byte("b"), []byte("20"))
+	store.Delete([]byte("c"))
+	store.Set([]byte("d"), []byte("4"))
+	_, _, err = store.SaveVersion()
+	require.NoError(t, err)
+
+	var diffs []*KeyDiff
+	require.NoError(t, DiffIAVLStores(store, 1, store, 2, func(diff *KeyDiff) {
+		diffs = append(diffs, diff)
+	}))
+	require.Equal(t, []*KeyDiff{
+		{Key: []byte("b"), Left: []byte("2"), Right: []byte("20")},
+		{Key: []byte("c"), Left: []byte("3")},
+		{Key: []byte("d"), Right: []byte("4")},
+	}, diffs)
+
+	diffs = nil
+	require.NoError(t, DiffIAVLStores(store, 2, store, 2, func(diff *KeyDiff) {
+		diffs = append(diffs, diff)
+	}))
+	require.Empty(t, diffs)
+}
+
+func TestDiffIAVLStoresInDifferentDBs(t *testing.T) {
+	left, err := NewIAVLStore(dbm.NewMemDB(), 0, 0, -1)
+	require.NoError(t, err)
+	right, err := NewIAVLStore(dbm.NewMemDB(), 0, 0, -1)
+	require.NoError(t, err)
+	for i := 0; i < 200; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		left.Set(key, []byte("value"))
+		right.Set(key, []byte("value"))
+	}
+	left.Set([]byte("key050"), []byte("changed"))
+	left.Delete([]byte("key100"))
+	right.Set([]byte("key150a"), []byte("value"))
+	_, _, err = left.SaveVersion()
+	require.NoError(t, err)
+	_, _, err = right.SaveVersion()
+	require.NoError(t, err)
+
+	var diffs []*KeyDiff
+	require.NoError(t, DiffIAVLStores(left, 1, right, 1, func(diff *KeyDiff) {
+		diffs = append(diffs, diff)
+	}))
+	require.Equal(t, []*KeyDiff{
+		{Key: []byte("key050"), Left: []byte("changed"), Right: []byte("value")},
+		{Key: []byte("key100"), Right: []byte("value")},
+		{Key: []byte("key150a"), Right: []byte("value")},
+	}, diffs)
+}
@@ -34,6 +34,8 @@ type EventStore interface {
 	FilterEvents(filter EventFilter) ([]*types.EventData, error)
 	// ContractID mapping
 	GetContractID(pluginName string) uint64
+	// RollbackEvents deletes the events of all the blocks above the given height
+	RollbackEvents(blockHeight uint64) error
 }
 
 type KVEventStore struct {
@@ -116,6 +118,33 @@ func (s *KVEventStore) FilterEvents(filter EventFilter) ([]*types.EventData, err
 	return events, nil
 }
 
+func (s *KVEventStore) RollbackEvents(blockHeight uint64) error {
+	start := prefixBlockHeightEventIndex(blockHeight+1, 0)
+	end := util.PrefixRangeEnd([]byte{blockHeightKeyPrefix})
+	itr := s.Iterator(start, end)
+	defer itr.Close()
+
+	batch := s.NewBatch()
+	for ; itr.Valid(); itr.Next() {
+		var ed types.EventData
+		if err := proto.Unmarshal(itr.Value(), &ed); err != nil {
+			return err
+		}
+		contractName := ed.PluginName
+		if contractName == "" {
+			contractName = loom.UnmarshalAddressPB(ed.Address).String()
+		}
+		// the index of the event is the last part of the key
+		key := itr.Key()
+		eventIndex := binary.BigEndian.Uint16(key[len(key)-2:])
+		contractID := bytesToUint64(s.Get(prefixPluginName(contractName)))
+		batch.Delete(key)
+		batch.Delete(prefixContractIDBlockHightEventIndex(contractID, ed.BlockHeight, eventIndex))
+	}
+	batch.Write()
+	return nil
+}
+
 func (s *KVEventStore) GetContractID(pluginName string) uint64 {
 	data := s.Get(prefixPluginName(pluginName))
 	id := bytesToUint64(data)
@@ -228,3 +228,27 @@ func TestEventStoreFilterMultiplePluginsMemDB(t *testing.T) {
 	_, err = eventStore.FilterEvents(filter4)
 	require.Nil(t, err)
 }
+
+func TestEventStoreRollbackEvents(t *testing.T) {
+	memdb := dbm.NewMemDB()
+	eventStore := NewKVEventStore(memdb)
+
+	var events []*types.EventData
+	for height := uint64(1); height <= 3; height++ {
+		events = append(events, &types.EventData{
+			PluginName:  "plugin1",
+			BlockHeight: height,
+			EncodedBody: []byte(fmt.Sprintf("event%d", height)),
+		})
+	}
+	require.NoError(t, eventStore.BatchSaveEvents(events))
+
+	require.NoError(t, eventStore.RollbackEvents(1))
+	filtered, err := eventStore.FilterEvents(EventFilter{FromBlock: 1, ToBlock: 3})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+	require.EqualValues(t, 1, filtered[0].BlockHeight)
+	filtered, err = eventStore.FilterEvents(EventFilter{FromBlock: 1, ToBlock: 3, Contract: "plugin1"})
+	require.NoError(t, err)
+	require.Len(t, filtered, 1)
+}
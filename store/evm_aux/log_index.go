@@ -0,0 +1,136 @@
+package evmaux
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/loomnetwork/go-loom/plugin/types"
+	"github.com/loomnetwork/go-loom/util"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// The log index maps the address & topics of each EVM event log to the location of the log, i.e.
+// (height, tx index, log index), so logs can be looked up without having to scan every block in
+// the queried range. Index keys are made up of the prefix, len(value), value, height, tx index, and
+// log index, for topics the prefix includes the position of the topic in the log.
+var (
+	logAddressIndexPrefix = []byte("lia")
+	logTopicIndexPrefix   = []byte("lit")
+	// Height of the first block whose logs were indexed.
+	logIndexStartKey = []byte("lis")
+)
+
+// LogRef identifies an event log emitted by an EVM tx.
+type LogRef struct {
+	Height   uint64
+	TxIndex  uint32
+	LogIndex uint32
+	TxHash   []byte
+}
+
+func logIndexValuePrefix(prefix []byte, value []byte) []byte {
+	return util.PrefixKey(prefix, append([]byte{byte(len(value))}, value...))
+}
+
+func topicIndexPrefix(position int) []byte {
+	return append(append([]byte{}, logTopicIndexPrefix...), byte(position))
+}
+
+func logIndexKey(valuePrefix []byte, height uint64, txIndex, logIndex uint32) []byte {
+	key := make([]byte, len(valuePrefix)+16)
+	copy(key, valuePrefix)
+	binary.BigEndian.PutUint64(key[len(valuePrefix):], height)
+	binary.BigEndian.PutUint32(key[len(valuePrefix)+8:], txIndex)
+	binary.BigEndian.PutUint32(key[len(valuePrefix)+12:], logIndex)
+	return key
+}
+
+// logIndexKeys returns the index keys of all the logs in the given receipt.
+func logIndexKeys(receipt *types.EvmTxReceipt) [][]byte {
+	var keys [][]byte
+	height := uint64(receipt.BlockNumber)
+	txIndex := uint32(receipt.TransactionIndex)
+	for i, log := range receipt.Logs {
+		if log.Address != nil {
+			valuePrefix := logIndexValuePrefix(logAddressIndexPrefix, log.Address.Local)
+			keys = append(keys, logIndexKey(valuePrefix, height, txIndex, uint32(i)))
+		}
+		for pos, topic := range log.Topics {
+			valuePrefix := logIndexValuePrefix(topicIndexPrefix(pos), []byte(topic))
+			keys = append(keys, logIndexKey(valuePrefix, height, txIndex, uint32(i)))
+		}
+	}
+	return keys
+}
+
+// IndexLogs adds the logs in the given receipt to the log index. The height of the first block
+// that had its logs indexed is recorded, since the logs in earlier blocks can't be found via the index.
+func (s *EvmAuxStore) IndexLogs(batch dbm.SetDeleter, receipt *types.EvmTxReceipt) {
+	if len(receipt.Logs) == 0 {
+		return
+	}
+	if !s.db.Has(logIndexStartKey) {
+		startHeight := make([]byte, 8)
+		binary.BigEndian.PutUint64(startHeight, uint64(receipt.BlockNumber))
+		batch.Set(logIndexStartKey, startHeight)
+	}
+	for _, key := range logIndexKeys(receipt) {
+		batch.Set(key, receipt.TxHash)
+	}
+}
+
+// UnindexLogs removes the logs in the given receipt from the log index.
+func (s *EvmAuxStore) UnindexLogs(batch dbm.SetDeleter, receipt *types.EvmTxReceipt) {
+	for _, key := range logIndexKeys(receipt) {
+		batch.Delete(key)
+	}
+}
+
+// LogIndexStartHeight returns the height of the first block that had its logs indexed, the logs in
+// any earlier blocks aren't in the index. Returns false if no logs have been indexed yet.
+func (s *EvmAuxStore) LogIndexStartHeight() (uint64, bool) {
+	value := s.db.Get(logIndexStartKey)
+	if len(value) != 8 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(value), true
+}
+
+// AddressLogRefs returns the locations of up to limit logs emitted by the contract with the given
+// address in the given (inclusive) block range, a limit of zero means no limit.
+func (s *EvmAuxStore) AddressLogRefs(address []byte, from, to uint64, limit int) []LogRef {
+	return s.getLogRefs(logIndexValuePrefix(logAddressIndexPrefix, address), from, to, limit)
+}
+
+// TopicLogRefs returns the locations of up to limit logs that have the given topic at the given
+// position in the given (inclusive) block range, a limit of zero means no limit.
+func (s *EvmAuxStore) TopicLogRefs(position int, topic string, from, to uint64, limit int) []LogRef {
+	return s.getLogRefs(logIndexValuePrefix(topicIndexPrefix(position), []byte(topic)), from, to, limit)
+}
+
+func (s *EvmAuxStore) getLogRefs(valuePrefix []byte, from, to uint64, limit int) []LogRef {
+	start := logIndexKey(valuePrefix, from, 0, 0)
+	var end []byte
+	if to == math.MaxUint64 {
+		end = util.PrefixRangeEnd(valuePrefix)
+	} else {
+		end = logIndexKey(valuePrefix, to+1, 0, 0)
+	}
+
+	var refs []LogRef
+	iter := s.db.Iterator(start, end)
+	defer iter.Close()
+	for ; iter.Valid() && (limit <= 0 || len(refs) < limit); iter.Next() {
+		key := iter.Key()[len(valuePrefix):]
+		if len(key) != 16 {
+			continue
+		}
+		refs = append(refs, LogRef{
+			Height:   binary.BigEndian.Uint64(key),
+			TxIndex:  binary.BigEndian.Uint32(key[8:]),
+			LogIndex: binary.BigEndian.Uint32(key[12:]),
+			TxHash:   append([]byte{}, iter.Value()...),
+		})
+	}
+	return refs
+}
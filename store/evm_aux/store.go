@@ -3,23 +3,24 @@ package evmaux
 import (
 	"encoding/binary"
 	"os"
+	"path/filepath"
 
 	"github.com/gogo/protobuf/proto"
 	"github.com/loomnetwork/go-loom/plugin/types"
 	"github.com/loomnetwork/go-loom/util"
+	cdb "github.com/loomnetwork/loomchain/db"
 	"github.com/pkg/errors"
-	"github.com/syndtr/goleveldb/leveldb"
-	goleveldb "github.com/syndtr/goleveldb/leveldb"
-	goutil "github.com/syndtr/goleveldb/leveldb/util"
+	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
 var (
 	EvmAuxDBName = "receipts_db"
 
-	BloomPrefix     = []byte("bf")
-	TxHashPrefix    = []byte("th")
-	txRefPrefix     = []byte("txr")
-	dupTxHashPrefix = []byte("dtx")
+	BloomPrefix      = []byte("bf")
+	TxHashPrefix     = []byte("th")
+	txRefPrefix      = []byte("txr")
+	dupTxHashPrefix  = []byte("dtx")
+	revertDataPrefix = []byte("rvt")
 )
 
 func dupTxHashKey(txHash []byte) []byte {
@@ -40,8 +41,9 @@ func blockHeightToBytes(height uint64) []byte {
 	return heightB
 }
 
-func LoadStore() (*EvmAuxStore, error) {
-	evmAuxDB, err := goleveldb.OpenFile(EvmAuxDBName, nil)
+// LoadStore loads the EVM auxiliary store from a DB of the given backend type.
+func LoadStore(dbBackend string) (*EvmAuxStore, error) {
+	evmAuxDB, err := LoadDB(dbBackend)
 	if err != nil {
 		return nil, err
 	}
@@ -49,12 +51,9 @@ func LoadStore() (*EvmAuxStore, error) {
 
 	// load duplicate tx hashes from db
 	dupEVMTxHashes := make(map[string]bool)
-	iter := evmAuxDB.NewIterator(
-		&goutil.Range{Start: dupTxHashPrefix, Limit: util.PrefixRangeEnd(dupTxHashPrefix)},
-		nil,
-	)
-	defer iter.Release()
-	for iter.Next() {
+	iter := evmAuxDB.Iterator(dupTxHashPrefix, util.PrefixRangeEnd(dupTxHashPrefix))
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
 		dupTxHash, err := util.UnprefixKey(iter.Key(), dupTxHashPrefix)
 		if err != nil {
 			return nil, err
@@ -66,6 +65,33 @@ func LoadStore() (*EvmAuxStore, error) {
 	return evmAuxStore, nil
 }
 
+// LoadDB opens the DB that backs the EVM auxiliary store.
+// The goleveldb DB predates db.LoadDB so it's stored in EvmAuxDBName, rather than in
+// EvmAuxDBName.db like the DBs of all the other backends.
+func LoadDB(dbBackend string) (cdb.DBWrapper, error) {
+	return LoadDBFromDir(dbBackend, ".")
+}
+
+// LoadDBFromDir opens the DB that backs the EVM auxiliary store in the given directory.
+func LoadDBFromDir(dbBackend, dir string) (cdb.DBWrapper, error) {
+	if isLegacyBackend(dbBackend) {
+		return cdb.LoadLegacyGoLevelDB(DBPath(dbBackend, dir), nil)
+	}
+	return cdb.LoadDB(dbBackend, EvmAuxDBName, dir, 0, 0, false)
+}
+
+// DBPath returns the path of the DB that backs the EVM auxiliary store in the given directory.
+func DBPath(dbBackend, dir string) string {
+	if isLegacyBackend(dbBackend) {
+		return filepath.Join(dir, EvmAuxDBName)
+	}
+	return filepath.Join(dir, EvmAuxDBName+".db")
+}
+
+func isLegacyBackend(dbBackend string) bool {
+	return dbBackend == "" || dbBackend == cdb.GoLevelDBBackend
+}
+
 // ChildTxRef links a Tendermint tx hash to an EVM tx hash.
 type ChildTxRef struct {
 	ParentTxHash []byte
@@ -73,11 +99,11 @@ type ChildTxRef struct {
 }
 
 type EvmAuxStore struct {
-	db             *leveldb.DB
+	db             cdb.DBWrapper
 	dupEVMTxHashes map[string]bool
 }
 
-func NewEvmAuxStore(db *leveldb.DB) *EvmAuxStore {
+func NewEvmAuxStore(db cdb.DBWrapper) *EvmAuxStore {
 	return &EvmAuxStore{
 		db:             db,
 		dupEVMTxHashes: make(map[string]bool),
@@ -85,7 +111,8 @@ func NewEvmAuxStore(db *leveldb.DB) *EvmAuxStore {
 }
 
 func (s *EvmAuxStore) Close() error {
-	return s.db.Close()
+	s.db.Close()
+	return nil
 }
 
 func (s *EvmAuxStore) SetDupEVMTxHashes(dupEVMTxHashes map[string]bool) {
@@ -97,31 +124,22 @@ func (s *EvmAuxStore) GetDupEVMTxHashes() map[string]bool {
 }
 
 func (s *EvmAuxStore) GetBloomFilter(height uint64) []byte {
-	filter, err := s.db.Get(bloomFilterKey(height), nil)
-	if err != nil && err != leveldb.ErrNotFound {
-		panic(err)
-	}
-	if err == leveldb.ErrNotFound {
-		return nil
-	}
-	return filter
+	return s.db.Get(bloomFilterKey(height))
 }
 
 func (s *EvmAuxStore) GetTxHashList(height uint64) ([][]byte, error) {
-	protHashList, err := s.db.Get(evmTxHashKey(height), nil)
-	if err != nil && err != leveldb.ErrNotFound {
-		return nil, err
-	}
-	if err == leveldb.ErrNotFound {
+	protHashList := s.db.Get(evmTxHashKey(height))
+	if protHashList == nil {
 		return [][]byte{}, nil
 	}
 	txHashList := types.EthTxHashList{}
-	err = proto.Unmarshal(protHashList, &txHashList)
+	err := proto.Unmarshal(protHashList, &txHashList)
 	return txHashList.EthTxHash, err
 }
 
-func (s *EvmAuxStore) SetBloomFilter(tran *leveldb.Transaction, filter []byte, height uint64) error {
-	return tran.Put(bloomFilterKey(height), filter, nil)
+func (s *EvmAuxStore) SetBloomFilter(batch dbm.SetDeleter, filter []byte, height uint64) error {
+	batch.Set(bloomFilterKey(height), filter)
+	return nil
 }
 
 func (s *EvmAuxStore) IsDupEVMTxHash(txHash []byte) bool {
@@ -129,12 +147,12 @@ func (s *EvmAuxStore) IsDupEVMTxHash(txHash []byte) bool {
 	return ok
 }
 
-func (s *EvmAuxStore) SetTxHashList(tran *leveldb.Transaction, txHashList [][]byte, height uint64) error {
+func (s *EvmAuxStore) SetTxHashList(batch dbm.SetDeleter, txHashList [][]byte, height uint64) error {
 	postTxHashList, err := proto.Marshal(&types.EthTxHashList{EthTxHash: txHashList})
 	if err != nil {
 		return errors.Wrap(err, "marshal tx hash list")
 	}
-	tran.Put(evmTxHashKey(height), postTxHashList, nil)
+	batch.Set(evmTxHashKey(height), postTxHashList)
 	return nil
 }
 
@@ -144,31 +162,54 @@ func (s *EvmAuxStore) SaveChildTxRefs(refs []ChildTxRef) error {
 		return nil
 	}
 
-	tran, err := s.db.OpenTransaction()
-	if err != nil {
-		return errors.Wrap(err, "failed to open tx in EvmAuxStore")
-	}
-	defer tran.Discard()
-
+	batch := s.db.NewBatch()
 	for _, ref := range refs {
-		tran.Put(util.PrefixKey(txRefPrefix, ref.ParentTxHash), ref.ChildTxHash, nil)
+		batch.Set(util.PrefixKey(txRefPrefix, ref.ParentTxHash), ref.ChildTxHash)
 	}
+	batch.Write()
 
-	if err := tran.Commit(); err != nil {
-		return errors.Wrap(err, "failed to commit tx in EvmAuxStore")
+	return nil
+}
+
+// DeleteChildTxRefs removes the references saved for the given Tendermint tx hashes.
+func (s *EvmAuxStore) DeleteChildTxRefs(parentTxHashes [][]byte) {
+	if len(parentTxHashes) == 0 {
+		return
 	}
 
-	return nil
+	batch := s.db.NewBatch()
+	for _, txHash := range parentTxHashes {
+		batch.Delete(util.PrefixKey(txRefPrefix, txHash))
+	}
+	batch.Write()
 }
 
 // GetChildTxHash looks up the EVM tx hash that corresponds to the given Tendermint tx hash.
 func (s *EvmAuxStore) GetChildTxHash(parentTxHash []byte) ([]byte, error) {
-	return s.db.Get(util.PrefixKey(txRefPrefix, parentTxHash), nil)
+	return s.db.Get(util.PrefixKey(txRefPrefix, parentTxHash)), nil
+}
+
+// SetRevertData stores the data returned by a reverted EVM tx with the given hash.
+func (s *EvmAuxStore) SetRevertData(batch dbm.SetDeleter, txHash, data []byte) {
+	batch.Set(util.PrefixKey(revertDataPrefix, txHash), data)
+}
+
+// DeleteRevertData removes the data returned by a reverted EVM tx with the given hash.
+func (s *EvmAuxStore) DeleteRevertData(batch dbm.SetDeleter, txHash []byte) {
+	batch.Delete(util.PrefixKey(revertDataPrefix, txHash))
+}
+
+// GetRevertData looks up the data returned by a reverted EVM tx with the given hash, returns nil if
+// the tx wasn't reverted, or didn't return any data.
+func (s *EvmAuxStore) GetRevertData(txHash []byte) []byte {
+	return s.db.Get(util.PrefixKey(revertDataPrefix, txHash))
 }
 
-func (s *EvmAuxStore) DB() *leveldb.DB {
+func (s *EvmAuxStore) DB() cdb.DBWrapper {
 	return s.db
 }
+
 func (s *EvmAuxStore) ClearData() {
 	os.RemoveAll(EvmAuxDBName)
+	os.RemoveAll(EvmAuxDBName + ".db")
 }
@@ -5,30 +5,38 @@ import (
 	"fmt"
 	"testing"
 
+	cdb "github.com/loomnetwork/loomchain/db"
 	"github.com/stretchr/testify/require"
 )
 
 func TestLoadDupEvmTxHashes(t *testing.T) {
+	for _, dbBackend := range []string{cdb.GoLevelDBBackend, cdb.BadgerDBBackend} {
+		t.Run(dbBackend, func(t *testing.T) {
+			testLoadDupEvmTxHashes(t, dbBackend)
+		})
+	}
+}
 
+func testLoadDupEvmTxHashes(t *testing.T, dbBackend string) {
 	// load to set dup tx hashes
-	evmAuxStore, err := LoadStore()
+	evmAuxStore, err := LoadStore(dbBackend)
 
 	require.NoError(t, err)
 	// add dup EVM txhash keys prefixed with dtx
 	for i := 0; i < 100; i++ {
-		evmAuxStore.db.Put(dupTxHashKey([]byte(fmt.Sprintf("hash:%d", i))), []byte{1}, nil)
+		evmAuxStore.db.Set(dupTxHashKey([]byte(fmt.Sprintf("hash:%d", i))), []byte{1})
 	}
 	// add 100 keys prefixed with hash
 	for i := 0; i < 100; i++ {
-		evmAuxStore.db.Put([]byte(fmt.Sprintf("hash:%d", i)), []byte{1}, nil)
+		evmAuxStore.db.Set([]byte(fmt.Sprintf("hash:%d", i)), []byte{1})
 	}
 	// add another 100 keys prefixed with ahash
 	for i := 0; i < 100; i++ {
-		evmAuxStore.db.Put([]byte(fmt.Sprintf("ahash:%d", i)), []byte{1}, nil)
+		evmAuxStore.db.Set([]byte(fmt.Sprintf("ahash:%d", i)), []byte{1})
 	}
 	require.NoError(t, evmAuxStore.Close())
 
-	evmAuxStore2, err := LoadStore()
+	evmAuxStore2, err := LoadStore(dbBackend)
 	require.NoError(t, err)
 	dupEvmTxHashes := evmAuxStore2.GetDupEVMTxHashes()
 	require.Equal(t, 100, len(dupEvmTxHashes))
@@ -41,16 +49,14 @@ func TestTxHashOperation(t *testing.T) {
 		[]byte("hash1"),
 		[]byte("hash2"),
 	}
-	evmAuxStore, err := LoadStore()
+	evmAuxStore, err := LoadStore(cdb.GoLevelDBBackend)
 	require.NoError(t, err)
 	txHashList, err := evmAuxStore.GetTxHashList(40)
 	require.NoError(t, err)
 	require.Equal(t, 0, len(txHashList))
-	db := evmAuxStore.DB()
-	tran, err := db.OpenTransaction()
-	require.NoError(t, err)
-	evmAuxStore.SetTxHashList(tran, txHashList1, 30)
-	tran.Commit()
+	batch := evmAuxStore.DB().NewBatch()
+	require.NoError(t, evmAuxStore.SetTxHashList(batch, txHashList1, 30))
+	batch.Write()
 	txHashList, err = evmAuxStore.GetTxHashList(30)
 	require.NoError(t, err)
 	require.Equal(t, 2, len(txHashList))
@@ -61,15 +67,13 @@ func TestTxHashOperation(t *testing.T) {
 
 func TestBloomFilterOperation(t *testing.T) {
 	bf1 := []byte("bloomfilter1")
-	evmAuxStore, err := LoadStore()
+	evmAuxStore, err := LoadStore(cdb.GoLevelDBBackend)
 	require.NoError(t, err)
 	bf := evmAuxStore.GetBloomFilter(40)
 	require.Nil(t, bf)
-	db := evmAuxStore.DB()
-	tran, err := db.OpenTransaction()
-	require.NoError(t, err)
-	evmAuxStore.SetBloomFilter(tran, bf1, 30)
-	tran.Commit()
+	batch := evmAuxStore.DB().NewBatch()
+	require.NoError(t, evmAuxStore.SetBloomFilter(batch, bf1, 30))
+	batch.Write()
 	bf = evmAuxStore.GetBloomFilter(30)
 	require.Equal(t, true, bytes.Equal(bf, bf1))
 	evmAuxStore.ClearData()
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
@@ -50,6 +51,26 @@ func getVersionFromEvmRootKey(key []byte) (int64, error) {
 	return version, nil
 }
 
+// IsEvmRootKey checks if the given evm.db key is one of the keys that store versioned Patricia
+// roots, if so the version (block height) encoded in the key is also returned.
+func IsEvmRootKey(key []byte) (bool, int64) {
+	if !util.HasPrefix(key, util.PrefixKey(vmPrefix, []byte(evmRootPrefix))) {
+		return false, 0
+	}
+	version, err := getVersionFromEvmRootKey(key)
+	if err != nil {
+		return false, 0
+	}
+	return true, version
+}
+
+// EvmRootKeyRange returns the range of evm.db keys that store versioned Patricia roots, the start
+// key is inclusive, the end key is exclusive.
+func EvmRootKeyRange() ([]byte, []byte) {
+	prefix := util.PrefixKey(vmPrefix, []byte(evmRootPrefix))
+	return prefix, prefixRangeEnd(prefix)
+}
+
 // EvmStore persists EVM state to a DB.
 type EvmStore struct {
 	evmDB         db.DBWrapper
@@ -58,6 +79,13 @@ type EvmStore struct {
 	lastSavedRoot []byte
 	rootCache     *lru.Cache
 	version       int64
+	// Held while changes are written to evm.db, so the pruner can delete keys without racing
+	// against Commit.
+	commitMutex sync.Mutex
+	// Keys written to evm.db since the pruner started collecting garbage, these must not be deleted
+	// by the pruner since they may have been written by a version it hasn't walked.
+	// Set to nil if the pruner isn't running.
+	writtenKeys map[string]struct{}
 }
 
 // NewEvmStore returns a new instance of the store backed by the given DB.
@@ -201,6 +229,9 @@ func (s *EvmStore) Commit(version int64) []byte {
 
 	s.rootCache.Add(version, currentRoot)
 
+	s.commitMutex.Lock()
+	defer s.commitMutex.Unlock()
+
 	batch := s.evmDB.NewBatch()
 	for key, item := range s.cache {
 		if !item.Deleted {
@@ -208,6 +239,9 @@ func (s *EvmStore) Commit(version int64) []byte {
 		} else {
 			batch.Delete([]byte(key))
 		}
+		if s.writtenKeys != nil {
+			s.writtenKeys[key] = struct{}{}
+		}
 	}
 	batch.Write()
 	s.cache = make(map[string]cacheItem)
@@ -236,6 +270,37 @@ func (s *EvmStore) LoadVersion(targetVersion int64) error {
 	return nil
 }
 
+// Rollback deletes the Patricia roots of all versions above the given version from evm.db, returns
+// the number of roots that were deleted. The roots of versions that were never committed to app.db
+// must not be left behind, otherwise they may be picked up when those versions are committed again.
+func (s *EvmStore) Rollback(version int64) (int, error) {
+	s.commitMutex.Lock()
+	defer s.commitMutex.Unlock()
+
+	_, end := EvmRootKeyRange()
+	iter := s.evmDB.Iterator(evmRootKey(version+1), end)
+	var staleKeys [][]byte
+	for ; iter.Valid(); iter.Next() {
+		staleKeys = append(staleKeys, iter.Key())
+	}
+	iter.Close()
+
+	if len(staleKeys) == 0 {
+		return 0, nil
+	}
+	batch := s.evmDB.NewBatch()
+	for _, key := range staleKeys {
+		staleVersion, err := getVersionFromEvmRootKey(key)
+		if err != nil {
+			return 0, err
+		}
+		batch.Delete(key)
+		s.rootCache.Remove(staleVersion)
+	}
+	batch.WriteSync()
+	return len(staleKeys), nil
+}
+
 func (s *EvmStore) Version() ([]byte, int64) {
 	return s.rootHash, s.version
 }
@@ -257,6 +322,29 @@ func (s *EvmStore) getLastSavedRoot(targetVersion int64) ([]byte, int64) {
 	return nil, 0
 }
 
+// GetRootAt returns the Patricia root of the EVM state at the given version, the root of an empty
+// EVM state is returned as nil.
+func (s *EvmStore) GetRootAt(version int64) ([]byte, error) {
+	if version <= 0 {
+		return nil, errors.Errorf("invalid version %d", version)
+	}
+	var root []byte
+	if val, exist := s.rootCache.Get(version); exist {
+		root = val.([]byte)
+	} else {
+		root, _ = s.getLastSavedRoot(version)
+		if root == nil {
+			// roots are saved from the first version onwards, so a missing root means the pruner
+			// has deleted it
+			return nil, errors.Wrapf(ErrVersionPruned, "failed to load EVM root for version %d", version)
+		}
+	}
+	if bytes.Equal(root, defaultRoot) || len(root) == 0 {
+		return nil, nil
+	}
+	return root, nil
+}
+
 func (s *EvmStore) GetSnapshot(version int64) db.Snapshot {
 	var targetRoot []byte
 	// Expect cache to be almost 100% hit since cache miss yields extremely poor performance
@@ -269,6 +357,16 @@ func (s *EvmStore) GetSnapshot(version int64) db.Snapshot {
 	return NewEvmStoreSnapshot(s.evmDB.GetSnapshot(), targetRoot)
 }
 
+// GetSnapshotAt returns a read-only snapshot of the EVM state at the given (previously committed)
+// version, ErrVersionPruned is returned if the Patricia root for the version has been pruned.
+func (s *EvmStore) GetSnapshotAt(version int64) (db.Snapshot, error) {
+	root, err := s.GetRootAt(version)
+	if err != nil {
+		return nil, err
+	}
+	return NewEvmStoreSnapshot(s.evmDB.GetSnapshot(), root), nil
+}
+
 func NewEvmStoreSnapshot(snapshot db.Snapshot, rootHash []byte) *EvmStoreSnapshot {
 	return &EvmStoreSnapshot{
 		Snapshot: snapshot,
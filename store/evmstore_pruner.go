@@ -0,0 +1,298 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/log"
+	"github.com/pkg/errors"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	pruneEvmStoreDuration  metrics.Histogram
+	pruneEvmStoreKeysCount metrics.Counter
+)
+
+func init() {
+	const namespace = "loomchain"
+	const subsystem = "evmstore_pruner"
+
+	pruneEvmStoreDuration = kitprometheus.NewSummaryFrom(
+		stdprometheus.SummaryOpts{
+			Namespace:  namespace,
+			Subsystem:  subsystem,
+			Name:       "prune_duration",
+			Help:       "How long EvmStorePruner.Prune() took to execute (in seconds)",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, []string{"error"})
+	pruneEvmStoreKeysCount = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "num_pruned_keys",
+			Help:      "Number of unreachable trie nodes & stale roots deleted from evm.db",
+		}, []string{})
+}
+
+// Length of the evm.db keys of trie nodes & contract code, which are keyed by their hash.
+var evmNodeKeyLen = len(util.PrefixKey(vmPrefix, make([]byte, 32)))
+
+// EvmStateWalker should call visit with the evm.db key of every trie node & code blob that's
+// reachable from the given Patricia root. If visit returns false the walker should skip the
+// children of the node that was just visited.
+type EvmStateWalker func(snap db.Snapshot, root []byte, visit func(key []byte) bool) error
+
+type EvmStorePrunerConfig struct {
+	MaxVersions int64 // number of the latest EVM state versions to keep
+	BatchSize   int   // maximum number of keys to delete at a time
+	// Maximum number of reachable keys to keep track of in memory while pruning, zero for no limit.
+	MaxMarkedKeys int
+	// How often the IAVL store is flushed to disk, the app may need to reload the EVM state of any
+	// version since the last flush so those versions must not be pruned.
+	FlushInterval int64
+	Interval      time.Duration
+	Logger        *loom.Logger
+}
+
+// EvmStorePruner is a mark-and-sweep garbage collector that runs in the background & deletes
+// Patricia trie nodes (and contract code) from evm.db that aren't reachable from any of the
+// roots of the most recent versions of the EVM state. The roots of older versions are deleted too,
+// so those versions can no longer be loaded.
+type EvmStorePruner struct {
+	store         *EvmStore
+	walkState     EvmStateWalker
+	maxVersions   int64
+	batchSize     int
+	maxMarkedKeys int
+	interval      time.Duration
+	logger        *loom.Logger
+}
+
+// NewEvmStorePruner creates a new pruner for the given store, the pruner won't do anything until
+// it's started.
+func NewEvmStorePruner(
+	store *EvmStore, walkState EvmStateWalker, cfg EvmStorePrunerConfig,
+) (*EvmStorePruner, error) {
+	if cfg.MaxVersions < 2 {
+		return nil, errors.New("EvmStorePruner must keep at least 2 versions")
+	}
+	if cfg.FlushInterval > 0 && cfg.MaxVersions < cfg.FlushInterval {
+		return nil, errors.Errorf(
+			"EvmStorePruner must keep at least as many versions as the IAVL flush interval (%d)",
+			cfg.FlushInterval,
+		)
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, errors.New("EvmStorePruner batch size must be greater than zero")
+	}
+	p := &EvmStorePruner{
+		store:         store,
+		walkState:     walkState,
+		maxVersions:   cfg.MaxVersions,
+		batchSize:     cfg.BatchSize,
+		maxMarkedKeys: cfg.MaxMarkedKeys,
+		interval:      cfg.Interval,
+		logger:        cfg.Logger,
+	}
+	if p.logger == nil {
+		p.logger = log.Default
+	}
+	return p, nil
+}
+
+// Start runs the pruner in a goroutine, pausing for the configured interval between runs.
+func (p *EvmStorePruner) Start() {
+	go func() {
+		for {
+			numKeys, err := p.Prune()
+			if err != nil {
+				p.logger.Error("EvmStorePruner encountered an error", "err", err)
+			} else if numKeys > 0 {
+				p.logger.Info("EvmStorePruner deleted unreachable keys", "count", numKeys)
+			}
+			time.Sleep(p.interval)
+		}
+	}()
+}
+
+// Prune deletes all the keys in evm.db that are no longer needed to load the most recent versions
+// of the EVM state, returns the number of keys that were deleted.
+func (p *EvmStorePruner) Prune() (int, error) {
+	var err error
+	defer func(begin time.Time) {
+		lvs := []string{"error", fmt.Sprint(err != nil)}
+		pruneEvmStoreDuration.With(lvs...).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	snap, latestVersion := p.beginGC()
+	defer snap.Release()
+	defer p.endGC()
+
+	oldestVersion := latestVersion - p.maxVersions + 1
+	if oldestVersion <= 1 {
+		return 0, nil
+	}
+
+	var roots [][]byte
+	var staleRootKeys [][]byte
+	roots, staleRootKeys, err = p.findRoots(snap, oldestVersion)
+	if err != nil {
+		return 0, err
+	}
+
+	// Only the node hashes are tracked to keep the mark set small, keys of any other length are
+	// never swept anyway.
+	reachable := map[[32]byte]struct{}{}
+	var markErr error
+	for _, root := range roots {
+		err = p.walkState(snap, root, func(key []byte) bool {
+			hash, ok := evmNodeHash(key)
+			if !ok {
+				return true
+			}
+			if _, visited := reachable[hash]; visited {
+				return false
+			}
+			if p.maxMarkedKeys > 0 && len(reachable) >= p.maxMarkedKeys {
+				markErr = errors.Errorf("EVM state has more than %d reachable keys", p.maxMarkedKeys)
+				return false
+			}
+			reachable[hash] = struct{}{}
+			return true
+		})
+		if err == nil {
+			err = markErr
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to walk EVM state with root %X", root)
+		}
+	}
+
+	numDeleted := p.deleteKeys(staleRootKeys)
+	batch := make([][]byte, 0, p.batchSize)
+	iter := snap.NewIterator(vmPrefix, prefixRangeEnd(vmPrefix))
+	defer iter.Close()
+	for ; iter.Valid(); iter.Next() {
+		key := iter.Key()
+		hash, ok := evmNodeHash(key)
+		if !ok {
+			continue
+		}
+		if _, ok := reachable[hash]; ok {
+			continue
+		}
+		batch = append(batch, key)
+		if len(batch) == p.batchSize {
+			numDeleted += p.deleteKeys(batch)
+			batch = batch[:0]
+		}
+	}
+	numDeleted += p.deleteKeys(batch)
+
+	// make sure pruned versions can't be loaded from the cache
+	for _, key := range p.store.rootCache.Keys() {
+		if version, ok := key.(int64); ok && version < oldestVersion {
+			p.store.rootCache.Remove(key)
+		}
+	}
+	return numDeleted, nil
+}
+
+// findRoots returns the Patricia roots of all the versions from oldestVersion onwards, and the
+// keys of the roots of any older versions.
+func (p *EvmStorePruner) findRoots(snap db.Snapshot, oldestVersion int64) ([][]byte, [][]byte, error) {
+	start, end := EvmRootKeyRange()
+	iter := snap.NewIterator(start, end)
+	defer iter.Close()
+
+	var roots [][]byte
+	var staleRootKeys [][]byte
+	// Roots are only saved when they change, so the root of the oldest version is the one saved at,
+	// or most recently before, that version.
+	var baseRootKey, baseRoot []byte
+	for ; iter.Valid(); iter.Next() {
+		version, err := getVersionFromEvmRootKey(iter.Key())
+		if err != nil {
+			return nil, nil, err
+		}
+		if version <= oldestVersion {
+			if baseRootKey != nil {
+				staleRootKeys = append(staleRootKeys, baseRootKey)
+			}
+			baseRootKey, baseRoot = iter.Key(), iter.Value()
+			continue
+		}
+		roots = appendEvmRoot(roots, iter.Value())
+	}
+	if baseRoot != nil {
+		roots = appendEvmRoot(roots, baseRoot)
+	}
+	return roots, staleRootKeys, nil
+}
+
+// evmNodeHash extracts the hash from the evm.db key of a trie node or contract code, returns false
+// if the key isn't one of those.
+func evmNodeHash(key []byte) ([32]byte, bool) {
+	var hash [32]byte
+	if len(key) != evmNodeKeyLen {
+		return hash, false
+	}
+	copy(hash[:], key[evmNodeKeyLen-32:])
+	return hash, true
+}
+
+// appendEvmRoot appends the given root to the list, unless the root indicates empty EVM state.
+func appendEvmRoot(roots [][]byte, root []byte) [][]byte {
+	if len(root) == 0 || bytes.Equal(root, defaultRoot) {
+		return roots
+	}
+	return append(roots, root)
+}
+
+// beginGC takes a snapshot of evm.db, and starts tracking all the keys written to the store from
+// that point onwards.
+func (p *EvmStorePruner) beginGC() (db.Snapshot, int64) {
+	p.store.commitMutex.Lock()
+	defer p.store.commitMutex.Unlock()
+
+	p.store.writtenKeys = map[string]struct{}{}
+	return p.store.evmDB.GetSnapshot(), p.store.version
+}
+
+func (p *EvmStorePruner) endGC() {
+	p.store.commitMutex.Lock()
+	defer p.store.commitMutex.Unlock()
+
+	p.store.writtenKeys = nil
+}
+
+// deleteKeys deletes the given keys from evm.db, except for any keys that have been written since
+// the snapshot the pruner is working with was taken. Returns the number of deleted keys.
+func (p *EvmStorePruner) deleteKeys(keys [][]byte) int {
+	if len(keys) == 0 {
+		return 0
+	}
+
+	p.store.commitMutex.Lock()
+	defer p.store.commitMutex.Unlock()
+
+	numDeleted := 0
+	batch := p.store.evmDB.NewBatch()
+	for _, key := range keys {
+		if _, ok := p.store.writtenKeys[string(key)]; ok {
+			continue
+		}
+		batch.Delete(key)
+		numDeleted++
+	}
+	batch.Write()
+	pruneEvmStoreKeysCount.Add(float64(numDeleted))
+	return numDeleted
+}
@@ -2,11 +2,13 @@ package store
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"testing"
 
 	"github.com/loomnetwork/go-loom/util"
 	"github.com/loomnetwork/loomchain/db"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -165,3 +167,127 @@ func (t *EvmStoreTestSuite) TestLoadVersionEvmStore() {
 	require.Equal(true, bytes.Equal(root, []byte{100}))
 	require.Equal(int64(100), version)
 }
+
+func (t *EvmStoreTestSuite) TestGetRootAt() {
+	require := t.Require()
+	evmDb, err := db.LoadMemDB()
+	require.NoError(err)
+	evmDb.Set(evmRootKey(1), defaultRoot)
+	evmDb.Set(evmRootKey(3), []byte{3})
+	evmDb.Set(evmRootKey(100), []byte{100})
+
+	evmStore := NewEvmStore(evmDb, 100)
+	require.NoError(evmStore.LoadVersion(100))
+
+	root, err := evmStore.GetRootAt(1)
+	require.NoError(err)
+	require.Nil(root)
+
+	root, err = evmStore.GetRootAt(2)
+	require.NoError(err)
+	require.Nil(root)
+
+	root, err = evmStore.GetRootAt(50)
+	require.NoError(err)
+	require.Equal([]byte{3}, root)
+
+	root, err = evmStore.GetRootAt(100)
+	require.NoError(err)
+	require.Equal([]byte{100}, root)
+
+	_, err = evmStore.GetRootAt(0)
+	require.Error(err)
+}
+
+func (t *EvmStoreTestSuite) TestEvmStorePruner() {
+	require := t.Require()
+	evmDb, err := db.LoadMemDB()
+	require.NoError(err)
+	evmStore := NewEvmStore(evmDb, 100)
+	require.NoError(evmStore.LoadVersion(0))
+
+	nodeHash := func(name string) []byte {
+		h := sha256.Sum256([]byte(name))
+		return h[:]
+	}
+	nodeKey := func(hash []byte) []byte {
+		return util.PrefixKey(vmPrefix, hash)
+	}
+	// each root node stores the hashes of its child nodes
+	commitRoot := func(version int64, children ...[]byte) []byte {
+		root := nodeHash(fmt.Sprintf("root%d", version))
+		for _, child := range children {
+			evmStore.Set(nodeKey(child), []byte("node"))
+		}
+		evmStore.Set(nodeKey(root), bytes.Join(children, nil))
+		evmStore.Set(rootHashKey, root)
+		evmStore.Commit(version)
+		return root
+	}
+	walkState := func(snap db.Snapshot, root []byte, visit func(key []byte) bool) error {
+		if !visit(nodeKey(root)) {
+			return nil
+		}
+		children := snap.Get(nodeKey(root))
+		for i := 0; i < len(children); i += 32 {
+			visit(nodeKey(children[i : i+32]))
+		}
+		return nil
+	}
+
+	shared := nodeHash("shared")
+	var roots [][]byte
+	for i := int64(1); i <= 5; i++ {
+		roots = append(roots, commitRoot(i, shared, nodeHash(fmt.Sprintf("node%d", i))))
+	}
+	// EVM root doesn't change in the last version
+	evmStore.Commit(6)
+
+	pruner, err := NewEvmStorePruner(evmStore, walkState, EvmStorePrunerConfig{
+		MaxVersions: 2,
+		BatchSize:   3,
+	})
+	require.NoError(err)
+	numDeleted, err := pruner.Prune()
+	require.NoError(err)
+	// 4 root nodes, 4 child nodes, and 4 Patricia roots
+	require.Equal(12, numDeleted)
+
+	for i := int64(1); i <= 4; i++ {
+		require.False(evmDb.Has(nodeKey(roots[i-1])))
+		require.False(evmDb.Has(nodeKey(nodeHash(fmt.Sprintf("node%d", i)))))
+		_, err := evmStore.GetRootAt(i)
+		require.Equal(ErrVersionPruned, errors.Cause(err))
+	}
+	require.True(evmDb.Has(nodeKey(roots[4])))
+	require.True(evmDb.Has(nodeKey(nodeHash("node5"))))
+	require.True(evmDb.Has(nodeKey(shared)))
+	for _, version := range []int64{5, 6} {
+		root, err := evmStore.GetRootAt(version)
+		require.NoError(err)
+		require.Equal(roots[4], root)
+	}
+
+	// keys written while the pruner is running shouldn't be deleted even if they're unreachable
+	// from the snapshot the pruner is working with
+	snap, _ := pruner.beginGC()
+	commitRoot(7, shared, nodeHash("node1"))
+	require.Equal(0, pruner.deleteKeys([][]byte{nodeKey(nodeHash("node1"))}))
+	pruner.endGC()
+	snap.Release()
+	require.True(evmDb.Has(nodeKey(nodeHash("node1"))))
+
+	// the pruner should give up rather than track an unbounded number of keys
+	commitRoot(8, nodeHash("node8"), nodeHash("node9"))
+	pruner.maxMarkedKeys = 2
+	_, err = pruner.Prune()
+	require.Error(err)
+	require.True(evmDb.Has(nodeKey(nodeHash("node5"))))
+
+	_, err = NewEvmStorePruner(evmStore, walkState, EvmStorePrunerConfig{
+		MaxVersions:   2,
+		BatchSize:     3,
+		FlushInterval: 10,
+	})
+	require.Error(err)
+}
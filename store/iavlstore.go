@@ -2,6 +2,7 @@ package store
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/metrics"
@@ -46,8 +47,18 @@ func init() {
 
 type IAVLStore struct {
 	tree          *iavl.MutableTree
-	maxVersions   int64 // maximum number of versions to keep when pruning
-	flushInterval int64 // how often we persist to disk
+	db            dbm.DB // the DB the tree nodes are stored in
+	maxVersions   int64  // maximum number of versions to keep when pruning
+	flushInterval int64  // how often we persist to disk
+	// Guards the list of saved tree versions, which may be read from other goroutines (to load
+	// historical versions) while new versions are being saved & old ones are being deleted.
+	versionMutex       sync.RWMutex
+	lastFlushedVersion int64 // last version written to disk
+	// Number of live readers of each historical version, the nodes of a version that's being read
+	// mustn't be deleted, so pinned versions are only deleted once all their readers are done.
+	pinnedVersions map[int64]int
+	// Versions that were pinned when they were due to be deleted.
+	deferredVersions []int64
 }
 
 func (s *IAVLStore) Delete(key []byte) {
@@ -101,9 +112,13 @@ func (s *IAVLStore) Range(prefix []byte) plugin.RangeData {
 // unpredictable results, if there are N matching keys, and the limit is N, the number of keys
 // returned may be less than N.
 func (s *IAVLStore) RangeWithLimit(prefix []byte, limit int) plugin.RangeData {
+	return rangeWithLimit(s.tree.ImmutableTree, prefix, limit)
+}
+
+func rangeWithLimit(tree *iavl.ImmutableTree, prefix []byte, limit int) plugin.RangeData {
 	ret := make(plugin.RangeData, 0)
 
-	keys, values, _, err := s.tree.GetRangeWithProof(prefix, prefixRangeEnd(prefix), limit)
+	keys, values, _, err := tree.GetRangeWithProof(prefix, prefixRangeEnd(prefix), limit)
 	if err != nil {
 		log.Error("failed to get range", "err", err)
 		return ret
@@ -140,6 +155,14 @@ func (s *IAVLStore) Version() int64 {
 	return s.tree.Version()
 }
 
+// LastFlushedVersion returns the last version of the tree that was written to disk.
+func (s *IAVLStore) LastFlushedVersion() int64 {
+	s.versionMutex.RLock()
+	defer s.versionMutex.RUnlock()
+
+	return s.lastFlushedVersion
+}
+
 func (s *IAVLStore) SaveVersion() ([]byte, int64, error) {
 	var err error
 	defer func(begin time.Time) {
@@ -163,6 +186,9 @@ func (s *IAVLStore) SaveVersion() ([]byte, int64, error) {
 		flushInterval = 0
 	}
 
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
 	var version int64
 	var hash []byte
 	// Every X versions we should persist to disk
@@ -173,6 +199,9 @@ func (s *IAVLStore) SaveVersion() ([]byte, int64, error) {
 		} else {
 			hash, version, err = s.tree.SaveVersion()
 		}
+		if err == nil {
+			s.lastFlushedVersion = version
+		}
 	} else {
 		hash, version, err = s.tree.SaveVersionMem()
 	}
@@ -201,14 +230,52 @@ func (s *IAVLStore) Prune() error {
 		pruneTime.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
+	if err = s.deleteDeferredVersions(); err != nil {
+		return err
+	}
 	if s.tree.VersionExists(oldVer) {
-		if err = s.tree.DeleteVersion(oldVer); err != nil {
+		if err = s.deleteVersion(oldVer); err != nil {
 			return errors.Wrapf(err, "failed to delete tree version %d", oldVer)
 		}
 	}
 	return nil
 }
 
+// deleteVersion deletes the given version of the tree, unless the version is currently being read,
+// in which case the deletion is deferred until the next time the store is pruned.
+func (s *IAVLStore) deleteVersion(version int64) error {
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
+	if s.pinnedVersions[version] > 0 {
+		s.deferredVersions = append(s.deferredVersions, version)
+		return nil
+	}
+	return s.tree.DeleteVersion(version)
+}
+
+// deleteDeferredVersions deletes the versions whose deletion was deferred, and that are no longer
+// being read.
+func (s *IAVLStore) deleteDeferredVersions() error {
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
+	remaining := s.deferredVersions[:0]
+	for _, version := range s.deferredVersions {
+		if s.pinnedVersions[version] > 0 {
+			remaining = append(remaining, version)
+			continue
+		}
+		if s.tree.VersionExists(version) {
+			if err := s.tree.DeleteVersion(version); err != nil {
+				return errors.Wrapf(err, "failed to delete tree version %d", version)
+			}
+		}
+	}
+	s.deferredVersions = remaining
+	return nil
+}
+
 func (s *IAVLStore) GetSnapshot() Snapshot {
 	// This isn't an actual snapshot obviously, and never will be, but lets pretend...
 	return &iavlStoreSnapshot{
@@ -216,6 +283,76 @@ func (s *IAVLStore) GetSnapshot() Snapshot {
 	}
 }
 
+// GetVersionedWithProof returns the value of the given key at the given version of the tree, along
+// with a range proof of the existence (or absence) of the key. The proof can be verified against the
+// root hash of the tree at that version.
+func (s *IAVLStore) GetVersionedWithProof(key []byte, version int64) ([]byte, *iavl.RangeProof, error) {
+	tree, release, err := s.getImmutableTree(version)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	val, proof, err := tree.GetWithProof(key)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to generate proof at version %d", version)
+	}
+	return val, proof, nil
+}
+
+// GetSnapshotAt returns a read-only snapshot of the given version of the tree, the version won't be
+// pruned until the snapshot is released.
+func (s *IAVLStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	tree, release, err := s.getImmutableTree(version)
+	if err != nil {
+		return nil, err
+	}
+	return &iavlVersionSnapshot{
+		tree:    tree,
+		release: release,
+	}, nil
+}
+
+// getImmutableTree loads the given version of the tree and pins it so it can't be pruned while
+// it's being read, the returned func must be called to unpin the version once the caller is done
+// with the tree.
+func (s *IAVLStore) getImmutableTree(version int64) (*iavl.ImmutableTree, func(), error) {
+	if version <= 0 {
+		return nil, nil, errors.Errorf("invalid version %d", version)
+	}
+
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
+	latestVersion := s.tree.Version()
+	if version > latestVersion {
+		return nil, nil, errors.Errorf(
+			"version %d hasn't been saved yet, latest version is %d", version, latestVersion,
+		)
+	}
+	tree, err := s.tree.GetImmutable(version)
+	if err == nil {
+		s.pinnedVersions[version]++
+		var once sync.Once
+		return tree, func() { once.Do(func() { s.unpinVersion(version) }) }, nil
+	}
+	// Versions that are older than the retained versions have been pruned, any other version that
+	// can't be loaded must've been skipped by the flush interval.
+	if s.maxVersions > 0 && version <= latestVersion-s.maxVersions && version <= s.lastFlushedVersion {
+		return nil, nil, errors.Wrapf(ErrVersionPruned, "state at version %d is unavailable", version)
+	}
+	return nil, nil, errors.Wrapf(ErrVersionNotFlushed, "state at version %d is unavailable", version)
+}
+
+func (s *IAVLStore) unpinVersion(version int64) {
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
+	s.pinnedVersions[version]--
+	if s.pinnedVersions[version] <= 0 {
+		delete(s.pinnedVersions, version)
+	}
+}
+
 // NewIAVLStore creates a new IAVLStore.
 // maxVersions can be used to specify how many versions should be retained, if set to zero then
 // old versions will never been deleted.
@@ -242,9 +379,12 @@ func NewIAVLStore(db dbm.DB, maxVersions, targetVersion, flushInterval int64) (*
 	}
 
 	return &IAVLStore{
-		tree:          tree,
-		maxVersions:   maxVersions,
-		flushInterval: flushInterval,
+		tree:               tree,
+		db:                 db,
+		maxVersions:        maxVersions,
+		flushInterval:      flushInterval,
+		lastFlushedVersion: tree.Version(),
+		pinnedVersions:     map[int64]int{},
 	}, nil
 }
 
@@ -255,3 +395,27 @@ type iavlStoreSnapshot struct {
 func (s *iavlStoreSnapshot) Release() {
 	// noop
 }
+
+// iavlVersionSnapshot is a read-only snapshot of a specific version of an IAVL tree.
+type iavlVersionSnapshot struct {
+	tree    *iavl.ImmutableTree
+	release func() // unpins the version
+}
+
+func (s *iavlVersionSnapshot) Get(key []byte) []byte {
+	_, val := s.tree.Get(key)
+	return val
+}
+
+func (s *iavlVersionSnapshot) Has(key []byte) bool {
+	return s.tree.Has(key)
+}
+
+func (s *iavlVersionSnapshot) Range(prefix []byte) plugin.RangeData {
+	return rangeWithLimit(s.tree, prefix, 0)
+}
+
+func (s *iavlVersionSnapshot) Release() {
+	s.tree = nil
+	s.release()
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"github.com/tendermint/iavl"
 	"github.com/tendermint/tendermint/libs/db"
@@ -101,6 +102,66 @@ func testOrphans(t *testing.T, store *IAVLStore, diskDb db.DB, flushInterval int
 	require.Equal(t, 0, bytes.Compare([]byte(""), k2Value))
 }
 
+func TestGetVersionedWithProof(t *testing.T) {
+	store, err := NewIAVLStore(db.NewMemDB(), 0, 0, -1)
+	require.NoError(t, err)
+
+	store.Set([]byte("k1"), []byte("Fred"))
+	store.Set([]byte("k3"), []byte("Harry"))
+	hash1, version1, err := store.SaveVersion()
+	require.NoError(t, err)
+
+	store.Set([]byte("k1"), []byte("Mary"))
+	_, _, err = store.SaveVersion()
+	require.NoError(t, err)
+
+	// existence proof at an older version
+	val, proof, err := store.GetVersionedWithProof([]byte("k1"), version1)
+	require.NoError(t, err)
+	require.Equal(t, []byte("Fred"), val)
+	require.NoError(t, proof.Verify(hash1))
+	require.NoError(t, proof.VerifyItem([]byte("k1"), val))
+	require.Error(t, proof.VerifyItem([]byte("k1"), []byte("Mary")))
+
+	// absence proof
+	val, proof, err = store.GetVersionedWithProof([]byte("k2"), version1)
+	require.NoError(t, err)
+	require.Nil(t, val)
+	require.NoError(t, proof.Verify(hash1))
+	require.NoError(t, proof.VerifyAbsence([]byte("k2")))
+
+	_, _, err = store.GetVersionedWithProof([]byte("k1"), version1+5)
+	require.Error(t, err)
+}
+
+func TestSnapshotAtPinsVersion(t *testing.T) {
+	store, err := NewIAVLStore(db.NewMemDB(), 2, 0, -1)
+	require.NoError(t, err)
+
+	store.Set([]byte("k1"), []byte("Fred"))
+	_, version1, err := store.SaveVersion()
+	require.NoError(t, err)
+	snap, err := store.GetSnapshotAt(version1)
+	require.NoError(t, err)
+
+	// the version shouldn't be pruned while the snapshot is alive
+	for i := 0; i < 3; i++ {
+		store.Set([]byte("k1"), []byte("Mary"))
+		_, _, err = store.SaveVersion()
+		require.NoError(t, err)
+		require.NoError(t, store.Prune())
+	}
+	require.True(t, store.tree.VersionExists(version1))
+	require.Equal(t, []byte("Fred"), snap.Get([]byte("k1")))
+
+	// once the snapshot is released the version should be pruned
+	snap.Release()
+	require.NoError(t, store.Prune())
+	require.False(t, store.tree.VersionExists(version1))
+	_, err = store.GetSnapshotAt(version1)
+	require.Equal(t, ErrVersionPruned, errors.Cause(err))
+}
+
 func TestIavl(t *testing.T) {
 	numBlocks = 20
 	blockSize = 5
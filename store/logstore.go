@@ -5,6 +5,8 @@ import (
 	"os"
 
 	"github.com/loomnetwork/go-loom/plugin"
+	"github.com/pkg/errors"
+	"github.com/tendermint/iavl"
 )
 
 type LogParams struct {
@@ -129,3 +131,21 @@ func (s *LogStore) Prune() error {
 func (s *LogStore) GetSnapshot() Snapshot {
 	return s.store.GetSnapshot()
 }
+
+func (s *LogStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	return s.store.GetSnapshotAt(version)
+}
+
+func (s *LogStore) GetVersionedWithProof(key []byte, version int64) ([]byte, *iavl.RangeProof, error) {
+	if ps, ok := s.store.(ProvableStore); ok {
+		return ps.GetVersionedWithProof(key, version)
+	}
+	return nil, nil, ErrProofsNotSupported
+}
+
+func (s *LogStore) GetEvmRootAt(version int64) ([]byte, error) {
+	if r, ok := s.store.(EvmRootReader); ok {
+		return r.GetEvmRootAt(version)
+	}
+	return nil, errors.New("store doesn't keep track of EVM roots")
+}
@@ -0,0 +1,56 @@
+package store
+
+import (
+	mempl "github.com/tendermint/tendermint/mempool"
+	"github.com/tendermint/tendermint/types"
+)
+
+// Mempool provides access to the txs that are waiting to be committed to a block.
+type Mempool interface {
+	// GetUnconfirmedTxs returns up to limit txs from the mempool, in the order they were added to it.
+	// If limit is negative all the txs in the mempool are returned.
+	GetUnconfirmedTxs(limit int) ([]types.Tx, error)
+	// NumUnconfirmedTxs returns the number of txs in the mempool.
+	NumUnconfirmedTxs() (int, error)
+}
+
+// TendermintMempool reads txs directly from the mempool of the Tendermint node, rather than going
+// through the Tendermint RPC, which caps the number of txs returned at 100.
+type TendermintMempool struct {
+	mempool *mempl.Mempool
+}
+
+var _ Mempool = &TendermintMempool{}
+
+func NewTendermintMempool(mempool *mempl.Mempool) Mempool {
+	return &TendermintMempool{mempool: mempool}
+}
+
+func (m *TendermintMempool) GetUnconfirmedTxs(limit int) ([]types.Tx, error) {
+	return m.mempool.ReapMaxTxs(limit), nil
+}
+
+func (m *TendermintMempool) NumUnconfirmedTxs() (int, error) {
+	return m.mempool.Size(), nil
+}
+
+type MockMempool struct {
+	Txs []types.Tx
+}
+
+var _ Mempool = &MockMempool{}
+
+func NewMockMempool(txs ...types.Tx) *MockMempool {
+	return &MockMempool{Txs: txs}
+}
+
+func (m *MockMempool) GetUnconfirmedTxs(limit int) ([]types.Tx, error) {
+	if limit >= 0 && limit < len(m.Txs) {
+		return m.Txs[:limit], nil
+	}
+	return m.Txs, nil
+}
+
+func (m *MockMempool) NumUnconfirmedTxs() (int, error) {
+	return len(m.Txs), nil
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/loomnetwork/go-loom/plugin"
 	"github.com/loomnetwork/go-loom/util"
+	"github.com/pkg/errors"
 )
 
 type MemStore struct {
@@ -79,3 +80,7 @@ func (m *MemStore) Prune() error {
 func (m *MemStore) GetSnapshot() Snapshot {
 	panic("not implemented")
 }
+
+func (m *MemStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	return nil, errors.New("MemStore doesn't keep historical versions")
+}
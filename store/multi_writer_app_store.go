@@ -212,6 +212,25 @@ func (s *MultiWriterAppStore) SaveVersion() ([]byte, int64, error) {
 	return hash, version, err
 }
 
+// GetVersionedWithProof returns the value of the given key at the given version of app.db, along
+// with a proof that can be verified against the app hash at that version. Keys with the "vm" prefix
+// are only proven if they're also stored in app.db, EVM state stored exclusively in evm.db must be
+// proven via the Patricia root stored in app.db instead.
+func (s *MultiWriterAppStore) GetVersionedWithProof(key []byte, version int64) ([]byte, *iavl.RangeProof, error) {
+	return s.appStore.GetVersionedWithProof(key, version)
+}
+
+// GetEvmRootAt returns the Patricia root of the EVM state at the given version of the store.
+func (s *MultiWriterAppStore) GetEvmRootAt(version int64) ([]byte, error) {
+	appStoreTree := (*iavl.ImmutableTree)(atomic.LoadPointer(&s.lastSavedTree))
+	if version > appStoreTree.Version() {
+		return nil, errors.Errorf(
+			"version %d hasn't been saved yet, last saved version is %d", version, appStoreTree.Version(),
+		)
+	}
+	return s.evmStore.GetRootAt(version)
+}
+
 func (s *MultiWriterAppStore) pruneOldEVMKeys() error {
 	defer func(begin time.Time) {
 		pruneEVMKeysDuration.Observe(time.Since(begin).Seconds())
@@ -270,9 +289,31 @@ func (s *MultiWriterAppStore) GetSnapshot() Snapshot {
 	return newMultiWriterStoreSnapshot(evmDbSnapshot, appStoreTree)
 }
 
+// GetSnapshotAt returns a read-only snapshot of a previously saved version of the store.
+func (s *MultiWriterAppStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	appStoreTree := (*iavl.ImmutableTree)(atomic.LoadPointer(&s.lastSavedTree))
+	if version == appStoreTree.Version() {
+		return s.GetSnapshot(), nil
+	}
+	tree, release, err := s.appStore.getImmutableTree(version)
+	if err != nil {
+		return nil, err
+	}
+	evmDbSnapshot, err := s.evmStore.GetSnapshotAt(version)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	snap := newMultiWriterStoreSnapshot(evmDbSnapshot, tree)
+	snap.releaseAppStoreTree = release
+	return snap, nil
+}
+
 type multiWriterStoreSnapshot struct {
 	evmDbSnapshot db.Snapshot
 	appStoreTree  *iavl.ImmutableTree
+	// Unpins the app store version, only set for historical versions.
+	releaseAppStoreTree func()
 }
 
 func newMultiWriterStoreSnapshot(evmDbSnapshot db.Snapshot, appStoreTree *iavl.ImmutableTree) *multiWriterStoreSnapshot {
@@ -285,6 +326,9 @@ func newMultiWriterStoreSnapshot(evmDbSnapshot db.Snapshot, appStoreTree *iavl.I
 func (s *multiWriterStoreSnapshot) Release() {
 	s.evmDbSnapshot.Release()
 	s.appStoreTree = nil
+	if s.releaseAppStoreTree != nil {
+		s.releaseAppStoreTree()
+	}
 }
 
 func (s *multiWriterStoreSnapshot) Has(key []byte) bool {
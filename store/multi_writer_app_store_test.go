@@ -9,6 +9,7 @@ import (
 	"github.com/loomnetwork/go-loom/util"
 	"github.com/loomnetwork/loomchain/db"
 	"github.com/loomnetwork/loomchain/log"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -336,6 +337,47 @@ func (m *MultiWriterAppStoreTestSuite) TestIAVLRangeWithlimit() {
 	require.Equal(4, len(rangeData))
 }
 
+func (m *MultiWriterAppStoreTestSuite) TestMultiWriterAppStoreGetSnapshotAt() {
+	require := m.Require()
+	memDb, _ := db.LoadMemDB()
+	iavlStore, err := NewIAVLStore(memDb, 2, 0, -1)
+	require.NoError(err)
+	memDb, _ = db.LoadMemDB()
+	store, err := NewMultiWriterAppStore(iavlStore, NewEvmStore(memDb, 100), false)
+	require.NoError(err)
+
+	store.Set(evmDBFeatureKey, []byte{1})
+	for i := 1; i <= 4; i++ {
+		store.Set([]byte("abcd"), []byte{byte(i)})
+		store.Set(rootHashKey, []byte{byte(i), byte(i)})
+		_, _, err = store.SaveVersion()
+		require.NoError(err)
+	}
+	require.NoError(iavlStore.Prune())
+
+	snapshot, err := store.GetSnapshotAt(3)
+	require.NoError(err)
+	require.Equal([]byte{3}, snapshot.Get([]byte("abcd")))
+	require.Equal([]byte{3, 3}, snapshot.Get(rootHashKey))
+	require.Equal([]byte{3, 3}, snapshot.Get(rootKey))
+	snapshot.Release()
+
+	snapshot, err = store.GetSnapshotAt(4)
+	require.NoError(err)
+	require.Equal([]byte{4}, snapshot.Get([]byte("abcd")))
+	require.Equal([]byte{4, 4}, snapshot.Get(rootHashKey))
+	require.Equal([]byte{4, 4}, snapshot.Get(rootKey))
+	snapshot.Release()
+
+	// old versions are no longer available once they're pruned
+	_, err = store.GetSnapshotAt(2)
+	require.Equal(ErrVersionPruned, errors.Cause(err))
+
+	// versions that haven't been saved yet are not available either
+	_, err = store.GetSnapshotAt(5)
+	require.Error(err)
+}
+
 func mockMultiWriterStore(flushInterval int64) (*MultiWriterAppStore, error) {
 	memDb, _ := db.LoadMemDB()
 	iavlStore, err := NewIAVLStore(memDb, 0, 0, flushInterval)
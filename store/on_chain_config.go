@@ -1,9 +1,13 @@
 package store
 
 import (
+	"encoding/json"
+	"strconv"
+
 	"github.com/gogo/protobuf/proto"
 	cctypes "github.com/loomnetwork/go-loom/builtin/types/chainconfig"
 	"github.com/loomnetwork/go-loom/config"
+	"github.com/pkg/errors"
 )
 
 const configKey = "config"
@@ -29,3 +33,86 @@ func SaveOnChainConfig(kvStore KVWriter, cfg *cctypes.Config) error {
 	kvStore.Set([]byte(configKey), configBytes)
 	return nil
 }
+
+const settingsKey = "settings"
+
+// Names of the on-chain config settings that are stored in OnChainSettings.
+const (
+	EvmBlockGasLimitSetting  = "Evm.BlockGasLimit"
+	TxFeeGasPriceSetting     = "TxFee.GasPrice"
+	TxFeeMinGasSetting       = "TxFee.MinGas"
+	TxFeePayInEthCoinSetting = "TxFee.PayInEthCoin"
+)
+
+// OnChainSettings contains the on-chain config settings that aren't part of the chainconfig.Config
+// defined in go-loom. These settings are changed via the ChainConfig contract just like the rest
+// of the on-chain config, but they're stored under a separate key.
+type OnChainSettings struct {
+	// Max amount of gas that can be used by all the EVM txs in a block, zero means unlimited.
+	EvmBlockGasLimit uint64 `json:"evmBlockGasLimit,omitempty"`
+	// Price of each unit of gas used by a tx, zero means txs are free.
+	TxFeeGasPrice uint64 `json:"txFeeGasPrice,omitempty"`
+	// Min amount of gas each tx is charged for.
+	TxFeeMinGas uint64 `json:"txFeeMinGas,omitempty"`
+	// Indicates whether tx fees are paid in ETH (ethcoin) rather than LOOM (coin).
+	TxFeePayInEthCoin bool `json:"txFeePayInEthCoin,omitempty"`
+}
+
+var onChainSettingParsers = map[string]func(settings *OnChainSettings, value string) error{
+	EvmBlockGasLimitSetting: func(settings *OnChainSettings, value string) (err error) {
+		settings.EvmBlockGasLimit, err = strconv.ParseUint(value, 10, 64)
+		return err
+	},
+	TxFeeGasPriceSetting: func(settings *OnChainSettings, value string) (err error) {
+		settings.TxFeeGasPrice, err = strconv.ParseUint(value, 10, 64)
+		return err
+	},
+	TxFeeMinGasSetting: func(settings *OnChainSettings, value string) (err error) {
+		settings.TxFeeMinGas, err = strconv.ParseUint(value, 10, 64)
+		return err
+	},
+	TxFeePayInEthCoinSetting: func(settings *OnChainSettings, value string) (err error) {
+		settings.TxFeePayInEthCoin, err = strconv.ParseBool(value)
+		return err
+	},
+}
+
+// IsOnChainSetting checks if the setting with the given name is stored in OnChainSettings.
+func IsOnChainSetting(name string) bool {
+	_, ok := onChainSettingParsers[name]
+	return ok
+}
+
+// SetOnChainSetting parses the given value and assigns it to the named setting.
+func SetOnChainSetting(settings *OnChainSettings, name, value string) error {
+	parse, ok := onChainSettingParsers[name]
+	if !ok {
+		return errors.Errorf("unknown on-chain setting %s", name)
+	}
+	if err := parse(settings, value); err != nil {
+		return errors.Wrapf(err, "invalid value for on-chain setting %s", name)
+	}
+	return nil
+}
+
+// LoadOnChainSettings loads the on-chain settings from the given kv store.
+func LoadOnChainSettings(kvStore KVReader) (*OnChainSettings, error) {
+	settings := &OnChainSettings{}
+	settingsBytes := kvStore.Get([]byte(settingsKey))
+	if len(settingsBytes) > 0 {
+		if err := json.Unmarshal(settingsBytes, settings); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal on-chain settings")
+		}
+	}
+	return settings, nil
+}
+
+// SaveOnChainSettings saves the on-chain settings to the given kv store.
+func SaveOnChainSettings(kvStore KVWriter, settings *OnChainSettings) error {
+	settingsBytes, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	kvStore.Set([]byte(settingsKey), settingsBytes)
+	return nil
+}
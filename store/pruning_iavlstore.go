@@ -13,6 +13,7 @@ import (
 	"github.com/loomnetwork/loomchain/log"
 	"github.com/pkg/errors"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/tendermint/iavl"
 	dbm "github.com/tendermint/tendermint/libs/db"
 )
 
@@ -184,6 +185,20 @@ func (s *PruningIAVLStore) GetSnapshot() Snapshot {
 	}
 }
 
+func (s *PruningIAVLStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.store.GetSnapshotAt(version)
+}
+
+func (s *PruningIAVLStore) GetVersionedWithProof(key []byte, version int64) ([]byte, *iavl.RangeProof, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.store.GetVersionedWithProof(key, version)
+}
+
 func (s *PruningIAVLStore) prune() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -197,6 +212,10 @@ func (s *PruningIAVLStore) prune() error {
 	latestVer := s.store.Version()
 	endVer := latestVer - s.maxVersions
 
+	if err = s.store.deleteDeferredVersions(); err != nil {
+		return err
+	}
+
 	if (s.oldestVer == 0) || (s.oldestVer > endVer) {
 		return nil // nothing to prune yet
 	}
@@ -229,7 +248,7 @@ func (s *PruningIAVLStore) deleteVersion(ver int64) error {
 		deleteVersionDuration.With(lvs...).Observe(time.Since(begin).Seconds())
 	}(time.Now())
 
-	err = s.store.tree.DeleteVersion(ver)
+	err = s.store.deleteVersion(ver)
 	return err
 }
 
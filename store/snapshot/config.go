@@ -0,0 +1,40 @@
+package snapshot
+
+// Config contains settings for the periodic export of app.db & evm.db snapshots.
+type Config struct {
+	// Enables periodic snapshots, requires AppStore.Version 3 (MultiWriterAppStore).
+	Enabled bool
+	// Number of blocks between snapshots, a snapshot will be exported after every block whose
+	// height is divisible by this number. If AppStore.IAVLFlushInterval is non-zero this must
+	// be a multiple of the flush interval, since snapshots can only be exported at heights that
+	// have been flushed to app.db. The same applies to any flush interval set via the on-chain
+	// config, snapshots that are due at heights that haven't been flushed are skipped.
+	Interval int64
+	// Directory (relative to the node root dir) snapshots should be written to.
+	Dir string
+	// Maximum size of each snapshot chunk (in megabytes).
+	ChunkSizeMegs int
+	// Maximum number of snapshots to keep on disk, older snapshots will be deleted once this
+	// number is exceeded. If set to zero old snapshots will never be deleted.
+	MaxSnapshots int
+}
+
+// DefaultConfig returns the default config for state snapshots.
+func DefaultConfig() *Config {
+	return &Config{
+		Enabled:       false,
+		Interval:      10000,
+		Dir:           "snapshots",
+		ChunkSizeMegs: 64,
+		MaxSnapshots:  2,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *Config) Clone() *Config {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
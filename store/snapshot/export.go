@@ -0,0 +1,180 @@
+package snapshot
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
+)
+
+// chunkWriter splits a stream of key/value pairs into chunk files of (roughly) fixed size.
+// Each key/value pair is written as a uvarint key length, the key, a uvarint value length, and
+// the value.
+type chunkWriter struct {
+	dir       string
+	dbName    string
+	chunkSize int
+
+	file    *os.File
+	buf     *bufio.Writer
+	hasher  hash.Hash
+	written int
+	chunk   *ChunkInfo
+	chunks  []*ChunkInfo
+}
+
+func newChunkWriter(dir, dbName string, chunkSize int) *chunkWriter {
+	return &chunkWriter{
+		dir:       dir,
+		dbName:    dbName,
+		chunkSize: chunkSize,
+	}
+}
+
+func (w *chunkWriter) openChunk() error {
+	index := len(w.chunks)
+	filename := chunkFilename(w.dbName, index)
+	f, err := os.Create(filepath.Join(w.dir, filename))
+	if err != nil {
+		return errors.Wrapf(err, "failed to create chunk %s", filename)
+	}
+	w.file = f
+	w.hasher = sha256.New()
+	w.buf = bufio.NewWriter(io.MultiWriter(f, w.hasher))
+	w.written = 0
+	w.chunk = &ChunkInfo{
+		DB:       w.dbName,
+		Index:    index,
+		Filename: filename,
+	}
+	w.chunks = append(w.chunks, w.chunk)
+	return nil
+}
+
+func (w *chunkWriter) closeChunk() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.buf.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Sync(); err != nil {
+		w.file.Close()
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	w.chunk.Hash = hex.EncodeToString(w.hasher.Sum(nil))
+	w.file = nil
+	return nil
+}
+
+func (w *chunkWriter) Write(key, value []byte) error {
+	if w.file == nil || w.written >= w.chunkSize {
+		if err := w.closeChunk(); err != nil {
+			return err
+		}
+		if err := w.openChunk(); err != nil {
+			return err
+		}
+	}
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	for _, b := range [][]byte{key, value} {
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		if _, err := w.buf.Write(lenBuf[:n]); err != nil {
+			return err
+		}
+		if _, err := w.buf.Write(b); err != nil {
+			return err
+		}
+		w.written += n + len(b)
+	}
+	w.chunk.NumKeys++
+	return nil
+}
+
+// Close flushes the last chunk and returns the list of chunks that were written.
+func (w *chunkWriter) Close() ([]*ChunkInfo, error) {
+	if err := w.closeChunk(); err != nil {
+		return nil, err
+	}
+	return w.chunks, nil
+}
+
+// Export writes the contents of the given app.db & evm.db snapshots to a new snapshot directory
+// under rootDir. The DB snapshots must have been taken immediately after the block at the given
+// height was committed, the caller remains responsible for releasing them.
+//
+// app.db is exported as-is, so the image contains all the IAVL versions that haven't been pruned
+// yet. evm.db is exported as-is as well, with the exception of any versioned Patricia roots saved
+// above the snapshot height.
+func Export(
+	rootDir string, height int64, appHash []byte, appDB, evmDB db.Snapshot, chunkSize int,
+) (*Manifest, error) {
+	dir := snapshotDir(rootDir, height)
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create snapshot dir %s", dir)
+	}
+
+	appWriter := newChunkWriter(dir, AppDBName, chunkSize)
+	appIter := appDB.NewIterator(nil, nil)
+	for ; appIter.Valid(); appIter.Next() {
+		if err := appWriter.Write(appIter.Key(), appIter.Value()); err != nil {
+			appIter.Close()
+			return nil, errors.Wrap(err, "failed to export app.db")
+		}
+	}
+	appIter.Close()
+	appChunks, err := appWriter.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to export app.db")
+	}
+
+	var evmRoot []byte
+	evmWriter := newChunkWriter(dir, EvmDBName, chunkSize)
+	evmIter := evmDB.NewIterator(nil, nil)
+	for ; evmIter.Valid(); evmIter.Next() {
+		if isRoot, version := store.IsEvmRootKey(evmIter.Key()); isRoot {
+			if version > height {
+				continue
+			}
+			// versioned roots are sorted by height, so the last one seen is the one at the
+			// snapshot height
+			evmRoot = evmIter.Value()
+		}
+		if err := evmWriter.Write(evmIter.Key(), evmIter.Value()); err != nil {
+			evmIter.Close()
+			return nil, errors.Wrap(err, "failed to export evm.db")
+		}
+	}
+	evmIter.Close()
+	evmChunks, err := evmWriter.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to export evm.db")
+	}
+
+	m := &Manifest{
+		Format:  FormatVersion,
+		Height:  height,
+		AppHash: hex.EncodeToString(appHash),
+		EvmRoot: hex.EncodeToString(evmRoot),
+		Chunks:  append(appChunks, evmChunks...),
+	}
+	if err := m.Save(dir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,135 @@
+package snapshot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/pkg/errors"
+	dbm "github.com/tendermint/tendermint/libs/db"
+)
+
+// Import loads the snapshot stored in the given directory into the given DBs, which are expected
+// to be empty. The hash of every chunk is checked against the manifest before the chunk is written
+// to a DB, once all the chunks have been imported the restored stores are verified against the
+// manifest.
+func Import(dir string, appDB, evmDB db.DBWrapper) (*Manifest, error) {
+	m, err := LoadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, chunk := range m.Chunks {
+		var target dbm.DB
+		switch chunk.DB {
+		case AppDBName:
+			target = appDB
+		case EvmDBName:
+			target = evmDB
+		default:
+			return nil, fmt.Errorf("chunk %s belongs to unknown DB %s", chunk.Filename, chunk.DB)
+		}
+		if err := importChunk(dir, chunk, target); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := Verify(m, appDB, evmDB); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Max number of keys written to a DB in a single batch by the import.
+const importBatchSize = 10000
+
+func importChunk(dir string, chunk *ChunkInfo, target dbm.DB) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, chunk.Filename))
+	if err != nil {
+		return errors.Wrapf(err, "failed to read chunk %s", chunk.Filename)
+	}
+	chunkHash := sha256.Sum256(data)
+	if hex.EncodeToString(chunkHash[:]) != chunk.Hash {
+		return fmt.Errorf("chunk %s hash mismatch, expected %s, got %x", chunk.Filename, chunk.Hash, chunkHash)
+	}
+
+	// Some backends limit the size of a single batch, and the import doesn't need to be atomic
+	// since a partially imported snapshot is deleted, so write the chunk in multiple batches.
+	batch := target.NewBatch()
+	batchKeys := 0
+	r := bytes.NewReader(data)
+	numKeys := 0
+	for {
+		key, err := readBytes(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to read key from chunk %s", chunk.Filename)
+		}
+		value, err := readBytes(r)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read value from chunk %s", chunk.Filename)
+		}
+		batch.Set(key, value)
+		numKeys++
+		batchKeys++
+		if batchKeys == importBatchSize {
+			batch.Write()
+			batch = target.NewBatch()
+			batchKeys = 0
+		}
+	}
+	if numKeys != chunk.NumKeys {
+		return fmt.Errorf("chunk %s contains %d keys, expected %d", chunk.Filename, numKeys, chunk.NumKeys)
+	}
+	batch.WriteSync()
+	return nil
+}
+
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(r.Len()) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Verify checks that app.db & evm.db restored from a snapshot match the snapshot manifest.
+func Verify(m *Manifest, appDB, evmDB db.DBWrapper) error {
+	iavlStore, err := store.NewIAVLStore(appDB, 0, m.Height, -1)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load app.db at height %d", m.Height)
+	}
+	if iavlStore.Version() != m.Height {
+		return fmt.Errorf("app.db is at height %d, expected %d", iavlStore.Version(), m.Height)
+	}
+	if appHash := hex.EncodeToString(iavlStore.Hash()); appHash != m.AppHash {
+		return fmt.Errorf("app hash mismatch at height %d, expected %s, got %s", m.Height, m.AppHash, appHash)
+	}
+
+	evmStore := store.NewEvmStore(evmDB, 1)
+	if err := evmStore.LoadVersion(m.Height); err != nil {
+		return errors.Wrapf(err, "failed to load evm.db at height %d", m.Height)
+	}
+	// The EVM root stored in app.db is covered by the app hash, so if it matches the root stored
+	// in evm.db then evm.db has been restored correctly.
+	if _, err := store.NewMultiWriterAppStore(iavlStore, evmStore, false); err != nil {
+		return err
+	}
+	return nil
+}
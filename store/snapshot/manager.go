@@ -0,0 +1,201 @@
+package snapshot
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/loomnetwork/go-loom"
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/pkg/errors"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exportDuration metrics.Histogram
+)
+
+func init() {
+	exportDuration = kitprometheus.NewSummaryFrom(
+		stdprometheus.SummaryOpts{
+			Namespace:  "loomchain",
+			Subsystem:  "state_snapshot",
+			Name:       "export_duration",
+			Help:       "How long it took to export a state snapshot (in seconds)",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		}, []string{"error"},
+	)
+}
+
+// Manager periodically exports snapshots of app.db & evm.db.
+type Manager struct {
+	cfg               *Config
+	rootDir           string
+	appDB             db.DBWrapper
+	evmDB             db.DBWrapper
+	lastFlushedHeight func() int64
+	logger            *loom.Logger
+	mutex             sync.Mutex
+	exporting         bool
+}
+
+// NewManager creates a new snapshot manager that will write snapshots to the given directory.
+// Snapshots can only be taken at heights that have been flushed to app.db, lastFlushedHeight must
+// return the height of the last IAVL version that was flushed to disk. flushInterval is the number
+// of blocks between IAVL flushes specified in the node config (if any), the snapshot interval must
+// be a multiple of it. The flush interval may also be set by the on-chain config, so heights that
+// haven't been flushed are skipped when the snapshot is due.
+func NewManager(
+	cfg *Config, flushInterval int64, lastFlushedHeight func() int64, rootDir string, appDB, evmDB db.DBWrapper,
+	logger *loom.Logger,
+) (*Manager, error) {
+	if cfg.Interval <= 0 {
+		return nil, errors.New("snapshot interval must be greater than zero")
+	}
+	if flushInterval > 0 && cfg.Interval%flushInterval != 0 {
+		return nil, fmt.Errorf(
+			"snapshot interval %d is not a multiple of the IAVL flush interval %d", cfg.Interval, flushInterval,
+		)
+	}
+	if cfg.ChunkSizeMegs <= 0 {
+		return nil, errors.New("snapshot chunk size must be greater than zero")
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return nil, errors.Wrapf(err, "failed to create snapshot dir %s", rootDir)
+	}
+	return &Manager{
+		cfg:               cfg,
+		rootDir:           rootDir,
+		appDB:             appDB,
+		evmDB:             evmDB,
+		lastFlushedHeight: lastFlushedHeight,
+		logger:            logger,
+	}, nil
+}
+
+// OnCommit should be called right after the block at the given height has been committed to
+// app.db & evm.db. If a snapshot is due at this height consistent DB snapshots will be obtained
+// before this function returns, and then exported in the background. If the previous export is
+// still in progress, or the height hasn't been flushed to app.db, the snapshot at this height will
+// be skipped.
+func (m *Manager) OnCommit(height int64, appHash []byte) {
+	if height%m.cfg.Interval != 0 {
+		return
+	}
+	if flushedHeight := m.lastFlushedHeight(); flushedHeight != height {
+		m.logger.Error(
+			"Skipping state snapshot, height hasn't been flushed to disk, "+
+				"the snapshot interval should be a multiple of the IAVL flush interval",
+			"height", height, "lastFlushedHeight", flushedHeight,
+		)
+		return
+	}
+	if !m.beginExport() {
+		m.logger.Error("Skipping state snapshot, previous export still in progress", "height", height)
+		return
+	}
+
+	appSnap := m.appDB.GetSnapshot()
+	evmSnap := m.evmDB.GetSnapshot()
+	hash := make([]byte, len(appHash))
+	copy(hash, appHash)
+
+	go func() {
+		defer m.endExport()
+		defer appSnap.Release()
+		defer evmSnap.Release()
+
+		var err error
+		defer func(begin time.Time) {
+			lvs := []string{"error", fmt.Sprint(err != nil)}
+			exportDuration.With(lvs...).Observe(time.Since(begin).Seconds())
+		}(time.Now())
+
+		m.logger.Info("Exporting state snapshot", "height", height)
+		if _, err = Export(m.rootDir, height, hash, appSnap, evmSnap, m.cfg.ChunkSizeMegs*1024*1024); err != nil {
+			m.logger.Error("Failed to export state snapshot", "height", height, "err", err)
+			return
+		}
+		m.logger.Info("Exported state snapshot", "height", height)
+
+		if err = m.deleteOldSnapshots(); err != nil {
+			m.logger.Error("Failed to delete old state snapshots", "err", err)
+		}
+	}()
+}
+
+func (m *Manager) beginExport() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.exporting {
+		return false
+	}
+	m.exporting = true
+	return true
+}
+
+func (m *Manager) endExport() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.exporting = false
+}
+
+// deleteOldSnapshots removes all but the most recent MaxSnapshots snapshots.
+func (m *Manager) deleteOldSnapshots() error {
+	if m.cfg.MaxSnapshots <= 0 {
+		return nil
+	}
+	heights, err := ListSnapshots(m.rootDir)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(heights)-m.cfg.MaxSnapshots; i++ {
+		if err := os.RemoveAll(snapshotDir(m.rootDir, heights[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSnapshots returns the heights of all the complete snapshots in the given directory,
+// sorted in ascending order.
+func ListSnapshots(rootDir string) ([]int64, error) {
+	entries, err := ioutil.ReadDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	heights := []int64{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		var height int64
+		if _, err := fmt.Sscanf(entry.Name(), "%d", &height); err != nil {
+			continue
+		}
+		if _, err := LoadManifest(snapshotDir(rootDir, height)); err != nil {
+			continue
+		}
+		heights = append(heights, height)
+	}
+	sort.Slice(heights, func(i, j int) bool { return heights[i] < heights[j] })
+	return heights, nil
+}
+
+// LatestSnapshotDir returns the directory of the most recent complete snapshot in the given
+// directory.
+func LatestSnapshotDir(rootDir string) (string, error) {
+	heights, err := ListSnapshots(rootDir)
+	if err != nil {
+		return "", err
+	}
+	if len(heights) == 0 {
+		return "", fmt.Errorf("no snapshots found in %s", rootDir)
+	}
+	return snapshotDir(rootDir, heights[len(heights)-1]), nil
+}
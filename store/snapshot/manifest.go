@@ -0,0 +1,88 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ManifestFilename is the name of the file that describes the contents of a snapshot.
+	ManifestFilename = "manifest.json"
+	// FormatVersion is incremented every time the snapshot layout changes in an incompatible way.
+	FormatVersion = 1
+
+	// AppDBName identifies chunks that contain app.db keys.
+	AppDBName = "app"
+	// EvmDBName identifies chunks that contain evm.db keys.
+	EvmDBName = "evm"
+)
+
+// ChunkInfo describes a single chunk of a snapshot.
+type ChunkInfo struct {
+	// Name of the DB the chunk belongs to (AppDBName or EvmDBName).
+	DB string `json:"db"`
+	// Position of the chunk within the DB image, chunks must be imported in order.
+	Index int `json:"index"`
+	// Name of the chunk file, relative to the snapshot directory.
+	Filename string `json:"filename"`
+	// Hex-encoded SHA-256 hash of the chunk file.
+	Hash string `json:"hash"`
+	// Number of key/value pairs stored in the chunk.
+	NumKeys int `json:"numKeys"`
+}
+
+// Manifest describes a snapshot of app.db & evm.db taken at a specific block height.
+type Manifest struct {
+	Format int   `json:"format"`
+	Height int64 `json:"height"`
+	// Hex-encoded app hash (IAVL root hash) at Height.
+	AppHash string `json:"appHash"`
+	// Hex-encoded Patricia root of the EVM state at Height.
+	EvmRoot string       `json:"evmRoot"`
+	Chunks  []*ChunkInfo `json:"chunks"`
+}
+
+// chunkFilename returns the filename of the chunk with the given index.
+func chunkFilename(dbName string, index int) string {
+	return fmt.Sprintf("%s-%06d.chunk", dbName, index)
+}
+
+// snapshotDir returns the directory a snapshot at the given height should be stored in.
+func snapshotDir(rootDir string, height int64) string {
+	return filepath.Join(rootDir, fmt.Sprintf("%012d", height))
+}
+
+// LoadManifest reads the manifest from the given snapshot directory.
+func LoadManifest(dir string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, ManifestFilename))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot manifest")
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal snapshot manifest")
+	}
+	if m.Format != FormatVersion {
+		return nil, fmt.Errorf("unsupported snapshot format %d, expected %d", m.Format, FormatVersion)
+	}
+	return &m, nil
+}
+
+// Save writes the manifest to the given snapshot directory. The manifest is written last when
+// exporting a snapshot, so a directory without a manifest contains an incomplete snapshot.
+func (m *Manifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpPath := filepath.Join(dir, ManifestFilename+".tmp")
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write snapshot manifest")
+	}
+	return os.Rename(tmpPath, filepath.Join(dir, ManifestFilename))
+}
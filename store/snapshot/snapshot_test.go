@@ -0,0 +1,154 @@
+package snapshot
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/loomnetwork/loomchain/db"
+	"github.com/loomnetwork/loomchain/log"
+	"github.com/loomnetwork/loomchain/store"
+	"github.com/stretchr/testify/require"
+)
+
+func buildTestStores(t *testing.T, numVersions int) (*db.MemDB, *db.MemDB, []byte) {
+	appDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+	evmDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+
+	iavlStore, err := store.NewIAVLStore(appDB, 0, 0, -1)
+	require.NoError(t, err)
+	evmStore := store.NewEvmStore(evmDB, 10)
+	require.NoError(t, evmStore.LoadVersion(0))
+	appStore, err := store.NewMultiWriterAppStore(iavlStore, evmStore, false)
+	require.NoError(t, err)
+
+	var appHash []byte
+	for i := 0; i < numVersions; i++ {
+		appStore.Set([]byte{byte(i), 1}, []byte("app value"))
+		appStore.Set(util.PrefixKey([]byte("vm"), []byte{byte(i)}), []byte("evm value"))
+		appStore.Set(util.PrefixKey([]byte("vm"), []byte("vmroot")), []byte{byte(i), 2})
+		appHash, _, err = appStore.SaveVersion()
+		require.NoError(t, err)
+	}
+	return appDB, evmDB, appHash
+}
+
+func TestExportImport(t *testing.T) {
+	appDB, evmDB, appHash := buildTestStores(t, 10)
+
+	rootDir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	// use a tiny chunk size to make sure each DB is split into multiple chunks
+	m, err := Export(rootDir, 10, appHash, appDB.GetSnapshot(), evmDB.GetSnapshot(), 64)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), m.Height)
+	require.Equal(t, hex.EncodeToString(appHash), m.AppHash)
+	require.True(t, len(m.Chunks) > 2)
+
+	heights, err := ListSnapshots(rootDir)
+	require.NoError(t, err)
+	require.Equal(t, []int64{10}, heights)
+
+	dir, err := LatestSnapshotDir(rootDir)
+	require.NoError(t, err)
+
+	newAppDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+	newEvmDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+	m2, err := Import(dir, newAppDB, newEvmDB)
+	require.NoError(t, err)
+	require.Equal(t, m.Height, m2.Height)
+
+	iavlStore, err := store.NewIAVLStore(newAppDB, 0, 0, -1)
+	require.NoError(t, err)
+	require.Equal(t, int64(10), iavlStore.Version())
+	require.Equal(t, appHash, iavlStore.Hash())
+}
+
+func TestImportCorruptChunk(t *testing.T) {
+	appDB, evmDB, appHash := buildTestStores(t, 5)
+
+	rootDir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	m, err := Export(rootDir, 5, appHash, appDB.GetSnapshot(), evmDB.GetSnapshot(), 1024*1024)
+	require.NoError(t, err)
+
+	dir := snapshotDir(rootDir, 5)
+	chunkPath := filepath.Join(dir, m.Chunks[0].Filename)
+	data, err := ioutil.ReadFile(chunkPath)
+	require.NoError(t, err)
+	data[len(data)-1] ^= 0xFF
+	require.NoError(t, ioutil.WriteFile(chunkPath, data, 0644))
+
+	newAppDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+	newEvmDB, err := db.LoadMemDB()
+	require.NoError(t, err)
+	_, err = Import(dir, newAppDB, newEvmDB)
+	require.Error(t, err)
+}
+
+func TestDeleteOldSnapshots(t *testing.T) {
+	appDB, evmDB, appHash := buildTestStores(t, 1)
+
+	rootDir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	cfg := DefaultConfig()
+	cfg.Interval = 1
+	cfg.MaxSnapshots = 2
+	m, err := NewManager(cfg, 0, func() int64 { return 4 }, rootDir, appDB, evmDB, nil)
+	require.NoError(t, err)
+	for h := int64(1); h <= 4; h++ {
+		_, err := Export(rootDir, h, appHash, appDB.GetSnapshot(), evmDB.GetSnapshot(), 1024)
+		require.NoError(t, err)
+	}
+	require.NoError(t, m.deleteOldSnapshots())
+	heights, err := ListSnapshots(rootDir)
+	require.NoError(t, err)
+	require.Equal(t, []int64{3, 4}, heights)
+}
+
+func TestManagerRequiresFlushedHeights(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	lastFlushedHeight := func() int64 { return 0 }
+	cfg := DefaultConfig()
+	cfg.Interval = 150
+	_, err = NewManager(cfg, 100, lastFlushedHeight, rootDir, nil, nil, nil)
+	require.Error(t, err)
+	cfg.Interval = 200
+	_, err = NewManager(cfg, 100, lastFlushedHeight, rootDir, nil, nil, nil)
+	require.NoError(t, err)
+}
+
+func TestManagerSkipsUnflushedHeights(t *testing.T) {
+	log.Setup("debug", "file://-")
+	rootDir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(t, err)
+	defer os.RemoveAll(rootDir)
+
+	cfg := DefaultConfig()
+	cfg.Interval = 10
+	// the flush interval is set by the on-chain config, so it's unknown to the manager
+	m, err := NewManager(cfg, 0, func() int64 { return 5 }, rootDir, nil, nil, log.Default)
+	require.NoError(t, err)
+	m.OnCommit(10, []byte{1})
+	require.False(t, m.exporting)
+	heights, err := ListSnapshots(rootDir)
+	require.NoError(t, err)
+	require.Empty(t, heights)
+}
@@ -0,0 +1,314 @@
+package store
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/go-kit/kit/metrics"
+	kitprometheus "github.com/go-kit/kit/metrics/prometheus"
+	"github.com/gogo/protobuf/proto"
+	lru "github.com/hashicorp/golang-lru"
+	"github.com/loomnetwork/go-loom/util"
+	"github.com/pkg/errors"
+	stdprometheus "github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	stateCacheHits          metrics.Counter
+	stateCacheMisses        metrics.Counter
+	stateCacheInvalidations metrics.Counter
+)
+
+func init() {
+	const namespace = "loomchain"
+	const subsystem = "state_cache"
+
+	stateCacheHits = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_hit",
+			Help:      "Number of decoded values that were found in the StateCache",
+		}, []string{"type"})
+	stateCacheMisses = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_miss",
+			Help:      "Number of decoded values that were not found in the StateCache",
+		}, []string{"type"})
+	stateCacheInvalidations = kitprometheus.NewCounterFrom(
+		stdprometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "num_invalidated_keys",
+			Help:      "Number of keys evicted from the StateCache because they were modified by a block",
+		}, []string{})
+}
+
+type StateCacheConfig struct {
+	// Enables the cache of decoded contract state used by the query server.
+	Enabled bool
+	// Maximum number of app store keys to cache decoded values for.
+	MaxKeys int
+}
+
+func DefaultStateCacheConfig() *StateCacheConfig {
+	return &StateCacheConfig{
+		Enabled: false,
+		MaxKeys: 10000,
+	}
+}
+
+// Clone returns a deep clone of the config.
+func (c *StateCacheConfig) Clone() *StateCacheConfig {
+	if c == nil {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
+
+// StateCache caches the decoded values of app store keys as of the last committed block. Values
+// remain in the cache across blocks until a block modifies the key they were decoded from, so the
+// values read frequently by the query server (balances, address mappings, etc.) only have to be
+// loaded & decoded once.
+//
+// Values can only be read from, and added to, the cache at the height the cache is currently at,
+// reads from older (or uncommitted) state bypass the cache.
+type StateCache struct {
+	mutex sync.Mutex
+	// app store key -> *stateCacheEntry
+	entries *lru.Cache
+	// height of the last block committed to the app store
+	height int64
+}
+
+// stateCacheEntry holds the values decoded from a single key, the same key may be decoded into
+// more than one type of message.
+type stateCacheEntry struct {
+	// message type -> decoded message, nil messages indicate the key doesn't exist
+	values map[string]proto.Message
+}
+
+// NewStateCache creates a new cache that starts out at the given height.
+func NewStateCache(cfg *StateCacheConfig, height int64) (*StateCache, error) {
+	if cfg.MaxKeys <= 0 {
+		return nil, errors.New("StateCache max keys must be greater than zero")
+	}
+	entries, err := lru.New(cfg.MaxKeys)
+	if err != nil {
+		return nil, err
+	}
+	return &StateCache{
+		entries: entries,
+		height:  height,
+	}, nil
+}
+
+// Height returns the height of the state the cache currently holds values for.
+func (c *StateCache) Height() int64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.height
+}
+
+// Get looks up the value of the given app store key at the given height, if a value of the same
+// type as pb is found it's copied into pb. Returns false if the value isn't in the cache, in which
+// case exists should be ignored. If exists is false the key doesn't exist in the store.
+func (c *StateCache) Get(height int64, key []byte, pb proto.Message) (exists bool, ok bool) {
+	msgType := messageType(pb)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if height != c.height {
+		stateCacheMisses.With("type", msgType).Add(1)
+		return false, false
+	}
+	if val, found := c.entries.Get(string(key)); found {
+		if cached, found := val.(*stateCacheEntry).values[msgType]; found {
+			stateCacheHits.With("type", msgType).Add(1)
+			if cached == nil {
+				return false, true
+			}
+			pb.Reset()
+			proto.Merge(pb, cached)
+			return true, true
+		}
+	}
+	stateCacheMisses.With("type", msgType).Add(1)
+	return false, false
+}
+
+// Set caches the value decoded from the given app store key at the given height, the value is
+// ignored if the cache has already moved on to another height. If exists is false the key is
+// cached as not existing in the store, and pb is only used to determine the message type.
+func (c *StateCache) Set(height int64, key []byte, pb proto.Message, exists bool) {
+	msgType := messageType(pb)
+	var value proto.Message
+	if exists {
+		value = proto.Clone(pb)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if height != c.height {
+		return
+	}
+	if val, found := c.entries.Get(string(key)); found {
+		val.(*stateCacheEntry).values[msgType] = value
+		return
+	}
+	c.entries.Add(string(key), &stateCacheEntry{
+		values: map[string]proto.Message{msgType: value},
+	})
+}
+
+// Commit evicts the given keys from the cache, and moves the cache on to the given height.
+func (c *StateCache) Commit(height int64, modifiedKeys map[string]struct{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key := range modifiedKeys {
+		if c.entries.Contains(key) {
+			c.entries.Remove(key)
+			stateCacheInvalidations.Add(1)
+		}
+	}
+	c.height = height
+}
+
+// Reset evicts all the values from the cache, and moves the cache on to the given height.
+func (c *StateCache) Reset(height int64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries.Purge()
+	c.height = height
+}
+
+func messageType(pb proto.Message) string {
+	return reflect.TypeOf(pb).String()
+}
+
+// StateCacheProvider is implemented by stores that keep a StateCache in sync with their contents.
+type StateCacheProvider interface {
+	StateCache() *StateCache
+}
+
+// VersionedSnapshot is a snapshot that knows which version of the store it was taken from.
+type VersionedSnapshot interface {
+	Snapshot
+	Version() int64
+}
+
+// stateCacheInvalidator wraps a VersionedKVStore, and keeps track of the keys written to the store
+// so they can be evicted from a StateCache when a new version of the store is saved.
+type stateCacheInvalidator struct {
+	VersionedKVStore
+	cache *StateCache
+	// keys modified since the last version was saved
+	modifiedKeys map[string]struct{}
+	// Held while a new version is saved, so snapshots can be matched up with the version they
+	// were taken from.
+	versionMutex sync.RWMutex
+}
+
+// The wrapper must only implement the optional store interfaces the source store implements.
+type provableStateCacheInvalidator struct {
+	*stateCacheInvalidator
+	ProvableStore
+}
+
+type evmStateCacheInvalidator struct {
+	*stateCacheInvalidator
+	EvmRootReader
+}
+
+type provableEvmStateCacheInvalidator struct {
+	*stateCacheInvalidator
+	ProvableStore
+	EvmRootReader
+}
+
+// NewStateCacheInvalidator wraps the given store so that the given cache is kept up to date with
+// the changes made to the store.
+func NewStateCacheInvalidator(source VersionedKVStore, cache *StateCache) VersionedKVStore {
+	s := &stateCacheInvalidator{
+		VersionedKVStore: source,
+		cache:            cache,
+		modifiedKeys:     map[string]struct{}{},
+	}
+	ps, isProvable := source.(ProvableStore)
+	rr, hasEvmRoots := source.(EvmRootReader)
+	switch {
+	case isProvable && hasEvmRoots:
+		return &provableEvmStateCacheInvalidator{s, ps, rr}
+	case isProvable:
+		return &provableStateCacheInvalidator{s, ps}
+	case hasEvmRoots:
+		return &evmStateCacheInvalidator{s, rr}
+	}
+	return s
+}
+
+func (s *stateCacheInvalidator) StateCache() *StateCache {
+	return s.cache
+}
+
+func (s *stateCacheInvalidator) Set(key, val []byte) {
+	s.trackKey(key)
+	s.VersionedKVStore.Set(key, val)
+}
+
+func (s *stateCacheInvalidator) Delete(key []byte) {
+	s.trackKey(key)
+	s.VersionedKVStore.Delete(key)
+}
+
+func (s *stateCacheInvalidator) trackKey(key []byte) {
+	// EVM state is never decoded via the cache, and there's a lot of it.
+	if util.HasPrefix(key, vmPrefix) {
+		return
+	}
+	s.modifiedKeys[string(key)] = struct{}{}
+}
+
+func (s *stateCacheInvalidator) SaveVersion() ([]byte, int64, error) {
+	s.versionMutex.Lock()
+	defer s.versionMutex.Unlock()
+
+	hash, version, err := s.VersionedKVStore.SaveVersion()
+	if err != nil {
+		// can't be sure which changes made it into the store
+		s.cache.Reset(s.VersionedKVStore.Version())
+	} else {
+		s.cache.Commit(version, s.modifiedKeys)
+	}
+	s.modifiedKeys = map[string]struct{}{}
+	return hash, version, err
+}
+
+// GetSnapshot returns a snapshot of the latest version of the store, the snapshot implements
+// VersionedSnapshot so values read from it can be cached at the correct height.
+func (s *stateCacheInvalidator) GetSnapshot() Snapshot {
+	s.versionMutex.RLock()
+	defer s.versionMutex.RUnlock()
+
+	return &stateCacheSnapshot{
+		Snapshot: s.VersionedKVStore.GetSnapshot(),
+		version:  s.VersionedKVStore.Version(),
+	}
+}
+
+type stateCacheSnapshot struct {
+	Snapshot
+	version int64
+}
+
+func (s *stateCacheSnapshot) Version() int64 {
+	return s.version
+}
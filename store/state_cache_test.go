@@ -0,0 +1,89 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/loomnetwork/go-loom/types"
+	"github.com/stretchr/testify/require"
+	"github.com/tendermint/tendermint/libs/db"
+)
+
+func TestStateCache(t *testing.T) {
+	iavlStore, err := NewIAVLStore(db.NewMemDB(), 0, 0, -1)
+	require.NoError(t, err)
+	cache, err := NewStateCache(DefaultStateCacheConfig(), iavlStore.Version())
+	require.NoError(t, err)
+	appStore := NewStateCacheInvalidator(iavlStore, cache)
+
+	addr1 := &types.Address{ChainId: "default", Local: []byte{1}}
+	addr2 := &types.Address{ChainId: "default", Local: []byte{2}}
+	data1, err := proto.Marshal(addr1)
+	require.NoError(t, err)
+	appStore.Set([]byte("k1"), data1)
+	appStore.Set([]byte("k2"), data1)
+	_, version, err := appStore.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, version, cache.Height())
+
+	var addr types.Address
+	_, ok := cache.Get(version, []byte("k1"), &addr)
+	require.False(t, ok)
+
+	cache.Set(version, []byte("k1"), addr1, true)
+	cache.Set(version, []byte("k2"), addr1, true)
+	cache.Set(version, []byte("k3"), &addr, false)
+	exists, ok := cache.Get(version, []byte("k1"), &addr)
+	require.True(t, ok)
+	require.True(t, exists)
+	require.True(t, proto.Equal(addr1, &addr))
+	exists, ok = cache.Get(version, []byte("k3"), &addr)
+	require.True(t, ok)
+	require.False(t, exists)
+	// values can't be read from another height
+	_, ok = cache.Get(version-1, []byte("k1"), &addr)
+	require.False(t, ok)
+	// values of other types aren't returned
+	_, ok = cache.Get(version, []byte("k1"), &types.BigUInt{})
+	require.False(t, ok)
+
+	// modifying the cached value after it was added shouldn't affect the cache
+	addr1.Local = []byte{3}
+	exists, ok = cache.Get(version, []byte("k1"), &addr)
+	require.True(t, ok && exists)
+	require.Equal(t, []byte{1}, []byte(addr.Local))
+
+	// keys modified by the next block should be evicted, the rest should carry over
+	data2, err := proto.Marshal(addr2)
+	require.NoError(t, err)
+	appStore.Set([]byte("k1"), data2)
+	appStore.Delete([]byte("k3"))
+	// values from a stale height should be ignored
+	cache.Set(version-1, []byte("k4"), addr2, true)
+	_, version, err = appStore.SaveVersion()
+	require.NoError(t, err)
+	require.Equal(t, version, cache.Height())
+
+	_, ok = cache.Get(version, []byte("k1"), &addr)
+	require.False(t, ok)
+	_, ok = cache.Get(version, []byte("k3"), &addr)
+	require.False(t, ok)
+	_, ok = cache.Get(version, []byte("k4"), &addr)
+	require.False(t, ok)
+	exists, ok = cache.Get(version, []byte("k2"), &addr)
+	require.True(t, ok && exists)
+	require.Equal(t, []byte{1}, []byte(addr.Local))
+
+	// snapshots should know which version they were taken from
+	snap := appStore.GetSnapshot()
+	defer snap.Release()
+	require.Equal(t, version, snap.(VersionedSnapshot).Version())
+
+	// optional interfaces should only be implemented if the wrapped store implements them
+	_, ok = appStore.(ProvableStore)
+	require.True(t, ok)
+	_, ok = appStore.(EvmRootReader)
+	require.False(t, ok)
+	_, ok = appStore.(StateCacheProvider)
+	require.True(t, ok)
+}
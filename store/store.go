@@ -3,6 +3,8 @@ package store
 import (
 	"github.com/loomnetwork/go-loom/plugin"
 	"github.com/loomnetwork/go-loom/util"
+	"github.com/pkg/errors"
+	"github.com/tendermint/iavl"
 )
 
 // KVReader interface for reading data out of a store
@@ -54,6 +56,37 @@ type VersionedKVStore interface {
 	// Delete old version of the store
 	Prune() error
 	GetSnapshot() Snapshot
+	// GetSnapshotAt returns a read-only snapshot of a previously saved version of the store,
+	// ErrVersionPruned is returned if the version has been pruned, and ErrVersionNotFlushed if the
+	// version was never written to disk.
+	GetSnapshotAt(version int64) (Snapshot, error)
+}
+
+// ErrVersionPruned is returned when a version of a store that has already been pruned is requested.
+var ErrVersionPruned = errors.New("version has been pruned")
+
+// ErrVersionNotFlushed is returned when a version of a store that was only ever kept in memory,
+// and was never written to disk, is requested.
+var ErrVersionNotFlushed = errors.New("version hasn't been flushed to disk")
+
+// ErrProofsNotSupported is returned when a Merkle proof is requested from a store that's unable to
+// generate one.
+var ErrProofsNotSupported = errors.New("store doesn't support Merkle proofs")
+
+// ProvableStore is implemented by stores that can generate Merkle proofs of their contents.
+type ProvableStore interface {
+	// GetVersionedWithProof returns the value of the given key at the given version of the store,
+	// along with a proof of the existence (or absence) of the key. The proof can be verified against
+	// the store hash at that version.
+	GetVersionedWithProof(key []byte, version int64) ([]byte, *iavl.RangeProof, error)
+}
+
+// EvmRootReader is implemented by stores that keep track of the Patricia root of the EVM state at
+// each version.
+type EvmRootReader interface {
+	// GetEvmRootAt returns the Patricia root of the EVM state at the given version of the store,
+	// the root of an empty EVM state is returned as nil.
+	GetEvmRootAt(version int64) ([]byte, error)
 }
 
 type cacheItem struct {
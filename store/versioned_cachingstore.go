@@ -14,6 +14,7 @@ import (
 	loom "github.com/loomnetwork/go-loom"
 	"github.com/pkg/errors"
 	stdprometheus "github.com/prometheus/client_golang/prometheus"
+	"github.com/tendermint/iavl"
 )
 
 const separator = "|"
@@ -378,6 +379,28 @@ func (c *versionedCachingStore) GetSnapshot() Snapshot {
 	)
 }
 
+// GetSnapshotAt bypasses the cache, historical versions are read directly from the source store.
+func (c *versionedCachingStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	return c.VersionedKVStore.GetSnapshotAt(version)
+}
+
+// GetVersionedWithProof bypasses the cache since proofs can only be generated by the source store.
+func (c *versionedCachingStore) GetVersionedWithProof(
+	key []byte, version int64,
+) ([]byte, *iavl.RangeProof, error) {
+	if ps, ok := c.VersionedKVStore.(ProvableStore); ok {
+		return ps.GetVersionedWithProof(key, version)
+	}
+	return nil, nil, ErrProofsNotSupported
+}
+
+func (c *versionedCachingStore) GetEvmRootAt(version int64) ([]byte, error) {
+	if r, ok := c.VersionedKVStore.(EvmRootReader); ok {
+		return r.GetEvmRootAt(version)
+	}
+	return nil, errors.New("store doesn't keep track of EVM roots")
+}
+
 // CachingStoreSnapshot is a read-only CachingStore with specified version
 type versionedCachingStoreSnapshot struct {
 	Snapshot
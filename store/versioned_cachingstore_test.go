@@ -71,6 +71,10 @@ func (m *MockStore) GetSnapshot() Snapshot {
 	}
 }
 
+func (m *MockStore) GetSnapshotAt(version int64) (Snapshot, error) {
+	panic("not implemented")
+}
+
 type mockStoreSnapshot struct {
 	*MockStore
 }
@@ -13,6 +13,7 @@ import (
 	"github.com/loomnetwork/loomchain"
 	"github.com/loomnetwork/loomchain/auth"
 	"github.com/loomnetwork/loomchain/eth/utils"
+	"github.com/loomnetwork/loomchain/evm"
 	"github.com/loomnetwork/loomchain/features"
 	"github.com/loomnetwork/loomchain/registry/factory"
 	"github.com/loomnetwork/loomchain/vm"
@@ -66,6 +67,12 @@ func (h *EthTxHandler) ProcessTx(
 		return r, errors.New("tx value can't be negative")
 	}
 
+	if state.FeatureEnabled(features.EvmGasMeteringFeature, false) {
+		if err := checkTxGas(state, &ethTx, isCheckTx); err != nil {
+			return r, err
+		}
+	}
+
 	// Only do basic validation in CheckTx, don't execute the actual EVM deploy/call
 	if isCheckTx {
 		return r, nil
@@ -107,3 +114,32 @@ func (h *EthTxHandler) ProcessTx(
 	}
 	return r, nil
 }
+
+// checkTxGas checks that the gas limit specified in the tx is sufficient to cover the intrinsic gas
+// cost of the tx, and doesn't exceed the block gas limit. When the tx is executed the gas available
+// to the tx is limited to the amount specified in the tx, minus the intrinsic gas.
+func checkTxGas(state loomchain.State, ethTx *etypes.Transaction, isCheckTx bool) error {
+	blockGasLimit := state.OnChainSettings().EvmBlockGasLimit
+	if blockGasLimit > 0 && ethTx.Gas() > blockGasLimit {
+		return errors.Errorf("tx gas limit %d exceeds block gas limit %d", ethTx.Gas(), blockGasLimit)
+	}
+	intrinsicGas, err := evm.IntrinsicGas(state, ethTx.Data(), ethTx.To() == nil)
+	if err != nil {
+		return err
+	}
+	if ethTx.Gas() < intrinsicGas {
+		return errors.Errorf("intrinsic gas too low: have %d, want %d", ethTx.Gas(), intrinsicGas)
+	}
+
+	gasMeter := loomchain.GasMeterFromContext(state.Context())
+	if isCheckTx || gasMeter == nil {
+		return nil
+	}
+	if ethTx.Gas() > gasMeter.GasRemaining() {
+		return errors.Errorf(
+			"tx gas limit %d exceeds gas remaining in block %d", ethTx.Gas(), gasMeter.GasRemaining(),
+		)
+	}
+	gasMeter.SetLimit(ethTx.Gas())
+	return gasMeter.ConsumeGas(intrinsicGas)
+}